@@ -12,7 +12,6 @@ package v1
 
 import (
 	context "context"
-	empty "github.com/golang/protobuf/ptypes/empty"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
@@ -24,16 +23,30 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	CloudEventService_Publish_FullMethodName   = "/io.cloudevents.v1.CloudEventService/Publish"
-	CloudEventService_Subscribe_FullMethodName = "/io.cloudevents.v1.CloudEventService/Subscribe"
+	CloudEventService_Publish_FullMethodName        = "/io.cloudevents.v1.CloudEventService/Publish"
+	CloudEventService_PublishBatch_FullMethodName   = "/io.cloudevents.v1.CloudEventService/PublishBatch"
+	CloudEventService_Subscribe_FullMethodName      = "/io.cloudevents.v1.CloudEventService/Subscribe"
+	CloudEventService_SubscribeBatch_FullMethodName = "/io.cloudevents.v1.CloudEventService/SubscribeBatch"
+	CloudEventService_Unsubscribe_FullMethodName    = "/io.cloudevents.v1.CloudEventService/Unsubscribe"
+	CloudEventService_Resync_FullMethodName         = "/io.cloudevents.v1.CloudEventService/Resync"
+	CloudEventService_Acknowledge_FullMethodName    = "/io.cloudevents.v1.CloudEventService/Acknowledge"
+	CloudEventService_GetResource_FullMethodName    = "/io.cloudevents.v1.CloudEventService/GetResource"
+	CloudEventService_ListResources_FullMethodName  = "/io.cloudevents.v1.CloudEventService/ListResources"
 )
 
 // CloudEventServiceClient is the client API for CloudEventService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type CloudEventServiceClient interface {
-	Publish(ctx context.Context, in *PublishRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	Publish(ctx context.Context, in *PublishRequest, opts ...grpc.CallOption) (*PublishResponse, error)
+	PublishBatch(ctx context.Context, opts ...grpc.CallOption) (CloudEventService_PublishBatchClient, error)
 	Subscribe(ctx context.Context, in *SubscriptionRequest, opts ...grpc.CallOption) (CloudEventService_SubscribeClient, error)
+	SubscribeBatch(ctx context.Context, in *SubscriptionRequest, opts ...grpc.CallOption) (CloudEventService_SubscribeBatchClient, error)
+	Unsubscribe(ctx context.Context, in *UnsubscribeRequest, opts ...grpc.CallOption) (*UnsubscribeResponse, error)
+	Resync(ctx context.Context, in *ResyncRequest, opts ...grpc.CallOption) (*ResyncResponse, error)
+	Acknowledge(ctx context.Context, in *AcknowledgeRequest, opts ...grpc.CallOption) (*AcknowledgeResponse, error)
+	GetResource(ctx context.Context, in *GetResourceRequest, opts ...grpc.CallOption) (*GetResourceResponse, error)
+	ListResources(ctx context.Context, in *ListResourcesRequest, opts ...grpc.CallOption) (*ListResourcesResponse, error)
 }
 
 type cloudEventServiceClient struct {
@@ -44,8 +57,8 @@ func NewCloudEventServiceClient(cc grpc.ClientConnInterface) CloudEventServiceCl
 	return &cloudEventServiceClient{cc}
 }
 
-func (c *cloudEventServiceClient) Publish(ctx context.Context, in *PublishRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
-	out := new(empty.Empty)
+func (c *cloudEventServiceClient) Publish(ctx context.Context, in *PublishRequest, opts ...grpc.CallOption) (*PublishResponse, error) {
+	out := new(PublishResponse)
 	err := c.cc.Invoke(ctx, CloudEventService_Publish_FullMethodName, in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -53,8 +66,42 @@ func (c *cloudEventServiceClient) Publish(ctx context.Context, in *PublishReques
 	return out, nil
 }
 
+func (c *cloudEventServiceClient) PublishBatch(ctx context.Context, opts ...grpc.CallOption) (CloudEventService_PublishBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CloudEventService_ServiceDesc.Streams[0], CloudEventService_PublishBatch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cloudEventServicePublishBatchClient{stream}
+	return x, nil
+}
+
+type CloudEventService_PublishBatchClient interface {
+	Send(*PublishBatchRequest) error
+	CloseAndRecv() (*PublishBatchResponse, error)
+	grpc.ClientStream
+}
+
+type cloudEventServicePublishBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *cloudEventServicePublishBatchClient) Send(m *PublishBatchRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *cloudEventServicePublishBatchClient) CloseAndRecv() (*PublishBatchResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(PublishBatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *cloudEventServiceClient) Subscribe(ctx context.Context, in *SubscriptionRequest, opts ...grpc.CallOption) (CloudEventService_SubscribeClient, error) {
-	stream, err := c.cc.NewStream(ctx, &CloudEventService_ServiceDesc.Streams[0], CloudEventService_Subscribe_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &CloudEventService_ServiceDesc.Streams[1], CloudEventService_Subscribe_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -85,12 +132,96 @@ func (x *cloudEventServiceSubscribeClient) Recv() (*CloudEvent, error) {
 	return m, nil
 }
 
+func (c *cloudEventServiceClient) SubscribeBatch(ctx context.Context, in *SubscriptionRequest, opts ...grpc.CallOption) (CloudEventService_SubscribeBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CloudEventService_ServiceDesc.Streams[2], CloudEventService_SubscribeBatch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cloudEventServiceSubscribeBatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CloudEventService_SubscribeBatchClient interface {
+	Recv() (*CloudEventBatch, error)
+	grpc.ClientStream
+}
+
+type cloudEventServiceSubscribeBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *cloudEventServiceSubscribeBatchClient) Recv() (*CloudEventBatch, error) {
+	m := new(CloudEventBatch)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *cloudEventServiceClient) Unsubscribe(ctx context.Context, in *UnsubscribeRequest, opts ...grpc.CallOption) (*UnsubscribeResponse, error) {
+	out := new(UnsubscribeResponse)
+	err := c.cc.Invoke(ctx, CloudEventService_Unsubscribe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cloudEventServiceClient) Resync(ctx context.Context, in *ResyncRequest, opts ...grpc.CallOption) (*ResyncResponse, error) {
+	out := new(ResyncResponse)
+	err := c.cc.Invoke(ctx, CloudEventService_Resync_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cloudEventServiceClient) Acknowledge(ctx context.Context, in *AcknowledgeRequest, opts ...grpc.CallOption) (*AcknowledgeResponse, error) {
+	out := new(AcknowledgeResponse)
+	err := c.cc.Invoke(ctx, CloudEventService_Acknowledge_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cloudEventServiceClient) GetResource(ctx context.Context, in *GetResourceRequest, opts ...grpc.CallOption) (*GetResourceResponse, error) {
+	out := new(GetResourceResponse)
+	err := c.cc.Invoke(ctx, CloudEventService_GetResource_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cloudEventServiceClient) ListResources(ctx context.Context, in *ListResourcesRequest, opts ...grpc.CallOption) (*ListResourcesResponse, error) {
+	out := new(ListResourcesResponse)
+	err := c.cc.Invoke(ctx, CloudEventService_ListResources_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CloudEventServiceServer is the server API for CloudEventService service.
 // All implementations must embed UnimplementedCloudEventServiceServer
 // for forward compatibility
 type CloudEventServiceServer interface {
-	Publish(context.Context, *PublishRequest) (*empty.Empty, error)
+	Publish(context.Context, *PublishRequest) (*PublishResponse, error)
+	PublishBatch(CloudEventService_PublishBatchServer) error
 	Subscribe(*SubscriptionRequest, CloudEventService_SubscribeServer) error
+	SubscribeBatch(*SubscriptionRequest, CloudEventService_SubscribeBatchServer) error
+	Unsubscribe(context.Context, *UnsubscribeRequest) (*UnsubscribeResponse, error)
+	Resync(context.Context, *ResyncRequest) (*ResyncResponse, error)
+	Acknowledge(context.Context, *AcknowledgeRequest) (*AcknowledgeResponse, error)
+	GetResource(context.Context, *GetResourceRequest) (*GetResourceResponse, error)
+	ListResources(context.Context, *ListResourcesRequest) (*ListResourcesResponse, error)
 	mustEmbedUnimplementedCloudEventServiceServer()
 }
 
@@ -98,12 +229,33 @@ type CloudEventServiceServer interface {
 type UnimplementedCloudEventServiceServer struct {
 }
 
-func (UnimplementedCloudEventServiceServer) Publish(context.Context, *PublishRequest) (*empty.Empty, error) {
+func (UnimplementedCloudEventServiceServer) Publish(context.Context, *PublishRequest) (*PublishResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Publish not implemented")
 }
+func (UnimplementedCloudEventServiceServer) PublishBatch(CloudEventService_PublishBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method PublishBatch not implemented")
+}
 func (UnimplementedCloudEventServiceServer) Subscribe(*SubscriptionRequest, CloudEventService_SubscribeServer) error {
 	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
 }
+func (UnimplementedCloudEventServiceServer) SubscribeBatch(*SubscriptionRequest, CloudEventService_SubscribeBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeBatch not implemented")
+}
+func (UnimplementedCloudEventServiceServer) Unsubscribe(context.Context, *UnsubscribeRequest) (*UnsubscribeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unsubscribe not implemented")
+}
+func (UnimplementedCloudEventServiceServer) Resync(context.Context, *ResyncRequest) (*ResyncResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resync not implemented")
+}
+func (UnimplementedCloudEventServiceServer) Acknowledge(context.Context, *AcknowledgeRequest) (*AcknowledgeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Acknowledge not implemented")
+}
+func (UnimplementedCloudEventServiceServer) GetResource(context.Context, *GetResourceRequest) (*GetResourceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetResource not implemented")
+}
+func (UnimplementedCloudEventServiceServer) ListResources(context.Context, *ListResourcesRequest) (*ListResourcesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListResources not implemented")
+}
 func (UnimplementedCloudEventServiceServer) mustEmbedUnimplementedCloudEventServiceServer() {}
 
 // UnsafeCloudEventServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -135,6 +287,32 @@ func _CloudEventService_Publish_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CloudEventService_PublishBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CloudEventServiceServer).PublishBatch(&cloudEventServicePublishBatchServer{stream})
+}
+
+type CloudEventService_PublishBatchServer interface {
+	SendAndClose(*PublishBatchResponse) error
+	Recv() (*PublishBatchRequest, error)
+	grpc.ServerStream
+}
+
+type cloudEventServicePublishBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *cloudEventServicePublishBatchServer) SendAndClose(m *PublishBatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *cloudEventServicePublishBatchServer) Recv() (*PublishBatchRequest, error) {
+	m := new(PublishBatchRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _CloudEventService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(SubscriptionRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -156,6 +334,117 @@ func (x *cloudEventServiceSubscribeServer) Send(m *CloudEvent) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _CloudEventService_SubscribeBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscriptionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CloudEventServiceServer).SubscribeBatch(m, &cloudEventServiceSubscribeBatchServer{stream})
+}
+
+type CloudEventService_SubscribeBatchServer interface {
+	Send(*CloudEventBatch) error
+	grpc.ServerStream
+}
+
+type cloudEventServiceSubscribeBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *cloudEventServiceSubscribeBatchServer) Send(m *CloudEventBatch) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CloudEventService_Unsubscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnsubscribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CloudEventServiceServer).Unsubscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CloudEventService_Unsubscribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CloudEventServiceServer).Unsubscribe(ctx, req.(*UnsubscribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CloudEventService_Resync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CloudEventServiceServer).Resync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CloudEventService_Resync_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CloudEventServiceServer).Resync(ctx, req.(*ResyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CloudEventService_Acknowledge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcknowledgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CloudEventServiceServer).Acknowledge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CloudEventService_Acknowledge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CloudEventServiceServer).Acknowledge(ctx, req.(*AcknowledgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CloudEventService_GetResource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetResourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CloudEventServiceServer).GetResource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CloudEventService_GetResource_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CloudEventServiceServer).GetResource(ctx, req.(*GetResourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CloudEventService_ListResources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListResourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CloudEventServiceServer).ListResources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CloudEventService_ListResources_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CloudEventServiceServer).ListResources(ctx, req.(*ListResourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CloudEventService_ServiceDesc is the grpc.ServiceDesc for CloudEventService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -167,13 +456,43 @@ var CloudEventService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Publish",
 			Handler:    _CloudEventService_Publish_Handler,
 		},
+		{
+			MethodName: "Unsubscribe",
+			Handler:    _CloudEventService_Unsubscribe_Handler,
+		},
+		{
+			MethodName: "Resync",
+			Handler:    _CloudEventService_Resync_Handler,
+		},
+		{
+			MethodName: "Acknowledge",
+			Handler:    _CloudEventService_Acknowledge_Handler,
+		},
+		{
+			MethodName: "GetResource",
+			Handler:    _CloudEventService_GetResource_Handler,
+		},
+		{
+			MethodName: "ListResources",
+			Handler:    _CloudEventService_ListResources_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PublishBatch",
+			Handler:       _CloudEventService_PublishBatch_Handler,
+			ClientStreams: true,
+		},
 		{
 			StreamName:    "Subscribe",
 			Handler:       _CloudEventService_Subscribe_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "SubscribeBatch",
+			Handler:       _CloudEventService_SubscribeBatch_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "cloudevent.proto",
 }
@@ -2,6 +2,9 @@ package protocol
 
 import (
 	"fmt"
+	"time"
+
+	"k8s.io/utils/clock"
 )
 
 // Option is the function signature
@@ -10,6 +13,9 @@ type Option func(*Protocol) error
 // SubscribeOption
 type SubscribeOption struct {
 	Source string
+	// ClusterName scopes the subscription to the events of a cluster, the events of all clusters are subscribed if
+	// it is empty.
+	ClusterName string
 }
 
 // WithSubscribeOption sets the Subscribe configuration for the client.
@@ -22,3 +28,37 @@ func WithSubscribeOption(subscribeOpt *SubscribeOption) Option {
 		return nil
 	}
 }
+
+// ReconnectOption configures how the subscription is re-established after the subscribe stream is broken by a
+// transient error, the interval between the retries is doubled with jitter from the InitialInterval until it reaches
+// the MaxInterval.
+type ReconnectOption struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+// WithReconnectOption enables the client to re-establish the subscription when the subscribe stream is broken.
+func WithReconnectOption(reconnectOpt *ReconnectOption) Option {
+	return func(p *Protocol) error {
+		if reconnectOpt == nil {
+			return fmt.Errorf("the reconnect option must not be nil")
+		}
+		if reconnectOpt.InitialInterval <= 0 || reconnectOpt.MaxInterval < reconnectOpt.InitialInterval {
+			return fmt.Errorf("the reconnect intervals are invalid, initial %v, max %v",
+				reconnectOpt.InitialInterval, reconnectOpt.MaxInterval)
+		}
+		p.reconnectOption = reconnectOpt
+		return nil
+	}
+}
+
+// WithClock sets the clock that the retries of the reconnects wait with, the default is the real clock.
+func WithClock(clock clock.Clock) Option {
+	return func(p *Protocol) error {
+		if clock == nil {
+			return fmt.Errorf("the clock must not be nil")
+		}
+		p.clock = clock
+		return nil
+	}
+}
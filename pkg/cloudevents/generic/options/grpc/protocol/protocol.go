@@ -2,30 +2,75 @@ package protocol
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"sync"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/clock"
 
 	"github.com/cloudevents/sdk-go/v2/binding"
 	cecontext "github.com/cloudevents/sdk-go/v2/context"
 	"github.com/cloudevents/sdk-go/v2/protocol"
+	cetypes "github.com/cloudevents/sdk-go/v2/types"
 
 	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
 )
 
+// ConnectionState is the state of the subscription of the protocol.
+type ConnectionState string
+
+const (
+	// Disconnected means the subscription is not established or it is closed.
+	Disconnected ConnectionState = "Disconnected"
+
+	// Connected means the subscription is established.
+	Connected ConnectionState = "Connected"
+
+	// Reconnecting means the subscribe stream is broken and the subscription is being re-established.
+	Reconnecting ConnectionState = "Reconnecting"
+)
+
+// extensionResumeToken is the extension of the events that carries their resume tokens, it is set by the servers
+// that can resume a subscription after an event.
+const extensionResumeToken = "resumetoken"
+
 // protocol for grpc
 // define protocol for grpc
 
 type Protocol struct {
 	client          pbv1.CloudEventServiceClient
 	subscribeOption *SubscribeOption
+	reconnectOption *ReconnectOption
 	// receiver
 	incoming chan *pbv1.CloudEvent
 	// inOpen
 	openerMutex sync.Mutex
 
+	stateMutex sync.RWMutex
+	state      ConnectionState
+
+	// clock is the clock that the retries of the reconnects wait with.
+	clock clock.Clock
+
+	// resumeToken is the resume token of the last received event, the subscription is resumed after it once the
+	// stream is broken. It is empty if the server does not send the resume tokens.
+	resumeToken string
+	// resourceVersions are the versions of the resources that are received in the current subscription, a resource is
+	// removed once its delete event is received, so it can be created again from a lower version. They are moved to
+	// the replayedVersions once the subscription is re-established, so they are only kept for one subscription.
+	resourceVersions map[string]int64
+	// replayedVersions are the versions of the resources that were received before the subscription is
+	// re-established, the events that the server replays with lower versions are skipped. A resource is removed once
+	// its event is not stale, the later events of it are not replayed.
+	replayedVersions map[string]int64
+
 	closeChan chan struct{}
 }
 
@@ -46,8 +91,12 @@ func NewProtocol(clientConn grpc.ClientConnInterface, opts ...Option) (*Protocol
 	p := &Protocol{
 		client: pbv1.NewCloudEventServiceClient(clientConn),
 		// subClient:
-		incoming:  make(chan *pbv1.CloudEvent),
-		closeChan: make(chan struct{}),
+		incoming:         make(chan *pbv1.CloudEvent),
+		state:            Disconnected,
+		clock:            clock.RealClock{},
+		resourceVersions: make(map[string]int64),
+		replayedVersions: make(map[string]int64),
+		closeChan:        make(chan struct{}),
 	}
 
 	if err := p.applyOptions(opts...); err != nil {
@@ -101,24 +150,17 @@ func (p *Protocol) OpenInbound(ctx context.Context) error {
 	p.openerMutex.Lock()
 	defer p.openerMutex.Unlock()
 
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	logger := cecontext.LoggerFrom(ctx)
-	subClient, err := p.client.Subscribe(ctx, &pbv1.SubscriptionRequest{
-		Source: p.subscribeOption.Source,
-	})
+	subClient, err := p.subscribe(subCtx, "")
 	if err != nil {
 		return err
 	}
 
 	logger.Infof("subscribing events for: %v", p.subscribeOption.Source)
-	go func() {
-		for {
-			msg, err := subClient.Recv()
-			if err != nil {
-				return
-			}
-			p.incoming <- msg
-		}
-	}()
+	go p.receive(subCtx, subClient)
 
 	// Wait until external or internal context done
 	select {
@@ -129,6 +171,220 @@ func (p *Protocol) OpenInbound(ctx context.Context) error {
 	return nil
 }
 
+// ConnectionState returns the current state of the subscription.
+func (p *Protocol) ConnectionState() ConnectionState {
+	p.stateMutex.RLock()
+	defer p.stateMutex.RUnlock()
+
+	return p.state
+}
+
+func (p *Protocol) setConnectionState(state ConnectionState) {
+	p.stateMutex.Lock()
+	defer p.stateMutex.Unlock()
+
+	p.state = state
+}
+
+func (p *Protocol) subscribe(ctx context.Context, resumeToken string) (pbv1.CloudEventService_SubscribeClient, error) {
+	subClient, err := p.client.Subscribe(ctx, &pbv1.SubscriptionRequest{
+		Source:      p.subscribeOption.Source,
+		ClusterName: p.subscribeOption.ClusterName,
+		ResumeToken: resumeToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.setConnectionState(Connected)
+	return subClient, nil
+}
+
+// receive receives the events from the subscribe stream until the context is done. If the reconnect option is
+// set, the subscription is re-established with a jittered exponential backoff once the stream is broken by a transient
+// error, it is resumed after the last received event if the server sends the resume tokens. The subscription is not
+// re-established if the stream is closed by the server or broken by a permanent error.
+func (p *Protocol) receive(ctx context.Context, subClient pbv1.CloudEventService_SubscribeClient) {
+	defer p.setConnectionState(Disconnected)
+
+	logger := cecontext.LoggerFrom(ctx)
+	var backoff *wait.Backoff
+	for {
+		msg, err := subClient.Recv()
+		if err == nil {
+			// the stream works, restart the backoff for the next broken stream
+			backoff = nil
+
+			if p.reconnectOption != nil {
+				if p.isStale(msg) {
+					continue
+				}
+				p.record(msg)
+			}
+
+			select {
+			case p.incoming <- msg:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		if ctx.Err() != nil || p.reconnectOption == nil {
+			return
+		}
+
+		switch {
+		case errors.Is(err, io.EOF):
+			logger.Infof("the subscribe stream for %v is closed by the server", p.subscribeOption.Source)
+			return
+		case status.Code(err) == codes.OutOfRange && len(p.resumeToken) != 0:
+			// the server no longer keeps the events after the resume token, resync the resources without it
+			logger.Warnf("the subscription for %v cannot be resumed, resyncing: %v", p.subscribeOption.Source, err)
+			p.resumeToken = ""
+		case !isTransient(err):
+			logger.Errorf("the subscribe stream for %v is broken: %v", p.subscribeOption.Source, err)
+			return
+		}
+
+		logger.Warnf("the subscribe stream for %v is broken, reconnecting: %v", p.subscribeOption.Source, err)
+		p.setConnectionState(Reconnecting)
+		if backoff == nil {
+			backoff = &wait.Backoff{
+				Duration: p.reconnectOption.InitialInterval,
+				Cap:      p.reconnectOption.MaxInterval,
+				Steps:    math.MaxInt32,
+				Factor:   2.0,
+				Jitter:   0.5,
+			}
+		}
+
+		for {
+			// the jitter may exceed the cap of the backoff, make sure the interval does not exceed the max interval
+			interval := backoff.Step()
+			if interval > p.reconnectOption.MaxInterval {
+				interval = p.reconnectOption.MaxInterval
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.clock.After(interval):
+			}
+
+			subClient, err = p.subscribe(ctx, p.resumeToken)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil || !isTransient(err) {
+				logger.Errorf("failed to re-establish the subscription for %v: %v", p.subscribeOption.Source, err)
+				return
+			}
+			logger.Warnf("failed to re-establish the subscription for %v: %v", p.subscribeOption.Source, err)
+		}
+
+		// the server replays the events after the resume token, or the current state of the resources without it. The
+		// replayed versions of the resources that are not replayed yet are kept, e.g. the stream is broken again before
+		// they are replayed.
+		for id, version := range p.resourceVersions {
+			p.replayedVersions[id] = version
+		}
+		p.resourceVersions = make(map[string]int64)
+		logger.Infof("the subscription for %v is re-established", p.subscribeOption.Source)
+	}
+}
+
+// isTransient returns true if the subscribe stream is broken by an error that the subscription can be re-established
+// after, e.g. the server is unavailable or it has too many subscribers.
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// isStale returns true if the event is replayed by the server after the subscription is re-established and its
+// version is lower than the one that was received before, so the client does not go back to it.
+func (p *Protocol) isStale(msg *pbv1.CloudEvent) bool {
+	id, version, ok := resourceVersionOf(msg)
+	if !ok {
+		return false
+	}
+
+	last, ok := p.replayedVersions[id]
+	if !ok {
+		return false
+	}
+	if version < last {
+		return true
+	}
+
+	delete(p.replayedVersions, id)
+	return false
+}
+
+// record records the resume token of the received event and the version of its resource, the resource is forgotten
+// once it is deleted.
+func (p *Protocol) record(msg *pbv1.CloudEvent) {
+	if value, ok := attributeOf(msg, extensionResumeToken); ok {
+		if token, err := cetypes.ToString(value); err == nil {
+			p.resumeToken = token
+		}
+	}
+
+	id, version, ok := resourceVersionOf(msg)
+	if !ok {
+		return
+	}
+
+	if _, deleted := msg.Attributes[prefix+types.ExtensionDeletionTimestamp]; deleted {
+		delete(p.resourceVersions, id)
+		delete(p.replayedVersions, id)
+		return
+	}
+
+	p.resourceVersions[id] = version
+}
+
+// resourceVersionOf returns the resource id and the resource version of the event.
+func resourceVersionOf(msg *pbv1.CloudEvent) (string, int64, bool) {
+	resourceID, ok := attributeOf(msg, types.ExtensionResourceID)
+	if !ok {
+		return "", 0, false
+	}
+	resourceVersion, ok := attributeOf(msg, types.ExtensionResourceVersion)
+	if !ok {
+		return "", 0, false
+	}
+
+	id, err := cetypes.ToString(resourceID)
+	if err != nil {
+		return "", 0, false
+	}
+	version, err := cetypes.ToInteger(resourceVersion)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return id, int64(version), true
+}
+
+// attributeOf returns the value of the extension attribute of the event.
+func attributeOf(msg *pbv1.CloudEvent, extension string) (interface{}, bool) {
+	attr, ok := msg.Attributes[prefix+extension]
+	if !ok {
+		return nil, false
+	}
+
+	value, err := valueFrom(attr)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
 // Receive implements Receiver.Receive
 func (p *Protocol) Receive(ctx context.Context) (binding.Message, error) {
 	select {
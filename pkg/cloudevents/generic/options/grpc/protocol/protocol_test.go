@@ -0,0 +1,313 @@
+package protocol
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cetypes "github.com/cloudevents/sdk-go/v2/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/wait"
+	testingclock "k8s.io/utils/clock/testing"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+type resourceEvent struct {
+	resourceID      string
+	resourceVersion int64
+	deleted         bool
+	// resumeToken is the resume token of the event, it is not set if it is empty.
+	resumeToken string
+}
+
+// subscribeCall is a subscription to the brokenStreamServer, the events are sent on it and then the stream is
+// finished with the err, or closed by the server if closed is true. The stream is kept until the client cancels it if
+// neither is set.
+type subscribeCall struct {
+	events []resourceEvent
+	err    error
+	closed bool
+}
+
+// brokenStreamServer sends the events of a call on each subscription and records the resume tokens of the
+// subscriptions.
+type brokenStreamServer struct {
+	pbv1.UnimplementedCloudEventServiceServer
+
+	mu           sync.Mutex
+	calls        []subscribeCall
+	resumeTokens []string
+}
+
+func (s *brokenStreamServer) Subscribe(req *pbv1.SubscriptionRequest, stream pbv1.CloudEventService_SubscribeServer) error {
+	s.mu.Lock()
+	call := s.calls[len(s.resumeTokens)]
+	s.resumeTokens = append(s.resumeTokens, req.ResumeToken)
+	s.mu.Unlock()
+
+	for _, e := range call.events {
+		builder := types.NewEventBuilder("test-source", types.CloudEventsType{
+			CloudEventsDataType: types.CloudEventsDataType{Group: "test", Version: "v1", Resource: "tests"},
+			SubResource:         types.SubResourceStatus,
+			Action:              "status_update",
+		}).WithResourceID(e.resourceID).WithResourceVersion(e.resourceVersion)
+		if e.deleted {
+			builder.WithDeletionTimestamp(time.Now())
+		}
+		evt := builder.NewEvent()
+		if len(e.resumeToken) != 0 {
+			evt.SetExtension(extensionResumeToken, e.resumeToken)
+		}
+
+		pbEvt := &pbv1.CloudEvent{}
+		if err := WritePBMessage(context.TODO(), binding.ToMessage(&evt), pbEvt); err != nil {
+			return err
+		}
+		if err := stream.Send(pbEvt); err != nil {
+			return err
+		}
+	}
+
+	if call.err != nil || call.closed {
+		return call.err
+	}
+
+	<-stream.Context().Done()
+	return nil
+}
+
+func (s *brokenStreamServer) subscriptions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.resumeTokens...)
+}
+
+func TestReconnect(t *testing.T) {
+	killed := status.Error(codes.Unavailable, "the stream is killed")
+
+	cases := []struct {
+		name     string
+		calls    []subscribeCall
+		expected []resourceEvent
+		// expectedResumeTokens are the resume tokens of the subscriptions.
+		expectedResumeTokens []string
+		expectedState        ConnectionState
+	}{
+		{
+			name: "stale event is replayed",
+			calls: []subscribeCall{
+				{events: []resourceEvent{{resourceID: "resource1", resourceVersion: 2}}, err: killed},
+				// the server replays the current state after the subscription is re-established
+				{events: []resourceEvent{{resourceID: "resource1", resourceVersion: 1},
+					{resourceID: "resource2", resourceVersion: 1}}},
+			},
+			// the stale resource1 is skipped after the subscription is re-established
+			expected: []resourceEvent{
+				{resourceID: "resource1", resourceVersion: 2},
+				{resourceID: "resource2", resourceVersion: 1},
+			},
+			expectedResumeTokens: []string{"", ""},
+			expectedState:        Connected,
+		},
+		{
+			name: "resource is deleted and created again",
+			calls: []subscribeCall{
+				{events: []resourceEvent{{resourceID: "resource1", resourceVersion: 2},
+					{resourceID: "resource1", resourceVersion: 3, deleted: true}}, err: killed},
+				// the resource is created again from the first version
+				{events: []resourceEvent{{resourceID: "resource1", resourceVersion: 1}}},
+			},
+			expected: []resourceEvent{
+				{resourceID: "resource1", resourceVersion: 2},
+				{resourceID: "resource1", resourceVersion: 3, deleted: true},
+				{resourceID: "resource1", resourceVersion: 1},
+			},
+			expectedResumeTokens: []string{"", ""},
+			expectedState:        Connected,
+		},
+		{
+			name: "resumed after the last received event",
+			calls: []subscribeCall{
+				{events: []resourceEvent{{resourceID: "resource1", resourceVersion: 1, resumeToken: "1"},
+					{resourceID: "resource2", resourceVersion: 1, resumeToken: "2"}}, err: killed},
+				{events: []resourceEvent{{resourceID: "resource1", resourceVersion: 2, resumeToken: "3"}},
+					err: status.Error(codes.ResourceExhausted, "too many subscribers")},
+				{events: []resourceEvent{{resourceID: "resource2", resourceVersion: 2, resumeToken: "4"}}},
+			},
+			expected: []resourceEvent{
+				{resourceID: "resource1", resourceVersion: 1, resumeToken: "1"},
+				{resourceID: "resource2", resourceVersion: 1, resumeToken: "2"},
+				{resourceID: "resource1", resourceVersion: 2, resumeToken: "3"},
+				{resourceID: "resource2", resourceVersion: 2, resumeToken: "4"},
+			},
+			expectedResumeTokens: []string{"", "2", "3"},
+			expectedState:        Connected,
+		},
+		{
+			name: "resume token is expired",
+			calls: []subscribeCall{
+				{events: []resourceEvent{{resourceID: "resource1", resourceVersion: 2, resumeToken: "1"}}, err: killed},
+				{err: status.Error(codes.OutOfRange, "the events after the resume token are not kept")},
+				// the resources are resynced without the resume token
+				{events: []resourceEvent{{resourceID: "resource1", resourceVersion: 1, resumeToken: "2"},
+					{resourceID: "resource2", resourceVersion: 1, resumeToken: "3"}}},
+			},
+			expected: []resourceEvent{
+				{resourceID: "resource1", resourceVersion: 2, resumeToken: "1"},
+				{resourceID: "resource2", resourceVersion: 1, resumeToken: "3"},
+			},
+			expectedResumeTokens: []string{"", "1", ""},
+			expectedState:        Connected,
+		},
+		{
+			name: "stream is closed by the server",
+			calls: []subscribeCall{
+				{events: []resourceEvent{{resourceID: "resource1", resourceVersion: 1}}, closed: true},
+			},
+			expected:             []resourceEvent{{resourceID: "resource1", resourceVersion: 1}},
+			expectedResumeTokens: []string{""},
+			expectedState:        Disconnected,
+		},
+		{
+			name: "stream is broken by a permanent error",
+			calls: []subscribeCall{
+				{events: []resourceEvent{{resourceID: "resource1", resourceVersion: 1}},
+					err: status.Error(codes.Unauthenticated, "the token is expired")},
+			},
+			expected:             []resourceEvent{{resourceID: "resource1", resourceVersion: 1}},
+			expectedResumeTokens: []string{""},
+			expectedState:        Disconnected,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			svr := &brokenStreamServer{calls: c.calls}
+
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			grpcServer := grpc.NewServer()
+			pbv1.RegisterCloudEventServiceServer(grpcServer, svr)
+			go func() {
+				_ = grpcServer.Serve(lis)
+			}()
+			defer grpcServer.Stop()
+
+			conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			fakeClock := testingclock.NewFakeClock(time.Now())
+			p, err := NewProtocol(conn,
+				WithSubscribeOption(&SubscribeOption{Source: "test-source"}),
+				WithReconnectOption(&ReconnectOption{InitialInterval: time.Second, MaxInterval: time.Minute}),
+				WithClock(fakeClock),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				if err := p.OpenInbound(ctx); err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+			}()
+
+			// the retries are not waited for, the clock is stepped once a retry waits for it
+			go func() {
+				_ = wait.PollUntilContextCancel(ctx, time.Millisecond, true, func(ctx context.Context) (bool, error) {
+					if fakeClock.HasWaiters() {
+						fakeClock.Step(time.Minute)
+					}
+					return false, nil
+				})
+			}()
+
+			for _, e := range c.expected {
+				receiveCtx, receiveCancel := context.WithTimeout(ctx, 5*time.Second)
+				msg, err := p.Receive(receiveCtx)
+				receiveCancel()
+				if err != nil {
+					t.Fatalf("failed to receive event %v, %v", e, err)
+				}
+
+				evt, err := binding.ToEvent(ctx, msg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resourceID, err := cetypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+				if err != nil {
+					t.Fatal(err)
+				}
+				resourceVersion, err := cetypes.ToInteger(evt.Extensions()[types.ExtensionResourceVersion])
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, deleted := evt.Extensions()[types.ExtensionDeletionTimestamp]
+				if resourceID != e.resourceID || int64(resourceVersion) != e.resourceVersion || deleted != e.deleted {
+					t.Errorf("expected event %v, but got %s/%d deleted %t", e, resourceID, resourceVersion, deleted)
+				}
+			}
+
+			err = wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true,
+				func(ctx context.Context) (bool, error) {
+					return p.ConnectionState() == c.expectedState, nil
+				})
+			if err != nil {
+				t.Errorf("expected %s state, but got %s", c.expectedState, p.ConnectionState())
+			}
+			if tokens := svr.subscriptions(); !reflect.DeepEqual(tokens, c.expectedResumeTokens) {
+				t.Errorf("expected the subscriptions with resume tokens %q, but got %q", c.expectedResumeTokens, tokens)
+			}
+		})
+	}
+}
+
+func TestWithReconnectOption(t *testing.T) {
+	cases := []struct {
+		name        string
+		option      *ReconnectOption
+		expectedErr bool
+	}{
+		{
+			name:   "valid option",
+			option: &ReconnectOption{InitialInterval: time.Second, MaxInterval: time.Minute},
+		},
+		{
+			name:        "nil option",
+			expectedErr: true,
+		},
+		{
+			name:        "max interval is less than initial interval",
+			option:      &ReconnectOption{InitialInterval: time.Minute, MaxInterval: time.Second},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := WithReconnectOption(c.option)(&Protocol{})
+			if c.expectedErr && err == nil {
+				t.Errorf("expected error, but failed")
+			}
+			if !c.expectedErr && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/binding/format"
+	"github.com/cloudevents/sdk-go/v2/binding/spec"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+)
+
+var specV1 = specs.Version(event.CloudEventsVersionV1)
+
+// ToEvent converts the protobuf message to an event, the event is the same as the one that is converted by
+// binding.ToEvent with NewMessage. The binary messages of the spec version 1.0, which are the messages that the
+// clients of this protocol send, are read into the event directly without the binding writers, so the attributes are
+// not boxed and the data is not wrapped for the writers. The other messages are converted by binding.ToEvent.
+func ToEvent(ctx context.Context, msg *pbv1.CloudEvent) (*event.Event, error) {
+	if !isBinaryV1(msg) {
+		return binding.ToEvent(ctx, NewMessage(msg))
+	}
+
+	e := event.New(event.CloudEventsVersionV1)
+	if msg.Id != "" {
+		if err := e.Context.SetID(msg.Id); err != nil {
+			return nil, err
+		}
+	}
+	if msg.Source != "" {
+		if err := e.Context.SetSource(msg.Source); err != nil {
+			return nil, err
+		}
+	}
+	if msg.Type != "" {
+		if err := e.Context.SetType(msg.Type); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, value := range msg.Attributes {
+		var attr spec.Attribute
+		if strings.HasPrefix(name, prefix) {
+			attr = specV1.Attribute(name)
+		} else if name == contenttype {
+			attr = specV1.AttributeFromKind(spec.DataContentType)
+		} else {
+			continue
+		}
+
+		attrVal, err := valueFrom(value)
+		if err == nil {
+			if attr != nil {
+				err = setAttribute(&e, attr, attrVal)
+			} else {
+				// the value is already validated by valueFrom
+				err = e.Context.SetExtension(strings.TrimPrefix(name, prefix), attrVal)
+			}
+		}
+		if err != nil {
+			if attr != nil {
+				return nil, &AttributeError{Name: attr.Name(), Err: err}
+			}
+			return nil, &AttributeError{Name: strings.TrimPrefix(name, prefix), Extension: true, Err: err}
+		}
+	}
+
+	if data := msg.GetBinaryData(); len(data) > 0 {
+		e.DataEncoded = data
+	}
+
+	return &e, nil
+}
+
+// AttributeError is returned by ToEvent when an attribute or an extension of a binary message of the spec version 1.0
+// cannot be read into the event, e.g. its value is not set, or it is of a type that the attribute does not accept.
+type AttributeError struct {
+	// Name is the name of the attribute or the extension in the event, it is not prefixed as in the message.
+	Name string
+	// Extension is true if the Name is an extension rather than an attribute of the spec.
+	Extension bool
+	Err       error
+}
+
+func (e *AttributeError) Error() string {
+	return fmt.Sprintf("failed to convert attribute %s: %v", e.Name, e.Err)
+}
+
+func (e *AttributeError) Unwrap() error {
+	return e.Err
+}
+
+// isBinaryV1 returns true if the message is read as a binary message of the spec version 1.0 by NewMessage.
+func isBinaryV1(msg *pbv1.CloudEvent) bool {
+	if msg.Attributes == nil || msg.SpecVersion != event.CloudEventsVersionV1 {
+		return false
+	}
+
+	contentType, ok := msg.Attributes[contenttype]
+	return !ok || !format.IsFormat(contentType.GetCeString())
+}
+
+// setAttribute sets the attribute of the event in the same way as the event writer of binding.ToEvent.
+func setAttribute(e *event.Event, attribute spec.Attribute, value interface{}) error {
+	if attribute.Kind() != spec.SpecVersion {
+		return attribute.Set(e.Context, value)
+	}
+
+	str, err := types.ToString(value)
+	if err != nil {
+		return err
+	}
+	switch str {
+	case event.CloudEventsVersionV03:
+		e.Context = e.Context.AsV03()
+	case event.CloudEventsVersionV1:
+		e.Context = e.Context.AsV1()
+	default:
+		return fmt.Errorf("unrecognized event version %s", str)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/event"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+)
+
+func TestToEvent(t *testing.T) {
+	newPBEvent := func(t *testing.T, mutate func(e *event.Event)) *pbv1.CloudEvent {
+		e := event.New()
+		e.SetID("ABC-123")
+		e.SetSource("test-source")
+		e.SetType("binary.test")
+		mutate(&e)
+
+		msg := &pbv1.CloudEvent{}
+		if err := WritePBMessage(context.Background(), binding.ToMessage(&e), msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	cases := []struct {
+		name string
+		msg  func(t *testing.T) *pbv1.CloudEvent
+		// expectedAttrErr is the error of the attribute that cannot be converted
+		expectedAttrErr *AttributeError
+	}{
+		{
+			name: "binary with data and extensions",
+			msg: func(t *testing.T) *pbv1.CloudEvent {
+				return newPBEvent(t, func(e *event.Event) {
+					e.SetSubject("test-subject")
+					e.SetTime(time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC))
+					e.SetExtension("resourceid", "resource1")
+					e.SetExtension("resourceversion", 2)
+					e.SetExtension("deleted", true)
+					if err := e.SetData(event.ApplicationJSON, map[string]string{"hello": "world"}); err != nil {
+						t.Fatal(err)
+					}
+				})
+			},
+		},
+		{
+			name: "binary without data",
+			msg: func(t *testing.T) *pbv1.CloudEvent {
+				return newPBEvent(t, func(e *event.Event) {})
+			},
+		},
+		{
+			name: "binary with empty id",
+			msg: func(t *testing.T) *pbv1.CloudEvent {
+				msg := newPBEvent(t, func(e *event.Event) {})
+				msg.Id = " "
+				return msg
+			},
+		},
+		{
+			name: "binary with unsupported attribute",
+			msg: func(t *testing.T) *pbv1.CloudEvent {
+				msg := newPBEvent(t, func(e *event.Event) {})
+				msg.Attributes["ce-unsupported"] = &pbv1.CloudEventAttributeValue{}
+				return msg
+			},
+			expectedAttrErr: &AttributeError{Name: "unsupported", Extension: true},
+		},
+		{
+			name: "structured",
+			msg: func(t *testing.T) *pbv1.CloudEvent {
+				e := event.New()
+				e.SetID("ABC-123")
+				e.SetSource("test-source")
+				e.SetType("structured.test")
+				msg := &pbv1.CloudEvent{}
+				if err := WritePBMessage(binding.WithForceStructured(context.Background()), binding.ToMessage(&e),
+					msg); err != nil {
+					t.Fatal(err)
+				}
+				return msg
+			},
+		},
+		{
+			name: "unknown spec version",
+			msg: func(t *testing.T) *pbv1.CloudEvent {
+				msg := newPBEvent(t, func(e *event.Event) {})
+				msg.SpecVersion = "2.0"
+				return msg
+			},
+		},
+		{
+			name: "attribute of wrong type",
+			msg: func(t *testing.T) *pbv1.CloudEvent {
+				msg := newPBEvent(t, func(e *event.Event) {})
+				msg.Attributes["ce-time"] = &pbv1.CloudEventAttributeValue{
+					Attr: &pbv1.CloudEventAttributeValue_CeBoolean{CeBoolean: true},
+				}
+				return msg
+			},
+			expectedAttrErr: &AttributeError{Name: "time"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msg := c.msg(t)
+
+			// the event is the same as the one that is converted with the binding writers
+			expected, expectedErr := binding.ToEvent(context.Background(), NewMessage(msg))
+			actual, err := ToEvent(context.Background(), msg)
+			if (err == nil) != (expectedErr == nil) {
+				t.Fatalf("expected error %v, but got %v", expectedErr, err)
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("expected event %v, but got %v", expected, actual)
+			}
+
+			var attrErr *AttributeError
+			if errors.As(err, &attrErr) != (c.expectedAttrErr != nil) {
+				t.Fatalf("expected attribute error %v, but got %v", c.expectedAttrErr, err)
+			}
+			if c.expectedAttrErr != nil &&
+				(attrErr.Name != c.expectedAttrErr.Name || attrErr.Extension != c.expectedAttrErr.Extension) {
+				t.Errorf("expected attribute error of %s (extension %t), but got %s (extension %t)",
+					c.expectedAttrErr.Name, c.expectedAttrErr.Extension, attrErr.Name, attrErr.Extension)
+			}
+		})
+	}
+}
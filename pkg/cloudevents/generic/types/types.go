@@ -2,11 +2,14 @@ package types
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
 	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/util/errors"
 )
 
 const (
@@ -60,6 +63,13 @@ const (
 
 	// ExtensionOriginalSource is the cloud event extension key of the original source.
 	ExtensionOriginalSource = "originalsource"
+
+	// ExtensionAgentID is the cloud event extension key of the agent ID that produces the event.
+	ExtensionAgentID = "agentid"
+
+	// ExtensionPayloadVersion is the cloud event extension key of the version of the payload schema in the event
+	// data, the data is of the first version of its payload if it is not set.
+	ExtensionPayloadVersion = "payloadversion"
 )
 
 // ResourceAction represents an action on a resource object on the source or agent.
@@ -181,30 +191,115 @@ func ParseCloudEventsDataType(cloudEventsDataType string) (*CloudEventsDataType,
 
 // ParseCloudEventsType parse the cloud event type to a struct object.
 // The type format is `<reverse-group-of-resource>.<resource-version>.<resource-name>.<subresource>.<action>`.
-// The `<subresource>` must be one of "spec" and "status".
+// The leading and trailing spaces of the type and its segments are ignored and the action is normalized to lower
+// case. A *CloudEventsTypeFormatError is returned if the type has too few or empty segments, an
+// *UnknownCloudEventsDataTypeError if the segments of the data type are not a group, a version and a resource, and an
+// *UnsupportedSubResourceError if the subresource is neither spec nor status.
 func ParseCloudEventsType(cloudEventsType string) (*CloudEventsType, error) {
-	types := strings.Split(cloudEventsType, ".")
+	types := strings.Split(strings.TrimSpace(cloudEventsType), ".")
 	length := len(types)
 	if length < 5 {
-		return nil, fmt.Errorf("unsupported cloudevents type format: %s", cloudEventsType)
+		return nil, &CloudEventsTypeFormatError{Type: cloudEventsType, Segments: length}
+	}
+
+	for i := range types {
+		types[i] = strings.TrimSpace(types[i])
+		if len(types[i]) == 0 {
+			return nil, &CloudEventsTypeFormatError{Type: cloudEventsType, Segments: length}
+		}
+	}
+
+	dataType := CloudEventsDataType{
+		Group:    strings.Join(types[0:length-4], "."),
+		Version:  types[length-4],
+		Resource: types[length-3],
+	}
+	if !versionRegexp.MatchString(dataType.Version) {
+		return nil, &UnknownCloudEventsDataTypeError{
+			DataType: dataType.String(),
+			Reason:   fmt.Sprintf("%q is not a resource version", dataType.Version),
+		}
 	}
 
 	subResource := EventSubResource(types[length-2])
 	if subResource != SubResourceSpec && subResource != SubResourceStatus {
-		return nil, fmt.Errorf("unsupported subresource %s", subResource)
+		return nil, &UnsupportedSubResourceError{SubResource: subResource}
 	}
 
 	return &CloudEventsType{
-		CloudEventsDataType: CloudEventsDataType{
-			Group:    strings.Join(types[0:length-4], "."),
-			Version:  types[length-4],
-			Resource: types[length-3],
-		},
-		SubResource: subResource,
-		Action:      EventAction(types[length-1]),
+		CloudEventsDataType: dataType,
+		SubResource:         subResource,
+		Action:              EventAction(strings.ToLower(types[length-1])),
 	}, nil
 }
 
+// versionRegexp matches the resource versions, e.g. v1, v1alpha1 or v2beta3.
+var versionRegexp = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]+)?$`)
+
+// CloudEventsTypeFormatError is returned when a cloud events type does not have the segments of a data type, a
+// subresource and an action.
+type CloudEventsTypeFormatError struct {
+	// Type is the malformed cloud events type.
+	Type string
+
+	// Segments is the number of the segments of the type.
+	Segments int
+}
+
+func (e *CloudEventsTypeFormatError) Error() string {
+	return fmt.Sprintf("unsupported cloudevents type format: %s", e.Type)
+}
+
+// UnknownCloudEventsDataTypeError is returned when the data type of a cloud events type is not formed by a group, a
+// version and a resource.
+type UnknownCloudEventsDataTypeError struct {
+	// DataType is the unknown data type.
+	DataType string
+
+	// Reason tells why the data type is unknown.
+	Reason string
+}
+
+func (e *UnknownCloudEventsDataTypeError) Error() string {
+	return fmt.Sprintf("unknown cloudevents data type %s: %s", e.DataType, e.Reason)
+}
+
+// UnsupportedSubResourceError is returned when the subresource of a cloud events type is neither spec nor status.
+type UnsupportedSubResourceError struct {
+	SubResource EventSubResource
+}
+
+func (e *UnsupportedSubResourceError) Error() string {
+	return fmt.Sprintf("unsupported subresource %s", e.SubResource)
+}
+
+// ValidateEventExtensions validates the required extensions of a resource cloud event, which are the resource ID,
+// the resource version and the cluster name. All of the missing or malformed extensions are reported in one error.
+func ValidateEventExtensions(evt *cloudevents.Event) error {
+	errs := []error{}
+	extensions := evt.Extensions()
+
+	for _, key := range []string{ExtensionResourceID, ExtensionClusterName} {
+		value, ok := extensions[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("missing %s extension", key))
+			continue
+		}
+
+		if _, err := cloudeventstypes.ToString(value); err != nil {
+			errs = append(errs, fmt.Errorf("malformed %s extension: %v", key, err))
+		}
+	}
+
+	if value, ok := extensions[ExtensionResourceVersion]; !ok {
+		errs = append(errs, fmt.Errorf("missing %s extension", ExtensionResourceVersion))
+	} else if _, err := cloudeventstypes.ToInteger(value); err != nil {
+		errs = append(errs, fmt.Errorf("malformed %s extension: %v", ExtensionResourceVersion, err))
+	}
+
+	return errors.NewAggregate(errs)
+}
+
 type EventBuilder struct {
 	source            string
 	clusterName       string
@@ -1,9 +1,13 @@
 package types
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"k8s.io/apimachinery/pkg/api/equality"
 )
 
@@ -67,6 +71,16 @@ func TestParseCloudEventsDataType(t *testing.T) {
 }
 
 func TestParseCloudEventsType(t *testing.T) {
+	manifestsType := &CloudEventsType{
+		CloudEventsDataType: CloudEventsDataType{
+			Group:    "io.open-cluster-management.works",
+			Version:  "v1alpha1",
+			Resource: "manifests",
+		},
+		SubResource: "spec",
+		Action:      "create_request",
+	}
+
 	cases := []struct {
 		name         string
 		eventType    string
@@ -74,37 +88,68 @@ func TestParseCloudEventsType(t *testing.T) {
 		err          error
 	}{
 		{
-			name:      "manifest creation event",
-			eventType: testManifestsType,
-			expectedType: &CloudEventsType{
-				CloudEventsDataType: CloudEventsDataType{
-					Group:    "io.open-cluster-management.works",
-					Version:  "v1alpha1",
-					Resource: "manifests",
-				},
-				SubResource: "spec",
-				Action:      "create_request",
-			},
+			name:         "manifest creation event",
+			eventType:    testManifestsType,
+			expectedType: manifestsType,
+		},
+		{
+			name:         "extra whitespace",
+			eventType:    "  io.open-cluster-management.works. v1alpha1 .manifests.spec.create_request\n",
+			expectedType: manifestsType,
+		},
+		{
+			name:         "upper case action",
+			eventType:    "io.open-cluster-management.works.v1alpha1.manifests.spec.Create_Request",
+			expectedType: manifestsType,
 		},
 		{
-			name:      "wrong format",
+			name:      "single segment",
 			eventType: "test",
-			err:       fmt.Errorf("unsupported cloudevents type format: %s", "test"),
+			err:       &CloudEventsTypeFormatError{Type: "test", Segments: 1},
+		},
+		{
+			name:      "missing action",
+			eventType: "io.v1alpha1.manifests.spec",
+			err:       &CloudEventsTypeFormatError{Type: "io.v1alpha1.manifests.spec", Segments: 4},
+		},
+		{
+			name:      "empty segment",
+			eventType: "io..v1alpha1.manifests.spec.create_request",
+			err:       &CloudEventsTypeFormatError{Type: "io..v1alpha1.manifests.spec.create_request", Segments: 6},
+		},
+		{
+			name:      "empty",
+			eventType: "",
+			err:       &CloudEventsTypeFormatError{Type: "", Segments: 1},
+		},
+		{
+			name:      "extra segment",
+			eventType: "io.open-cluster-management.works.v1alpha1.manifests.extra.spec.create_request",
+			err: &UnknownCloudEventsDataTypeError{
+				DataType: "io.open-cluster-management.works.v1alpha1.manifests.extra",
+				Reason:   `"manifests" is not a resource version`,
+			},
+		},
+		{
+			name:      "missing version",
+			eventType: "io.open-cluster-management.works.manifests.spec.create_request",
+			err: &UnknownCloudEventsDataTypeError{
+				DataType: "io.open-cluster-management.works.manifests",
+				Reason:   `"works" is not a resource version`,
+			},
 		},
 		{
 			name:      "unsupported subresource",
 			eventType: "io.open-cluster-management.works.v1alpha1.manifests.unsupported.create_request",
-			err:       fmt.Errorf("unsupported subresource unsupported"),
+			err:       &UnsupportedSubResourceError{SubResource: "unsupported"},
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			eventType, err := ParseCloudEventsType(c.eventType)
-			if err != nil {
-				if err.Error() != c.err.Error() {
-					t.Errorf("unexpected error %v", err)
-				}
+			if !reflect.DeepEqual(err, c.err) {
+				t.Errorf("expected error %v, but got %v", c.err, err)
 			}
 
 			if !equality.Semantic.DeepEqual(eventType, c.expectedType) {
@@ -113,3 +158,97 @@ func TestParseCloudEventsType(t *testing.T) {
 		})
 	}
 }
+
+func TestCloudEventsTypeErrors(t *testing.T) {
+	_, err := ParseCloudEventsType("wrongType")
+	if err.Error() != "unsupported cloudevents type format: wrongType" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, err = ParseCloudEventsType("io.open-cluster-management.works.v1alpha1.manifests.unsupported.create_request")
+	if err.Error() != "unsupported subresource unsupported" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, err = ParseCloudEventsType("io.test.tests.spec.create_request")
+	var dataTypeErr *UnknownCloudEventsDataTypeError
+	if !errors.As(fmt.Errorf("failed to parse: %w", err), &dataTypeErr) {
+		t.Fatalf("expected an unknown data type error, but got %v", err)
+	}
+	if dataTypeErr.DataType != "io.test.tests" {
+		t.Errorf("unexpected data type %s", dataTypeErr.DataType)
+	}
+}
+
+func TestValidateEventExtensions(t *testing.T) {
+	eventType := CloudEventsType{
+		CloudEventsDataType: CloudEventsDataType{
+			Group:    "io.open-cluster-management.works",
+			Version:  "v1alpha1",
+			Resource: "manifests",
+		},
+		SubResource: SubResourceSpec,
+		Action:      "create_request",
+	}
+
+	cases := []struct {
+		name           string
+		event          func() cloudevents.Event
+		expectedErrors []string
+	}{
+		{
+			name: "valid event",
+			event: func() cloudevents.Event {
+				return NewEventBuilder("source1", eventType).
+					WithResourceID("test").
+					WithResourceVersion(1).
+					WithClusterName("cluster1").
+					NewEvent()
+			},
+		},
+		{
+			name: "missing resource id and resource version",
+			event: func() cloudevents.Event {
+				return NewEventBuilder("source1", eventType).WithClusterName("cluster1").NewEvent()
+			},
+			expectedErrors: []string{
+				"missing resourceid extension",
+				"missing resourceversion extension",
+			},
+		},
+		{
+			name: "malformed resource version",
+			event: func() cloudevents.Event {
+				evt := NewEventBuilder("source1", eventType).
+					WithResourceID("test").
+					WithClusterName("cluster1").
+					NewEvent()
+				evt.SetExtension(ExtensionResourceVersion, "v1")
+				return evt
+			},
+			expectedErrors: []string{"malformed resourceversion extension"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			evt := c.event()
+			err := ValidateEventExtensions(&evt)
+			if len(c.expectedErrors) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error, but failed")
+			}
+			for _, expected := range c.expectedErrors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected %q in error %q", expected, err)
+				}
+			}
+		})
+	}
+}
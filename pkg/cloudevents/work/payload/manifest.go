@@ -1,6 +1,8 @@
 package payload
 
 import (
+	"fmt"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -52,3 +54,76 @@ type ManifestConfigOption struct {
 	// UpdateStrategy is Update if it is not set.
 	UpdateStrategy *workv1.UpdateStrategy `json:"updateStrategy,omitempty"`
 }
+
+// The versions of the ManifestStatus schema in the event data, the version is carried by the
+// types.ExtensionPayloadVersion of the event.
+const (
+	// ManifestStatusVersionV1 is the schema of the ManifestStatus, it is the version of the data without a payload
+	// version, e.g. the data of the older agents.
+	ManifestStatusVersionV1 = "v1"
+	// ManifestStatusVersionV2 is the schema of the ManifestStatusV2.
+	ManifestStatusVersionV2 = "v2"
+)
+
+// ManifestStatusV2 is the version v2 of the ManifestStatus schema, the status of the manifest is flattened into it.
+type ManifestStatusV2 struct {
+	// Conditions contains the different condition statuses for a SingleManifest on a managed cluster, they are the
+	// same as the Conditions of the ManifestStatus.
+	Conditions []metav1.Condition `json:"conditions"`
+
+	// ResourceMeta represents the group, version, kind, name and namespace of the manifest on a managed cluster.
+	ResourceMeta *workv1.ManifestResourceMeta `json:"resourceMeta,omitempty"`
+
+	// ResourceConditions represents the conditions of the manifest on a managed cluster.
+	ResourceConditions []metav1.Condition `json:"resourceConditions,omitempty"`
+
+	// StatusFeedbacks represents the values of the feedback fields of the manifest.
+	StatusFeedbacks *workv1.StatusFeedbackResult `json:"statusFeedback,omitempty"`
+}
+
+// manifestStatusDecoders decode the data of each version of the ManifestStatus schema and migrate it to the
+// ManifestStatus, unmarshal unmarshals the event data into a struct of the version.
+var manifestStatusDecoders = map[string]func(unmarshal func(obj interface{}) error) (*ManifestStatus, error){
+	ManifestStatusVersionV1: func(unmarshal func(obj interface{}) error) (*ManifestStatus, error) {
+		status := &ManifestStatus{}
+		if err := unmarshal(status); err != nil {
+			return nil, err
+		}
+		return status, nil
+	},
+	ManifestStatusVersionV2: func(unmarshal func(obj interface{}) error) (*ManifestStatus, error) {
+		v2 := &ManifestStatusV2{}
+		if err := unmarshal(v2); err != nil {
+			return nil, err
+		}
+
+		status := &ManifestStatus{Conditions: v2.Conditions}
+		if v2.ResourceMeta == nil && v2.ResourceConditions == nil && v2.StatusFeedbacks == nil {
+			return status, nil
+		}
+
+		status.Status = &workv1.ManifestCondition{Conditions: v2.ResourceConditions}
+		if v2.ResourceMeta != nil {
+			status.Status.ResourceMeta = *v2.ResourceMeta
+		}
+		if v2.StatusFeedbacks != nil {
+			status.Status.StatusFeedbacks = *v2.StatusFeedbacks
+		}
+		return status, nil
+	},
+}
+
+// DecodeManifestStatus decodes the event data of the version of the ManifestStatus schema, and migrates it to the
+// ManifestStatus, the data is of the ManifestStatusVersionV1 if the version is empty. The unmarshal unmarshals the
+// event data into a struct, so the data can be of any content type.
+func DecodeManifestStatus(version string, unmarshal func(obj interface{}) error) (*ManifestStatus, error) {
+	if len(version) == 0 {
+		version = ManifestStatusVersionV1
+	}
+
+	decode, ok := manifestStatusDecoders[version]
+	if !ok {
+		return nil, fmt.Errorf("unsupported manifest status version %q", version)
+	}
+	return decode(unmarshal)
+}
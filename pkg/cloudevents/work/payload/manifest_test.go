@@ -0,0 +1,95 @@
+package payload
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestDecodeManifestStatus(t *testing.T) {
+	lastTransitionTime := metav1.NewTime(metav1.Now().Rfc3339Copy().Time)
+	conditions := []metav1.Condition{
+		{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied", LastTransitionTime: lastTransitionTime},
+	}
+	resourceConditions := []metav1.Condition{
+		{Type: "Available", Status: metav1.ConditionTrue, Reason: "Available", LastTransitionTime: lastTransitionTime},
+	}
+	resourceMeta := workv1.ManifestResourceMeta{Version: "v1", Kind: "ConfigMap", Name: "test", Namespace: "default"}
+
+	// the current internal form of the status that every version is migrated to
+	expected := &ManifestStatus{
+		Conditions: conditions,
+		Status:     &workv1.ManifestCondition{ResourceMeta: resourceMeta, Conditions: resourceConditions},
+	}
+
+	cases := []struct {
+		name          string
+		version       string
+		data          interface{}
+		expected      *ManifestStatus
+		expectedError bool
+	}{
+		{
+			name:     "no version",
+			data:     expected,
+			expected: expected,
+		},
+		{
+			name:     "v1",
+			version:  ManifestStatusVersionV1,
+			data:     expected,
+			expected: expected,
+		},
+		{
+			name:    "v2",
+			version: ManifestStatusVersionV2,
+			data: &ManifestStatusV2{
+				Conditions:         conditions,
+				ResourceMeta:       &resourceMeta,
+				ResourceConditions: resourceConditions,
+			},
+			expected: expected,
+		},
+		{
+			name:     "v2 without the status of the manifest",
+			version:  ManifestStatusVersionV2,
+			data:     &ManifestStatusV2{Conditions: conditions},
+			expected: &ManifestStatus{Conditions: conditions},
+		},
+		{
+			name:          "unknown version",
+			version:       "v3",
+			data:          expected,
+			expectedError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := json.Marshal(c.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			status, err := DecodeManifestStatus(c.version, func(obj interface{}) error {
+				return json.Unmarshal(data, obj)
+			})
+			if c.expectedError {
+				if err == nil {
+					t.Errorf("expected error for the version %s, but failed", c.version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(status, c.expected) {
+				t.Errorf("expected status %v, but got %v", c.expected, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package payload
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// jsonSchemaDraft is the draft of the JSON schemas that are generated for the payloads.
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// knownSchemas are the schemas of the types that are not encoded as their fields.
+var knownSchemas = map[reflect.Type]map[string]interface{}{
+	reflect.TypeOf(metav1.Time{}):               {"type": "string", "format": "date-time"},
+	reflect.TypeOf(metav1.MicroTime{}):          {"type": "string", "format": "date-time"},
+	reflect.TypeOf(unstructured.Unstructured{}): {"type": "object"},
+	reflect.TypeOf(runtime.RawExtension{}):      {},
+	reflect.TypeOf(intstr.IntOrString{}):        {"type": []string{"integer", "string"}},
+}
+
+// ManifestJSONSchema returns the JSON schema of the Manifest, the clients in other languages can be generated from
+// it. The fields that are always encoded are required.
+func ManifestJSONSchema() ([]byte, error) {
+	return JSONSchema("Manifest", Manifest{})
+}
+
+// ManifestStatusJSONSchema returns the JSON schema of the ManifestStatus, the clients in other languages can be
+// generated from it. The fields that are always encoded are required.
+func ManifestStatusJSONSchema() ([]byte, error) {
+	return JSONSchema("ManifestStatus", ManifestStatus{})
+}
+
+// JSONSchema returns the JSON schema of the JSON encoding of a payload with the title. The properties of a struct are
+// its encoded fields, a field is required if it is not omitted when it is empty.
+func JSONSchema(title string, payload interface{}) ([]byte, error) {
+	schema, err := schemaOf(reflect.TypeOf(payload), map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = title
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// schemaOf returns the schema of the type, visiting has the structs that the type is in, so a recursive type is
+// reported rather than expanded forever.
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	if known, ok := knownSchemas[t]; ok {
+		return copySchema(known), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), visiting)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Slice, reflect.Array:
+		// the bytes are encoded as a base64 string
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		items, err := schemaOf(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaOf(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return structSchemaOf(t, visiting)
+	case reflect.Interface:
+		// any value
+		return map[string]interface{}{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// structSchemaOf returns the schema of a struct, the fields of the embedded structs without a JSON name are the
+// properties of the struct, as they are encoded.
+func structSchemaOf(t reflect.Type, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	if visiting[t] {
+		return nil, fmt.Errorf("unsupported recursive type %s", t)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && len(name) == 0 {
+			embedded, err := schemaOf(field.Type, visiting)
+			if err != nil {
+				return nil, err
+			}
+			embeddedProperties, _ := embedded["properties"].(map[string]interface{})
+			for name, property := range embeddedProperties {
+				properties[name] = property
+			}
+			if embeddedRequired, ok := embedded["required"].([]string); ok {
+				required = append(required, embeddedRequired...)
+			}
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		property, err := schemaOf(field.Type, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %w", field.Name, t, err)
+		}
+		properties[name] = property
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) != 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+func copySchema(schema map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		copied[k] = v
+	}
+	return copied
+}
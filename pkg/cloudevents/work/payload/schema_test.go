@@ -0,0 +1,91 @@
+package payload
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	cases := []struct {
+		name             string
+		schema           func() ([]byte, error)
+		expectedTitle    string
+		expectedRequired []interface{}
+		validate         func(t *testing.T, properties map[string]interface{})
+	}{
+		{
+			name:             "manifest status",
+			schema:           ManifestStatusJSONSchema,
+			expectedTitle:    "ManifestStatus",
+			expectedRequired: []interface{}{"conditions"},
+			validate: func(t *testing.T, properties map[string]interface{}) {
+				conditions := properties["conditions"].(map[string]interface{})
+				if conditions["type"] != "array" {
+					t.Fatalf("expected conditions is an array, but got %v", conditions)
+				}
+				condition := conditions["items"].(map[string]interface{})
+				expected := []interface{}{"type", "status", "lastTransitionTime", "reason", "message"}
+				if !reflect.DeepEqual(condition["required"], expected) {
+					t.Errorf("expected the required fields %v of a condition, but got %v", expected, condition["required"])
+				}
+				lastTransitionTime := condition["properties"].(map[string]interface{})["lastTransitionTime"]
+				if !reflect.DeepEqual(lastTransitionTime, map[string]interface{}{"type": "string", "format": "date-time"}) {
+					t.Errorf("expected lastTransitionTime is a date-time, but got %v", lastTransitionTime)
+				}
+
+				status := properties["status"].(map[string]interface{})
+				if _, ok := status["properties"].(map[string]interface{})["resourceMeta"]; !ok {
+					t.Errorf("expected the resourceMeta of status, but got %v", status)
+				}
+			},
+		},
+		{
+			name:             "manifest",
+			schema:           ManifestJSONSchema,
+			expectedTitle:    "Manifest",
+			expectedRequired: []interface{}{"manifest"},
+			validate: func(t *testing.T, properties map[string]interface{}) {
+				if !reflect.DeepEqual(properties["manifest"], map[string]interface{}{"type": "object"}) {
+					t.Errorf("expected manifest is an object, but got %v", properties["manifest"])
+				}
+				for _, name := range []string{"deleteOption", "configOption"} {
+					if _, ok := properties[name]; !ok {
+						t.Errorf("expected property %s, but got %v", name, properties)
+					}
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := c.schema()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			schema := map[string]interface{}{}
+			if err := json.Unmarshal(data, &schema); err != nil {
+				t.Fatal(err)
+			}
+			if schema["$schema"] != jsonSchemaDraft || schema["title"] != c.expectedTitle || schema["type"] != "object" {
+				t.Errorf("expected the object schema %s of %s, but got %v", c.expectedTitle, jsonSchemaDraft, schema)
+			}
+			if !reflect.DeepEqual(schema["required"], c.expectedRequired) {
+				t.Errorf("expected the required fields %v, but got %v", c.expectedRequired, schema["required"])
+			}
+			c.validate(t, schema["properties"].(map[string]interface{}))
+		})
+	}
+}
+
+func TestJSONSchemaWithRecursiveType(t *testing.T) {
+	type node struct {
+		Children []node `json:"children"`
+	}
+
+	if _, err := JSONSchema("node", node{}); err == nil {
+		t.Errorf("expected error for the recursive type, but failed")
+	}
+}
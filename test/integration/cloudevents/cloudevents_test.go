@@ -399,7 +399,7 @@ var _ = ginkgo.Describe("Cloudevents clients test", func() {
 		})
 	})
 
-	ginkgo.Context("Publish a resource without version", func() {
+	ginkgo.Context("Publish a resource with or without version", func() {
 		var ctx context.Context
 		var cancel context.CancelFunc
 		ginkgo.BeforeEach(func() {
@@ -409,7 +409,7 @@ var _ = ginkgo.Describe("Cloudevents clients test", func() {
 			// cancel the context to gracefully shutdown the agent
 			cancel()
 		})
-		ginkgo.It("publish resource withou version from consumer and ensure resource can be received by source and agent", func() {
+		ginkgo.It("publish resource with versions from consumer and ensure resource can be received by source and agent", func() {
 			ginkgo.By("Publish a resource from consumer")
 			resourceName := "resource1"
 			clusterName := "cluster3"
@@ -423,7 +423,6 @@ var _ = ginkgo.Describe("Cloudevents clients test", func() {
 
 			ginkgo.By("create resource1 for cluster3 on the consumer and publish it to the source", func() {
 				res := source.NewResource(clusterName, resourceName)
-				res.ResourceVersion = 0
 				consumerStore.Add(res)
 				err := grpcSourceCloudEventsClient.Publish(ctx, types.CloudEventsType{
 					CloudEventsDataType: payload.ManifestEventDataType,
@@ -465,7 +464,6 @@ var _ = ginkgo.Describe("Cloudevents clients test", func() {
 			newResourceName := "resource2"
 			ginkgo.By("create resource2 for cluster3 on the consumer and publish it to the source", func() {
 				newResource := source.NewResource(clusterName, newResourceName)
-				newResource.ResourceVersion = 0
 				consumerStore.Add(newResource)
 				err := grpcSourceCloudEventsClient.Publish(ctx, types.CloudEventsType{
 					CloudEventsDataType: payload.ManifestEventDataType,
@@ -557,7 +555,7 @@ var _ = ginkgo.Describe("Cloudevents clients test", func() {
 					return nil
 				}, 10*time.Second, 1*time.Second).Should(gomega.Succeed())
 
-				resource.ResourceVersion = 0
+				resource.ResourceVersion = resource.ResourceVersion + 1
 				resource.Spec.Object["data"] = "test"
 
 				err = consumerStore.Update(resource)
@@ -668,5 +666,44 @@ var _ = ginkgo.Describe("Cloudevents clients test", func() {
 				}, 10*time.Second, 1*time.Second).Should(gomega.Succeed())
 			})
 		})
+
+		ginkgo.It("publish resource without version from consumer and ensure resource is rejected by source", func() {
+			resourceName := "resource1"
+			clusterName := "cluster4"
+
+			ginkgo.By("start an agent on cluster4")
+			clientHolder, err := agent.StartWorkAgent(ctx, clusterName, mqttOptions, codec.NewManifestCodec(nil))
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+
+			lister := clientHolder.ManifestWorkInformer().Lister().ManifestWorks(clusterName)
+
+			ginkgo.By("publish resource1 for cluster4 without version to the source", func() {
+				res := source.NewResource(clusterName, resourceName)
+				res.ResourceVersion = 0
+				err := grpcSourceCloudEventsClient.Publish(ctx, types.CloudEventsType{
+					CloudEventsDataType: payload.ManifestEventDataType,
+					SubResource:         types.SubResourceSpec,
+					Action:              "test_create_request",
+				}, res)
+				gomega.Expect(err).To(gomega.HaveOccurred())
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("it must be a positive integer"))
+			})
+
+			ginkgo.By("ensure resource1 is not synced to cluster4", func() {
+				_, err := store.Get(source.ResourceID(clusterName, resourceName))
+				gomega.Expect(err).To(gomega.HaveOccurred())
+
+				gomega.Consistently(func() error {
+					list, err := lister.List(labels.Everything())
+					if err != nil {
+						return err
+					}
+					if len(list) != 0 {
+						return fmt.Errorf("unexpected work list %v", list)
+					}
+					return nil
+				}, 3*time.Second, 1*time.Second).Should(gomega.Succeed())
+			})
+		})
 	})
 })
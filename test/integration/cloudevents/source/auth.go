@@ -0,0 +1,114 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authorizationHeader is the metadata of the requests that carries the bearer token.
+const authorizationHeader = "authorization"
+
+// TokenVerifier verifies the bearer tokens of the requests.
+type TokenVerifier interface {
+	// Verify verifies the token and returns the identity that the token is issued to, an error is returned if the
+	// token is invalid, e.g. it is expired.
+	Verify(ctx context.Context, token string) (string, error)
+}
+
+// SubscribeAuthorizer authorizes the subscriptions by their sources.
+type SubscribeAuthorizer interface {
+	// Authorize returns an error if the identity is not allowed to subscribe to the source of a subscription request,
+	// the source is the one that is requested, e.g. a source name or a pattern. The identity is the one that the
+	// request is authenticated as, it is empty if the request is not authenticated with a bearer token.
+	Authorize(ctx context.Context, identity, source string) error
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the identity that the request is authenticated as, it is false if the request is not
+// authenticated with a bearer token.
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(identityKey{}).(string)
+	return identity, ok
+}
+
+// authenticate verifies the bearer token in the metadata of the request and returns the context with the
+// authenticated identity. The health checks are not authenticated, so they can be used as probes.
+func (svr *GRPCServer) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if svr.tokenVerifier == nil || strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "the bearer token is missing")
+	}
+
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || len(token) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "the authorization is not a bearer token")
+	}
+
+	identity, err := svr.tokenVerifier.Verify(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, fmt.Sprintf("invalid bearer token: %v", err))
+	}
+
+	return context.WithValue(ctx, identityKey{}, identity), nil
+}
+
+// unaryAuthInterceptor authenticates the unary requests.
+func (svr *GRPCServer) unaryAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := svr.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// streamAuthInterceptor authenticates the stream requests.
+func (svr *GRPCServer) streamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	ctx, err := svr.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// contextStream is a server stream with the context of the interceptors, e.g. the context has the authenticated
+// identity.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorizeSubscription authorizes the identity of the subscription request to subscribe to the requested source,
+// all of the subscriptions are allowed if there is no subscribe authorizer.
+func (svr *GRPCServer) authorizeSubscription(ctx context.Context, source string) error {
+	if svr.subscribeAuthorizer == nil {
+		return nil
+	}
+
+	identity, _ := IdentityFromContext(ctx)
+	if err := svr.subscribeAuthorizer.Authorize(ctx, identity, source); err != nil {
+		return status.Error(codes.PermissionDenied,
+			fmt.Sprintf("%q is not allowed to subscribe to the source %q: %v", identity, source, err))
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+)
+
+// fakeTokenVerifier verifies the tokens against the identities that they are issued to.
+type fakeTokenVerifier struct {
+	identities map[string]string
+	expired    map[string]bool
+}
+
+func (v *fakeTokenVerifier) Verify(ctx context.Context, token string) (string, error) {
+	if v.expired[token] {
+		return "", fmt.Errorf("the token is expired")
+	}
+
+	identity, ok := v.identities[token]
+	if !ok {
+		return "", fmt.Errorf("the token is unknown")
+	}
+	return identity, nil
+}
+
+// fakeSubscribeAuthorizer allows the identities to subscribe to their sources.
+type fakeSubscribeAuthorizer struct {
+	sources map[string]string
+}
+
+func (a *fakeSubscribeAuthorizer) Authorize(ctx context.Context, identity, source string) error {
+	if a.sources[identity] != source {
+		return fmt.Errorf("the source is not owned by the identity")
+	}
+	return nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	svr := NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(), WithTokenVerifier(&fakeTokenVerifier{
+		identities: map[string]string{"valid-token": "agent1", "expired-token": "agent1"},
+		expired:    map[string]bool{"expired-token": true},
+	}))
+
+	cases := []struct {
+		name             string
+		method           string
+		md               metadata.MD
+		expectedCode     codes.Code
+		expectedIdentity string
+	}{
+		{
+			name:             "valid token",
+			method:           pbv1.CloudEventService_Publish_FullMethodName,
+			md:               metadata.Pairs(authorizationHeader, "Bearer valid-token"),
+			expectedCode:     codes.OK,
+			expectedIdentity: "agent1",
+		},
+		{
+			name:         "expired token",
+			method:       pbv1.CloudEventService_Publish_FullMethodName,
+			md:           metadata.Pairs(authorizationHeader, "Bearer expired-token"),
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "missing token",
+			method:       pbv1.CloudEventService_Publish_FullMethodName,
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "not a bearer token",
+			method:       pbv1.CloudEventService_Publish_FullMethodName,
+			md:           metadata.Pairs(authorizationHeader, "Basic dXNlcjpwYXNz"),
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "health check without token",
+			method:       "/" + healthpb.Health_ServiceDesc.ServiceName + "/Check",
+			expectedCode: codes.OK,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), c.md)
+
+			ctx, err := svr.authenticate(ctx, c.method)
+			if status.Code(err) != c.expectedCode {
+				t.Fatalf("expected code %v, but got %v", c.expectedCode, err)
+			}
+			if err != nil {
+				return
+			}
+
+			identity, _ := IdentityFromContext(ctx)
+			if identity != c.expectedIdentity {
+				t.Errorf("expected identity %q, but got %q", c.expectedIdentity, identity)
+			}
+		})
+	}
+}
+
+func TestSubscribeWithTokenVerifier(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster, WithTokenVerifier(&fakeTokenVerifier{
+		identities: map[string]string{"valid-token": "source1"},
+	})))
+
+	cases := []struct {
+		name         string
+		md           metadata.MD
+		expectedCode codes.Code
+	}{
+		{
+			name:         "valid token",
+			md:           metadata.Pairs(authorizationHeader, "Bearer valid-token"),
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "missing token",
+			expectedCode: codes.Unauthenticated,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			stream, err := client.Subscribe(metadata.NewOutgoingContext(ctx, c.md),
+				&pbv1.SubscriptionRequest{Source: "test-source"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the snapshot done event is received once the subscription is authenticated
+			_, err = stream.Recv()
+			if status.Code(err) != c.expectedCode {
+				t.Errorf("expected code %v, but got %v", c.expectedCode, err)
+			}
+		})
+	}
+}
+
+func TestSubscribeWithSubscribeAuthorizer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster,
+		WithTokenVerifier(&fakeTokenVerifier{identities: map[string]string{"token-a": "identity-a"}}),
+		WithSubscribeAuthorizer(&fakeSubscribeAuthorizer{sources: map[string]string{"identity-a": "source-a"}})))
+
+	cases := []struct {
+		name         string
+		source       string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "authorized source",
+			source:       "source-a",
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "unauthorized source",
+			source:       "source-b",
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "all sources",
+			source:       "*",
+			expectedCode: codes.PermissionDenied,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			md := metadata.Pairs(authorizationHeader, "Bearer token-a")
+			stream, err := client.Subscribe(metadata.NewOutgoingContext(ctx, md), &pbv1.SubscriptionRequest{Source: c.source})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the snapshot done event is received once the subscription is authorized
+			_, err = stream.Recv()
+			if status.Code(err) != c.expectedCode {
+				t.Errorf("expected code %v, but got %v", c.expectedCode, err)
+			}
+		})
+	}
+}
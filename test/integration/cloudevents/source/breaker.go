@@ -0,0 +1,59 @@
+package source
+
+import (
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// sendBreaker is the circuit breaker of the deliveries to a subscriber. It is opened once the deliveries fail the
+// threshold times in a row, then the deliveries are skipped without being encoded and sent until the cooldown
+// elapses. The first delivery after the cooldown is a probe, the breaker is closed if it succeeds, otherwise, the
+// breaker is opened for another cooldown. The deliveries of a subscriber are made one by one, so it is not guarded.
+type sendBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	clock     clock.Clock
+
+	failures int
+	// openedAt is when the breaker is opened, it is zero if the breaker is closed.
+	openedAt time.Time
+}
+
+// newSendBreaker returns nil if the threshold is not positive, so the deliveries are never skipped.
+func newSendBreaker(threshold int, cooldown time.Duration, clock clock.Clock) *sendBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+
+	return &sendBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		clock:     clock,
+	}
+}
+
+// allow returns false if the breaker is open and its cooldown does not elapse.
+func (b *sendBreaker) allow() bool {
+	return b.openedAt.IsZero() || b.clock.Since(b.openedAt) >= b.cooldown
+}
+
+// fail records a failed delivery, it returns true if the breaker is opened by it.
+func (b *sendBreaker) fail() bool {
+	b.failures++
+	if b.failures < b.threshold {
+		return false
+	}
+
+	b.openedAt = b.clock.Now()
+	return true
+}
+
+// succeed records a successful delivery and closes the breaker, it returns true if the deliveries before it failed,
+// so their events are missed by the subscriber.
+func (b *sendBreaker) succeed() bool {
+	recovered := b.failures != 0
+	b.failures = 0
+	b.openedAt = time.Time{}
+	return recovered
+}
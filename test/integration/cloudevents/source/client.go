@@ -9,6 +9,7 @@ import (
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
 
 	workv1 "open-cluster-management.io/api/work/v1"
@@ -21,7 +22,13 @@ import (
 	"open-cluster-management.io/sdk-go/pkg/cloudevents/work/source/codec"
 )
 
-type ResourceCodec struct{}
+type ResourceCodec struct {
+	// DataContentType is the content type of the encoded spec events, the data is encoded in JSON if it is empty.
+	DataContentType string
+	// DataContentEncoding is the encoding that the data of the encoded spec events is compressed with, e.g.
+	// ContentEncodingGzip, the data is not compressed if it is empty.
+	DataContentEncoding string
+}
 
 var _ generic.Codec[*Resource] = &ResourceCodec{}
 
@@ -45,14 +52,19 @@ func (c *ResourceCodec) Encode(source string, eventType types.CloudEventsType, r
 
 	if !resource.GetDeletionTimestamp().IsZero() {
 		evt := eventBuilder.WithDeletionTimestamp(resource.GetDeletionTimestamp().Time).NewEvent()
+		setObservedGeneration(&evt, resource)
 		return &evt, nil
 	}
 
 	evt := eventBuilder.NewEvent()
+	setObservedGeneration(&evt, resource)
 
-	if err := evt.SetData(cloudevents.ApplicationJSON, &payload.Manifest{Manifest: resource.Spec}); err != nil {
+	if err := setEventData(&evt, c.DataContentType, &payload.Manifest{Manifest: resource.Spec}); err != nil {
 		return nil, fmt.Errorf("failed to encode manifests to cloud event: %v", err)
 	}
+	if err := compressEventData(&evt, c.DataContentEncoding); err != nil {
+		return nil, fmt.Errorf("failed to compress manifests of cloud event: %v", err)
+	}
 
 	return &evt, nil
 }
@@ -89,21 +101,48 @@ func (c *ResourceCodec) Decode(evt *cloudevents.Event) (*Resource, error) {
 		return nil, fmt.Errorf("failed to get originalsource extension: %v", err)
 	}
 
-	manifestStatus := &payload.ManifestStatus{}
-	if err := evt.DataAs(manifestStatus); err != nil {
+	// the status is of the first version of the payload if the event does not have a payload version
+	payloadVersion := ""
+	if value, exists := evtExtensions[types.ExtensionPayloadVersion]; exists {
+		payloadVersion, err = cloudeventstypes.ToString(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get payloadversion extension: %v", err)
+		}
+	}
+
+	manifestStatus, err := payload.DecodeManifestStatus(payloadVersion, func(obj interface{}) error {
+		return eventDataAs(evt, obj)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal event data %s, %v", string(evt.Data()), err)
 	}
 
 	resource := &Resource{
+		DataType:        eventType.CloudEventsDataType,
 		Source:          originalSource,
 		ResourceID:      resourceID,
 		ResourceVersion: int64(resourceVersion),
 		Namespace:       clusterName,
+		EventID:         evt.ID(),
+		EventTime:       evt.Time(),
 		Status: ResourceStatus{
 			Conditions: manifestStatus.Conditions,
 		},
 	}
 
+	if deletionTimestampValue, exists := evtExtensions[types.ExtensionDeletionTimestamp]; exists {
+		deletionTimestamp, err := cloudeventstypes.ToTime(deletionTimestampValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert deletion timestamp %v to time.Time: %v", deletionTimestampValue, err)
+		}
+		resource.DeletionTimestamp = &metav1.Time{Time: deletionTimestamp}
+	}
+
+	resource.ObservedGeneration, err = observedGenerationOf(evtExtensions)
+	if err != nil {
+		return nil, err
+	}
+
 	return resource, nil
 }
 
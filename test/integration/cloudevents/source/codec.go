@@ -0,0 +1,403 @@
+package source
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/clock"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/work/payload"
+)
+
+// Codec is used by the server to decode the resource spec from a published cloudevent and encode the resource
+// status to a cloudevent for the subscribers.
+type Codec interface {
+	// EventDataType indicates which type of the event data the codec is used for.
+	EventDataType() types.CloudEventsDataType
+
+	// Encode a resource status to a cloudevent.
+	Encode(*Resource) (*cloudevents.Event, error)
+
+	// Decode a cloudevent to a resource object.
+	Decode(*cloudevents.Event) (*Resource, error)
+}
+
+const (
+	// statusUpdateAction is the action of the status events for the resources.
+	statusUpdateAction types.EventAction = "status_update"
+
+	// statusDeleteAction is the action of the status events for the resources that are being deleted.
+	statusDeleteAction types.EventAction = "status_delete"
+)
+
+// SnapshotDoneEventType is the type of the event that is sent to a subscriber after the current resources are sent,
+// the events after it are the live events.
+var SnapshotDoneEventType = types.CloudEventsType{
+	CloudEventsDataType: types.CloudEventsDataType{
+		Group:    "cloudevents.open-cluster-management.io",
+		Version:  "v1",
+		Resource: "snapshots",
+	},
+	SubResource: types.SubResourceStatus,
+	Action:      "snapshot_done",
+}
+
+// HeartbeatEventType is the type of the event that is sent to an idle subscriber periodically, so the subscriber
+// knows the subscription is still alive, it does not carry any resource.
+var HeartbeatEventType = types.CloudEventsType{
+	CloudEventsDataType: types.CloudEventsDataType{
+		Group:    "cloudevents.open-cluster-management.io",
+		Version:  "v1",
+		Resource: "heartbeats",
+	},
+	SubResource: types.SubResourceStatus,
+	Action:      "heartbeat",
+}
+
+// StatusEventTypeFunc returns the sub-resource and the action of the status event that is encoded for the resource,
+// so the event type reflects the state of the resource.
+type StatusEventTypeFunc func(res *Resource) (types.EventSubResource, types.EventAction)
+
+// defaultStatusEventType returns the status_delete action for the resources that are being deleted, otherwise, the
+// status_update action.
+func defaultStatusEventType(res *Resource) (types.EventSubResource, types.EventAction) {
+	if !res.GetDeletionTimestamp().IsZero() {
+		return types.SubResourceStatus, statusDeleteAction
+	}
+
+	return types.SubResourceStatus, statusUpdateAction
+}
+
+// ApplicationProtobuf is the content type of the event data that is encoded as a protobuf google.protobuf.Struct, the
+// struct is converted from the JSON representation of the data.
+const ApplicationProtobuf = "application/protobuf"
+
+// ApplicationCBOR is the content type of the event data that is encoded in CBOR, the CBOR data is converted from the
+// JSON representation of the data.
+const ApplicationCBOR = "application/cbor"
+
+// ExtensionContentEncoding is the extension of the events whose data is compressed, its value is the encoding of the
+// data. The content type of the events is still the content type of the uncompressed data.
+const ExtensionContentEncoding = "contentencoding"
+
+// ContentEncodingGzip is the content encoding of the event data that is compressed with gzip.
+const ContentEncodingGzip = "gzip"
+
+// maxDecompressedDataSize is the max size of the decompressed event data, so a small compressed data cannot exhaust
+// the memory of the decoder.
+const maxDecompressedDataSize = 64 << 20
+
+// manifestStatusData is the data of the manifest status events, the manifest of the resource is echoed with its
+// status if the manifest codec echoes the manifests, it is omitted if the resource does not have one, e.g. it is
+// stripped by the projection of the subscription.
+type manifestStatusData struct {
+	payload.ManifestStatus
+	Manifest map[string]interface{} `json:"manifest,omitempty"`
+}
+
+// statusEncoding is how the codecs of a server encode the status events, it is built from the options of the server.
+type statusEncoding struct {
+	// source is the source of the encoded status events.
+	source string
+	// dataContentType is the content type of the encoded status events, the data is encoded in JSON if it is empty.
+	dataContentType string
+	// dataContentEncoding is the encoding that the data of the encoded status events is compressed with, the data is
+	// not compressed if it is empty.
+	dataContentEncoding string
+	// statusEventType decides the type of the encoded status events, the defaultStatusEventType is used if it is nil.
+	statusEventType StatusEventTypeFunc
+	// clock is the clock of the time of the encoded status events, the real time is used if it is nil.
+	clock clock.PassiveClock
+}
+
+// encodeStatus encodes the status event of the resource of the data type with the data.
+func (e statusEncoding) encodeStatus(dataType types.CloudEventsDataType, resource *Resource,
+	data interface{}) (*cloudevents.Event, error) {
+	evt := newStatusEvent(e.source, dataType, e.statusEventType, e.clock, resource)
+	if err := setEventData(&evt, e.dataContentType, data); err != nil {
+		return nil, fmt.Errorf("failed to encode %s status to cloud event: %v", dataType, err)
+	}
+	if err := compressEventData(&evt, e.dataContentEncoding); err != nil {
+		return nil, fmt.Errorf("failed to compress %s status of cloud event: %v", dataType, err)
+	}
+
+	return &evt, nil
+}
+
+// manifestCodec is the codec for the manifests.
+type manifestCodec struct {
+	statusEncoding
+	// echoManifest echoes the manifests of the resources in the status events.
+	echoManifest bool
+}
+
+var _ Codec = &manifestCodec{}
+
+func (c *manifestCodec) EventDataType() types.CloudEventsDataType {
+	return payload.ManifestEventDataType
+}
+
+func (c *manifestCodec) Encode(resource *Resource) (*cloudevents.Event, error) {
+	data := &manifestStatusData{ManifestStatus: payload.ManifestStatus{Conditions: resource.Status.Conditions}}
+	if c.echoManifest {
+		data.Manifest = resource.Spec.Object
+	}
+
+	return c.encodeStatus(payload.ManifestEventDataType, resource, data)
+}
+
+func (c *manifestCodec) Decode(evt *cloudevents.Event) (*Resource, error) {
+	resource, err := decodeResource(evt, payload.ManifestEventDataType)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &payload.Manifest{}
+	if err := eventDataAs(evt, manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data %s, %v", string(evt.Data()), err)
+	}
+	resource.Spec = manifest.Manifest
+	resource.Labels = manifest.Manifest.GetLabels()
+	resource.Annotations = manifest.Manifest.GetAnnotations()
+
+	return resource, nil
+}
+
+// newStatusEvent builds the status event of the resource without the data, the type of the event is decided by the
+// statusEventType, the defaultStatusEventType is used if it is nil.
+func newStatusEvent(source string, dataType types.CloudEventsDataType, statusEventType StatusEventTypeFunc,
+	clock clock.PassiveClock, resource *Resource) cloudevents.Event {
+	if statusEventType == nil {
+		statusEventType = defaultStatusEventType
+	}
+
+	subResource, action := statusEventType(resource)
+	eventType := types.CloudEventsType{
+		CloudEventsDataType: dataType,
+		SubResource:         subResource,
+		Action:              action,
+	}
+
+	eventBuilder := types.NewEventBuilder(source, eventType).
+		WithResourceID(resource.ResourceID).
+		WithResourceVersion(resource.ResourceVersion).
+		WithClusterName(resource.Namespace)
+
+	if !resource.GetDeletionTimestamp().IsZero() {
+		eventBuilder.WithDeletionTimestamp(resource.GetDeletionTimestamp().Time)
+	}
+
+	evt := eventBuilder.NewEvent()
+	setObservedGeneration(&evt, resource)
+	if clock != nil {
+		evt.SetTime(clock.Now())
+	}
+	return evt
+}
+
+// decodeResource decodes the resource of the data type from the extensions of a published cloudevent, the spec of
+// the resource is decoded from the event data by the codec of the data type.
+func decodeResource(evt *cloudevents.Event, dataType types.CloudEventsDataType) (*Resource, error) {
+	eventType, err := types.ParseCloudEventsType(evt.Type())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cloud event type %s, %v", evt.Type(), err)
+	}
+
+	if eventType.CloudEventsDataType != dataType {
+		return nil, fmt.Errorf("%w %s", ErrUnsupportedDataType, eventType.CloudEventsDataType)
+	}
+
+	evtExtensions := evt.Context.GetExtensions()
+
+	resourceID, err := cloudeventstypes.ToString(evtExtensions[types.ExtensionResourceID])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resourceid extension: %v", err)
+	}
+
+	resourceVersion, err := cloudeventstypes.ToInteger(evtExtensions[types.ExtensionResourceVersion])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resourceversion extension: %v", err)
+	}
+	// the versions of the published resources are compared to detect the conflicts, so they must be positive
+	if resourceVersion <= 0 {
+		return nil, fmt.Errorf("invalid resourceversion extension %d, it must be a positive integer", resourceVersion)
+	}
+
+	clusterName, err := cloudeventstypes.ToString(evtExtensions[types.ExtensionClusterName])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clustername extension: %v", err)
+	}
+
+	// the cluster name is the namespace of the resource and the resource id is a name of the resource downstream, so
+	// they must follow the kubernetes naming rules
+	if errs := validation.IsDNS1123Label(clusterName); len(errs) != 0 {
+		return nil, fmt.Errorf("invalid clustername extension %q: %s", clusterName, strings.Join(errs, "; "))
+	}
+	if errs := validation.IsDNS1123Subdomain(resourceID); len(errs) != 0 {
+		return nil, fmt.Errorf("invalid resourceid extension %q: %s", resourceID, strings.Join(errs, "; "))
+	}
+
+	resource := &Resource{
+		DataType:        dataType,
+		Source:          evt.Source(),
+		ResourceID:      resourceID,
+		ResourceVersion: int64(resourceVersion),
+		Namespace:       clusterName,
+		EventID:         evt.ID(),
+		EventTime:       evt.Time(),
+	}
+
+	if deletionTimestampValue, exists := evtExtensions[types.ExtensionDeletionTimestamp]; exists {
+		deletionTimestamp, err := cloudeventstypes.ToTime(deletionTimestampValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert deletion timestamp %v to time.Time: %v", deletionTimestampValue, err)
+		}
+		resource.DeletionTimestamp = &metav1.Time{Time: deletionTimestamp}
+	}
+
+	if agentIDValue, exists := evtExtensions[types.ExtensionAgentID]; exists {
+		agentID, err := cloudeventstypes.ToString(agentIDValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get agentid extension: %v", err)
+		}
+		resource.AgentID = agentID
+	}
+
+	resource.ObservedGeneration, err = observedGenerationOf(evtExtensions)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// setObservedGeneration sets the ExtensionObservedGeneration of the event if the resource has an observed generation.
+func setObservedGeneration(evt *cloudevents.Event, resource *Resource) {
+	if resource.ObservedGeneration != 0 {
+		evt.SetExtension(ExtensionObservedGeneration, resource.ObservedGeneration)
+	}
+}
+
+// observedGenerationOf returns the observed generation in the extensions of an event, it is zero if the extension is
+// not set.
+func observedGenerationOf(evtExtensions map[string]interface{}) (int64, error) {
+	value, exists := evtExtensions[ExtensionObservedGeneration]
+	if !exists {
+		return 0, nil
+	}
+
+	observedGeneration, err := cloudeventstypes.ToInteger(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get observedgeneration extension: %v", err)
+	}
+	if observedGeneration < 0 {
+		return 0, fmt.Errorf("invalid observedgeneration extension %d, it must not be negative", observedGeneration)
+	}
+	return int64(observedGeneration), nil
+}
+
+// setEventData encodes the data of the event with the serializer of the content type, the data is encoded in JSON if
+// the content type is empty.
+func setEventData(evt *cloudevents.Event, contentType string, obj interface{}) error {
+	serializer, err := serializerOf(contentType)
+	if err != nil {
+		return err
+	}
+
+	data, err := serializer.marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	if len(contentType) == 0 {
+		contentType = cloudevents.ApplicationJSON
+	}
+	return evt.SetData(contentType, data)
+}
+
+// compressEventData compresses the encoded data of the event with the content encoding and sets the
+// ExtensionContentEncoding of the event, the data is not compressed if the content encoding is empty.
+func compressEventData(evt *cloudevents.Event, contentEncoding string) error {
+	switch contentEncoding {
+	case "":
+		return nil
+	case ContentEncodingGzip:
+		buf := &bytes.Buffer{}
+		writer := gzip.NewWriter(buf)
+		if _, err := writer.Write(evt.Data()); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+
+		if err := evt.SetData(evt.DataContentType(), buf.Bytes()); err != nil {
+			return err
+		}
+		evt.SetExtension(ExtensionContentEncoding, ContentEncodingGzip)
+		return nil
+	default:
+		return fmt.Errorf("unsupported data content encoding %s", contentEncoding)
+	}
+}
+
+// decompressEventData returns a copy of the event with the decompressed data if the event has the
+// ExtensionContentEncoding, otherwise, the event itself is returned.
+func decompressEventData(evt *cloudevents.Event) (*cloudevents.Event, error) {
+	value, ok := evt.Extensions()[ExtensionContentEncoding]
+	if !ok {
+		return evt, nil
+	}
+
+	contentEncoding, err := cloudeventstypes.ToString(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s extension: %v", ExtensionContentEncoding, err)
+	}
+	if contentEncoding != ContentEncodingGzip {
+		return nil, fmt.Errorf("unsupported data content encoding %s", contentEncoding)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(evt.Data()))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxDecompressedDataSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDecompressedDataSize {
+		return nil, fmt.Errorf("the decompressed data exceeds %d bytes", maxDecompressedDataSize)
+	}
+
+	decompressed := evt.Clone()
+	decompressed.DataEncoded = data
+	decompressed.SetExtension(ExtensionContentEncoding, nil)
+	return &decompressed, nil
+}
+
+// eventDataAs decodes the data of the event to the obj with the serializer of the content type of the event, the data
+// is decompressed first if it is compressed. The data of the other content types is decoded by the event itself, e.g.
+// the JSON data whose content type has parameters.
+func eventDataAs(evt *cloudevents.Event, obj interface{}) error {
+	evt, err := decompressEventData(evt)
+	if err != nil {
+		return err
+	}
+
+	serializer, ok := dataSerializers[evt.DataContentType()]
+	if !ok {
+		return evt.DataAs(obj)
+	}
+
+	return serializer.unmarshal(evt.Data(), obj)
+}
@@ -0,0 +1,463 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/work/payload"
+)
+
+func TestManifestCodecEncode(t *testing.T) {
+	deletionTimestamp := metav1.NewTime(time.Now())
+
+	cases := []struct {
+		name                      string
+		deletionTimestamp         *metav1.Time
+		expectedAction            types.EventAction
+		expectedDeletionTimestamp bool
+	}{
+		{
+			name:           "status update",
+			expectedAction: statusUpdateAction,
+		},
+		{
+			name:                      "status delete",
+			deletionTimestamp:         &deletionTimestamp,
+			expectedAction:            statusDeleteAction,
+			expectedDeletionTimestamp: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := NewResource("cluster1", "resource1")
+			res.ResourceVersion = 1
+			res.DeletionTimestamp = c.deletionTimestamp
+
+			evt, err := (&manifestCodec{statusEncoding: statusEncoding{source: "test-source"}}).Encode(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			eventType, err := types.ParseCloudEventsType(evt.Type())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if eventType.Action != c.expectedAction {
+				t.Errorf("expected action %s, but got %s", c.expectedAction, eventType.Action)
+			}
+
+			// decode the status event as the source client does
+			decoded, err := (&ResourceCodec{}).Decode(evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !c.expectedDeletionTimestamp {
+				if decoded.DeletionTimestamp != nil {
+					t.Errorf("unexpected deletion timestamp %v", decoded.DeletionTimestamp)
+				}
+				return
+			}
+			if decoded.DeletionTimestamp == nil {
+				t.Fatalf("expected deletion timestamp, but got nil")
+			}
+			if diff := decoded.DeletionTimestamp.Sub(c.deletionTimestamp.Time); diff > time.Millisecond || diff < -time.Millisecond {
+				t.Errorf("expected deletion timestamp %v, but got %v", c.deletionTimestamp, decoded.DeletionTimestamp)
+			}
+		})
+	}
+}
+
+func TestManifestCodecDecodeMetadata(t *testing.T) {
+	res := NewResource("cluster1", "resource1")
+	res.Spec.SetLabels(map[string]string{"app": "nginx"})
+	res.Spec.SetAnnotations(map[string]string{"owner": "team-a"})
+
+	evt, err := (&ResourceCodec{}).Encode("test-source", testSpecEventType, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := (&manifestCodec{}).Decode(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(decoded.Labels, res.Spec.GetLabels()) {
+		t.Errorf("expected labels %v, but got %v", res.Spec.GetLabels(), decoded.Labels)
+	}
+	if !equality.Semantic.DeepEqual(decoded.Annotations, res.Spec.GetAnnotations()) {
+		t.Errorf("expected annotations %v, but got %v", res.Spec.GetAnnotations(), decoded.Annotations)
+	}
+}
+
+func TestObservedGeneration(t *testing.T) {
+	cases := []struct {
+		name               string
+		observedGeneration int64
+		expectedExtension  bool
+	}{
+		{
+			name: "no observed generation",
+		},
+		{
+			name:               "observed generation",
+			observedGeneration: 3,
+			expectedExtension:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := NewResource("cluster1", "resource1")
+			res.ObservedGeneration = c.observedGeneration
+
+			// the status event of the server is decoded by the source client
+			evt, err := (&manifestCodec{statusEncoding: statusEncoding{source: "test-source"}}).Encode(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := evt.Extensions()[ExtensionObservedGeneration]; ok != c.expectedExtension {
+				t.Errorf("expected the observedgeneration extension %t, but got %v", c.expectedExtension, evt.Extensions())
+			}
+			decoded, err := (&ResourceCodec{}).Decode(evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.ObservedGeneration != c.observedGeneration {
+				t.Errorf("expected observed generation %d of the status event, but got %d",
+					c.observedGeneration, decoded.ObservedGeneration)
+			}
+
+			// the event that is published with the source client is decoded by the server
+			evt, err = (&ResourceCodec{}).Encode("test-source", testSpecEventType, res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err = (&manifestCodec{}).Decode(evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.ObservedGeneration != c.observedGeneration {
+				t.Errorf("expected observed generation %d of the published event, but got %d",
+					c.observedGeneration, decoded.ObservedGeneration)
+			}
+		})
+	}
+}
+
+func TestDecodeWithInvalidObservedGeneration(t *testing.T) {
+	evt, err := (&ResourceCodec{}).Encode("test-source", testSpecEventType, NewResource("cluster1", "resource1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []interface{}{-1, "one"} {
+		evt.SetExtension(ExtensionObservedGeneration, value)
+		if _, err := (&manifestCodec{}).Decode(evt); err == nil {
+			t.Errorf("expected error for the observed generation %v, but failed", value)
+		}
+	}
+}
+
+func TestDecodeWithPayloadVersion(t *testing.T) {
+	res := NewResource("cluster1", "resource1")
+	res.Status.Conditions = []metav1.Condition{
+		{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied", LastTransitionTime: metav1.Now().Rfc3339Copy()},
+	}
+
+	cases := []struct {
+		name          string
+		version       interface{}
+		data          interface{}
+		expectedError bool
+	}{
+		{
+			name: "status of the first version without payload version",
+			data: payload.ManifestStatus{Conditions: res.Status.Conditions},
+		},
+		{
+			name:    "status of v2",
+			version: payload.ManifestStatusVersionV2,
+			data:    payload.ManifestStatusV2{Conditions: res.Status.Conditions},
+		},
+		{
+			name:          "status of unknown version",
+			version:       "v3",
+			data:          payload.ManifestStatus{Conditions: res.Status.Conditions},
+			expectedError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			evt, err := (&manifestCodec{statusEncoding: statusEncoding{source: "test-source"}}).Encode(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.version != nil {
+				evt.SetExtension(types.ExtensionPayloadVersion, c.version)
+			}
+			if err := evt.SetData(cloudevents.ApplicationJSON, c.data); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := (&ResourceCodec{}).Decode(evt)
+			if c.expectedError {
+				if err == nil {
+					t.Errorf("expected error for the payload version %v, but failed", c.version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(decoded.Status.Conditions, res.Status.Conditions) {
+				t.Errorf("expected conditions %v, but got %v", res.Status.Conditions, decoded.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestEventDataContentType(t *testing.T) {
+	cases := []struct {
+		name                    string
+		contentType             string
+		expectedDataContentType string
+	}{
+		{
+			name:                    "default",
+			expectedDataContentType: cloudevents.ApplicationJSON,
+		},
+		{
+			name:                    "json",
+			contentType:             cloudevents.ApplicationJSON,
+			expectedDataContentType: cloudevents.ApplicationJSON,
+		},
+		{
+			name:                    "protobuf",
+			contentType:             ApplicationProtobuf,
+			expectedDataContentType: ApplicationProtobuf,
+		},
+		{
+			name:                    "cbor",
+			contentType:             ApplicationCBOR,
+			expectedDataContentType: ApplicationCBOR,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := NewResource("cluster1", "resource1")
+			res.Spec.Object["data"] = map[string]interface{}{"key": "value", "replicas": int64(3)}
+			res.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied"}}
+
+			// the spec is encoded by the source client and decoded by the server
+			specEvt, err := (&ResourceCodec{DataContentType: c.contentType}).Encode("test-source", types.CloudEventsType{
+				CloudEventsDataType: payload.ManifestEventDataType,
+				SubResource:         types.SubResourceSpec,
+				Action:              "test_create_request",
+			}, res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if specEvt.DataContentType() != c.expectedDataContentType {
+				t.Errorf("expected content type %s, but got %s", c.expectedDataContentType, specEvt.DataContentType())
+			}
+			decodedSpec, err := (&manifestCodec{}).Decode(specEvt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(decodedSpec.Spec, res.Spec) {
+				t.Errorf("expected spec %v, but got %v", res.Spec, decodedSpec.Spec)
+			}
+
+			// the status is encoded by the server and decoded by the source client
+			statusEvt, err := (&manifestCodec{statusEncoding: statusEncoding{
+				source:          "test-source",
+				dataContentType: c.contentType,
+			}}).Encode(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusEvt.DataContentType() != c.expectedDataContentType {
+				t.Errorf("expected content type %s, but got %s", c.expectedDataContentType, statusEvt.DataContentType())
+			}
+			decodedStatus, err := (&ResourceCodec{}).Decode(statusEvt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(decodedStatus.Status.Conditions, res.Status.Conditions) {
+				t.Errorf("expected conditions %v, but got %v", res.Status.Conditions, decodedStatus.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestEventDataContentEncoding(t *testing.T) {
+	cases := []struct {
+		name            string
+		contentType     string
+		contentEncoding string
+	}{
+		{
+			name: "uncompressed",
+		},
+		{
+			name:            "gzip json",
+			contentEncoding: ContentEncodingGzip,
+		},
+		{
+			name:            "gzip protobuf",
+			contentType:     ApplicationProtobuf,
+			contentEncoding: ContentEncodingGzip,
+		},
+	}
+
+	// a large manifest which is compressed well
+	res := NewResource("cluster1", "resource1")
+	data := map[string]interface{}{}
+	for i := 0; i < 1000; i++ {
+		data[fmt.Sprintf("key%d", i)] = strings.Repeat("value", 20)
+	}
+	res.Spec.Object["data"] = data
+	res.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied"}}
+	specEventType := types.CloudEventsType{
+		CloudEventsDataType: payload.ManifestEventDataType,
+		SubResource:         types.SubResourceSpec,
+		Action:              "test_create_request",
+	}
+
+	uncompressed, err := (&ResourceCodec{}).Encode("test-source", specEventType, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// the spec is encoded by the source client and decoded by the server
+			specEvt, err := (&ResourceCodec{DataContentType: c.contentType, DataContentEncoding: c.contentEncoding}).
+				Encode("test-source", specEventType, res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			contentEncoding, ok := specEvt.Extensions()[ExtensionContentEncoding]
+			if len(c.contentEncoding) == 0 {
+				if ok {
+					t.Errorf("expected no content encoding, but got %v", contentEncoding)
+				}
+			} else {
+				if contentEncoding != c.contentEncoding {
+					t.Errorf("expected content encoding %s, but got %v", c.contentEncoding, contentEncoding)
+				}
+				if len(specEvt.Data()) >= len(uncompressed.Data())/10 {
+					t.Errorf("expected the data is compressed, but got %d bytes of %d bytes",
+						len(specEvt.Data()), len(uncompressed.Data()))
+				}
+			}
+
+			decodedSpec, err := (&manifestCodec{}).Decode(specEvt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(decodedSpec.Spec.Object["data"], res.Spec.Object["data"]) {
+				t.Errorf("expected the manifest is decoded")
+			}
+
+			// the status is encoded by the server and decoded by the source client
+			statusEvt, err := (&manifestCodec{statusEncoding: statusEncoding{
+				source:              "test-source",
+				dataContentType:     c.contentType,
+				dataContentEncoding: c.contentEncoding,
+			}}).Encode(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decodedStatus, err := (&ResourceCodec{}).Decode(statusEvt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(decodedStatus.Status.Conditions, res.Status.Conditions) {
+				t.Errorf("expected conditions %v, but got %v", res.Status.Conditions, decodedStatus.Status.Conditions)
+			}
+		})
+	}
+
+	t.Run("unsupported content encoding", func(t *testing.T) {
+		if _, err := (&ResourceCodec{DataContentEncoding: "br"}).Encode("test-source", specEventType, res); err == nil {
+			t.Errorf("expected the unsupported content encoding is rejected")
+		}
+
+		evt := uncompressed.Clone()
+		evt.SetExtension(ExtensionContentEncoding, "br")
+		if _, err := (&manifestCodec{}).Decode(&evt); err == nil {
+			t.Errorf("expected the event of the unsupported content encoding is rejected")
+		}
+	})
+}
+
+func TestDataSerializers(t *testing.T) {
+	lastTransitionTime := metav1.NewTime(time.Now().Truncate(time.Second))
+	status := &manifestStatusData{
+		ManifestStatus: payload.ManifestStatus{Conditions: []metav1.Condition{{
+			Type:               "Applied",
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: 3,
+			LastTransitionTime: lastTransitionTime,
+			Reason:             "Applied",
+			Message:            "the manifest is applied",
+		}}},
+		Manifest: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"data":       map[string]interface{}{"key": "value"},
+			"spec": map[string]interface{}{
+				"replicas": int64(3),
+				"ratio":    0.5,
+				"enabled":  true,
+				"items":    []interface{}{"a", "b"},
+			},
+		},
+	}
+
+	// the status means the same whichever content type it is serialized in
+	decoded := map[string]*manifestStatusData{}
+	for _, contentType := range []string{cloudevents.ApplicationJSON, ApplicationCBOR} {
+		evt := cloudevents.NewEvent()
+		if err := setEventData(&evt, contentType, status); err != nil {
+			t.Fatal(err)
+		}
+		if evt.DataContentType() != contentType {
+			t.Errorf("expected content type %s, but got %s", contentType, evt.DataContentType())
+		}
+
+		decoded[contentType] = &manifestStatusData{}
+		if err := eventDataAs(&evt, decoded[contentType]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(decoded[cloudevents.ApplicationJSON], decoded[ApplicationCBOR]) {
+		t.Errorf("expected the same status in json and cbor, but got %v and %v",
+			decoded[cloudevents.ApplicationJSON], decoded[ApplicationCBOR])
+	}
+	if !equality.Semantic.DeepEqual(decoded[ApplicationCBOR].Conditions, status.Conditions) {
+		t.Errorf("expected conditions %v, but got %v", status.Conditions, decoded[ApplicationCBOR].Conditions)
+	}
+
+	// the cbor data is not json
+	evt := cloudevents.NewEvent()
+	if err := setEventData(&evt, ApplicationCBOR, status); err != nil {
+		t.Fatal(err)
+	}
+	if json.Valid(evt.Data()) {
+		t.Errorf("expected the data is encoded in cbor, but got %s", string(evt.Data()))
+	}
+}
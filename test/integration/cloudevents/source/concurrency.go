@@ -0,0 +1,62 @@
+package source
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// publishLimiter limits the number of the publishes that are executed at the same time, a publish beyond the limit
+// waits for a running one to finish, and it is rejected if too many publishes are waiting already.
+type publishLimiter struct {
+	// slots holds a token for each running publish.
+	slots     chan struct{}
+	queueSize int64
+	// waiting is the number of the publishes that are waiting for a slot.
+	waiting atomic.Int64
+}
+
+func newPublishLimiter(limit, queueSize int) *publishLimiter {
+	return &publishLimiter{
+		slots:     make(chan struct{}, limit),
+		queueSize: int64(queueSize),
+	}
+}
+
+// acquire waits until the publish can be executed, the publishes are not limited if the limiter is nil. The returned
+// errors are grpc status errors, the publish is rejected with the ResourceExhausted code if the queue is full.
+func (l *publishLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if l.waiting.Add(1) > l.queueSize {
+		l.waiting.Add(-1)
+		return status.Error(codes.ResourceExhausted, "too many concurrent publishes, retry later")
+	}
+	defer l.waiting.Add(-1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// release releases the slot of a publish that is acquired.
+func (l *publishLimiter) release() {
+	if l == nil {
+		return
+	}
+
+	<-l.slots
+}
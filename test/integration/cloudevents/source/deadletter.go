@@ -0,0 +1,99 @@
+package source
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+)
+
+// defaultDeadLetterCapacity is the capacity of a DeadLetterStore whose capacity is not positive.
+const defaultDeadLetterCapacity = 100
+
+// DeadLetter is a published event that is rejected by the server, e.g. it is malformed or it cannot be decoded.
+type DeadLetter struct {
+	// Event is the rejected event as it is published, the events that are published over the other transports than
+	// grpc are converted to the protobuf cloudevents. It is nil if the event is not set or cannot be converted.
+	Event *pbv1.CloudEvent
+	// Code and Message are the code and the message of the status error that the event is rejected with.
+	Code    codes.Code
+	Message string
+	// Reason is the reason of the ErrorInfo detail of the status error, e.g. ReasonMissingAttribute, it is empty if
+	// the status error has no ErrorInfo detail.
+	Reason string
+	// Time is the time when the event is rejected.
+	Time time.Time
+}
+
+// DeadLetterStore retains the latest events that are rejected by the server up to its capacity, the oldest one is
+// evicted once it is full, so the rejected events can be inspected after the fact.
+type DeadLetterStore struct {
+	mu       sync.Mutex
+	capacity int
+	letters  []*DeadLetter
+}
+
+// NewDeadLetterStore returns a dead letter store of the capacity, the defaultDeadLetterCapacity is used if the
+// capacity is not positive.
+func NewDeadLetterStore(capacity int) *DeadLetterStore {
+	if capacity <= 0 {
+		capacity = defaultDeadLetterCapacity
+	}
+
+	return &DeadLetterStore{capacity: capacity}
+}
+
+// List returns the retained dead letters in the order that they are rejected.
+func (s *DeadLetterStore) List() []*DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters := make([]*DeadLetter, 0, len(s.letters))
+	for _, letter := range s.letters {
+		copied := *letter
+		letters = append(letters, &copied)
+	}
+	return letters
+}
+
+func (s *DeadLetterStore) add(letter *DeadLetter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.letters) == s.capacity {
+		s.letters[0] = nil
+		s.letters = s.letters[1:]
+	}
+	s.letters = append(s.letters, letter)
+}
+
+// addDeadLetter keeps the published event in the dead letter store if the server has one and the event is rejected
+// with the error for what it is, the events that fail for the other reasons, e.g. the rate limit, are not kept.
+func (svr *GRPCServer) addDeadLetter(pbEvt *pbv1.CloudEvent, err error) {
+	if svr.deadLetters == nil {
+		return
+	}
+
+	st := status.Convert(toStatusError(err))
+	switch st.Code() {
+	case codes.InvalidArgument, codes.Unimplemented, codes.PermissionDenied:
+	default:
+		return
+	}
+
+	letter := &DeadLetter{Code: st.Code(), Message: st.Message(), Time: svr.clock.Now()}
+	if pbEvt != nil {
+		letter.Event = proto.Clone(pbEvt).(*pbv1.CloudEvent)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			letter.Reason = info.Reason
+		}
+	}
+	svr.deadLetters.add(letter)
+}
@@ -0,0 +1,103 @@
+package source
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+)
+
+func TestPublishWithDeadLetterStore(t *testing.T) {
+	deadLetters := NewDeadLetterStore(2)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(),
+		WithDeadLetterStore(deadLetters)))
+	ctx := context.Background()
+
+	// the published events and the rejected events of the dry runs are not retained
+	if _, err := client.Publish(ctx, newPublishRequest(t, NewResource("cluster1", "resource1"))); err != nil {
+		t.Fatal(err)
+	}
+	dryRun := newPublishRequest(t, NewResource("cluster1", "resource2"))
+	dryRun.Event.Id = ""
+	dryRun.DryRun = true
+	if _, err := client.Publish(ctx, dryRun); err == nil {
+		t.Fatal("expected the dry run event without id is rejected, but failed")
+	}
+	if letters := deadLetters.List(); len(letters) != 0 {
+		t.Fatalf("expected no dead letter, but got %v", letters)
+	}
+
+	withoutID := newPublishRequest(t, NewResource("cluster1", "resource3"))
+	withoutID.Event.Id = ""
+	undecodable := newPublishRequest(t, NewResource("cluster1", "resource4"))
+	undecodable.Event.Type = "invalid"
+	withoutSource := newPublishRequest(t, NewResource("cluster1", "resource5"))
+	withoutSource.Event.Source = ""
+
+	publish := func(pubReq *pbv1.PublishRequest) {
+		if _, err := client.Publish(ctx, pubReq); err == nil {
+			t.Fatalf("expected the event %v is rejected, but failed", pubReq.Event)
+		}
+	}
+	expect := func(expected []*pbv1.CloudEvent, expectedReasons []string) {
+		letters := deadLetters.List()
+		if len(letters) != len(expected) {
+			t.Fatalf("expected %d dead letters, but got %d", len(expected), len(letters))
+		}
+		for i, letter := range letters {
+			if !proto.Equal(letter.Event, expected[i]) {
+				t.Errorf("expected the rejected event %v, but got %v", expected[i], letter.Event)
+			}
+			if letter.Code != codes.InvalidArgument || letter.Reason != expectedReasons[i] || len(letter.Message) == 0 {
+				t.Errorf("expected the invalid argument with reason %q, but got %s with reason %q: %s",
+					expectedReasons[i], letter.Code, letter.Reason, letter.Message)
+			}
+			if letter.Time.IsZero() {
+				t.Errorf("expected the time when the event is rejected")
+			}
+		}
+	}
+
+	// the malformed event is retained with the reason that it is rejected
+	publish(withoutID)
+	expect([]*pbv1.CloudEvent{withoutID.Event}, []string{ReasonMissingAttribute})
+
+	// the oldest dead letter is evicted once the store is full, the event that cannot be decoded has no reason
+	publish(undecodable)
+	publish(withoutSource)
+	expect([]*pbv1.CloudEvent{undecodable.Event, withoutSource.Event}, []string{"", ReasonMissingAttribute})
+}
+
+func TestPublishBatchWithDeadLetterStore(t *testing.T) {
+	deadLetters := NewDeadLetterStore(0)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(),
+		WithDeadLetterStore(deadLetters)))
+
+	malformed := newPublishRequest(t, NewResource("cluster1", "resource2")).Event
+	malformed.Id = ""
+
+	stream, err := client.PublishBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pbEvt := range []*pbv1.CloudEvent{newPublishRequest(t, NewResource("cluster1", "resource1")).Event, malformed} {
+		if err := stream.Send(&pbv1.PublishBatchRequest{Event: pbEvt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Succeeded != 1 || len(resp.Failures) != 1 {
+		t.Fatalf("expected 1 succeeded and 1 failed event, but got %v", resp)
+	}
+
+	letters := deadLetters.List()
+	if len(letters) != 1 || !proto.Equal(letters[0].Event, malformed) || letters[0].Reason != ReasonMissingAttribute {
+		t.Errorf("expected the malformed event is retained, but got %v", letters)
+	}
+}
@@ -0,0 +1,65 @@
+package source
+
+import (
+	"time"
+)
+
+// dedupEntry is an applied event that is kept until it is expired.
+type dedupEntry struct {
+	eventID string
+	expiry  time.Time
+}
+
+// dedupCache keeps the IDs of the recently applied events for a time window, at most size events are kept, the
+// oldest events are evicted first once the cache is full.
+type dedupCache struct {
+	ttl  time.Duration
+	size int
+
+	// entries are in the order they are added, since the events share the same ttl, it is also the order they expire.
+	entries []dedupEntry
+	ids     map[string]struct{}
+}
+
+func newDedupCache(ttl time.Duration, size int) *dedupCache {
+	return &dedupCache{
+		ttl:  ttl,
+		size: size,
+		ids:  make(map[string]struct{}),
+	}
+}
+
+// seen returns true if the event is applied within the time window.
+func (c *dedupCache) seen(eventID string, now time.Time) bool {
+	c.evict(now)
+
+	_, ok := c.ids[eventID]
+	return ok
+}
+
+// add keeps the event for the time window, the event is ignored if it is already kept.
+func (c *dedupCache) add(eventID string, now time.Time) {
+	if c.seen(eventID, now) {
+		return
+	}
+
+	if len(c.entries) >= c.size {
+		c.remove()
+	}
+
+	c.entries = append(c.entries, dedupEntry{eventID: eventID, expiry: now.Add(c.ttl)})
+	c.ids[eventID] = struct{}{}
+}
+
+// evict removes the expired events.
+func (c *dedupCache) evict(now time.Time) {
+	for len(c.entries) > 0 && !now.Before(c.entries[0].expiry) {
+		c.remove()
+	}
+}
+
+// remove removes the oldest event.
+func (c *dedupCache) remove() {
+	delete(c.ids, c.entries[0].eventID)
+	c.entries = c.entries[1:]
+}
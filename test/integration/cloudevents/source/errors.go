@@ -0,0 +1,143 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var (
+	// ErrDecode is returned when a cloudevent cannot be decoded to a resource, e.g. the event or its data is
+	// malformed.
+	ErrDecode = errors.New("failed to decode cloudevent")
+
+	// ErrEncode is returned when a resource cannot be encoded to a cloudevent.
+	ErrEncode = errors.New("failed to encode resource")
+
+	// ErrUnsupportedDataType is returned when there is no codec for the data type of a cloudevent or a resource.
+	ErrUnsupportedDataType = errors.New("unsupported cloudevents data type")
+
+	// ErrTooManyConditions is returned when the status of a resource has more conditions than the server accepts.
+	ErrTooManyConditions = errors.New("too many conditions")
+)
+
+// toStatusError converts the error of handling a request to a grpc status error by the class of the error, the grpc
+// status errors are returned as they are.
+func toStatusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, ErrResourceNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrStaleResourceVersion):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrUnsupportedDataType):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, ErrDecode):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrEncode):
+		return status.Error(codes.Internal, err.Error())
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Unknown, err.Error())
+	}
+}
+
+// SubscriptionErrorDomain is the domain of the ErrorInfo details of the status errors that end a subscription, the
+// status errors that reject a malformed published event are in the same domain.
+const SubscriptionErrorDomain = "cloudevents.open-cluster-management.io"
+
+// The reasons of the ErrorInfo details of the status errors that end a subscription, they tell the subscriber the
+// class of the failure.
+const (
+	// ReasonSubscriptionClosed means the subscription is closed by the server, e.g. the server is shutting down.
+	ReasonSubscriptionClosed = "SUBSCRIPTION_CLOSED"
+	// ReasonSubscriberNotAlive means the subscriber does not receive the events within the liveness timeout.
+	ReasonSubscriberNotAlive = "SUBSCRIBER_NOT_ALIVE"
+	// ReasonSubscriberTooSlow means the events are queued for the subscriber faster than it receives them.
+	ReasonSubscriberTooSlow = "SUBSCRIBER_TOO_SLOW"
+	// ReasonTooManySubscribers means the server has the maximum number of the subscribers, the subscriber should
+	// back off and subscribe again.
+	ReasonTooManySubscribers = "TOO_MANY_SUBSCRIBERS"
+	// ReasonResumeTokenExpired means the events after the resume token are no longer kept, the subscriber should
+	// subscribe without the token to resync.
+	ReasonResumeTokenExpired = "RESUME_TOKEN_EXPIRED"
+	// ReasonSubscriptionFailed means the events cannot be sent to the subscriber for other reasons.
+	ReasonSubscriptionFailed = "SUBSCRIPTION_FAILED"
+)
+
+// ResubscribeMetadataKey is the key of the ErrorInfo metadata that tells if the subscriber is advised to subscribe
+// again, its value is "true" or "false".
+const ResubscribeMetadataKey = "resubscribe"
+
+// resubscribeDelay is the delay of the RetryInfo details, so the subscribers do not subscribe again at once.
+const resubscribeDelay = time.Second
+
+// toSubscriptionError attaches the reason of a subscription failure to the status error that ends the subscription
+// as an ErrorInfo detail. If the subscriber is advised to subscribe again, a RetryInfo detail with the delay before
+// resubscribing is attached too.
+func toSubscriptionError(err error, reason string, resubscribe bool) error {
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   SubscriptionErrorDomain,
+		Metadata: map[string]string{ResubscribeMetadataKey: strconv.FormatBool(resubscribe)},
+	}}
+	if resubscribe {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(resubscribeDelay)})
+	}
+
+	st, detailsErr := status.Convert(toStatusError(err)).WithDetails(details...)
+	if detailsErr != nil {
+		// the details are always valid messages, the status is returned without them just in case
+		return toStatusError(err)
+	}
+	return st.Err()
+}
+
+// The reasons of the ErrorInfo details of the status errors that reject a malformed published event, they tell the
+// publisher what is malformed. The status errors are always of the InvalidArgument code.
+const (
+	// ReasonEventNotSet means the publish request has no event.
+	ReasonEventNotSet = "EVENT_NOT_SET"
+	// ReasonMissingAttribute means a required attribute of the event, e.g. the id or the source, is not set.
+	ReasonMissingAttribute = "MISSING_ATTRIBUTE"
+	// ReasonInvalidAttribute means an attribute of the event is set, but its value is invalid.
+	ReasonInvalidAttribute = "INVALID_ATTRIBUTE"
+	// ReasonInvalidExtension means the name or the value of an extension of the event is invalid.
+	ReasonInvalidExtension = "INVALID_EXTENSION"
+	// ReasonMalformedEvent means the event cannot be converted to a cloudevent for other reasons.
+	ReasonMalformedEvent = "MALFORMED_EVENT"
+)
+
+// AttributeMetadataKey is the key of the ErrorInfo metadata that tells the attribute or the extension of a malformed
+// event that is rejected, it is not set if the event is not rejected for an attribute.
+const AttributeMetadataKey = "attribute"
+
+// toMalformedEventError returns the InvalidArgument status error that rejects a malformed published event, the reason
+// and the attribute of the failure are attached as an ErrorInfo detail.
+func toMalformedEventError(reason, attribute string, err error) error {
+	errorInfo := &errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: SubscriptionErrorDomain,
+	}
+	if len(attribute) != 0 {
+		errorInfo.Metadata = map[string]string{AttributeMetadataKey: attribute}
+	}
+
+	st, detailsErr := status.New(codes.InvalidArgument, err.Error()).WithDetails(protoadapt.MessageV1(errorInfo))
+	if detailsErr != nil {
+		// the details are always valid messages, the status is returned without them just in case
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return st.Err()
+}
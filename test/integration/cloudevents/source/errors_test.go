@@ -0,0 +1,102 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatusError(t *testing.T) {
+	cases := []struct {
+		name         string
+		err          error
+		expectedErr  error
+		expectedCode codes.Code
+	}{
+		{
+			name:         "resource not found",
+			err:          fmt.Errorf("%w: resource1", ErrResourceNotFound),
+			expectedErr:  ErrResourceNotFound,
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "stale resource version",
+			err:          fmt.Errorf("failed to upsert resource: %w", ErrStaleResourceVersion),
+			expectedErr:  ErrStaleResourceVersion,
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:         "unsupported data type",
+			err:          fmt.Errorf("%w: %w test", ErrDecode, ErrUnsupportedDataType),
+			expectedErr:  ErrUnsupportedDataType,
+			expectedCode: codes.Unimplemented,
+		},
+		{
+			name:         "decode",
+			err:          fmt.Errorf("%w: malformed data", ErrDecode),
+			expectedErr:  ErrDecode,
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "encode",
+			err:          fmt.Errorf("%w resource1: malformed status", ErrEncode),
+			expectedErr:  ErrEncode,
+			expectedCode: codes.Internal,
+		},
+		{
+			name:         "context canceled",
+			err:          fmt.Errorf("failed to upsert resource: %w", context.Canceled),
+			expectedErr:  context.Canceled,
+			expectedCode: codes.Canceled,
+		},
+		{
+			name:         "status error",
+			err:          status.Error(codes.PermissionDenied, "denied"),
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "unknown error",
+			err:          errors.New("unknown"),
+			expectedCode: codes.Unknown,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.expectedErr != nil && !errors.Is(c.err, c.expectedErr) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, c.err)
+			}
+
+			err := toStatusError(c.err)
+			st, ok := status.FromError(err)
+			if !ok {
+				t.Fatalf("expected status error, but got %v", err)
+			}
+			if st.Code() != c.expectedCode {
+				t.Errorf("expected %s code, but got %s", c.expectedCode, st.Code())
+			}
+		})
+	}
+}
+
+func TestToSubscriptionError(t *testing.T) {
+	err := toSubscriptionError(status.Error(codes.Internal, "the stream is broken"), ReasonSubscriptionFailed, false)
+
+	st := status.Convert(err)
+	if st.Code() != codes.Internal {
+		t.Errorf("expected the code is kept, but got %v", err)
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("expected only the error info detail, but got %v", details)
+	}
+	errorInfo, ok := details[0].(*errdetails.ErrorInfo)
+	if !ok || errorInfo.Reason != ReasonSubscriptionFailed || errorInfo.Metadata[ResubscribeMetadataKey] != "false" {
+		t.Errorf("unexpected error info %v", details[0])
+	}
+}
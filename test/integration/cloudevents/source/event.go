@@ -2,63 +2,620 @@ package source
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
 )
 
+const defaultSubscriberBufferSize = 1024
+
+// eventsPerTurn is the max number of the events of a client that a worker handles before it turns to the other
+// clients, so a busy client does not starve them.
+const eventsPerTurn = 64
+
+// ExtensionSubscriptionSequence is the extension of the status events that carries the sequence of the event in the
+// subscription, the sequences of a subscription start from 1 and are contiguous, so a subscriber knows it misses
+// events when there is a gap, e.g. the events are dropped because the subscriber is slow.
+const ExtensionSubscriptionSequence = "subscriptionseq"
+
+// ErrSubscriberBufferFull is sent to the error channel of a client when its buffer is full and the client is
+// disconnected by the DisconnectSlowSubscriber policy.
+var ErrSubscriberBufferFull = errors.New("the subscriber buffer is full")
+
+// ErrSubscriberNotAlive is sent to the error channel of a client when an event is not handled by the client within the
+// liveness timeout, e.g. the network of the subscriber is dead and the sends to it are blocked, the client is
+// unregistered by the event broadcaster at the same time.
+var ErrSubscriberNotAlive = errors.New("the subscriber does not handle the events in time")
+
+// ErrTooManySubscribers is sent to the error channel of a client when it is rejected since the event broadcaster has
+// the maximum number of the clients that is set by WithMaxSubscribers.
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+// ErrClientNotFound is returned when a client is not registered to the event broadcaster.
+var ErrClientNotFound = errors.New("the client is not found")
+
+// SlowSubscriberPolicy decides what the event broadcaster does when the buffer of a client is full.
+type SlowSubscriberPolicy string
+
+const (
+	// DropOldest drops the oldest event in the buffer of the client to make room for the new event.
+	DropOldest SlowSubscriberPolicy = "DropOldest"
+
+	// DisconnectSlowSubscriber drops the new event and disconnects the client with ErrSubscriberBufferFull.
+	DisconnectSlowSubscriber SlowSubscriberPolicy = "Disconnect"
+)
+
+// SourceFilter decides whether the events of a source are delivered to a client.
+type SourceFilter func(source string) bool
+
+// NewSourceFilter creates a SourceFilter from a comma separated list of source patterns, the syntax of the patterns
+// is the same as path.Match, e.g. "source1,cluster-*" matches the source "source1" and the sources that have the
+// "cluster-" prefix.
+func NewSourceFilter(expr string) (SourceFilter, error) {
+	patterns := []string{}
+	for _, pattern := range strings.Split(expr, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if len(pattern) == 0 {
+			continue
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid source pattern %q: %v", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("the source filter %q is empty", expr)
+	}
+
+	return func(source string) bool {
+		for _, pattern := range patterns {
+			// the patterns are validated, the error can be ignored
+			if matched, _ := path.Match(pattern, source); matched {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
 // resourceHandler is a function that can handle resource status change events.
 type resourceHandler func(res *Resource) error
 
+// resourceBatchHandler is a function that can handle a batch of resource status change events, the events are in
+// the order that they are broadcast.
+type resourceBatchHandler func(resources []*Resource) error
+
 // eventClient is a client that can receive and handle resource status change events.
 type eventClient struct {
-	source  string
+	// id is the id of the client, a random id is generated if it is not specified.
+	id      string
+	filter  SourceFilter
 	handler resourceHandler
-	errChan chan<- error
+	errChan chan error
+
+	// clusterName scopes the events of the client to a cluster, the events of all clusters are handled if it is
+	// empty.
+	clusterName string
+	// dataType scopes the events of the client to the resources of the data type, the events of all data types are
+	// handled if it is empty.
+	dataType types.CloudEventsDataType
+	// conditionType scopes the events of the client to the ones that change the condition of the type, all of the
+	// events are handled if it is empty.
+	conditionType string
+	// labelSelector scopes the events of the client to the resources whose labels match it, the events of all
+	// resources are handled if it is nil.
+	labelSelector labels.Selector
+
+	// snapshot lists the current resources for the client, they are handled before the live events.
+	snapshot func() []*Resource
+	// snapshotDone is called once the snapshot is handled.
+	snapshotDone func() error
+	// resync lists the current resources for the client when it is resynced, the client cannot be resynced if it is
+	// not set.
+	resync func() []*Resource
+	// resyncs signals the client to resync, a resync that is requested while another one is pending is coalesced
+	// into it.
+	resyncs chan struct{}
+
+	// batchHandler handles the live events of the client in batches instead of the handler if it is set, a batch
+	// has at most batchSize events and it is handled once the batchLinger elapses after its first event.
+	batchHandler resourceBatchHandler
+	batchSize    int
+	batchLinger  time.Duration
+
+	// registry is the registry that the client is registered to.
+	registry *clientRegistry
+
+	// buffer holds the events that are not handled by the client yet.
+	buffer chan bufferedEvent
+	// dropped is the number of events that are dropped for the client.
+	dropped atomic.Uint64
+	// sequence is the sequence of the last event that is handled or dropped for the client, a dropped event takes a
+	// sequence too, so the client can find the gap.
+	sequence atomic.Uint64
+	// offered is the outbound number of the last event that is offered to the client by the fan out, whether it is
+	// buffered or dropped, it is only accessed by the goroutine of Start. taken is the outbound number of the last
+	// event that is taken from the buffer, it is only accessed by the goroutine or the worker that handles the events
+	// of the client. gaps is the number of the gaps between the taken outbound numbers, gapHandler is called for each.
+	offered    uint64
+	taken      uint64
+	gaps       atomic.Uint64
+	gapHandler func(res *Resource, missed uint64)
+
+	// versions is the version of the last handled event of each resource, it is only accessed by the goroutine or the
+	// worker that handles the events of the client.
+	versions map[string]int64
+	// conditions is the condition of the conditionType of the last handled event of each resource, it is only
+	// accessed by the goroutine or the worker that handles the events of the client.
+	conditions map[string]metav1.Condition
+
+	done    chan struct{}
+	stopped chan struct{}
+
+	// runMu is held while the client is handled by a worker of the pool, so the client is stopped after the worker
+	// returns. snapshotHandled and finished are only accessed with it held.
+	runMu sync.Mutex
+	// snapshotHandled is true once the snapshot of the client is handled by a worker.
+	snapshotHandled bool
+	// finished is true once the client fails or is unregistered, its events are no longer handled.
+	finished bool
+	stopOnce sync.Once
+
+	// handlingSince is the time in unix nanoseconds since when the handler is handling an event, it is zero if the
+	// handler is not handling any event. clock is the clock of the event broadcaster that the time is taken from and
+	// the batches linger with.
+	handlingSince atomic.Int64
+	clock         clock.Clock
+}
+
+// bufferedEvent is an event in the buffer of a client with its outbound number, the outbound numbers are given to the
+// events in the order that they are offered to the client.
+type bufferedEvent struct {
+	res      *Resource
+	outbound uint64
+}
+
+// clientRegistry holds the clients of a cluster, the events of a cluster are only fanned out to the clients of its
+// registry and the clients that are not scoped to a cluster, so the clients of the other clusters are not blocked.
+type clientRegistry struct {
+	mu sync.RWMutex
+
+	// key is the cluster name of the registry in the event broadcaster.
+	key string
+
+	clients map[string]*eventClient
 }
 
 // EventBroadcaster is a component that can broadcast resource status change events to registered clients.
 type EventBroadcaster struct {
+	// mu guards the clients, the registries, the dropped handler, the clock and the replay buffers, the lock of a
+	// registry is acquired after it.
 	mu sync.RWMutex
 
 	// registered clients.
 	clients map[string]*eventClient
+	// registries are the registries of the clients by the cluster names, the clients that are not scoped to a
+	// cluster are in the registry of the empty cluster name.
+	registries map[string]*clientRegistry
+	// singleRegistry registers all of the clients to one registry.
+	singleRegistry bool
 
 	// inbound messages from the clients.
 	broadcast chan *Resource
+
+	bufferSize int
+	policy     SlowSubscriberPolicy
+
+	// droppedHandler is called when an event is dropped for a client.
+	droppedHandler func(res *Resource)
+	// gapHandler is called when the events before an event that is taken by a client are not all taken, the missed is
+	// the number of the events that are missed before it.
+	gapHandler func(res *Resource, missed uint64)
+
+	// workers is the number of the goroutines that handle the events of the clients, each client has its own
+	// goroutine if it is zero. queue holds the clients that have events to handle for the workers.
+	workers int
+	queue   workqueue.Interface
+
+	// replayBufferSize is the number of the recent events that are kept for each source, the events are not kept if
+	// it is zero.
+	replayBufferSize int
+	// sequence is the sequence of the last broadcast event.
+	sequence      uint64
+	replayBuffers map[string]*replayBuffer
+
+	// maxSubscribers is the maximum number of the registered clients, the clients are not limited if it is zero.
+	maxSubscribers int
+
+	// livenessTimeout is how long a client can take to handle an event before it is unregistered as not alive, the
+	// liveness of the clients is not checked if it is zero.
+	livenessTimeout time.Duration
+
+	// clock is the clock that the liveness of the clients is checked with and the batches of the clients linger with,
+	// it is set to the clock of the server.
+	clock clock.Clock
+}
+
+// EventBroadcasterOption configures the EventBroadcaster.
+type EventBroadcasterOption func(*EventBroadcaster)
+
+// WithSubscriberBufferSize sets the number of events that can be buffered for each client, the default is 1024.
+func WithSubscriberBufferSize(size int) EventBroadcasterOption {
+	return func(eb *EventBroadcaster) {
+		eb.bufferSize = size
+	}
+}
+
+// WithSlowSubscriberPolicy sets the policy for the clients whose buffers are full, the default is DropOldest.
+func WithSlowSubscriberPolicy(policy SlowSubscriberPolicy) EventBroadcasterOption {
+	return func(eb *EventBroadcaster) {
+		eb.policy = policy
+	}
+}
+
+// WithReplayBufferSize keeps the given number of the recent events for each source, so a client can resume from the
+// last event it handled with RegisterWithResume. The events are not kept by default.
+func WithReplayBufferSize(size int) EventBroadcasterOption {
+	return func(eb *EventBroadcaster) {
+		eb.replayBufferSize = size
+	}
+}
+
+// WithWorkers handles the events of all of the clients with a pool of the given number of workers instead of a
+// goroutine for each client, so the goroutines and their memory are bounded by the pool rather than by the clients.
+// The events of a client are still handled in order by one worker at a time, and a worker turns to the other clients
+// after handling some events of a client. The workers are started by Start, a batch that lingers for more events
+// holds its worker in the meantime.
+func WithWorkers(workers int) EventBroadcasterOption {
+	return func(eb *EventBroadcaster) {
+		eb.workers = workers
+	}
+}
+
+// WithLivenessTimeout unregisters the clients that do not handle an event within the timeout, e.g. the network of a
+// subscriber dies silently and the sends to it are blocked, so the clients that are not alive do not hold their events
+// forever. The ErrSubscriberNotAlive is sent to the error channel of such a client before it is unregistered, its
+// handler is not waited for, since it may never return. The clients are checked every half of the timeout by Start,
+// the liveness of the clients is not checked by default.
+func WithLivenessTimeout(timeout time.Duration) EventBroadcasterOption {
+	return func(eb *EventBroadcaster) {
+		eb.livenessTimeout = timeout
+	}
+}
+
+// WithMaxSubscribers limits the number of the clients that are registered at the same time, so the subscriptions
+// cannot exhaust the resources of the server. A client that is registered beyond the limit is rejected, its id is
+// empty and the ErrTooManySubscribers is sent to its error channel, and a client can be registered again once another
+// one is unregistered. The clients are not limited by default.
+func WithMaxSubscribers(max int) EventBroadcasterOption {
+	return func(eb *EventBroadcaster) {
+		eb.maxSubscribers = max
+	}
+}
+
+// withSingleRegistry registers all of the clients to one registry, the events are fanned out to all of the clients.
+func withSingleRegistry() EventBroadcasterOption {
+	return func(eb *EventBroadcaster) {
+		eb.singleRegistry = true
+	}
 }
 
 // NewEventBroadcaster creates a new event broadcaster.
-func NewEventBroadcaster() *EventBroadcaster {
-	return &EventBroadcaster{
-		clients:   make(map[string]*eventClient),
-		broadcast: make(chan *Resource),
+func NewEventBroadcaster(opts ...EventBroadcasterOption) *EventBroadcaster {
+	eb := &EventBroadcaster{
+		clients:       make(map[string]*eventClient),
+		registries:    make(map[string]*clientRegistry),
+		broadcast:     make(chan *Resource),
+		bufferSize:    defaultSubscriberBufferSize,
+		policy:        DropOldest,
+		replayBuffers: make(map[string]*replayBuffer),
+		clock:         clock.RealClock{},
+	}
+
+	for _, opt := range opts {
+		opt(eb)
+	}
+
+	if eb.workers > 0 {
+		eb.queue = workqueue.New()
+	}
+
+	return eb
+}
+
+// RegisterOption configures a client when it is registered to the EventBroadcaster.
+type RegisterOption func(*eventClient)
+
+// WithClusterName scopes the client to the events of the given cluster, the cluster of an event is the namespace of
+// its resource.
+func WithClusterName(clusterName string) RegisterOption {
+	return func(c *eventClient) {
+		c.clusterName = clusterName
 	}
 }
 
-// Register registers a client for source and return client id and error channel.
-func (eb *EventBroadcaster) Register(source string, handler resourceHandler) (string, <-chan error) {
+// WithDataType scopes the client to the events of the resources of the given data type, the resources without data
+// type are the manifests.
+func WithDataType(dataType types.CloudEventsDataType) RegisterOption {
+	return func(c *eventClient) {
+		c.dataType = dataType
+	}
+}
+
+// WithConditionType scopes the client to the events that change the condition of the given type, the condition is
+// changed when it is added, removed, or its status, reason, message or observed generation is changed. The event of a
+// deleted resource is handled if the condition of the resource is handled before.
+func WithConditionType(conditionType string) RegisterOption {
+	return func(c *eventClient) {
+		c.conditionType = conditionType
+	}
+}
+
+// WithLabelSelector scopes the client to the events of the resources whose labels match the selector, the labels of a
+// resource are the labels of its manifest.
+func WithLabelSelector(selector labels.Selector) RegisterOption {
+	return func(c *eventClient) {
+		c.labelSelector = selector
+	}
+}
+
+// WithBatchHandler coalesces the live events that are buffered for the client into batches and handles them with the
+// batch handler instead of the handler, so a high event rate costs one call per batch rather than per event. A batch
+// has at most size events, it is handled once it is full or the linger elapses after its first event. The events
+// of the snapshot are still handled one by one with the handler.
+func WithBatchHandler(handler resourceBatchHandler, size int, linger time.Duration) RegisterOption {
+	return func(c *eventClient) {
+		c.batchHandler = handler
+		c.batchSize = size
+		c.batchLinger = linger
+	}
+}
+
+// WithResync enables the client to be resynced with Resync, the resources returned by the list are handled like a
+// snapshot, e.g. the current resources of the client in the store.
+func WithResync(list func() []*Resource) RegisterOption {
+	return func(c *eventClient) {
+		c.resync = list
+	}
+}
+
+// withClientID registers the client with the given id, a generated id is used instead if the given id is already
+// registered.
+func withClientID(id string) RegisterOption {
+	return func(c *eventClient) {
+		c.id = id
+	}
+}
+
+// Register registers a client for the sources that match the filter and return client id and error channel. Each
+// registration has a distinct client id, even if its filter is the same as the filter of another client. The events
+// of the client are buffered and handled in its own goroutine, so a slow client does not block the other clients.
+// The events of a resource are handled in the order of their versions, an event whose version is older than the last
+// handled event of the same resource is skipped.
+func (eb *EventBroadcaster) Register(filter SourceFilter, handler resourceHandler,
+	opts ...RegisterOption) (string, <-chan error) {
+	return eb.RegisterWithSnapshot(filter, nil, nil, handler, opts...)
+}
+
+// RegisterWithSnapshot registers a client like Register, but the resources returned by the snapshot are handled
+// before the live events, and the snapshotDone is called between them, so the client knows where the snapshot ends.
+// The snapshot is taken after the client is registered, so no live event is missed in the meantime. The resources of
+// the snapshot that the client is not scoped to are skipped.
+func (eb *EventBroadcaster) RegisterWithSnapshot(filter SourceFilter, snapshot func() []*Resource,
+	snapshotDone func() error, handler resourceHandler, opts ...RegisterOption) (string, <-chan error) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	return eb.register(filter, snapshot, snapshotDone, handler, opts...)
+}
+
+// RegisterWithResume registers a client like Register, but the kept events after the sequence are handled before the
+// live events, and the resumed is called between them. If the events after the sequence are no longer kept, the
+// client is not registered and ErrResumeTokenExpired is returned.
+func (eb *EventBroadcaster) RegisterWithResume(filter SourceFilter, sequence uint64, resumed func() error,
+	handler resourceHandler, opts ...RegisterOption) (string, <-chan error, error) {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
 
-	id := uuid.NewString()
-	errChan := make(chan error)
-	eb.clients[id] = &eventClient{
-		source:  source,
-		handler: handler,
-		errChan: errChan,
+	// the events are replayed with the lock held, so no event is missed or handled twice before the client is
+	// registered
+	events, err := eb.replay(filter, sequence)
+	if err != nil {
+		return "", nil, err
 	}
 
-	return id, errChan
+	id, errChan := eb.register(filter, func() []*Resource { return events }, resumed, handler, opts...)
+	return id, errChan, nil
 }
 
-// Unregister unregisters a client by id
+// register must be called with the lock held.
+func (eb *EventBroadcaster) register(filter SourceFilter, snapshot func() []*Resource,
+	snapshotDone func() error, handler resourceHandler, opts ...RegisterOption) (string, <-chan error) {
+	if eb.maxSubscribers > 0 && len(eb.clients) >= eb.maxSubscribers {
+		// the client is rejected without being registered, so there is nothing to unregister
+		errChan := make(chan error, 1)
+		errChan <- ErrTooManySubscribers
+		return "", errChan
+	}
+
+	client := &eventClient{
+		filter:       filter,
+		handler:      handler,
+		errChan:      make(chan error, 1),
+		snapshot:     snapshot,
+		snapshotDone: snapshotDone,
+		resyncs:      make(chan struct{}, 1),
+		buffer:       make(chan bufferedEvent, eb.bufferSize),
+		versions:     make(map[string]int64),
+		conditions:   make(map[string]metav1.Condition),
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+		gapHandler:   eb.gapHandler,
+		clock:        eb.clock,
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	// each registration has its own id, so the clients of the same source are handled and unregistered independently
+	id := client.id
+	if _, ok := eb.clients[id]; ok || len(id) == 0 {
+		id = uuid.NewString()
+		client.id = id
+	}
+
+	key := client.clusterName
+	if eb.singleRegistry {
+		key = ""
+	}
+	registry, ok := eb.registries[key]
+	if !ok {
+		registry = &clientRegistry{key: key, clients: make(map[string]*eventClient)}
+		eb.registries[key] = registry
+	}
+	client.registry = registry
+
+	registry.mu.Lock()
+	registry.clients[id] = client
+	registry.mu.Unlock()
+	eb.clients[id] = client
+
+	if eb.queue != nil {
+		// the snapshot is handled by a worker
+		eb.queue.Add(client)
+	} else {
+		go eb.handle(id, client)
+	}
+
+	return id, client.errChan
+}
+
+// Unregister unregisters a client by id and waits for the client to stop handling events.
 func (eb *EventBroadcaster) Unregister(id string) {
+	eb.mu.Lock()
+	client, ok := eb.clients[id]
+	if !ok {
+		eb.mu.Unlock()
+		return
+	}
+
+	eb.unregister(id, client)
+	eb.mu.Unlock()
+
+	if eb.queue != nil {
+		// the client is stopped once the worker that is handling it returns
+		client.runMu.Lock()
+		client.stop()
+		client.runMu.Unlock()
+	}
+
+	<-client.stopped
+}
+
+// UnregisterAll unregisters all of the registered clients, the error channels of the clients are closed.
+func (eb *EventBroadcaster) UnregisterAll() {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
 
-	close(eb.clients[id].errChan)
-	delete(eb.clients, id)
+	for id, client := range eb.clients {
+		eb.unregister(id, client)
+	}
+}
+
+// Resync resyncs the client by id, the resources of its resync are handled as if the client is just registered, so
+// they are handled even if the client has handled them, and then the snapshotDone of the client is called. The resync
+// is handled after the events that are being handled, it returns once the resync is requested. ErrClientNotFound is
+// returned if the client is not registered.
+func (eb *EventBroadcaster) Resync(id string) error {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	client, ok := eb.clients[id]
+	if !ok {
+		return ErrClientNotFound
+	}
+	if client.resync == nil {
+		return fmt.Errorf("the client %s cannot be resynced", id)
+	}
+
+	select {
+	case client.resyncs <- struct{}{}:
+		eb.schedule(client)
+	default:
+		// a resync is pending, the resources are listed once it is handled, so it covers this one
+	}
+	return nil
+}
+
+// DroppedEvents returns the number of events that are dropped for the client because its buffer is full.
+func (eb *EventBroadcaster) DroppedEvents(id string) uint64 {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	client, ok := eb.clients[id]
+	if !ok {
+		return 0
+	}
+
+	return client.dropped.Load()
+}
+
+// SequenceGaps returns the number of the gaps in the outbound sequence of the events that are offered to the client,
+// the events of a gap are offered by the fan out but never taken by the client, e.g. they are dropped since the client
+// is slow or they are lost inside the event broadcaster, so a gap indicates an overload or a bug of the event
+// broadcaster.
+func (eb *EventBroadcaster) SequenceGaps(id string) uint64 {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	client, ok := eb.clients[id]
+	if !ok {
+		return 0
+	}
+
+	return client.gaps.Load()
+}
+
+// setGapHandler sets the handler that is called when a gap in the outbound sequence of a client is found, it is used by
+// the clients that are registered after it is set.
+func (eb *EventBroadcaster) setGapHandler(handler func(res *Resource, missed uint64)) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.gapHandler = handler
+}
+
+// setDroppedHandler sets the handler that is called when an event is dropped for a client.
+func (eb *EventBroadcaster) setDroppedHandler(handler func(res *Resource)) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.droppedHandler = handler
+}
+
+// setClock sets the clock that the liveness of the clients is checked with and the batches of the clients linger with.
+// The clients that are registered before keep the previous clock, and the liveness checks are timed by the clock when
+// Start is called.
+func (eb *EventBroadcaster) setClock(clock clock.Clock) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.clock = clock
 }
 
 // Broadcast broadcasts a resource status change event to all registered clients.
@@ -66,22 +623,502 @@ func (eb *EventBroadcaster) Broadcast(res *Resource) {
 	eb.broadcast <- res
 }
 
-// Start starts the event broadcaster and waits for events to broadcast.
+// Start starts the event broadcaster and waits for events to broadcast, the workers of the pool are started too if
+// the pool is enabled, they are stopped once the context is done.
 func (eb *EventBroadcaster) Start(ctx context.Context) {
+	if eb.queue != nil {
+		defer eb.queue.ShutDown()
+		for i := 0; i < eb.workers; i++ {
+			go eb.runWorker()
+		}
+	}
+
+	// the liveness check is a timer that is reset after each check, since the clock of the server has no ticker
+	var livenessCheck <-chan time.Time
+	var livenessTimer clock.Timer
+	if eb.livenessTimeout > 0 {
+		eb.mu.RLock()
+		livenessTimer = eb.clock.NewTimer(eb.livenessTimeout / 2)
+		eb.mu.RUnlock()
+		defer livenessTimer.Stop()
+		livenessCheck = livenessTimer.C()
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case res := <-eb.broadcast:
-			eb.mu.RLock()
-			for _, client := range eb.clients {
-				if client.source == res.Source {
-					if err := client.handler(res); err != nil {
-						client.errChan <- err
-					}
-				}
+			eb.fanOut(res)
+		case <-livenessCheck:
+			eb.unregisterNotAlive()
+			livenessTimer.Reset(eb.livenessTimeout / 2)
+		}
+	}
+}
+
+// unregisterNotAlive unregisters the clients whose handlers are handling an event for longer than the liveness
+// timeout, the ErrSubscriberNotAlive is sent to them first. The time is taken from the clock rather than the tick,
+// so it is the same clock that the clients are tracked with even if the clock is set after Start.
+func (eb *EventBroadcaster) unregisterNotAlive() {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	now := eb.clock.Now()
+
+	for id, client := range eb.clients {
+		if !client.notAlive(now, eb.livenessTimeout) {
+			continue
+		}
+
+		client.registry.mu.RLock()
+		client.sendErr(ErrSubscriberNotAlive)
+		client.registry.mu.RUnlock()
+		eb.unregister(id, client)
+	}
+}
+
+// fanOut enqueues the event to the clients of the registry of its cluster and the clients that are not scoped to a
+// cluster.
+func (eb *EventBroadcaster) fanOut(res *Resource) {
+	eb.mu.Lock()
+	res = eb.record(res)
+	droppedHandler := eb.droppedHandler
+
+	keys := []string{""}
+	if len(res.Namespace) != 0 {
+		keys = append(keys, res.Namespace)
+	}
+	registries := make([]*clientRegistry, 0, len(keys))
+	for _, key := range keys {
+		if registry, ok := eb.registries[key]; ok {
+			registries = append(registries, registry)
+		}
+	}
+
+	// the locks of the registries are acquired before the lock of the broadcaster is released, so a client that is
+	// registered after the event is recorded does not receive it, e.g. it is replayed to a resumed client.
+	for _, registry := range registries {
+		registry.mu.RLock()
+	}
+	eb.mu.Unlock()
+
+	for _, registry := range registries {
+		for _, client := range registry.clients {
+			if client.matches(res) {
+				eb.enqueue(client, res, droppedHandler)
 			}
-			eb.mu.RUnlock()
 		}
+		registry.mu.RUnlock()
+	}
+}
+
+// enqueue adds the event to the buffer of the client without blocking, if the buffer is full, the event is handled
+// by the slow subscriber policy. The event takes the next outbound number of the client, whether it is buffered or not.
+// It must be called with the lock of the registry of the client held.
+func (eb *EventBroadcaster) enqueue(client *eventClient, res *Resource, droppedHandler func(res *Resource)) {
+	client.offered++
+	ev := bufferedEvent{res: res, outbound: client.offered}
+
+	select {
+	case client.buffer <- ev:
+		eb.schedule(client)
+		return
+	default:
+	}
+
+	switch eb.policy {
+	case DisconnectSlowSubscriber:
+		client.drop(res, droppedHandler)
+		client.sendErr(ErrSubscriberBufferFull)
+	default:
+		// drop the oldest event for the new one, the buffer may be drained by the client in the meantime, so nothing
+		// is evicted, or it may be full again, so the new event is dropped too.
+		select {
+		case oldest := <-client.buffer:
+			client.drop(oldest.res, droppedHandler)
+		default:
+		}
+
+		select {
+		case client.buffer <- ev:
+			eb.schedule(client)
+		default:
+			client.drop(res, droppedHandler)
+		}
+	}
+}
+
+// drop counts the event that is dropped for the client, the dropped event takes a sequence of the client and the
+// droppedHandler is called with it.
+func (c *eventClient) drop(res *Resource, droppedHandler func(res *Resource)) {
+	c.dropped.Add(1)
+	c.sequence.Add(1)
+	if droppedHandler != nil {
+		droppedHandler(res)
+	}
+}
+
+// schedule adds the client to the queue of the workers if the pool is enabled, a client that is already queued or
+// being handled is queued once.
+func (eb *EventBroadcaster) schedule(client *eventClient) {
+	if eb.queue != nil {
+		eb.queue.Add(client)
+	}
+}
+
+// runWorker handles the clients of the queue until the queue is shut down, a client that still has events after its
+// turn is queued again.
+func (eb *EventBroadcaster) runWorker() {
+	for {
+		item, shutdown := eb.queue.Get()
+		if shutdown {
+			return
+		}
+
+		client := item.(*eventClient)
+		if eb.process(client) {
+			eb.queue.Add(client)
+		}
+		eb.queue.Done(client)
+	}
+}
+
+// process handles the snapshot, the resyncs and at most eventsPerTurn buffered events of the client in a worker. It
+// returns true if the client may have more events to handle.
+func (eb *EventBroadcaster) process(client *eventClient) bool {
+	client.runMu.Lock()
+	defer client.runMu.Unlock()
+
+	if client.finished {
+		return false
+	}
+
+	if !client.snapshotHandled {
+		client.snapshotHandled = true
+		if !eb.handleSnapshot(client.id, client, client.listSnapshot()) {
+			client.finish()
+			return false
+		}
+	}
+
+	for i := 0; i < eventsPerTurn; i++ {
+		select {
+		case <-client.done:
+			client.finish()
+			return false
+		case <-client.resyncs:
+			if !eb.handleResync(client.id, client) {
+				client.finish()
+				return false
+			}
+		case ev := <-client.buffer:
+			if !eb.handleEvent(client.id, client, ev) {
+				client.finish()
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// handle handles the snapshot and then the buffered events of the client until the client is unregistered or the
+// handler fails.
+func (eb *EventBroadcaster) handle(id string, client *eventClient) {
+	defer client.stop()
+
+	if !eb.handleSnapshot(id, client, client.listSnapshot()) {
+		return
+	}
+
+	for {
+		select {
+		case <-client.done:
+			return
+		case <-client.resyncs:
+			if !eb.handleResync(id, client) {
+				return
+			}
+		case ev := <-client.buffer:
+			if !eb.handleEvent(id, client, ev) {
+				return
+			}
+		}
+	}
+}
+
+// handleEvent handles the buffered event of the client, or a batch that starts with it if the client handles the
+// events in batches. It returns false if the client fails.
+func (eb *EventBroadcaster) handleEvent(id string, client *eventClient, ev bufferedEvent) bool {
+	res := client.take(ev)
+
+	var err error
+	if client.batchHandler != nil {
+		err = client.handleBatch(client.nextBatch(res))
+	} else {
+		err = client.handle(res)
+	}
+	if err != nil {
+		eb.reportErr(id, client, err)
+		return false
+	}
+	return true
+}
+
+// handleResync handles the resources of the resync of the client like a snapshot, the handled resources are forgotten
+// first, so the resources of the resync are not skipped as unchanged. It returns false if the client is unregistered
+// or fails in the meantime.
+func (eb *EventBroadcaster) handleResync(id string, client *eventClient) bool {
+	client.versions = make(map[string]int64)
+	client.conditions = make(map[string]metav1.Condition)
+	return eb.handleSnapshot(id, client, client.resync())
+}
+
+// handleSnapshot handles the resources of a snapshot that the client is scoped to, and then calls the snapshotDone of
+// the client. It returns false if the client is unregistered or fails in the meantime.
+func (eb *EventBroadcaster) handleSnapshot(id string, client *eventClient, snapshot []*Resource) bool {
+	for _, res := range snapshot {
+		select {
+		case <-client.done:
+			return false
+		default:
+		}
+
+		if !client.matches(res) {
+			continue
+		}
+
+		if err := client.handle(res); err != nil {
+			eb.reportErr(id, client, err)
+			return false
+		}
+	}
+
+	if client.snapshotDone != nil {
+		if err := client.track(client.snapshotDone); err != nil {
+			eb.reportErr(id, client, err)
+			return false
+		}
+	}
+	return true
+}
+
+// reportErr sends the error to the client if the client is still registered.
+func (eb *EventBroadcaster) reportErr(id string, client *eventClient, err error) {
+	client.registry.mu.RLock()
+	defer client.registry.mu.RUnlock()
+
+	if client.registry.clients[id] == client {
+		client.sendErr(err)
+	}
+}
+
+// unregister must be called with the lock held.
+func (eb *EventBroadcaster) unregister(id string, client *eventClient) {
+	registry := client.registry
+	registry.mu.Lock()
+	close(client.done)
+	close(client.errChan)
+	delete(registry.clients, id)
+	registry.mu.Unlock()
+
+	delete(eb.clients, id)
+	if len(registry.clients) == 0 {
+		delete(eb.registries, registry.key)
+	}
+}
+
+// listSnapshot returns the snapshot of the client, it is empty if the client has no snapshot.
+func (c *eventClient) listSnapshot() []*Resource {
+	if c.snapshot == nil {
+		return nil
+	}
+	return c.snapshot()
+}
+
+// finish stops handling the events of the client in the worker pool, it must be called with the runMu held.
+func (c *eventClient) finish() {
+	c.finished = true
+	c.stop()
+}
+
+// stop closes the stopped channel of the client once.
+func (c *eventClient) stop() {
+	c.stopOnce.Do(func() { close(c.stopped) })
+}
+
+// matches returns true if the event of the resource should be handled by the client.
+func (c *eventClient) matches(res *Resource) bool {
+	if !c.filter(res.Source) {
+		return false
+	}
+
+	if len(c.clusterName) != 0 && c.clusterName != res.Namespace {
+		return false
+	}
+
+	if c.labelSelector != nil && !c.labelSelector.Matches(labels.Set(res.Labels)) {
+		return false
+	}
+
+	return c.dataType == (types.CloudEventsDataType{}) || c.dataType == resourceDataType(res)
+}
+
+// nextBatch collects the buffered events of the client into a batch that starts with the first event, until the
+// batch is full, the linger elapses or the client is unregistered. The events that are already buffered are
+// collected without waiting for the linger.
+func (c *eventClient) nextBatch(first *Resource) []*Resource {
+	batch := []*Resource{first}
+	timer := c.clock.NewTimer(c.batchLinger)
+	defer timer.Stop()
+
+	for len(batch) < c.batchSize {
+		select {
+		case ev := <-c.buffer:
+			batch = append(batch, c.take(ev))
+			continue
+		default:
+		}
+
+		select {
+		case ev := <-c.buffer:
+			batch = append(batch, c.take(ev))
+		case <-timer.C():
+			return batch
+		case <-c.done:
+			return batch
+		}
+	}
+	return batch
+}
+
+// handle handles the event of the resource if it is accepted by the client.
+func (c *eventClient) handle(res *Resource) error {
+	handled, ok := c.accept(res)
+	if !ok {
+		return nil
+	}
+
+	return c.track(func() error { return c.handler(handled) })
+}
+
+// handleBatch handles the events of the batch that are accepted by the client with one call of the batch handler,
+// nothing is handled if none of them is accepted.
+func (c *eventClient) handleBatch(batch []*Resource) error {
+	handled := make([]*Resource, 0, len(batch))
+	for _, res := range batch {
+		if accepted, ok := c.accept(res); ok {
+			handled = append(handled, accepted)
+		}
+	}
+
+	if len(handled) == 0 {
+		return nil
+	}
+
+	return c.track(func() error { return c.batchHandler(handled) })
+}
+
+// track calls the handle and records since when the client is handling, so the liveness of the client can be
+// checked while the handle is blocked.
+func (c *eventClient) track(handle func() error) error {
+	c.handlingSince.Store(c.clock.Now().UnixNano())
+	defer c.handlingSince.Store(0)
+
+	return handle()
+}
+
+// notAlive returns true if the client is handling an event for longer than the timeout.
+func (c *eventClient) notAlive(now time.Time, timeout time.Duration) bool {
+	since := c.handlingSince.Load()
+	return since != 0 && now.Sub(time.Unix(0, since)) > timeout
+}
+
+// accept returns a copy of the resource with the sequence of the client unless the client has handled a newer
+// version of the resource, e.g. a live event that is buffered while the snapshot is taken is older than the resource
+// in the snapshot. The events of the same version are still accepted, since the status of a resource can be changed
+// without changing its version. The events that do not change the condition of the client are skipped too. The
+// skipped events do not take a sequence, since they are not missed by the client.
+//
+// The event is recorded as handled once it is accepted, so the later events of the same batch are compared with it.
+// The client stops once a handler fails, so the events that are accepted but not handled are not compared again.
+func (c *eventClient) accept(res *Resource) (*Resource, bool) {
+	if version, ok := c.versions[res.ResourceID]; ok && res.ResourceVersion < version {
+		return nil, false
+	}
+
+	condition, changed := c.conditionChanged(res)
+	if !changed {
+		return nil, false
+	}
+
+	if !res.GetDeletionTimestamp().IsZero() {
+		// the resource is deleted, it may be created again from the first version
+		delete(c.versions, res.ResourceID)
+		delete(c.conditions, res.ResourceID)
+	} else {
+		c.versions[res.ResourceID] = res.ResourceVersion
+		if condition != nil {
+			c.conditions[res.ResourceID] = *condition
+		} else {
+			delete(c.conditions, res.ResourceID)
+		}
+	}
+
+	// the resource is shared by the clients, the sequence is set to a copy of it
+	handled := *res
+	handled.SubscriptionSequence = c.sequence.Add(1)
+	return &handled, true
+}
+
+// take returns the resource of the event that is taken from the buffer, a gap is found if the events that are offered
+// before it are not all taken, e.g. they are dropped or lost inside the event broadcaster. The events that are taken
+// but not accepted are not missed.
+func (c *eventClient) take(ev bufferedEvent) *Resource {
+	if ev.outbound > c.taken+1 {
+		c.gaps.Add(1)
+		if c.gapHandler != nil {
+			c.gapHandler(ev.res, ev.outbound-c.taken-1)
+		}
+	}
+
+	c.taken = ev.outbound
+	return ev.res
+}
+
+// conditionChanged returns the condition of the conditionType in the resource and whether it is changed since the
+// last handled event of the resource. The events always change the condition if the conditionType is not set.
+func (c *eventClient) conditionChanged(res *Resource) (*metav1.Condition, bool) {
+	if len(c.conditionType) == 0 {
+		return nil, true
+	}
+
+	last, handled := c.conditions[res.ResourceID]
+	if !res.GetDeletionTimestamp().IsZero() {
+		return nil, handled
+	}
+
+	condition := meta.FindStatusCondition(res.Status.Conditions, c.conditionType)
+	if condition == nil {
+		// the condition is removed if it is handled before
+		return nil, handled
+	}
+
+	if !handled {
+		return condition, true
+	}
+
+	return condition, condition.Status != last.Status || condition.Reason != last.Reason ||
+		condition.Message != last.Message || condition.ObservedGeneration != last.ObservedGeneration
+}
+
+// sendErr sends the error to the client without blocking, only the first error is kept. It must be called with
+// the lock of the registry of the client held, so the error channel is not closed in the meantime.
+func (c *eventClient) sendErr(err error) {
+	select {
+	case c.errChan <- err:
+	default:
 	}
 }
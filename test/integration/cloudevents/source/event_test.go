@@ -0,0 +1,1037 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestBroadcastWithSlowSubscriber(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy SlowSubscriberPolicy
+	}{
+		{
+			name:   "drop oldest",
+			policy: DropOldest,
+		},
+		{
+			name:   "disconnect",
+			policy: DisconnectSlowSubscriber,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			eb := NewEventBroadcaster(WithSubscriberBufferSize(2), WithSlowSubscriberPolicy(c.policy))
+			go eb.Start(ctx)
+
+			// the slow subscriber never reads the events
+			block := make(chan struct{})
+			slowID, slowErrChan := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+				<-block
+				return nil
+			})
+			defer func() {
+				close(block)
+				eb.Unregister(slowID)
+			}()
+
+			received := make(chan *Resource)
+			fastID, _ := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+				received <- res
+				return nil
+			})
+			defer eb.Unregister(fastID)
+
+			// the next event is broadcast once the fast subscriber receives the last one, the broadcaster must
+			// not be blocked by the slow subscriber.
+			for i := 0; i < 10; i++ {
+				res := NewResource("cluster1", fmt.Sprintf("resource%d", i))
+				res.Source = "test-source"
+				eb.Broadcast(res)
+
+				select {
+				case got := <-received:
+					if got.ResourceID != res.ResourceID {
+						t.Errorf("expected resource %s, but got %s", res.ResourceID, got.ResourceID)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("the fast subscriber is blocked by the slow subscriber")
+				}
+			}
+
+			if eb.DroppedEvents(slowID) == 0 {
+				t.Errorf("expected dropped events for the slow subscriber")
+			}
+			if eb.DroppedEvents(fastID) != 0 {
+				t.Errorf("unexpected dropped events %d for the fast subscriber", eb.DroppedEvents(fastID))
+			}
+
+			if c.policy != DisconnectSlowSubscriber {
+				return
+			}
+
+			select {
+			case err := <-slowErrChan:
+				if !errors.Is(err, ErrSubscriberBufferFull) {
+					t.Errorf("expected buffer full error, but got %v", err)
+				}
+			default:
+				t.Errorf("expected the slow subscriber is disconnected")
+			}
+		})
+	}
+}
+
+func mustSourceFilter(t *testing.T, expr string) SourceFilter {
+	filter, err := NewSourceFilter(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filter
+}
+
+func TestBroadcastWithSourceFilter(t *testing.T) {
+	cases := []struct {
+		name          string
+		filter        string
+		source        string
+		expectedEvent bool
+	}{
+		{
+			name:          "exact match",
+			filter:        "cluster-1",
+			source:        "cluster-1",
+			expectedEvent: true,
+		},
+		{
+			name:          "prefix match",
+			filter:        "cluster-*",
+			source:        "cluster-1",
+			expectedEvent: true,
+		},
+		{
+			name:          "match one of the sources",
+			filter:        "source1, cluster-1",
+			source:        "cluster-1",
+			expectedEvent: true,
+		},
+		{
+			name:   "no match",
+			filter: "cluster-*",
+			source: "source1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			eb := NewEventBroadcaster()
+			go eb.Start(ctx)
+
+			received := make(chan *Resource, 1)
+			id, _ := eb.Register(mustSourceFilter(t, c.filter), func(res *Resource) error {
+				received <- res
+				return nil
+			})
+			defer eb.Unregister(id)
+
+			res := NewResource("cluster1", "resource1")
+			res.Source = c.source
+			eb.Broadcast(res)
+
+			select {
+			case <-received:
+				if !c.expectedEvent {
+					t.Errorf("unexpected event from source %s", c.source)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if c.expectedEvent {
+					t.Errorf("expected event from source %s, but got nothing", c.source)
+				}
+			}
+		})
+	}
+}
+
+func TestBroadcastWithSameSource(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []EventBroadcasterOption
+	}{
+		{
+			name: "goroutine per client",
+		},
+		{
+			name: "worker pool",
+			opts: []EventBroadcasterOption{WithWorkers(2)},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			eb := NewEventBroadcaster(c.opts...)
+			go eb.Start(ctx)
+
+			register := func(opts ...RegisterOption) (string, chan *Resource) {
+				received := make(chan *Resource, 10)
+				id, _ := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+					received <- res
+					return nil
+				}, opts...)
+				return id, received
+			}
+			id1, received1 := register()
+			// the id of a registered client is not reused
+			id2, received2 := register(withClientID(id1))
+			if len(id2) == 0 || id1 == id2 {
+				t.Fatalf("expected distinct client ids, but got %q and %q", id1, id2)
+			}
+
+			expectEvent := func(received chan *Resource, resourceID string) {
+				select {
+				case res := <-received:
+					if res.ResourceID != resourceID {
+						t.Errorf("expected event of %s, but got %s", resourceID, res.ResourceID)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("expected event of %s, but got nothing", resourceID)
+				}
+			}
+
+			res := NewResource("cluster1", "resource1")
+			res.Source = "test-source"
+			eb.Broadcast(res)
+			expectEvent(received1, res.ResourceID)
+			expectEvent(received2, res.ResourceID)
+
+			// unregistering a client does not affect the other client of the same source
+			eb.Unregister(id1)
+			res = NewResource("cluster1", "resource2")
+			res.Source = "test-source"
+			eb.Broadcast(res)
+			expectEvent(received2, res.ResourceID)
+			select {
+			case res := <-received1:
+				t.Errorf("unexpected event of %s after the client is unregistered", res.ResourceID)
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			eb.Unregister(id2)
+			eb.mu.RLock()
+			defer eb.mu.RUnlock()
+			if len(eb.clients) != 0 {
+				t.Errorf("expected no clients, but got %d", len(eb.clients))
+			}
+		})
+	}
+}
+
+func TestBroadcastWithDeadSubscriber(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []EventBroadcasterOption
+	}{
+		{
+			name: "goroutine per client",
+		},
+		{
+			name: "worker pool",
+			opts: []EventBroadcasterOption{WithWorkers(2)},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			eb := NewEventBroadcaster(append(c.opts, WithLivenessTimeout(100*time.Millisecond))...)
+			go eb.Start(ctx)
+
+			// the handler of the dead subscriber is blocked, like the sends to a subscriber whose network is dead
+			blocked := make(chan struct{})
+			defer close(blocked)
+			deadID, deadErrs := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+				<-blocked
+				return nil
+			})
+			received := make(chan *Resource, 10)
+			aliveID, aliveErrs := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+				received <- res
+				return nil
+			})
+			defer eb.Unregister(aliveID)
+
+			res := NewResource("cluster1", "resource1")
+			res.Source = "test-source"
+			eb.Broadcast(res)
+
+			select {
+			case err := <-deadErrs:
+				if !errors.Is(err, ErrSubscriberNotAlive) {
+					t.Errorf("expected the subscriber is not alive, but got %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected the dead subscriber is unregistered")
+			}
+			waitForSubscribers(t, eb, 1)
+
+			eb.mu.RLock()
+			_, deadRegistered := eb.clients[deadID]
+			eb.mu.RUnlock()
+			if deadRegistered {
+				t.Errorf("expected the dead subscriber is unregistered")
+			}
+
+			// the alive subscriber still receives the events, it is not unregistered while it is idle
+			time.Sleep(200 * time.Millisecond)
+			res = NewResource("cluster1", "resource2")
+			res.Source = "test-source"
+			eb.Broadcast(res)
+			for _, resourceID := range []string{ResourceID("cluster1", "resource1"), ResourceID("cluster1", "resource2")} {
+				select {
+				case got := <-received:
+					if got.ResourceID != resourceID {
+						t.Errorf("expected event of %s, but got %s", resourceID, got.ResourceID)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("expected event of %s, but got nothing", resourceID)
+				}
+			}
+			select {
+			case err := <-aliveErrs:
+				t.Errorf("unexpected error of the alive subscriber %v", err)
+			default:
+			}
+		})
+	}
+}
+
+func TestRegisterWithMaxSubscribers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eb := NewEventBroadcaster(WithMaxSubscribers(2))
+	go eb.Start(ctx)
+
+	handler := func(res *Resource) error { return nil }
+	ids := []string{}
+	for i := 0; i < 2; i++ {
+		id, _ := eb.Register(mustSourceFilter(t, "test-source"), handler)
+		if len(id) == 0 {
+			t.Fatalf("expected the subscriber %d is registered", i)
+		}
+		ids = append(ids, id)
+	}
+
+	id, errChan := eb.Register(mustSourceFilter(t, "test-source"), handler)
+	if len(id) != 0 {
+		t.Errorf("expected the subscriber beyond the limit is rejected, but got id %s", id)
+	}
+	if err := <-errChan; !errors.Is(err, ErrTooManySubscribers) {
+		t.Errorf("expected too many subscribers, but got %v", err)
+	}
+	waitForSubscribers(t, eb, 2)
+
+	// the subscriber can be registered once another one is unregistered
+	eb.Unregister(ids[0])
+	id, errChan = eb.Register(mustSourceFilter(t, "test-source"), handler)
+	if len(id) == 0 {
+		t.Fatalf("expected the subscriber is registered after another one is unregistered")
+	}
+	defer eb.Unregister(id)
+	select {
+	case err := <-errChan:
+		t.Errorf("unexpected error of the registered subscriber %v", err)
+	default:
+	}
+	waitForSubscribers(t, eb, 2)
+}
+
+func TestBroadcastWithClusterName(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eb := NewEventBroadcaster()
+	go eb.Start(ctx)
+
+	clusters := []string{"cluster1", "cluster2"}
+	received := map[string]chan *Resource{}
+	for _, cluster := range clusters {
+		ch := make(chan *Resource, 10)
+		received[cluster] = ch
+		id, _ := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+			ch <- res
+			return nil
+		}, WithClusterName(cluster))
+		defer eb.Unregister(id)
+	}
+
+	for i := 0; i < 2; i++ {
+		for _, cluster := range clusters {
+			res := NewResource(cluster, fmt.Sprintf("resource%d", i))
+			res.Source = "test-source"
+			eb.Broadcast(res)
+		}
+	}
+
+	for _, cluster := range clusters {
+		for i := 0; i < 2; i++ {
+			select {
+			case res := <-received[cluster]:
+				if res.Namespace != cluster {
+					t.Errorf("expected event of cluster %s, but got %s", cluster, res.Namespace)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected event %d of cluster %s, but got nothing", i, cluster)
+			}
+		}
+
+		select {
+		case res := <-received[cluster]:
+			t.Errorf("unexpected event of cluster %s for cluster %s", res.Namespace, cluster)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func TestBroadcastWithClusterRegistries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eb := NewEventBroadcaster()
+	go eb.Start(ctx)
+
+	allReceived := make(chan *Resource, 10)
+	allID, _ := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+		allReceived <- res
+		return nil
+	})
+	defer eb.Unregister(allID)
+
+	clusterReceived := make(chan *Resource, 10)
+	clusterID, _ := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+		clusterReceived <- res
+		return nil
+	}, WithClusterName("cluster1"))
+
+	for _, cluster := range []string{"cluster1", "cluster2"} {
+		res := NewResource(cluster, "resource1")
+		res.Source = "test-source"
+		eb.Broadcast(res)
+	}
+
+	// the client that is not scoped to a cluster receives the events of all clusters
+	for _, expected := range []string{"cluster1", "cluster2"} {
+		select {
+		case res := <-allReceived:
+			if res.Namespace != expected {
+				t.Errorf("expected event of cluster %s, but got %s", expected, res.Namespace)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected event of cluster %s, but got nothing", expected)
+		}
+	}
+	select {
+	case res := <-clusterReceived:
+		if res.Namespace != "cluster1" {
+			t.Errorf("expected event of cluster cluster1, but got %s", res.Namespace)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected event of cluster cluster1, but got nothing")
+	}
+
+	// the registry of a cluster is removed once its last client is unregistered
+	eb.Unregister(clusterID)
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	if _, ok := eb.registries["cluster1"]; ok {
+		t.Errorf("expected the registry of cluster1 is removed")
+	}
+	if _, ok := eb.registries[""]; !ok {
+		t.Errorf("expected the registry of the clients that are not scoped to a cluster is kept")
+	}
+}
+
+// BenchmarkBroadcastWithClusters broadcasts the events of many clusters while the clients of the clusters register
+// and unregister in parallel, the per cluster registries only fan out an event to the clients of its cluster, so the
+// broadcasts and the registrations of the other clusters do not block each other.
+func BenchmarkBroadcastWithClusters(b *testing.B) {
+	cases := []struct {
+		name string
+		opts []EventBroadcasterOption
+	}{
+		{
+			name: "cluster registries",
+		},
+		{
+			name: "single registry",
+			opts: []EventBroadcasterOption{withSingleRegistry()},
+		},
+	}
+
+	for _, clusters := range []int{10, 1000} {
+		for _, c := range cases {
+			b.Run(fmt.Sprintf("%s with %d clusters", c.name, clusters), func(b *testing.B) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				eb := NewEventBroadcaster(c.opts...)
+				go eb.Start(ctx)
+
+				filter := func(string) bool { return true }
+				handler := func(*Resource) error { return nil }
+				resources := make([]*Resource, clusters)
+				for i := range resources {
+					cluster := fmt.Sprintf("cluster%d", i)
+					id, _ := eb.Register(filter, handler, WithClusterName(cluster))
+					defer eb.Unregister(id)
+
+					resources[i] = NewResource(cluster, "resource1")
+					resources[i].Source = "test-source"
+				}
+
+				var next atomic.Int64
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						res := resources[int(next.Add(1))%clusters]
+						id, _ := eb.Register(filter, handler, WithClusterName(res.Namespace))
+						eb.Broadcast(res)
+						eb.Unregister(id)
+					}
+				})
+			})
+		}
+	}
+}
+
+func TestBroadcastWithBatchHandler(t *testing.T) {
+	const (
+		events    = 50
+		batchSize = 8
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eb := NewEventBroadcaster()
+	go eb.Start(ctx)
+
+	// the events are queued while the first batch is blocked, so the next batches are full
+	block := make(chan struct{})
+	batches := make(chan []*Resource, events)
+	id, errChan := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+		return fmt.Errorf("unexpected event of resource %s out of a batch", res.ResourceID)
+	}, WithBatchHandler(func(resources []*Resource) error {
+		batches <- resources
+		<-block
+		return nil
+	}, batchSize, 100*time.Millisecond))
+	defer eb.Unregister(id)
+
+	for i := 1; i <= events; i++ {
+		// the events of a resource are handled in the order of their versions, the stale one is skipped
+		version := NewResource("cluster1", fmt.Sprintf("resource%d", i%3))
+		version.Source = "test-source"
+		version.ResourceVersion = int64(i)
+		eb.Broadcast(version)
+	}
+	stale := NewResource("cluster1", "resource1")
+	stale.Source = "test-source"
+	eb.Broadcast(stale)
+	close(block)
+
+	received := []*Resource{}
+	for len(received) < events {
+		select {
+		case batch := <-batches:
+			if len(batch) == 0 || len(batch) > batchSize {
+				t.Errorf("expected a batch of 1 to %d events, but got %d", batchSize, len(batch))
+			}
+			received = append(received, batch...)
+		case err := <-errChan:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d events, but got %d", events, len(received))
+		}
+	}
+
+	for i, res := range received {
+		if res.ResourceVersion != int64(i+1) {
+			t.Errorf("expected the event %d is version %d, but got %d", i, i+1, res.ResourceVersion)
+		}
+		if res.SubscriptionSequence != uint64(i+1) {
+			t.Errorf("expected the event %d has sequence %d, but got %d", i, i+1, res.SubscriptionSequence)
+		}
+	}
+
+	select {
+	case batch := <-batches:
+		t.Errorf("unexpected batch of %d events after the stale event is skipped", len(batch))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestBroadcastWithBatchLinger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	eb := NewEventBroadcaster()
+	eb.setClock(fakeClock)
+	go eb.Start(ctx)
+
+	batches := make(chan []*Resource, 1)
+	id, _ := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+		return fmt.Errorf("unexpected event of resource %s out of a batch", res.ResourceID)
+	}, WithBatchHandler(func(resources []*Resource) error {
+		batches <- resources
+		return nil
+	}, 10, time.Minute))
+	defer eb.Unregister(id)
+
+	res := NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	eb.Broadcast(res)
+
+	// the batch lingers for more events until the linger elapses on the clock
+	err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true,
+		func(ctx context.Context) (bool, error) {
+			return fakeClock.HasWaiters(), nil
+		})
+	if err != nil {
+		t.Fatalf("expected the batch lingers, %v", err)
+	}
+	select {
+	case batch := <-batches:
+		t.Fatalf("unexpected batch of %d events before the linger elapses", len(batch))
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	fakeClock.Step(time.Minute)
+	select {
+	case batch := <-batches:
+		if len(batch) != 1 || batch[0].ResourceID != res.ResourceID {
+			t.Errorf("expected a batch of %s, but got %v", res.ResourceID, batch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the batch is handled once the linger elapses")
+	}
+}
+
+func TestBroadcastWithWorkers(t *testing.T) {
+	const (
+		clients   = 20
+		snapshots = 5
+		events    = 200
+	)
+
+	for _, workers := range []int{1, 3} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			eb := NewEventBroadcaster(WithWorkers(workers))
+			go eb.Start(ctx)
+
+			snapshot := []*Resource{}
+			for i := 0; i < snapshots; i++ {
+				res := NewResource("cluster1", fmt.Sprintf("snapshot%d", i))
+				res.Source = "test-source"
+				snapshot = append(snapshot, res)
+			}
+
+			// each client receives the names of the resources of its events, the snapshot done is received as "done"
+			received := make([]chan string, clients)
+			ids := make([]string, clients)
+			for i := range received {
+				ch := make(chan string, snapshots+1+events)
+				received[i] = ch
+				ids[i], _ = eb.RegisterWithSnapshot(mustSourceFilter(t, "test-source"),
+					func() []*Resource { return snapshot },
+					func() error {
+						ch <- "done"
+						return nil
+					},
+					func(res *Resource) error {
+						ch <- res.Spec.GetName()
+						return nil
+					})
+			}
+
+			for i := 0; i < events; i++ {
+				res := NewResource("cluster1", fmt.Sprintf("resource%d", i))
+				res.Source = "test-source"
+				eb.Broadcast(res)
+			}
+
+			expected := []string{}
+			for _, res := range snapshot {
+				expected = append(expected, res.Spec.GetName())
+			}
+			expected = append(expected, "done")
+			for i := 0; i < events; i++ {
+				expected = append(expected, fmt.Sprintf("resource%d", i))
+			}
+
+			// none of the events is lost or reordered, even if the clients share a few workers
+			for i, ch := range received {
+				for j, name := range expected {
+					select {
+					case got := <-ch:
+						if got != name {
+							t.Fatalf("expected the event %d of the client %d is %s, but got %s", j, i, name, got)
+						}
+					case <-time.After(5 * time.Second):
+						t.Fatalf("expected the event %d of the client %d is %s, but got nothing", j, i, name)
+					}
+				}
+
+				if dropped := eb.DroppedEvents(ids[i]); dropped != 0 {
+					t.Errorf("unexpected %d dropped events of the client %d", dropped, i)
+				}
+			}
+
+			// the clients are stopped by the workers
+			for _, id := range ids {
+				eb.Unregister(id)
+			}
+		})
+	}
+}
+
+// BenchmarkBroadcastWithWorkers broadcasts the events to many clients, the clients share a few workers instead of a
+// goroutine for each, so the goroutines and the memory of the clients are bounded by the pool. An op is an event
+// that is handled by all of the clients.
+func BenchmarkBroadcastWithWorkers(b *testing.B) {
+	const clients = 10000
+
+	cases := []struct {
+		name string
+		opts []EventBroadcasterOption
+	}{
+		{
+			name: "goroutine per client",
+		},
+		{
+			name: "4 workers",
+			opts: []EventBroadcasterOption{WithWorkers(4)},
+		},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var before runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			goroutines := runtime.NumGoroutine()
+
+			eb := NewEventBroadcaster(append([]EventBroadcasterOption{WithSubscriberBufferSize(16)}, c.opts...)...)
+			go eb.Start(ctx)
+
+			filter := func(string) bool { return true }
+			handler := func(*Resource) error { return nil }
+			for i := 0; i < clients; i++ {
+				id, _ := eb.Register(filter, handler)
+				defer eb.Unregister(id)
+			}
+
+			var after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			clientGoroutines := runtime.NumGoroutine() - goroutines
+			clientMemory := after.HeapInuse + after.StackInuse - before.HeapInuse - before.StackInuse
+
+			eb.mu.RLock()
+			registered := make([]*eventClient, 0, clients)
+			for _, client := range eb.clients {
+				registered = append(registered, client)
+			}
+			eb.mu.RUnlock()
+
+			res := NewResource("cluster1", "resource1")
+			res.Source = "test-source"
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				eb.Broadcast(res)
+			}
+
+			// an event of a client is either handled or dropped
+			for _, client := range registered {
+				for client.sequence.Load() < uint64(b.N) {
+					time.Sleep(time.Millisecond)
+				}
+			}
+
+			b.ReportMetric(float64(clientGoroutines), "goroutines")
+			b.ReportMetric(float64(clientMemory)/clients, "B/client")
+		})
+	}
+}
+
+func TestBroadcastInResourceVersionOrder(t *testing.T) {
+	const versions = 100
+
+	cases := []struct {
+		name string
+		// broadcast broadcasts the versions of the resource, the snapshot of the client is returned if it is not nil.
+		broadcast        func(eb *EventBroadcaster, res *Resource) []*Resource
+		expectedVersions []int64
+	}{
+		{
+			name: "live events",
+			broadcast: func(eb *EventBroadcaster, res *Resource) []*Resource {
+				for i := 1; i <= versions; i++ {
+					eb.Broadcast(withVersion(res, int64(i)))
+				}
+				return nil
+			},
+		},
+		{
+			name: "live events that are older than the snapshot",
+			broadcast: func(eb *EventBroadcaster, res *Resource) []*Resource {
+				// the live events are buffered while the snapshot is taken
+				for i := 1; i <= versions; i++ {
+					eb.Broadcast(withVersion(res, int64(i)))
+				}
+				return []*Resource{withVersion(res, versions)}
+			},
+			expectedVersions: []int64{versions, versions},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			eb := NewEventBroadcaster()
+			go eb.Start(ctx)
+
+			res := NewResource("cluster1", "resource1")
+			res.Source = "test-source"
+
+			received := make(chan *Resource, versions*2)
+			snapshot := func() []*Resource {
+				return c.broadcast(eb, res)
+			}
+
+			id, _ := eb.RegisterWithSnapshot(mustSourceFilter(t, "test-source"), snapshot, nil, func(res *Resource) error {
+				received <- res
+				return nil
+			})
+			defer eb.Unregister(id)
+
+			expected := c.expectedVersions
+			if expected == nil {
+				for i := 1; i <= versions; i++ {
+					expected = append(expected, int64(i))
+				}
+			}
+
+			for _, version := range expected {
+				select {
+				case res := <-received:
+					if res.ResourceVersion != version {
+						t.Fatalf("expected version %d, but got %d", version, res.ResourceVersion)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("expected version %d, but got nothing", version)
+				}
+			}
+
+			select {
+			case res := <-received:
+				t.Errorf("unexpected version %d", res.ResourceVersion)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func withVersion(res *Resource, version int64) *Resource {
+	res = res.DeepCopy()
+	res.ResourceVersion = version
+	return res
+}
+
+func TestBroadcastWithDroppedEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eb := NewEventBroadcaster(WithSubscriberBufferSize(1))
+	go eb.Start(ctx)
+
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	received := make(chan uint64, 3)
+	id, _ := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+		if res.SubscriptionSequence == 1 {
+			close(handling)
+			<-release
+		}
+		received <- res.SubscriptionSequence
+		return nil
+	})
+	defer eb.Unregister(id)
+
+	res := NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	eb.Broadcast(res)
+	<-handling
+
+	// the second event is dropped for the third one, since the buffer is full
+	eb.Broadcast(res)
+	eb.Broadcast(res)
+	close(release)
+
+	for _, expected := range []uint64{1, 3} {
+		select {
+		case sequence := <-received:
+			if sequence != expected {
+				t.Errorf("expected sequence %d, but got %d", expected, sequence)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected sequence %d, but got nothing", expected)
+		}
+	}
+
+	// the dropped event is offered to the client but never taken by it
+	if gaps := eb.SequenceGaps(id); gaps != 1 {
+		t.Errorf("expected 1 sequence gap, but got %d", gaps)
+	}
+}
+
+func TestBroadcastWithSequenceGaps(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eb := NewEventBroadcaster(WithSubscriberBufferSize(1))
+	var gapped, dropped []string
+	var mu sync.Mutex
+	eb.setGapHandler(func(res *Resource, missed uint64) {
+		mu.Lock()
+		defer mu.Unlock()
+		gapped = append(gapped, fmt.Sprintf("%s missed %d", res.ResourceID, missed))
+	})
+	eb.setDroppedHandler(func(res *Resource) {
+		mu.Lock()
+		defer mu.Unlock()
+		dropped = append(dropped, res.ResourceID)
+	})
+	go eb.Start(ctx)
+
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	received := make(chan string, 4)
+	id, _ := eb.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error {
+		if res.ResourceID == ResourceID("cluster1", "resource1") {
+			close(handling)
+			<-release
+		}
+		received <- res.ResourceID
+		return nil
+	})
+	defer eb.Unregister(id)
+
+	broadcast := func(name string) {
+		res := NewResource("cluster1", name)
+		res.Source = "test-source"
+		eb.Broadcast(res)
+	}
+	receive := func(name string) {
+		select {
+		case resourceID := <-received:
+			if resourceID != ResourceID("cluster1", name) {
+				t.Errorf("expected event of %s, but got %s", name, resourceID)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected event of %s, but got nothing", name)
+		}
+	}
+
+	broadcast("resource1")
+	<-handling
+
+	// the buffer overflows while the client is handling the first event, the resource2 is dropped for the resource3
+	broadcast("resource2")
+	broadcast("resource3")
+	close(release)
+	receive("resource1")
+	receive("resource3")
+	broadcast("resource4")
+	receive("resource4")
+
+	// the gap is found once, the events after it have no new gap
+	if gaps := eb.SequenceGaps(id); gaps != 1 {
+		t.Errorf("expected 1 sequence gap, but got %d", gaps)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{ResourceID("cluster1", "resource3") + " missed 1"}
+	if !reflect.DeepEqual(gapped, expected) {
+		t.Errorf("expected the gaps %v, but got %v", expected, gapped)
+	}
+	// the evicted oldest event is reported as the dropped one rather than the new event
+	if expected := []string{ResourceID("cluster1", "resource2")}; !reflect.DeepEqual(dropped, expected) {
+		t.Errorf("expected the dropped events %v, but got %v", expected, dropped)
+	}
+}
+
+func TestNewSourceFilter(t *testing.T) {
+	cases := []struct {
+		name        string
+		expr        string
+		expectedErr bool
+	}{
+		{
+			name: "valid patterns",
+			expr: "source1,cluster-*",
+		},
+		{
+			name:        "empty filter",
+			expr:        " , ",
+			expectedErr: true,
+		},
+		{
+			name:        "invalid pattern",
+			expr:        "cluster-[",
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewSourceFilter(c.expr)
+			if c.expectedErr && err == nil {
+				t.Errorf("expected error, but failed")
+			}
+			if !c.expectedErr && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
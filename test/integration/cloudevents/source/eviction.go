@@ -0,0 +1,176 @@
+package source
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// lruList keeps the resource IDs in the order they are used, the least recently used one is at the front. It has its
+// own lock, so the readers of the store can use the resources with the read lock of the store held.
+type lruList struct {
+	mu       sync.Mutex
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newLRUList() *lruList {
+	return &lruList{
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// touch marks the resource as the most recently used one.
+func (l *lruList) touch(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.elements[id]; ok {
+		l.order.MoveToBack(elem)
+		return
+	}
+	l.elements[id] = l.order.PushBack(id)
+}
+
+func (l *lruList) remove(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.elements[id]; ok {
+		l.order.Remove(elem)
+		delete(l.elements, id)
+	}
+}
+
+// oldest returns the least recently used resource, it is false if there is no resource.
+func (l *lruList) oldest() (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem := l.order.Front()
+	if elem == nil {
+		return "", false
+	}
+	return elem.Value.(string), true
+}
+
+// put stores the resource, if the number of the resources exceeds the max resources, the least recently used
+// resources are evicted. It must be called with the lock held.
+func (s *MemoryStore) put(resource *Resource) {
+	if last, ok := s.resources[resource.ResourceID]; ok {
+		s.unindex(last)
+	}
+	s.resources[resource.ResourceID] = resource
+	s.index(resource)
+	s.metrics.resources.Set(float64(len(s.resources)))
+	if s.history != nil {
+		s.history.record(resource, s.clock.Now())
+	}
+	if s.lru == nil {
+		return
+	}
+
+	s.lru.touch(resource.ResourceID)
+	for len(s.resources) > s.maxResources {
+		id, ok := s.lru.oldest()
+		if !ok {
+			return
+		}
+		s.remove(id)
+	}
+}
+
+// remove must be called with the lock held.
+func (s *MemoryStore) remove(id string) {
+	if last, ok := s.resources[id]; ok {
+		s.unindex(last)
+	}
+	delete(s.resources, id)
+	s.metrics.resources.Set(float64(len(s.resources)))
+	if s.history != nil {
+		s.history.forget(id)
+	}
+	if s.lru != nil {
+		s.lru.remove(id)
+	}
+}
+
+// index adds the resource to the index of the sources, it must be called with the lock held.
+func (s *MemoryStore) index(resource *Resource) {
+	clusters, ok := s.sourceIndex[resource.Source]
+	if !ok {
+		clusters = make(map[string]sets.Set[string])
+		s.sourceIndex[resource.Source] = clusters
+	}
+
+	ids, ok := clusters[resource.Namespace]
+	if !ok {
+		ids = sets.New[string]()
+		clusters[resource.Namespace] = ids
+	}
+	if !ids.Has(resource.ResourceID) {
+		ids.Insert(resource.ResourceID)
+		s.metrics.added(resource.Source, resource.Namespace)
+	}
+}
+
+// unindex removes the resource from the index of the sources, the empty entries are removed too, so the index does
+// not grow with the sources and the clusters that have no resources. It must be called with the lock held.
+func (s *MemoryStore) unindex(resource *Resource) {
+	clusters, ok := s.sourceIndex[resource.Source]
+	if !ok {
+		return
+	}
+
+	ids, ok := clusters[resource.Namespace]
+	if !ok {
+		return
+	}
+
+	if !ids.Has(resource.ResourceID) {
+		return
+	}
+
+	ids.Delete(resource.ResourceID)
+	if ids.Len() == 0 {
+		delete(clusters, resource.Namespace)
+	}
+	if len(clusters) == 0 {
+		delete(s.sourceIndex, resource.Source)
+	}
+	s.metrics.removed(resource.Source, resource.Namespace, len(clusters) == 0)
+}
+
+// Evict removes the deleted resources whose deletion timestamps are older than the deleted resource retention, it
+// does nothing if the retention is not set.
+func (s *MemoryStore) Evict() {
+	if s.deletedRetention <= 0 {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.clock.Now()
+	for id, res := range s.resources {
+		if res.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if now.Sub(res.DeletionTimestamp.Time) >= s.deletedRetention {
+			s.remove(id)
+		}
+	}
+}
+
+// StartEviction evicts the deleted resources every interval until the context is done.
+func (s *MemoryStore) StartEviction(ctx context.Context, interval time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		s.Evict()
+	}, interval)
+}
@@ -0,0 +1,131 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	grpcprotocol "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protocol"
+)
+
+// maxCloseReasonSize is the max size of the reason of a websocket close frame.
+const maxCloseReasonSize = 123
+
+// Gateway serves the Subscribe stream of a CloudEventService over WebSocket for the clients that cannot speak the
+// native gRPC, e.g. the browsers. A client subscribes with the query parameters "source", "clusterName" and
+// "resumeToken", which are the same as the fields of the SubscriptionRequest, each CloudEvent of the stream is sent
+// as a text frame of its JSON format. The "Authorization" header of the request is forwarded to the server.
+type Gateway struct {
+	client   pbv1.CloudEventServiceClient
+	upgrader websocket.Upgrader
+}
+
+// Option configures the Gateway.
+type Option func(*Gateway)
+
+// WithCheckOrigin sets the function that decides whether the origin of a request is allowed, only the requests of the
+// same origin are allowed by default.
+func WithCheckOrigin(checkOrigin func(r *http.Request) bool) Option {
+	return func(g *Gateway) {
+		g.upgrader.CheckOrigin = checkOrigin
+	}
+}
+
+// NewGateway creates a gateway that subscribes with the given client.
+func NewGateway(client pbv1.CloudEventServiceClient, opts ...Option) *Gateway {
+	g := &Gateway{client: client}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	subReq := &pbv1.SubscriptionRequest{
+		Source:      query.Get("source"),
+		ClusterName: query.Get("clusterName"),
+		ResumeToken: query.Get("resumeToken"),
+	}
+	if len(subReq.Source) == 0 {
+		http.Error(w, "the source is required", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader responds with the error
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the client does not send any message, the subscription is canceled once the connection is closed
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if authorization := r.Header.Get("Authorization"); len(authorization) != 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", authorization)
+	}
+
+	closeCode, reason := g.forward(ctx, conn, subReq)
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, reason))
+}
+
+// forward forwards the CloudEvents of the subscription to the connection until the subscription is closed, the code
+// and the reason of closing the connection are returned.
+func (g *Gateway) forward(ctx context.Context, conn *websocket.Conn, subReq *pbv1.SubscriptionRequest) (int, string) {
+	stream, err := g.client.Subscribe(ctx, subReq)
+	if err != nil {
+		return websocket.CloseInternalServerErr, closeReason(err)
+	}
+
+	for {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return websocket.CloseNormalClosure, ""
+			}
+			return websocket.CloseInternalServerErr, closeReason(err)
+		}
+
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			return websocket.CloseInternalServerErr, closeReason(err)
+		}
+
+		data, err := json.Marshal(evt)
+		if err != nil {
+			return websocket.CloseInternalServerErr, closeReason(err)
+		}
+
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return websocket.CloseNormalClosure, ""
+		}
+	}
+}
+
+// closeReason returns the message of the error that fits in a close frame.
+func closeReason(err error) string {
+	reason := status.Convert(err).Message()
+	if len(reason) > maxCloseReasonSize {
+		reason = reason[:maxCloseReasonSize]
+	}
+	return reason
+}
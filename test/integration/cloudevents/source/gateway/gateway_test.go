@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	"github.com/gorilla/websocket"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/test/integration/cloudevents/source"
+)
+
+func TestGateway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := source.NewMemoryStore()
+	res := source.NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	store.Add(res)
+
+	eventBroadcaster := source.NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+
+	inMemoryServer, err := source.StartInMemoryServer(source.NewGRPCServer(store, eventBroadcaster))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inMemoryServer.Close(context.Background())
+
+	httpServer := httptest.NewServer(NewGateway(inMemoryServer.Client()))
+	defer httpServer.Close()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/?source=test-source"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messageType != websocket.TextMessage {
+		t.Errorf("expected a text frame, but got %d", messageType)
+	}
+
+	evt := cloudevents.NewEvent()
+	if err := json.Unmarshal(data, &evt); err != nil {
+		t.Fatalf("expected a JSON framed cloudevent, but got %s: %v", data, err)
+	}
+
+	eventType, err := types.ParseCloudEventsType(evt.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eventType.SubResource != types.SubResourceStatus {
+		t.Errorf("expected a status event, but got %s", evt.Type())
+	}
+	resourceID, err := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resourceID != res.ResourceID {
+		t.Errorf("expected the event of resource %s, but got %s", res.ResourceID, resourceID)
+	}
+}
+
+func TestGatewayWithoutSource(t *testing.T) {
+	httpServer := httptest.NewServer(NewGateway(nil))
+	defer httpServer.Close()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("expected error, but failed")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected the bad request response, but got %v", resp)
+	}
+}
@@ -0,0 +1,192 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"k8s.io/utils/clock"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	grpcprotocol "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protocol"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+const inMemoryBufferSize = 1024 * 1024
+
+// InMemoryServer serves a GRPCServer over an in-process buffer instead of a network socket, so the tests can publish
+// and subscribe without binding a real port.
+type InMemoryServer struct {
+	server   *GRPCServer
+	listener *bufconn.Listener
+	conn     *grpc.ClientConn
+	serveErr chan error
+}
+
+// StartInMemoryServer starts the server with the grpc server options on an in-process buffer and connects a client
+// to it.
+func StartInMemoryServer(svr *GRPCServer, opts ...grpc.ServerOption) (*InMemoryServer, error) {
+	s := &InMemoryServer{
+		server:   svr,
+		listener: bufconn.Listen(inMemoryBufferSize),
+		serveErr: make(chan error, 1),
+	}
+
+	go func() {
+		s.serveErr <- svr.serve(context.Background(), s.listener, opts...)
+	}()
+
+	conn, err := s.Dial(context.Background())
+	if err != nil {
+		svr.Stop(context.Background())
+		return nil, err
+	}
+	s.conn = conn
+
+	return s, nil
+}
+
+// Client returns the client that is connected to the server.
+func (s *InMemoryServer) Client() pbv1.CloudEventServiceClient {
+	return pbv1.NewCloudEventServiceClient(s.conn)
+}
+
+// Dial creates a new connection to the server with the dial options, e.g. a client with its own stats handler or
+// compressor. The caller is responsible for closing the connection.
+func (s *InMemoryServer) Dial(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, opts...)
+
+	return grpc.DialContext(ctx, "passthrough:///bufconn", opts...)
+}
+
+// ServeErr returns the channel that receives the result of serving once the server is stopped.
+func (s *InMemoryServer) ServeErr() <-chan error {
+	return s.serveErr
+}
+
+// Close closes the client connection and stops the server.
+func (s *InMemoryServer) Close(ctx context.Context) {
+	s.conn.Close()
+	s.server.Stop(ctx)
+}
+
+// Subscriber receives the events of a subscription to an InMemoryServer in the background, so a test can wait for
+// an event without racing with its delivery.
+type Subscriber struct {
+	events chan *cloudevents.Event
+	// done is closed once the subscription is finished, err is the error that it is finished with.
+	done chan struct{}
+	err  error
+	// clock is the clock of the server, the timeouts of Await are based on it.
+	clock clock.Clock
+}
+
+// Subscribe subscribes to the server with the request, and returns once the snapshot done event is received, so the
+// subscriber is registered and every event that is broadcast after it is received. The events of the snapshot are
+// skipped. The subscription is finished once the context is done.
+func (s *InMemoryServer) Subscribe(ctx context.Context, subReq *pbv1.SubscriptionRequest) (*Subscriber, error) {
+	stream, err := s.Client().Subscribe(ctx, subReq)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive the snapshot: %v", err)
+		}
+		if pbEvt.Type == SnapshotDoneEventType.String() {
+			break
+		}
+	}
+
+	subscriber := &Subscriber{
+		events: make(chan *cloudevents.Event),
+		done:   make(chan struct{}),
+		clock:  s.server.clock,
+	}
+	go func() {
+		defer close(subscriber.done)
+		for {
+			pbEvt, err := stream.Recv()
+			if err != nil {
+				subscriber.err = err
+				return
+			}
+			evt, err := grpcprotocol.ToEvent(ctx, pbEvt)
+			if err != nil {
+				subscriber.err = fmt.Errorf("failed to convert protobuf to cloudevent: %v", err)
+				return
+			}
+
+			select {
+			case subscriber.events <- evt:
+			case <-ctx.Done():
+				subscriber.err = ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return subscriber, nil
+}
+
+// Await blocks until the subscriber receives an event that matches, the events that do not match are skipped. It
+// fails if no event matches in the timeout of the clock of the server or the subscription is finished.
+func (s *Subscriber) Await(timeout time.Duration, match func(evt *cloudevents.Event) bool) (*cloudevents.Event, error) {
+	timer := s.clock.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case evt := <-s.events:
+			if match(evt) {
+				return evt, nil
+			}
+		case <-s.done:
+			return nil, fmt.Errorf("the subscription is finished: %v", s.err)
+		case <-timer.C():
+			return nil, fmt.Errorf("no event is received in %s", timeout)
+		}
+	}
+}
+
+// PublishAndAwait publishes the event, then blocks until the subscriber receives an event of the published resource,
+// e.g. the status that an agent reports for it, and returns the received resource. It fails if the event is not
+// published, or no event of the resource is received in the timeout.
+func (s *InMemoryServer) PublishAndAwait(ctx context.Context, pubReq *pbv1.PublishRequest, subscriber *Subscriber,
+	timeout time.Duration) (*Resource, error) {
+	evt, err := grpcprotocol.ToEvent(ctx, pubReq.Event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert protobuf to cloudevent: %v", err)
+	}
+	resourceID, err := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resourceid extension: %v", err)
+	}
+
+	if _, err := s.Client().Publish(ctx, pubReq); err != nil {
+		return nil, err
+	}
+
+	received, err := subscriber.Await(timeout, func(evt *cloudevents.Event) bool {
+		id, err := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+		return err == nil && id == resourceID
+	})
+	if err != nil {
+		return nil, fmt.Errorf("the event of the resource %s is not received: %v", resourceID, err)
+	}
+
+	return (&ResourceCodec{}).Decode(received)
+}
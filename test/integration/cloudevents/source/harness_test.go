@@ -0,0 +1,173 @@
+package source
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	testingclock "k8s.io/utils/clock/testing"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	grpcprotocol "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protocol"
+)
+
+func TestInMemoryServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	store := NewMemoryStore()
+
+	inMemoryServer, err := StartInMemoryServer(NewGRPCServer(store, eventBroadcaster))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inMemoryServer.Close(context.Background())
+	client := inMemoryServer.Client()
+
+	res := NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	if _, err := client.Publish(ctx, newPublishRequest(t, res)); err != nil {
+		t.Fatal(err)
+	}
+
+	// report the status of the published resource as an agent does
+	res.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue}}
+	if err := store.UpdateStatus(res); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recv := func() *cloudevents.Event {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+
+	// the published resource is received in the snapshot with its status
+	received, err := (&ResourceCodec{}).Decode(recv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.ResourceID != res.ResourceID {
+		t.Errorf("expected status event of %s, but got %s", res.ResourceID, received.ResourceID)
+	}
+	if !equality.Semantic.DeepEqual(received.Status.Conditions, res.Status.Conditions) {
+		t.Errorf("expected conditions %v, but got %v", res.Status.Conditions, received.Status.Conditions)
+	}
+
+	if evt := recv(); evt.Type() != SnapshotDoneEventType.String() {
+		t.Errorf("expected snapshot done event, but got %s", evt.Type())
+	}
+}
+
+func TestPublishAndAwait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	store := newMemoryStore(eventBroadcaster)
+
+	inMemoryServer, err := StartInMemoryServer(NewGRPCServer(store, eventBroadcaster))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inMemoryServer.Close(context.Background())
+
+	// the agent reports the status of the resources that are published
+	conditions := []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue}}
+	go func() {
+		for res := range store.GetResourceSpecChan() {
+			res.Status.Conditions = conditions
+			if err := store.UpdateStatus(res); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	subscriber, err := inMemoryServer.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"resource1", "resource2"} {
+		res := NewResource("cluster1", name)
+		received, err := inMemoryServer.PublishAndAwait(ctx, newPublishRequest(t, res), subscriber, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if received.ResourceID != res.ResourceID {
+			t.Errorf("expected status event of %s, but got %s", res.ResourceID, received.ResourceID)
+		}
+		if !equality.Semantic.DeepEqual(received.Status.Conditions, conditions) {
+			t.Errorf("expected conditions %v, but got %v", conditions, received.Status.Conditions)
+		}
+	}
+
+	// every status is received by its publish, no event is left behind
+	if _, err := subscriber.Await(100*time.Millisecond, func(*cloudevents.Event) bool { return true }); err == nil {
+		t.Errorf("expected no event, but received one")
+	}
+}
+
+func TestAwaitTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+
+	inMemoryServer, err := StartInMemoryServer(NewGRPCServer(NewMemoryStore(), eventBroadcaster, WithClock(fakeClock)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inMemoryServer.Close(context.Background())
+
+	subscriber, err := inMemoryServer.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	awaitErr := make(chan error, 1)
+	go func() {
+		_, err := subscriber.Await(time.Hour, func(*cloudevents.Event) bool { return true })
+		awaitErr <- err
+	}()
+
+	// the timeout elapses on the clock of the server rather than the real time
+	err = wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true,
+		func(ctx context.Context) (bool, error) {
+			return fakeClock.HasWaiters(), nil
+		})
+	if err != nil {
+		t.Fatalf("expected the subscriber awaits, %v", err)
+	}
+	fakeClock.Step(time.Hour)
+
+	select {
+	case err := <-awaitErr:
+		if err == nil {
+			t.Errorf("expected the await times out, but received an event")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the await times out on the clock of the server")
+	}
+}
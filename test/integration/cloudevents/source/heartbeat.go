@@ -0,0 +1,154 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/clock"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+// errStreamClosed is returned when an event is sent to a subscribe stream that is closed.
+var errStreamClosed = errors.New("the subscribe stream is closed")
+
+// subscribeStream serializes the events that are sent to a subscriber, so the heartbeat events can be sent beside
+// the resource events, and records when the last event is sent. Once it is closed, nothing is sent to the stream.
+type subscribeStream struct {
+	mu     sync.Mutex
+	closed bool
+	// abandoned is true once the stream is abandoned while an event may be being sent, it is checked without the mu.
+	abandoned atomic.Bool
+	ctx       context.Context
+	// sendMsg sends the events in one message of the stream.
+	sendMsg func(events []*pbv1.CloudEvent) error
+	// batched is true if a message of the stream carries a batch of events, otherwise, a message carries one event.
+	batched  bool
+	backoff  wait.Backoff
+	clock    clock.Clock
+	lastSent time.Time
+	// window bounds the events that are sent ahead of the acknowledgements of the subscriber, the events are not flow
+	// controlled if it is nil.
+	window *sendWindow
+}
+
+func newSubscribeStream(stream pbv1.CloudEventService_SubscribeServer, backoff wait.Backoff,
+	clock clock.Clock) *subscribeStream {
+	return &subscribeStream{
+		ctx: stream.Context(),
+		sendMsg: func(events []*pbv1.CloudEvent) error {
+			// one event is sent at a time, since the stream is not batched
+			return stream.Send(events[0])
+		},
+		backoff:  backoff,
+		clock:    clock,
+		lastSent: clock.Now(),
+	}
+}
+
+func newBatchSubscribeStream(stream pbv1.CloudEventService_SubscribeBatchServer, backoff wait.Backoff,
+	clock clock.Clock) *subscribeStream {
+	return &subscribeStream{
+		ctx: stream.Context(),
+		sendMsg: func(events []*pbv1.CloudEvent) error {
+			return stream.Send(&pbv1.CloudEventBatch{Events: events})
+		},
+		batched:  true,
+		backoff:  backoff,
+		clock:    clock,
+		lastSent: clock.Now(),
+	}
+}
+
+// send sends the events in one message, more than one event can only be sent if the stream is batched. If the stream
+// has a window, the events wait for the room of the window without holding the stream, so the heartbeats are still
+// sent.
+func (s *subscribeStream) send(evts ...*cloudevents.Event) (err error) {
+	if s.window != nil {
+		if err := s.window.acquire(s.ctx, len(evts)); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				s.window.release(len(evts))
+			}
+		}()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.abandoned.Load() {
+		return errStreamClosed
+	}
+
+	if err := send(s.ctx, s.sendMsg, evts, s.backoff, s.clock); err != nil {
+		return err
+	}
+
+	s.lastSent = s.clock.Now()
+	return nil
+}
+
+// close closes the stream once the event that is being sent is sent, the events are not sent to the stream after it
+// returns, so the stream can be closed by the server safely, even if the subscriber is still being unregistered.
+func (s *subscribeStream) close() {
+	if s.abandoned.Load() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+}
+
+// abandon closes the stream without waiting for the event that is being sent, e.g. the send is blocked since the
+// subscriber is not alive, the blocked send fails once the stream is finished by grpc.
+func (s *subscribeStream) abandon() {
+	s.abandoned.Store(true)
+}
+
+// heartbeat sends a heartbeat event from the source once nothing is sent to the subscriber in the interval, until the
+// context is done or a heartbeat event cannot be sent. The IDs of the heartbeat events are generated by the eventID.
+func (s *subscribeStream) heartbeat(ctx context.Context, source string, eventID EventIDGenerator,
+	interval time.Duration) {
+	timer := s.clock.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+		}
+
+		s.mu.Lock()
+		if s.closed || s.abandoned.Load() {
+			s.mu.Unlock()
+			return
+		}
+
+		idle := s.clock.Since(s.lastSent)
+		if idle >= interval {
+			evt := types.NewEventBuilder(source, HeartbeatEventType).NewEvent()
+			evt.SetID(eventID())
+			evt.SetTime(s.clock.Now())
+			if err := send(s.ctx, s.sendMsg, []*cloudevents.Event{&evt}, s.backoff, s.clock); err != nil {
+				s.mu.Unlock()
+				return
+			}
+			s.lastSent = s.clock.Now()
+			idle = 0
+		}
+		s.mu.Unlock()
+
+		timer.Reset(interval - idle)
+	}
+}
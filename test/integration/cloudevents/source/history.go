@@ -0,0 +1,74 @@
+package source
+
+import (
+	"time"
+)
+
+// historyEntry is a resource that is written to the store at a time, the resource is active from the time until the
+// time of the next entry.
+type historyEntry struct {
+	resource *Resource
+	since    time.Time
+}
+
+// historyRing keeps the last written resources of a resource ID, at most size resources are kept, the oldest one is
+// overwritten first once the ring is full.
+type historyRing struct {
+	entries []historyEntry
+	// next is the index that the next entry is written to, it is the index of the oldest entry once the ring is full.
+	next int
+}
+
+// resourceHistory keeps the bounded history rings of the resources.
+type resourceHistory struct {
+	size  int
+	rings map[string]*historyRing
+}
+
+func newResourceHistory(size int) *resourceHistory {
+	return &resourceHistory{
+		size:  size,
+		rings: make(map[string]*historyRing),
+	}
+}
+
+// record records that the resource is written at the given time. The resources in the store are never changed in
+// place, so the resource is kept without a copy.
+func (h *resourceHistory) record(resource *Resource, now time.Time) {
+	ring, ok := h.rings[resource.ResourceID]
+	if !ok {
+		ring = &historyRing{entries: make([]historyEntry, 0, h.size)}
+		h.rings[resource.ResourceID] = ring
+	}
+
+	entry := historyEntry{resource: resource, since: now}
+	if len(ring.entries) < h.size {
+		ring.entries = append(ring.entries, entry)
+	} else {
+		ring.entries[ring.next] = entry
+	}
+	ring.next = (ring.next + 1) % h.size
+}
+
+// forget removes the history of the resource.
+func (h *resourceHistory) forget(resourceID string) {
+	delete(h.rings, resourceID)
+}
+
+// at returns the resource that is active at the given time, it is false if the resource is not written before the
+// time or the history of the time is not kept anymore.
+func (h *resourceHistory) at(resourceID string, at time.Time) (*Resource, bool) {
+	ring, ok := h.rings[resourceID]
+	if !ok {
+		return nil, false
+	}
+
+	// the entries are visited from the newest one to the oldest one
+	for i := 0; i < len(ring.entries); i++ {
+		entry := ring.entries[(ring.next-1-i+len(ring.entries))%len(ring.entries)]
+		if !entry.since.After(at) {
+			return entry.resource, true
+		}
+	}
+	return nil, false
+}
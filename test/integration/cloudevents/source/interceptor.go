@@ -0,0 +1,114 @@
+package source
+
+import (
+	"sort"
+
+	"google.golang.org/grpc"
+)
+
+// InterceptorStage is the stage of an interceptor in the interceptor chains of the server. The interceptors of an
+// outer stage run before the ones of the inner stages, and the interceptors of the same stage run in the order that
+// they are added.
+type InterceptorStage int
+
+const (
+	// RecoveryStage is the outermost stage, the panics of the requests are recovered in it, so a panic of any inner
+	// interceptor is recovered too.
+	RecoveryStage InterceptorStage = iota
+	// RequestIDStage sets the ids of the requests, so the interceptors of the inner stages log with them.
+	RequestIDStage
+	// AuthStage authenticates the requests, the identity of a request is in the context of the inner stages.
+	AuthStage
+	// RateLimitStage limits the requests, they are authenticated before they are limited, so they can be limited by
+	// their identities, and the requests that are rejected by the auth do not count.
+	RateLimitStage
+)
+
+type unaryInterceptor struct {
+	stage       InterceptorStage
+	interceptor grpc.UnaryServerInterceptor
+}
+
+type streamInterceptor struct {
+	stage       InterceptorStage
+	interceptor grpc.StreamServerInterceptor
+}
+
+// InterceptorChainBuilder assembles the unary and the stream interceptor chains of a grpc server in the order of the
+// stages of the interceptors, whatever the order that they are added in.
+type InterceptorChainBuilder struct {
+	unary  []unaryInterceptor
+	stream []streamInterceptor
+}
+
+// NewInterceptorChainBuilder returns a builder without any interceptor.
+func NewInterceptorChainBuilder() *InterceptorChainBuilder {
+	return &InterceptorChainBuilder{}
+}
+
+// Unary adds the unary interceptors to the stage.
+func (b *InterceptorChainBuilder) Unary(stage InterceptorStage,
+	interceptors ...grpc.UnaryServerInterceptor) *InterceptorChainBuilder {
+	for _, interceptor := range interceptors {
+		b.unary = append(b.unary, unaryInterceptor{stage: stage, interceptor: interceptor})
+	}
+	return b
+}
+
+// Stream adds the stream interceptors to the stage.
+func (b *InterceptorChainBuilder) Stream(stage InterceptorStage,
+	interceptors ...grpc.StreamServerInterceptor) *InterceptorChainBuilder {
+	for _, interceptor := range interceptors {
+		b.stream = append(b.stream, streamInterceptor{stage: stage, interceptor: interceptor})
+	}
+	return b
+}
+
+// UnaryInterceptors returns the unary interceptors from the outermost to the innermost.
+func (b *InterceptorChainBuilder) UnaryInterceptors() []grpc.UnaryServerInterceptor {
+	sorted := append([]unaryInterceptor{}, b.unary...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].stage < sorted[j].stage })
+
+	interceptors := make([]grpc.UnaryServerInterceptor, 0, len(sorted))
+	for _, i := range sorted {
+		interceptors = append(interceptors, i.interceptor)
+	}
+	return interceptors
+}
+
+// StreamInterceptors returns the stream interceptors from the outermost to the innermost.
+func (b *InterceptorChainBuilder) StreamInterceptors() []grpc.StreamServerInterceptor {
+	sorted := append([]streamInterceptor{}, b.stream...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].stage < sorted[j].stage })
+
+	interceptors := make([]grpc.StreamServerInterceptor, 0, len(sorted))
+	for _, i := range sorted {
+		interceptors = append(interceptors, i.interceptor)
+	}
+	return interceptors
+}
+
+// ServerOptions returns the grpc server options that chain the interceptors. The interceptors that are chained by
+// the server options after them are inner than all of the stages.
+func (b *InterceptorChainBuilder) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(b.UnaryInterceptors()...),
+		grpc.ChainStreamInterceptor(b.StreamInterceptors()...),
+	}
+}
+
+// WithUnaryInterceptors adds the unary interceptors to the stage of the interceptor chain of the server, they run
+// after the interceptors of the server in the same stage, e.g. the authentication of the AuthStage.
+func WithUnaryInterceptors(stage InterceptorStage, interceptors ...grpc.UnaryServerInterceptor) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.interceptors.Unary(stage, interceptors...)
+	}
+}
+
+// WithStreamInterceptors adds the stream interceptors to the stage of the interceptor chain of the server, they run
+// after the interceptors of the server in the same stage.
+func WithStreamInterceptors(stage InterceptorStage, interceptors ...grpc.StreamServerInterceptor) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.interceptors.Stream(stage, interceptors...)
+	}
+}
@@ -0,0 +1,138 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+)
+
+// invocationRecorder records the invocations of the interceptors with the request id and the identity that they see
+// in the context of the request.
+type invocationRecorder struct {
+	mu          sync.Mutex
+	invocations []string
+}
+
+func (r *invocationRecorder) record(ctx context.Context, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, hasRequestID := RequestIDFromContext(ctx)
+	identity, _ := IdentityFromContext(ctx)
+	r.invocations = append(r.invocations, fmt.Sprintf("%s requestID=%t identity=%q", name, hasRequestID, identity))
+}
+
+func (r *invocationRecorder) unary(name string, panics bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		r.record(ctx, name)
+		if panics {
+			panic(name)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (r *invocationRecorder) stream(name string, panics bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		r.record(ss.Context(), name)
+		if panics {
+			panic(name)
+		}
+		return handler(srv, ss)
+	}
+}
+
+func TestInterceptorChainOrder(t *testing.T) {
+	// the interceptors are added in another order than their stages
+	stages := []struct {
+		name  string
+		stage InterceptorStage
+	}{
+		{name: "ratelimit", stage: RateLimitStage},
+		{name: "auth", stage: AuthStage},
+		{name: "recovery", stage: RecoveryStage},
+		{name: "requestid", stage: RequestIDStage},
+	}
+
+	// the interceptors of the server run first in each stage, e.g. the identity is authenticated before the auth
+	// interceptor of the options, and the panics of the inner interceptors are recovered
+	expectedInvocations := []string{
+		`recovery requestID=false identity=""`,
+		`requestid requestID=true identity=""`,
+		`auth requestID=true identity="identity-a"`,
+		`ratelimit requestID=true identity="identity-a"`,
+	}
+
+	cases := []struct {
+		name string
+		call func(ctx context.Context, client pbv1.CloudEventServiceClient) error
+	}{
+		{
+			name: "unary",
+			call: func(ctx context.Context, client pbv1.CloudEventServiceClient) error {
+				_, err := client.Publish(ctx, newPublishRequest(t, NewResource("cluster1", "resource1")))
+				return err
+			},
+		},
+		{
+			name: "stream",
+			call: func(ctx context.Context, client pbv1.CloudEventServiceClient) error {
+				stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+				if err != nil {
+					return err
+				}
+				_, err = stream.Recv()
+				return err
+			},
+		},
+	}
+
+	for _, c := range cases {
+		for _, panics := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s panics=%t", c.name, panics), func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				recorder := &invocationRecorder{}
+				opts := []GRPCServerOption{
+					WithTokenVerifier(&fakeTokenVerifier{identities: map[string]string{"token-a": "identity-a"}}),
+				}
+				for _, s := range stages {
+					// the innermost interceptor panics
+					panicked := panics && s.stage == RateLimitStage
+					opts = append(opts,
+						WithUnaryInterceptors(s.stage, recorder.unary(s.name, panicked)),
+						WithStreamInterceptors(s.stage, recorder.stream(s.name, panicked)))
+				}
+
+				eventBroadcaster := NewEventBroadcaster()
+				go eventBroadcaster.Start(ctx)
+				client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster, opts...))
+
+				err := c.call(metadata.AppendToOutgoingContext(ctx, authorizationHeader, "Bearer token-a"), client)
+				switch {
+				case panics && status.Code(err) != codes.Internal:
+					t.Errorf("expected the panic is recovered, but got %v", err)
+				case !panics && err != nil:
+					t.Fatal(err)
+				}
+
+				recorder.mu.Lock()
+				defer recorder.mu.Unlock()
+				if !reflect.DeepEqual(recorder.invocations, expectedInvocations) {
+					t.Errorf("expected invocations %v, but got %v", expectedInvocations, recorder.invocations)
+				}
+			})
+		}
+	}
+}
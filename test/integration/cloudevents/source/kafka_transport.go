@@ -0,0 +1,103 @@
+//go:build kafka
+
+package source
+
+import (
+	"context"
+	"fmt"
+
+	confluent "github.com/cloudevents/sdk-go/protocol/kafka_confluent/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cloudeventscontext "github.com/cloudevents/sdk-go/v2/context"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+	kafkaoptions "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/kafka"
+)
+
+const (
+	// kafkaSourceEventsTopicPattern matches the topics that the sources publish the resource specs to, the topic of a
+	// source and a cluster is "sourceevents.<source>.<cluster>".
+	kafkaSourceEventsTopicPattern = `^sourceevents\..+\..+`
+	// kafkaStatusEventsTopic is the default topic that the resource statuses are sent to, the status of a resource is
+	// sent to the topic "agentevents.<source>.<cluster>" of its source and cluster.
+	kafkaStatusEventsTopic = "agentevents"
+)
+
+// KafkaTransport serves the sources over the kafka protocol, the resource specs are consumed from the source events
+// topics and the resource statuses are produced to the agent events topics, the topics are the same as the ones that
+// the kafka source options use. The publishing errors are logged, since there is no response to the publisher.
+type KafkaTransport struct {
+	Options *kafkaoptions.KafkaOptions
+}
+
+var _ Transport = &KafkaTransport{}
+
+func (t *KafkaTransport) Serve(ctx context.Context, svr *GRPCServer) error {
+	configMap := kafka.ConfigMap{}
+	for key, value := range t.Options.ConfigMap {
+		configMap[key] = value
+	}
+	if groupID, err := configMap.Get("group.id", ""); err != nil || groupID == "" {
+		_ = configMap.SetKey("group.id", svr.source)
+	}
+
+	protocol, err := confluent.New(confluent.WithConfigMap(&configMap),
+		confluent.WithReceiverTopics([]string{kafkaSourceEventsTopicPattern}),
+		confluent.WithSenderTopic(kafkaStatusEventsTopic))
+	if err != nil {
+		return fmt.Errorf("failed to create the kafka protocol: %v", err)
+	}
+	defer protocol.Close(context.Background())
+
+	producerEvents, err := protocol.Events()
+	if err != nil {
+		return err
+	}
+	go func() {
+		for e := range producerEvents {
+			if msg, ok := e.(*kafka.Message); ok && msg.TopicPartition.Error != nil {
+				svr.logger.Error(msg.TopicPartition.Error, "failed to deliver the status event")
+			}
+		}
+	}()
+
+	client, err := cloudevents.NewClient(protocol)
+	if err != nil {
+		return fmt.Errorf("failed to create the kafka client: %v", err)
+	}
+
+	id, errChan := svr.eventBroadcaster.Register(func(string) bool { return true }, func(res *Resource) error {
+		evt, err := svr.encodeStatusEvent(res)
+		if err != nil {
+			return err
+		}
+
+		topic := fmt.Sprintf("%s.%s.%s", kafkaStatusEventsTopic, res.Source, res.Namespace)
+		sendCtx := confluent.WithMessageKey(cloudeventscontext.WithTopic(ctx, topic),
+			fmt.Sprintf("%s@%s", res.Source, res.Namespace))
+		if result := client.Send(sendCtx, *evt); cloudevents.IsUndelivered(result) {
+			return fmt.Errorf("failed to send the status event of the resource %s: %v", res.ResourceID, result)
+		}
+		return nil
+	})
+	defer svr.eventBroadcaster.Unregister(id)
+
+	receiverCtx, cancel := context.WithCancel(ctx)
+	receiverErr := make(chan error, 1)
+	go func() {
+		receiverErr <- client.StartReceiver(receiverCtx, svr.publishReceived)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err = <-errChan:
+	case err = <-receiverErr:
+		cancel()
+		return err
+	}
+
+	// the receiver is stopped before the protocol is closed
+	cancel()
+	<-receiverErr
+	return err
+}
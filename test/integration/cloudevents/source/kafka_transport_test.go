@@ -0,0 +1,208 @@
+//go:build kafka
+
+package source
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	confluent "github.com/cloudevents/sdk-go/protocol/kafka_confluent/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	grpcprotocol "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protocol"
+	kafkaoptions "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/kafka"
+)
+
+// roundTrip publishes the resource spec events and receives the resource status events over a transport.
+type roundTrip struct {
+	publish func(t *testing.T, evt *cloudevents.Event)
+	receive func(t *testing.T) *cloudevents.Event
+}
+
+func TestTransportRoundTrip(t *testing.T) {
+	cases := []struct {
+		name  string
+		start func(t *testing.T, ctx context.Context, svr *GRPCServer) roundTrip
+	}{
+		{
+			name:  "grpc",
+			start: startGRPCRoundTrip,
+		},
+		{
+			name:  "kafka",
+			start: startKafkaRoundTrip,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			eventBroadcaster := NewEventBroadcaster()
+			go eventBroadcaster.Start(ctx)
+			store := NewMemoryStore()
+			roundTrip := c.start(t, ctx, NewGRPCServer(store, eventBroadcaster))
+
+			res := NewResource("cluster1", "resource1")
+			evt, err := (&ResourceCodec{}).Encode("test-source", testSpecEventType, res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			roundTrip.publish(t, evt)
+
+			var stored *Resource
+			err = wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 30*time.Second, true,
+				func(ctx context.Context) (bool, error) {
+					stored, err = store.Get(res.ResourceID)
+					return err == nil, nil
+				})
+			if err != nil {
+				t.Fatalf("expected the resource is published, %v", err)
+			}
+			if stored.Source != "test-source" || !equality.Semantic.DeepEqual(stored.Spec, res.Spec) {
+				t.Errorf("unexpected resource %v", stored)
+			}
+
+			stored.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied"}}
+			eventBroadcaster.Broadcast(stored)
+
+			received, err := (&ResourceCodec{}).Decode(roundTrip.receive(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if received.ResourceID != res.ResourceID || !equality.Semantic.DeepEqual(received.Status.Conditions, stored.Status.Conditions) {
+				t.Errorf("unexpected status event of resource %s: %v", received.ResourceID, received.Status.Conditions)
+			}
+		})
+	}
+}
+
+func startGRPCRoundTrip(t *testing.T, ctx context.Context, svr *GRPCServer) roundTrip {
+	client, _ := startTestServer(t, svr)
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func(t *testing.T) *cloudevents.Event {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+
+	// the resources are published after the snapshot is done, so that they are only received as the status events
+	if evt := receive(t); evt.Type() != SnapshotDoneEventType.String() {
+		t.Fatalf("expected the snapshot done event, but got %s", evt.Type())
+	}
+
+	return roundTrip{
+		publish: func(t *testing.T, evt *cloudevents.Event) {
+			pbEvt, err := toPBEvent(evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.Publish(ctx, &pbv1.PublishRequest{Event: pbEvt}); err != nil {
+				t.Fatal(err)
+			}
+		},
+		receive: receive,
+	}
+}
+
+func startKafkaRoundTrip(t *testing.T, ctx context.Context, svr *GRPCServer) roundTrip {
+	kafkaCluster, err := kafka.NewMockCluster(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(kafkaCluster.Close)
+
+	for _, topic := range []string{"sourceevents.test-source.cluster1", "agentevents.test-source.cluster1"} {
+		if err := kafkaCluster.CreateTopic(topic, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	transport := &KafkaTransport{Options: &kafkaoptions.KafkaOptions{
+		ConfigMap: kafka.ConfigMap{
+			"bootstrap.servers": kafkaCluster.BootstrapServers(),
+			"auto.offset.reset": "earliest",
+		},
+	}}
+	go func() {
+		_ = transport.Serve(ctx, svr)
+	}()
+
+	sender, err := confluent.New(confluent.WithConfigMap(&kafka.ConfigMap{
+		"bootstrap.servers": kafkaCluster.BootstrapServers(),
+	}), confluent.WithSenderTopic("sourceevents.test-source.cluster1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sender.Close(context.Background()) })
+	// drain the delivery reports, otherwise the sender is blocked on flushing them when it is closed
+	senderEvents, err := sender.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for range senderEvents {
+		}
+	}()
+	senderClient, err := cloudevents.NewClient(sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receiver, err := confluent.New(confluent.WithConfigMap(&kafka.ConfigMap{
+		"bootstrap.servers": kafkaCluster.BootstrapServers(),
+		"group.id":          "test-source",
+		"auto.offset.reset": "earliest",
+	}), confluent.WithReceiverTopics([]string{"agentevents.test-source.cluster1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { receiver.Close(context.Background()) })
+	receiverClient, err := cloudevents.NewClient(receiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan cloudevents.Event, 1)
+	go func() {
+		_ = receiverClient.StartReceiver(ctx, func(evt cloudevents.Event) {
+			received <- evt
+		})
+	}()
+
+	return roundTrip{
+		publish: func(t *testing.T, evt *cloudevents.Event) {
+			if result := senderClient.Send(ctx, *evt); cloudevents.IsUndelivered(result) {
+				t.Fatal(result)
+			}
+		},
+		receive: func(t *testing.T) *cloudevents.Event {
+			select {
+			case evt := <-received:
+				return &evt
+			case <-time.After(30 * time.Second):
+				t.Fatal("expected the status event, but got nothing")
+				return nil
+			}
+		},
+	}
+}
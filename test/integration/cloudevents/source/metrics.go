@@ -0,0 +1,194 @@
+package source
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "cloudevents"
+	metricsSubsystem = "grpc_server"
+
+	storeMetricsSubsystem = "store"
+)
+
+// serverMetrics is the prometheus collectors of the publish and subscribe throughput of the server.
+type serverMetrics struct {
+	publishedEvents   *prometheus.CounterVec
+	deliveredEvents   *prometheus.CounterVec
+	droppedEvents     *prometheus.CounterVec
+	sequenceGaps      *prometheus.CounterVec
+	encodeDuration    *prometheus.HistogramVec
+	decodeDuration    *prometheus.HistogramVec
+	activeSubscribers *prometheus.GaugeVec
+
+	// eventDataSize and largestEventDataSize are the sizes of the encoded data of the events that are sent by the
+	// server, so the clusters whose status is bloated can be found.
+	eventDataSize        *prometheus.HistogramVec
+	largestEventDataSize *prometheus.GaugeVec
+	// largestMu guards the largestSizes, the largest sizes of the sources that are set to the gauge.
+	largestMu    sync.Mutex
+	largestSizes map[string]int
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		publishedEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "published_events_total",
+			Help:      "The number of the events that are published to the server.",
+		}, []string{"source", "data_type"}),
+		deliveredEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "delivered_events_total",
+			Help:      "The number of the events that are delivered to the subscribers.",
+		}, []string{"source", "data_type"}),
+		droppedEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "dropped_events_total",
+			Help:      "The number of the events that are dropped because the subscribers are too slow.",
+		}, []string{"source", "data_type"}),
+		sequenceGaps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "sequence_gaps_total",
+			Help: "The number of the gaps in the outbound sequences of the events that are offered to the subscribers, " +
+				"e.g. the events are dropped or lost, a gap indicates an overload or a bug of the server.",
+		}, []string{"source"}),
+		encodeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "encode_duration_seconds",
+			Help:      "The duration of encoding the resources to the events.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}, []string{"source", "data_type"}),
+		decodeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "decode_duration_seconds",
+			Help:      "The duration of decoding the events to the resources.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}, []string{"source", "data_type"}),
+		activeSubscribers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "active_subscribers",
+			Help:      "The number of the active subscribers.",
+		}, []string{"source"}),
+		eventDataSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "event_data_size_bytes",
+			Help:      "The size of the encoded data of the events that are sent by the server.",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 8),
+		}, []string{"source", "data_type"}),
+		largestEventDataSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "largest_event_data_size_bytes",
+			Help:      "The size of the largest encoded data of the events that are sent by the server.",
+		}, []string{"source"}),
+		largestSizes: make(map[string]int),
+	}
+}
+
+func (m *serverMetrics) register(registerer prometheus.Registerer) {
+	registerer.MustRegister(
+		m.publishedEvents,
+		m.deliveredEvents,
+		m.droppedEvents,
+		m.sequenceGaps,
+		m.encodeDuration,
+		m.decodeDuration,
+		m.activeSubscribers,
+		m.eventDataSize,
+		m.largestEventDataSize,
+	)
+}
+
+// observeEventDataSize records the size of the encoded data of an event, the gauge of the source is set if the size is
+// larger than the sizes of the events of the source that are seen before.
+func (m *serverMetrics) observeEventDataSize(source, dataType string, size int) {
+	m.eventDataSize.WithLabelValues(source, dataType).Observe(float64(size))
+
+	m.largestMu.Lock()
+	defer m.largestMu.Unlock()
+	if largest, ok := m.largestSizes[source]; ok && largest >= size {
+		return
+	}
+	m.largestSizes[source] = size
+	m.largestEventDataSize.WithLabelValues(source).Set(float64(size))
+}
+
+// storeMetrics is the prometheus collectors of the resources that are tracked by the MemoryStore, they are updated
+// with the lock of the store held.
+type storeMetrics struct {
+	resources        prometheus.Gauge
+	sourceResources  *prometheus.GaugeVec
+	clusterResources *prometheus.GaugeVec
+
+	// clusters counts the resources of the clusters of all of the sources, so the gauge of a cluster is removed once
+	// the cluster has no resources.
+	clusters map[string]int
+}
+
+func newStoreMetrics() *storeMetrics {
+	return &storeMetrics{
+		resources: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: storeMetricsSubsystem,
+			Name:      "resources",
+			Help:      "The number of the resources in the store.",
+		}),
+		sourceResources: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: storeMetricsSubsystem,
+			Name:      "source_resources",
+			Help:      "The number of the resources of the sources in the store.",
+		}, []string{"source"}),
+		clusterResources: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: storeMetricsSubsystem,
+			Name:      "cluster_resources",
+			Help:      "The number of the resources of the clusters in the store.",
+		}, []string{"cluster"}),
+		clusters: make(map[string]int),
+	}
+}
+
+func (m *storeMetrics) register(registerer prometheus.Registerer) {
+	registerer.MustRegister(
+		m.resources,
+		m.sourceResources,
+		m.clusterResources,
+	)
+}
+
+// added counts a resource that is added to the index of the sources.
+func (m *storeMetrics) added(source, cluster string) {
+	m.sourceResources.WithLabelValues(source).Inc()
+	m.clusterResources.WithLabelValues(cluster).Inc()
+	m.clusters[cluster]++
+}
+
+// removed counts a resource that is removed from the index of the sources, the gauge of the source is removed if the
+// source has no resources.
+func (m *storeMetrics) removed(source, cluster string, sourceRemoved bool) {
+	if sourceRemoved {
+		m.sourceResources.DeleteLabelValues(source)
+	} else {
+		m.sourceResources.WithLabelValues(source).Dec()
+	}
+
+	m.clusters[cluster]--
+	if m.clusters[cluster] > 0 {
+		m.clusterResources.WithLabelValues(cluster).Dec()
+		return
+	}
+	delete(m.clusters, cluster)
+	m.clusterResources.DeleteLabelValues(cluster)
+}
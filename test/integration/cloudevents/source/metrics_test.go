@@ -0,0 +1,264 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/work/payload"
+)
+
+// metricValue scrapes the registry and returns the value of the metric with the given labels.
+func metricValue(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) float64 {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			if !hasLabels(metric, labels) {
+				continue
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				return metric.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				return metric.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				return float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+
+	return 0
+}
+
+func hasLabels(metric *dto.Metric, labels map[string]string) bool {
+	matched := 0
+	for _, label := range metric.GetLabel() {
+		if value, ok := labels[label.GetName()]; ok && value == label.GetValue() {
+			matched++
+		}
+	}
+	return matched == len(labels)
+}
+
+func TestMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster, WithMetricsRegisterer(registry)))
+
+	labels := map[string]string{
+		"source":    "test-source",
+		"data_type": payload.ManifestEventDataType.String(),
+	}
+
+	res := NewResource("cluster1", "resource1")
+	if _, err := client.Publish(ctx, newPublishRequest(t, res)); err != nil {
+		t.Fatal(err)
+	}
+
+	if value := metricValue(t, registry, "cloudevents_grpc_server_published_events_total", labels); value != 1 {
+		t.Errorf("expected 1 published event, but got %v", value)
+	}
+	if value := metricValue(t, registry, "cloudevents_grpc_server_decode_duration_seconds", labels); value != 1 {
+		t.Errorf("expected 1 decode observation, but got %v", value)
+	}
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the snapshot of the published resource and the snapshot done event
+	for i := 0; i < 2; i++ {
+		if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	subscriberLabels := map[string]string{"source": "test-source"}
+	if value := metricValue(t, registry, "cloudevents_grpc_server_active_subscribers", subscriberLabels); value != 1 {
+		t.Errorf("expected 1 active subscriber, but got %v", value)
+	}
+	if value := metricValue(t, registry, "cloudevents_grpc_server_delivered_events_total", labels); value != 1 {
+		t.Errorf("expected 1 delivered event, but got %v", value)
+	}
+	if value := metricValue(t, registry, "cloudevents_grpc_server_encode_duration_seconds", labels); value != 1 {
+		t.Errorf("expected 1 encode observation, but got %v", value)
+	}
+
+	cancel()
+	err = wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true,
+		func(ctx context.Context) (bool, error) {
+			return metricValue(t, registry, "cloudevents_grpc_server_active_subscribers", subscriberLabels) == 0, nil
+		})
+	if err != nil {
+		t.Errorf("expected no active subscriber after the subscription is closed, %v", err)
+	}
+}
+
+func TestEventDataSizeMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	store := NewMemoryStore()
+	// the status of the resources are bloated by the messages of their conditions
+	for i, messageSize := range []int{0, 4 * 1024, 256 * 1024} {
+		res := NewResource("cluster1", fmt.Sprintf("resource%d", i))
+		res.Source = "test-source"
+		res.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied",
+			Message: strings.Repeat("x", messageSize)}}
+		if _, err := store.UpSert(ctx, res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster, WithMetricsRegisterer(registry)))
+
+	resp, err := client.ListResources(ctx, &pbv1.ListResourcesRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := []int{}
+	for _, pbEvt := range resp.Events {
+		sizes = append(sizes, len(pbEvt.GetBinaryData()))
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var histogram *dto.Histogram
+	for _, family := range families {
+		if family.GetName() == "cloudevents_grpc_server_event_data_size_bytes" && len(family.GetMetric()) == 1 {
+			histogram = family.GetMetric()[0].GetHistogram()
+		}
+	}
+	if histogram == nil {
+		t.Fatalf("expected the histogram of the event data sizes")
+	}
+	if histogram.GetSampleCount() != uint64(len(sizes)) {
+		t.Errorf("expected %d observations, but got %d", len(sizes), histogram.GetSampleCount())
+	}
+	populated, previous := 0, uint64(0)
+	for _, bucket := range histogram.GetBucket() {
+		expected := 0
+		for _, size := range sizes {
+			if float64(size) <= bucket.GetUpperBound() {
+				expected++
+			}
+		}
+		if bucket.GetCumulativeCount() != uint64(expected) {
+			t.Errorf("expected %d events in the bucket %v, but got %d",
+				expected, bucket.GetUpperBound(), bucket.GetCumulativeCount())
+		}
+		if bucket.GetCumulativeCount() > previous {
+			populated++
+		}
+		previous = bucket.GetCumulativeCount()
+	}
+	// the events are of the different sizes, so they populate the different buckets
+	if populated != len(sizes) {
+		t.Errorf("expected the events populate %d buckets, but got %d", len(sizes), populated)
+	}
+
+	largest := 0
+	for _, size := range sizes {
+		largest = max(largest, size)
+	}
+	value := metricValue(t, registry, "cloudevents_grpc_server_largest_event_data_size_bytes",
+		map[string]string{"source": "test-source"})
+	if value != float64(largest) {
+		t.Errorf("expected the largest event data size %d, but got %v", largest, value)
+	}
+}
+
+func TestStoreMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	store := NewMemoryStore(WithStoreMetricsRegisterer(registry))
+	newResource := func(source, cluster, name string) *Resource {
+		res := NewResource(cluster, name)
+		res.Source = source
+		return res
+	}
+
+	for _, res := range []*Resource{
+		newResource("source1", "cluster1", "resource1"),
+		newResource("source1", "cluster2", "resource2"),
+		newResource("source2", "cluster1", "resource3"),
+	} {
+		if _, err := store.UpSert(context.Background(), res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// updating a resource does not change the counts
+	updated := newResource("source1", "cluster1", "resource1")
+	updated.ResourceVersion++
+	if _, err := store.UpSert(context.Background(), updated); err != nil {
+		t.Fatal(err)
+	}
+
+	expectGauges := func(total float64, sources, clusters map[string]float64) {
+		t.Helper()
+		if value := metricValue(t, registry, "cloudevents_store_resources", nil); value != total {
+			t.Errorf("expected %v resources, but got %v", total, value)
+		}
+		for source, expected := range sources {
+			value := metricValue(t, registry, "cloudevents_store_source_resources", map[string]string{"source": source})
+			if value != expected {
+				t.Errorf("expected %v resources of source %s, but got %v", expected, source, value)
+			}
+		}
+		for cluster, expected := range clusters {
+			value := metricValue(t, registry, "cloudevents_store_cluster_resources", map[string]string{"cluster": cluster})
+			if value != expected {
+				t.Errorf("expected %v resources of cluster %s, but got %v", expected, cluster, value)
+			}
+		}
+	}
+	expectGauges(3,
+		map[string]float64{"source1": 2, "source2": 1},
+		map[string]float64{"cluster1": 2, "cluster2": 1})
+
+	store.Delete(ResourceID("cluster1", "resource3"))
+	store.Delete(ResourceID("cluster2", "resource2"))
+	expectGauges(1,
+		map[string]float64{"source1": 1, "source2": 0},
+		map[string]float64{"cluster1": 1, "cluster2": 0})
+
+	// the gauges of the sources and the clusters that have no resources are removed
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "cloudevents_store_resources" {
+			continue
+		}
+		if len(family.GetMetric()) != 1 {
+			t.Errorf("expected 1 series of %s, but got %d", family.GetName(), len(family.GetMetric()))
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	cloudeventsmqtt "github.com/cloudevents/sdk-go/protocol/mqtt_paho/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cloudeventscontext "github.com/cloudevents/sdk-go/v2/context"
+	"github.com/eclipse/paho.golang/paho"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/mqtt"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+// MQTTTransport serves the sources over the mqtt protocol, the resource specs are received from the source events
+// topic of the options, e.g. sources/+/clusters/+/sourceevents, and the resource statuses are published to the agent
+// events topic of the options with the source and the cluster of the resource, e.g. the status of a resource of the
+// source1 on the cluster1 is published to sources/source1/clusters/cluster1/agentevents for the agent events topic
+// sources/+/clusters/+/agentevents. The specs are subscribed with the SubQoS and the statuses are published with the
+// PubQoS of the options.
+type MQTTTransport struct {
+	Options *mqtt.MQTTOptions
+	// ClientID is the mqtt client ID of the transport, it is the source of the server if it is not set.
+	ClientID string
+}
+
+var _ Transport = &MQTTTransport{}
+
+func (t *MQTTTransport) Serve(ctx context.Context, svr *GRPCServer) error {
+	if !regexp.MustCompile(types.SourceEventsTopicPattern).MatchString(t.Options.Topics.SourceEvents) {
+		return fmt.Errorf("invalid source events topic %q, it should match `%s`",
+			t.Options.Topics.SourceEvents, types.SourceEventsTopicPattern)
+	}
+	if !regexp.MustCompile(types.AgentEventsTopicPattern).MatchString(t.Options.Topics.AgentEvents) {
+		return fmt.Errorf("invalid agent events topic %q, it should match `%s`",
+			t.Options.Topics.AgentEvents, types.AgentEventsTopicPattern)
+	}
+
+	clientID := t.ClientID
+	if clientID == "" {
+		clientID = svr.source
+	}
+
+	clientErr := make(chan error, 1)
+	protocol, err := t.Options.GetCloudEventsProtocol(ctx, clientID,
+		func(err error) {
+			select {
+			case clientErr <- err:
+			default:
+			}
+		},
+		cloudeventsmqtt.WithPublish(&paho.Publish{QoS: byte(t.Options.PubQoS)}),
+		cloudeventsmqtt.WithSubscribe(&paho.Subscribe{
+			Subscriptions: map[string]paho.SubscribeOptions{
+				t.Options.Topics.SourceEvents: {QoS: byte(t.Options.SubQoS)},
+			},
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create the mqtt protocol: %v", err)
+	}
+	defer protocol.Close(context.Background())
+
+	client, err := cloudevents.NewClient(protocol)
+	if err != nil {
+		return fmt.Errorf("failed to create the mqtt client: %v", err)
+	}
+
+	id, errChan := svr.eventBroadcaster.Register(func(string) bool { return true }, func(res *Resource) error {
+		evt, err := svr.encodeStatusEvent(res)
+		if err != nil {
+			return err
+		}
+
+		sendCtx := cloudeventscontext.WithTopic(ctx, t.statusTopic(res))
+		if result := client.Send(sendCtx, *evt); cloudevents.IsUndelivered(result) {
+			return fmt.Errorf("failed to send the status event of the resource %s: %v", res.ResourceID, result)
+		}
+		return nil
+	})
+	defer svr.eventBroadcaster.Unregister(id)
+
+	receiverCtx, cancel := context.WithCancel(ctx)
+	receiverErr := make(chan error, 1)
+	go func() {
+		receiverErr <- client.StartReceiver(receiverCtx, svr.publishReceived)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err = <-errChan:
+	case err = <-clientErr:
+	case err = <-receiverErr:
+		cancel()
+		return err
+	}
+
+	// the receiver is stopped before the protocol is closed
+	cancel()
+	<-receiverErr
+	return err
+}
+
+// statusTopic returns the agent events topic of the source and the cluster of the resource, the wildcards of the
+// source and the cluster are replaced, and the shared subscription prefix is removed.
+func (t *MQTTTransport) statusTopic(res *Resource) string {
+	topic := regexp.MustCompile(`^\$share/[a-z0-9-]+/`).ReplaceAllString(t.Options.Topics.AgentEvents, "")
+
+	// the topic is <prefix>/<source>/<prefix>/<cluster>/agentevents
+	subTopics := strings.Split(topic, "/")
+	if subTopics[1] == "+" {
+		subTopics[1] = res.Source
+	}
+	if subTopics[3] == "+" {
+		subTopics[3] = res.Namespace
+	}
+	return strings.Join(subTopics, "/")
+}
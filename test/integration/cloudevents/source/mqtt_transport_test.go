@@ -0,0 +1,253 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	cloudeventsmqtt "github.com/cloudevents/sdk-go/protocol/mqtt_paho/v2"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cloudeventscontext "github.com/cloudevents/sdk-go/v2/context"
+	"github.com/eclipse/paho.golang/paho"
+	mochimqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/mqtt"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/test/integration/cloudevents/util"
+)
+
+const (
+	testSourceEventsTopic = "sources/test-source/clusters/cluster1/sourceevents"
+	testAgentEventsTopic  = "sources/test-source/clusters/cluster1/agentevents"
+)
+
+// publishedQoSHook records the QoS of the messages that are published to the broker by the topics.
+type publishedQoSHook struct {
+	mochimqtt.HookBase
+
+	mu  sync.Mutex
+	qos map[string]byte
+}
+
+func (h *publishedQoSHook) ID() string {
+	return "published-qos"
+}
+
+func (h *publishedQoSHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{mochimqtt.OnPublished}, []byte{b})
+}
+
+func (h *publishedQoSHook) OnPublished(cl *mochimqtt.Client, pk packets.Packet) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.qos[pk.TopicName] = pk.FixedHeader.Qos
+}
+
+func (h *publishedQoSHook) get(topic string) (byte, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	qos, ok := h.qos[topic]
+	return qos, ok
+}
+
+func TestMQTTTransport(t *testing.T) {
+	cases := []struct {
+		name   string
+		pubQoS int
+	}{
+		{
+			name:   "at most once",
+			pubQoS: 0,
+		},
+		{
+			name:   "at least once",
+			pubQoS: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			broker, brokerHost, qosHook := startTestMQTTBroker(t)
+			options := func() *mqtt.MQTTOptions {
+				return &mqtt.MQTTOptions{
+					KeepAlive: 60,
+					PubQoS:    c.pubQoS,
+					SubQoS:    1,
+					Topics: types.Topics{
+						SourceEvents: "sources/+/clusters/+/sourceevents",
+						AgentEvents:  "sources/+/clusters/+/agentevents",
+					},
+					Dialer: &mqtt.MQTTDialer{BrokerHost: brokerHost, Timeout: 5 * time.Second},
+				}
+			}
+
+			eventBroadcaster := NewEventBroadcaster()
+			go eventBroadcaster.Start(ctx)
+			store := NewMemoryStore()
+			transport := &MQTTTransport{Options: options()}
+			go func() {
+				_ = transport.Serve(ctx, NewGRPCServer(store, eventBroadcaster))
+			}()
+
+			subscriber, err := options().GetCloudEventsProtocol(ctx, "test-subscriber", func(error) {},
+				cloudeventsmqtt.WithSubscribe(&paho.Subscribe{
+					Subscriptions: map[string]paho.SubscribeOptions{testAgentEventsTopic: {QoS: 1}},
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			subscriberClient, err := cloudevents.NewClient(subscriber)
+			if err != nil {
+				t.Fatal(err)
+			}
+			received := make(chan cloudevents.Event, 1)
+			go func() {
+				_ = subscriberClient.StartReceiver(ctx, func(evt cloudevents.Event) {
+					received <- evt
+				})
+			}()
+
+			publisher, err := options().GetCloudEventsProtocol(ctx, "test-publisher", func(error) {},
+				cloudeventsmqtt.WithPublish(&paho.Publish{QoS: 1}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			publisherClient, err := cloudevents.NewClient(publisher)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the messages are not retained, so they are published after the topics are subscribed
+			err = wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 10*time.Second, true,
+				func(ctx context.Context) (bool, error) {
+					return isSubscribed(broker, testSourceEventsTopic) &&
+						isSubscribed(broker, testAgentEventsTopic), nil
+				})
+			if err != nil {
+				t.Fatalf("expected the topics are subscribed, %v", err)
+			}
+
+			res := NewResource("cluster1", "resource1")
+			evt, err := (&ResourceCodec{}).Encode("test-source", testSpecEventType, res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubCtx := cloudeventscontext.WithTopic(ctx, testSourceEventsTopic)
+			if result := publisherClient.Send(pubCtx, *evt); cloudevents.IsUndelivered(result) {
+				t.Fatal(result)
+			}
+
+			var stored *Resource
+			err = wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 10*time.Second, true,
+				func(ctx context.Context) (bool, error) {
+					stored, err = store.Get(res.ResourceID)
+					return err == nil, nil
+				})
+			if err != nil {
+				t.Fatalf("expected the resource is published, %v", err)
+			}
+
+			stored.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied"}}
+			eventBroadcaster.Broadcast(stored)
+
+			select {
+			case evt := <-received:
+				status, err := (&ResourceCodec{}).Decode(&evt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if status.ResourceID != res.ResourceID ||
+					!equality.Semantic.DeepEqual(status.Status.Conditions, stored.Status.Conditions) {
+					t.Errorf("unexpected status event of resource %s: %v", status.ResourceID, status.Status.Conditions)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("expected the status event, but got nothing")
+			}
+
+			qos, ok := qosHook.get(testAgentEventsTopic)
+			if !ok || qos != byte(c.pubQoS) {
+				t.Errorf("expected the status event is published with QoS %d, but got %d", c.pubQoS, qos)
+			}
+		})
+	}
+}
+
+func TestMQTTStatusTopic(t *testing.T) {
+	cases := []struct {
+		name          string
+		agentEvents   string
+		expectedTopic string
+	}{
+		{
+			name:          "all sources and clusters",
+			agentEvents:   "sources/+/clusters/+/agentevents",
+			expectedTopic: testAgentEventsTopic,
+		},
+		{
+			name:          "one source",
+			agentEvents:   "sources/test-source/clusters/+/agentevents",
+			expectedTopic: testAgentEventsTopic,
+		},
+		{
+			name:          "shared subscription",
+			agentEvents:   "$share/test-group/sources/+/clusters/+/agentevents",
+			expectedTopic: testAgentEventsTopic,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			transport := &MQTTTransport{Options: &mqtt.MQTTOptions{Topics: types.Topics{AgentEvents: c.agentEvents}}}
+			res := NewResource("cluster1", "resource1")
+			res.Source = "test-source"
+
+			if topic := transport.statusTopic(res); topic != c.expectedTopic {
+				t.Errorf("expected topic %s, but got %s", c.expectedTopic, topic)
+			}
+		})
+	}
+}
+
+func startTestMQTTBroker(t *testing.T) (*mochimqtt.Server, string, *publishedQoSHook) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	brokerHost := lis.Addr().String()
+	if err := lis.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	broker := mochimqtt.New(&mochimqtt.Options{})
+	if err := broker.AddHook(new(util.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	qosHook := &publishedQoSHook{qos: map[string]byte{}}
+	if err := broker.AddHook(qosHook, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.AddListener(listeners.NewTCP("test-broker", brokerHost, nil)); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = broker.Serve()
+	}()
+	t.Cleanup(func() { _ = broker.Close() })
+
+	return broker, brokerHost, qosHook
+}
+
+func isSubscribed(broker *mochimqtt.Server, topic string) bool {
+	return len(broker.Topics.Subscribers(topic).Subscriptions) > 0
+}
@@ -0,0 +1,126 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding/format"
+	"github.com/cloudevents/sdk-go/v2/binding/spec"
+	"github.com/cloudevents/sdk-go/v2/event"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	grpcprotocol "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protocol"
+)
+
+const (
+	// pbAttributePrefix is the prefix of the names of the attributes and the extensions of the protobuf cloudevents,
+	// the data content type is not prefixed, its name is pbContentType.
+	pbAttributePrefix = "ce-"
+	pbContentType     = "contenttype"
+)
+
+var pbSpecs = spec.WithPrefix(pbAttributePrefix)
+
+// fromPBEvent converts a published protobuf cloudevent to a cloudevent. A malformed event is rejected with the
+// InvalidArgument status error whose ErrorInfo detail tells the class of the failure and the attribute that fails,
+// e.g. the event is not set, a required attribute is missing, or an extension is of a type that cannot be converted,
+// so the publisher knows what to fix.
+func fromPBEvent(ctx context.Context, pbEvt *pbv1.CloudEvent) (*cloudevents.Event, error) {
+	if pbEvt == nil {
+		return nil, toMalformedEventError(ReasonEventNotSet, "", errors.New("the cloudevent is not set"))
+	}
+
+	if err := validateSpecVersion(pbEvt); err != nil {
+		return nil, err
+	}
+
+	// WARNING: don't use "evt, err := pb.FromProto(pubReq.Event)" to convert protobuf to cloudevent, the ToEvent of
+	// the grpc protocol converts it as binding.ToEvent does, but without the binding writers for the binary events.
+	evt, err := grpcprotocol.ToEvent(ctx, pbEvt)
+	var attrErr *grpcprotocol.AttributeError
+	if errors.As(err, &attrErr) {
+		reason := ReasonInvalidAttribute
+		if attrErr.Extension {
+			reason = ReasonInvalidExtension
+		}
+		return nil, toMalformedEventError(reason, attrErr.Name,
+			fmt.Errorf("%v, failed to convert protobuf to cloudevent: %v", ErrDecode, err))
+	}
+	if err != nil {
+		return nil, toMalformedEventError(ReasonMalformedEvent, "",
+			fmt.Errorf("%v, failed to convert protobuf to cloudevent: %v", ErrDecode, err))
+	}
+
+	if err := evt.Validate(); err != nil {
+		return nil, toEventValidationError(evt, err)
+	}
+
+	return evt, nil
+}
+
+// validateSpecVersion validates the spec version of a binary protobuf cloudevent before it is converted, since the
+// message cannot be read without it. The spec version of a structured event is in its data, it is validated once the
+// event is converted.
+func validateSpecVersion(pbEvt *pbv1.CloudEvent) error {
+	if contentType, ok := pbEvt.Attributes[pbContentType]; ok && format.IsFormat(contentType.GetCeString()) {
+		return nil
+	}
+
+	if len(pbEvt.SpecVersion) == 0 {
+		return toMalformedEventError(ReasonMissingAttribute, "specversion",
+			errors.New("the specversion of the cloudevent is not set"))
+	}
+	if pbSpecs.Version(pbEvt.SpecVersion) == nil {
+		return toMalformedEventError(ReasonInvalidAttribute, "specversion",
+			fmt.Errorf("unsupported specversion %s of the cloudevent", pbEvt.SpecVersion))
+	}
+
+	return nil
+}
+
+// toEventValidationError converts the error of validating a converted cloudevent to the status error that rejects
+// it. The validation error is reported for its first attribute, a required attribute that is empty is missing.
+func toEventValidationError(evt *cloudevents.Event, err error) error {
+	var validationErr event.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr) == 0 {
+		return toMalformedEventError(ReasonMalformedEvent, "", fmt.Errorf("invalid cloudevent: %v", err))
+	}
+
+	attributes := make([]string, 0, len(validationErr))
+	for attribute := range validationErr {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+	attribute := attributes[0]
+
+	reason := ReasonInvalidAttribute
+	if isEmptyAttribute(evt, attribute) {
+		reason = ReasonMissingAttribute
+	}
+	version := pbSpecs.Version(evt.SpecVersion())
+	if version != nil && version.Attribute(pbAttributePrefix+attribute) == nil {
+		reason = ReasonInvalidExtension
+	}
+
+	return toMalformedEventError(reason, attribute,
+		fmt.Errorf("invalid %s of the cloudevent: %v", attribute, validationErr[attribute]))
+}
+
+// isEmptyAttribute returns true if the attribute is a required attribute of the cloudevent and it is empty.
+func isEmptyAttribute(evt *cloudevents.Event, attribute string) bool {
+	switch attribute {
+	case "id":
+		return len(evt.ID()) == 0
+	case "source":
+		return len(evt.Source()) == 0
+	case "specversion":
+		return len(evt.SpecVersion()) == 0
+	case "type":
+		return len(evt.Type()) == 0
+	default:
+		return false
+	}
+}
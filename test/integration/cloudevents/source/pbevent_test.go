@@ -0,0 +1,165 @@
+package source
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+func TestPublishWithMalformedEvents(t *testing.T) {
+	cases := []struct {
+		name              string
+		malform           func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent
+		expectedReason    string
+		expectedAttribute string
+	}{
+		{
+			name:           "no event",
+			malform:        func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent { return nil },
+			expectedReason: ReasonEventNotSet,
+		},
+		{
+			name: "no specversion",
+			malform: func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent {
+				pbEvt.SpecVersion = ""
+				return pbEvt
+			},
+			expectedReason:    ReasonMissingAttribute,
+			expectedAttribute: "specversion",
+		},
+		{
+			name: "unsupported specversion",
+			malform: func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent {
+				pbEvt.SpecVersion = "2.0"
+				return pbEvt
+			},
+			expectedReason:    ReasonInvalidAttribute,
+			expectedAttribute: "specversion",
+		},
+		{
+			name: "no id",
+			malform: func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent {
+				pbEvt.Id = ""
+				return pbEvt
+			},
+			expectedReason:    ReasonMissingAttribute,
+			expectedAttribute: "id",
+		},
+		{
+			name: "no source",
+			malform: func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent {
+				pbEvt.Source = ""
+				return pbEvt
+			},
+			expectedReason:    ReasonMissingAttribute,
+			expectedAttribute: "source",
+		},
+		{
+			name: "no type",
+			malform: func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent {
+				pbEvt.Type = ""
+				return pbEvt
+			},
+			expectedReason:    ReasonMissingAttribute,
+			expectedAttribute: "type",
+		},
+		{
+			name: "attribute without value",
+			malform: func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent {
+				pbEvt.Attributes["ce-subject"] = &pbv1.CloudEventAttributeValue{}
+				return pbEvt
+			},
+			expectedReason:    ReasonInvalidAttribute,
+			expectedAttribute: "subject",
+		},
+		{
+			name: "attribute of wrong type",
+			malform: func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent {
+				pbEvt.Attributes["ce-time"] = &pbv1.CloudEventAttributeValue{
+					Attr: &pbv1.CloudEventAttributeValue_CeBoolean{CeBoolean: true},
+				}
+				return pbEvt
+			},
+			expectedReason:    ReasonInvalidAttribute,
+			expectedAttribute: "time",
+		},
+		{
+			name: "extension without value",
+			malform: func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent {
+				pbEvt.Attributes["ce-"+types.ExtensionResourceID] = &pbv1.CloudEventAttributeValue{}
+				return pbEvt
+			},
+			expectedReason:    ReasonInvalidExtension,
+			expectedAttribute: types.ExtensionResourceID,
+		},
+		{
+			name: "extension with invalid name",
+			malform: func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent {
+				pbEvt.Attributes["ce-resource_id"] = &pbv1.CloudEventAttributeValue{
+					Attr: &pbv1.CloudEventAttributeValue_CeString{CeString: "resource1"},
+				}
+				return pbEvt
+			},
+			expectedReason:    ReasonInvalidExtension,
+			expectedAttribute: "resource_id",
+		},
+		{
+			name: "malformed structured event",
+			malform: func(pbEvt *pbv1.CloudEvent) *pbv1.CloudEvent {
+				return &pbv1.CloudEvent{
+					Attributes: map[string]*pbv1.CloudEventAttributeValue{
+						"contenttype": {
+							Attr: &pbv1.CloudEventAttributeValue_CeString{CeString: "application/cloudevents+json"},
+						},
+					},
+					Data: &pbv1.CloudEvent_BinaryData{BinaryData: []byte("{")},
+				}
+			},
+			expectedReason: ReasonMalformedEvent,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+
+			res := NewResource("cluster1", "resource1")
+			pubReq := newPublishRequest(t, res)
+			pubReq.Event = c.malform(pubReq.Event)
+
+			_, err := client.Publish(context.Background(), pubReq)
+			st := status.Convert(err)
+			if st.Code() != codes.InvalidArgument {
+				t.Fatalf("expected invalid argument status, but got %v", err)
+			}
+
+			var errorInfo *errdetails.ErrorInfo
+			for _, detail := range st.Details() {
+				if info, ok := detail.(*errdetails.ErrorInfo); ok {
+					errorInfo = info
+				}
+			}
+			if errorInfo == nil {
+				t.Fatalf("expected the error info detail in %v", st.Details())
+			}
+			if errorInfo.Domain != SubscriptionErrorDomain || errorInfo.Reason != c.expectedReason {
+				t.Errorf("expected reason %s of %s, but got %s of %s",
+					c.expectedReason, SubscriptionErrorDomain, errorInfo.Reason, errorInfo.Domain)
+			}
+			if attribute := errorInfo.Metadata[AttributeMetadataKey]; attribute != c.expectedAttribute {
+				t.Errorf("expected attribute %q, but got %q", c.expectedAttribute, attribute)
+			}
+
+			if _, err := store.Get(res.ResourceID); err == nil {
+				t.Errorf("expected the malformed event is not stored")
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// persistedResources is the content of the file that the resources of the store are persisted to.
+type persistedResources struct {
+	Resources []*persistedResource `json:"resources"`
+}
+
+// persistedResource is a resource in the persistence file. The spec is persisted as its object, since the unstructured
+// JSON of a spec cannot be read back without its kind, e.g. the spec of a resource of a TypedCodec.
+type persistedResource struct {
+	*Resource
+	Spec map[string]interface{} `json:"Spec,omitempty"`
+}
+
+// persistResources persists the resources of the store if WithPersistenceFile is set, the failure is logged.
+func (svr *GRPCServer) persistResources() {
+	if len(svr.persistenceFile) == 0 {
+		return
+	}
+
+	if err := writeResources(svr.store, svr.persistenceFile); err != nil {
+		svr.logger.Error(err, "failed to persist the resources", "file", svr.persistenceFile)
+	}
+}
+
+// writeResources writes all of the resources of the store to the file in the order of their IDs. The resources are
+// written to a temporary file that replaces the file, so the file is never left half written.
+func writeResources(store Store, path string) error {
+	resources := sortResources(store.ListBySourceAndCluster(func(string) bool { return true }, ""))
+	persisted := &persistedResources{Resources: make([]*persistedResource, 0, len(resources))}
+	for _, res := range resources {
+		persisted.Resources = append(persisted.Resources, &persistedResource{Resource: res, Spec: res.Spec.Object})
+	}
+
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the resources: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// restoreResources upserts the resources that are persisted in the file into the store, nothing is restored if the
+// file does not exist.
+func restoreResources(store Store, path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	persisted := &persistedResources{}
+	if err := json.Unmarshal(data, persisted); err != nil {
+		return fmt.Errorf("failed to unmarshal the resources: %v", err)
+	}
+
+	errs := []error{}
+	for _, res := range persisted.Resources {
+		if res.Resource == nil {
+			continue
+		}
+
+		res.Resource.Spec.Object = res.Spec
+		if _, err := store.UpSert(context.Background(), res.Resource); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore resource %s: %v", res.ResourceID, err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
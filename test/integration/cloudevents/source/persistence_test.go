@@ -0,0 +1,76 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestServerWithPersistenceFile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "resources.json")
+
+	// nothing is restored before the resources are persisted
+	store := NewMemoryStore()
+	svr := NewGRPCServer(store, NewEventBroadcaster(), WithPersistenceFile(path))
+	if resources := store.Snapshot(); len(resources) != 0 {
+		t.Fatalf("expected no resource is restored, but got %d", len(resources))
+	}
+
+	deleted := metav1.NewTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	resources := []*Resource{}
+	for i, name := range []string{"resource1", "resource2", "resource3"} {
+		res := NewResource("cluster1", name)
+		res.Source = "test-source"
+		res.ResourceVersion = int64(i + 1)
+		res.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied",
+			LastTransitionTime: deleted}}
+		resources = append(resources, res)
+	}
+	resources[2].DeletionTimestamp = &deleted
+	// the spec of a resource of a typed codec has no kind
+	resources[1].Spec.Object = map[string]interface{}{"clusterSet": "default"}
+	for _, res := range resources {
+		if _, err := store.UpSert(ctx, res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inMemoryServer, err := StartInMemoryServer(svr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inMemoryServer.Close(ctx)
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the resources are persisted, but got %v", err)
+	}
+
+	// the resources are restored into the store of the new server
+	restored := NewMemoryStore()
+	NewGRPCServer(restored, NewEventBroadcaster(), WithPersistenceFile(path))
+	for _, res := range resources {
+		got, err := restored.Get(res.ResourceID)
+		if err != nil {
+			t.Fatalf("expected resource %s is restored, but got %v", res.ResourceID, err)
+		}
+		if got.Source != res.Source || got.ResourceVersion != res.ResourceVersion || got.Namespace != res.Namespace ||
+			!got.DeletionTimestamp.Equal(res.DeletionTimestamp) {
+			t.Errorf("expected the restored resource %v, but got %v", res, got)
+		}
+		if !equality.Semantic.DeepEqual(got.Spec, res.Spec) || !equality.Semantic.DeepEqual(got.Status, res.Status) {
+			t.Errorf("expected the spec %v and the status %v of resource %s, but got %v and %v",
+				res.Spec, res.Status, res.ResourceID, got.Spec, got.Status)
+		}
+	}
+	if count := len(restored.Snapshot()); count != len(resources) {
+		t.Errorf("expected %d resources are restored, but got %d", len(resources), count)
+	}
+}
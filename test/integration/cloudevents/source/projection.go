@@ -0,0 +1,24 @@
+package source
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+)
+
+// project returns a copy of the resource that only keeps the fields of the projection, the resource itself is
+// returned for the full projection. The resource is shared by the subscribers, so it is never modified.
+func project(res *Resource, projection pbv1.Projection) *Resource {
+	switch projection {
+	case pbv1.Projection_PROJECTION_STATUS_ONLY:
+		projected := *res
+		projected.Spec = unstructured.Unstructured{}
+		return &projected
+	case pbv1.Projection_PROJECTION_SPEC_ONLY:
+		projected := *res
+		projected.Status = ResourceStatus{}
+		return &projected
+	default:
+		return res
+	}
+}
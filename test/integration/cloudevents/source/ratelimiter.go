@@ -0,0 +1,51 @@
+package source
+
+import (
+	"sync"
+
+	"k8s.io/client-go/util/flowcontrol"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options"
+)
+
+// publishRateLimiter limits the publish requests of all of the sources and of each source with token buckets, the
+// requests are not limited if the limits are not set.
+type publishRateLimiter struct {
+	mu sync.Mutex
+
+	global      flowcontrol.RateLimiter
+	sourceLimit *options.EventRateLimit
+	sources     map[string]flowcontrol.RateLimiter
+}
+
+func newPublishRateLimiter() *publishRateLimiter {
+	return &publishRateLimiter{
+		sources: make(map[string]flowcontrol.RateLimiter),
+	}
+}
+
+// tryAccept returns true if a publish request of the source is allowed. The limit of the source is consulted first,
+// so a flooding source does not consume the tokens of the global limit.
+func (l *publishRateLimiter) tryAccept(source string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.sourceLimit != nil {
+		limiter, ok := l.sources[source]
+		if !ok {
+			limiter = generic.NewRateLimiter(*l.sourceLimit)
+			l.sources[source] = limiter
+		}
+
+		if !limiter.TryAccept() {
+			return false
+		}
+	}
+
+	if l.global != nil {
+		return l.global.TryAccept()
+	}
+
+	return true
+}
@@ -0,0 +1,40 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recoverPanic recovers the panic of handling the method, the panic is logged with the stack trace and the err is set
+// to an Internal status error, so a bad event cannot crash the server. It must be deferred directly.
+func (svr *GRPCServer) recoverPanic(method string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	svr.logger.Error(fmt.Errorf("%v", r), "recovered from panic", "method", method, "stack", string(debug.Stack()))
+	*err = status.Error(codes.Internal, fmt.Sprintf("internal error when handling %s", method))
+}
+
+// unaryRecoveryInterceptor recovers the panics of the unary requests.
+func (svr *GRPCServer) unaryRecoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer svr.recoverPanic(info.FullMethod, &err)
+
+	return handler(ctx, req)
+}
+
+// streamRecoveryInterceptor recovers the panics of the stream requests, the events of a subscription are handled in
+// the event broadcaster, their panics are recovered by the handler of the subscription.
+func (svr *GRPCServer) streamRecoveryInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) (err error) {
+	defer svr.recoverPanic(info.FullMethod, &err)
+
+	return handler(srv, ss)
+}
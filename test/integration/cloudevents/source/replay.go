@@ -0,0 +1,106 @@
+package source
+
+import (
+	"errors"
+	"sort"
+)
+
+// ExtensionResumeToken is the extension of the status events that carries the resume token of the event, a subscriber
+// can resume from the last event it received with the token.
+const ExtensionResumeToken = "resumetoken"
+
+// ErrResumeTokenExpired is returned when a client resumes from an event that is no longer kept by the replay buffers
+// of the event broadcaster, the client has to resync all of the resources.
+var ErrResumeTokenExpired = errors.New("the resume token is expired")
+
+// replayBuffer is a ring buffer that keeps the recent events of a source.
+type replayBuffer struct {
+	events []*Resource
+	// next is the index that the next event is written to.
+	next int
+	// full is true once the buffer is wrapped around, the oldest event is at next.
+	full bool
+	// evicted is the sequence of the last event that is overwritten.
+	evicted uint64
+}
+
+func newReplayBuffer(size int) *replayBuffer {
+	return &replayBuffer{events: make([]*Resource, size)}
+}
+
+func (b *replayBuffer) add(res *Resource) {
+	if b.full {
+		b.evicted = b.events[b.next].Sequence
+	}
+
+	b.events[b.next] = res
+	b.next = (b.next + 1) % len(b.events)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// after returns the events whose sequences are greater than the given sequence in order.
+func (b *replayBuffer) after(sequence uint64) []*Resource {
+	start, count := 0, b.next
+	if b.full {
+		start, count = b.next, len(b.events)
+	}
+
+	events := []*Resource{}
+	for i := 0; i < count; i++ {
+		res := b.events[(start+i)%len(b.events)]
+		if res.Sequence > sequence {
+			events = append(events, res)
+		}
+	}
+	return events
+}
+
+// replay returns the buffered events of the sources that match the filter after the sequence in order. It must be
+// called with the lock held.
+func (eb *EventBroadcaster) replay(filter SourceFilter, sequence uint64) ([]*Resource, error) {
+	if eb.replayBufferSize <= 0 || sequence > eb.sequence {
+		return nil, ErrResumeTokenExpired
+	}
+
+	events := []*Resource{}
+	for source, buffer := range eb.replayBuffers {
+		if !filter(source) {
+			continue
+		}
+
+		if buffer.evicted > sequence {
+			return nil, ErrResumeTokenExpired
+		}
+		events = append(events, buffer.after(sequence)...)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence < events[j].Sequence
+	})
+	return events, nil
+}
+
+// record assigns the next sequence to the event and keeps it in the replay buffer of its source. It must be called
+// with the lock held.
+func (eb *EventBroadcaster) record(res *Resource) *Resource {
+	if eb.replayBufferSize <= 0 {
+		return res
+	}
+
+	eb.sequence++
+
+	// the broadcast resource may be shared, so the sequence is set on a copy
+	recorded := *res
+	recorded.Sequence = eb.sequence
+
+	buffer, ok := eb.replayBuffers[res.Source]
+	if !ok {
+		buffer = newReplayBuffer(eb.replayBufferSize)
+		eb.replayBuffers[res.Source] = buffer
+	}
+	buffer.add(&recorded)
+
+	return &recorded
+}
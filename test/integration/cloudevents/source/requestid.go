@@ -0,0 +1,66 @@
+package source
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader is the metadata of the requests that carries the id of the request, an id is generated if it is not
+// set. The id is sent back in the response header and it is the "requestID" of the server logs of the request, so
+// the log lines of a request can be correlated with each other and with the client.
+const RequestIDHeader = "x-request-id"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the id of the request, it is false if the context is not of a request.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// withRequestID returns the context with the request id from the metadata of the request, or a generated one if the
+// metadata does not have it.
+func withRequestID(ctx context.Context) (context.Context, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := uuid.NewString()
+	if values := md.Get(RequestIDHeader); len(values) != 0 && len(values[0]) != 0 {
+		id = values[0]
+	}
+
+	return context.WithValue(ctx, requestIDKey{}, id), id
+}
+
+// requestLogger returns the logger of the server with the id of the request, it is the logger of the server if the
+// context is not of a request, e.g. an event that is published over mqtt.
+func (svr *GRPCServer) requestLogger(ctx context.Context) logr.Logger {
+	if id, ok := RequestIDFromContext(ctx); ok {
+		return svr.logger.WithValues("requestID", id)
+	}
+	return svr.logger
+}
+
+// unaryRequestIDInterceptor sets the request id of the unary requests.
+func (svr *GRPCServer) unaryRequestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, id := withRequestID(ctx)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id)); err != nil {
+		svr.requestLogger(ctx).Error(err, "failed to set the request id header")
+	}
+
+	return handler(ctx, req)
+}
+
+// streamRequestIDInterceptor sets the request id of the stream requests.
+func (svr *GRPCServer) streamRequestIDInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	ctx, id := withRequestID(ss.Context())
+	if err := ss.SetHeader(metadata.Pairs(RequestIDHeader, id)); err != nil {
+		svr.requestLogger(ctx).Error(err, "failed to set the request id header")
+	}
+
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
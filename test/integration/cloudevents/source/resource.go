@@ -2,21 +2,54 @@ package source
 
 import (
 	"fmt"
+	"maps"
+	"time"
 
 	"github.com/google/uuid"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	kubetypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/errors"
 
 	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
 )
 
+// ExtensionObservedGeneration is the extension of the events that carries the observed generation of the resource, it
+// is not set if the observed generation is zero.
+const ExtensionObservedGeneration = "observedgeneration"
+
 type ResourceStatus struct {
 	Conditions []metav1.Condition
 }
 
+// Validate validates the conditions of the status, each condition must have a type and a status, and the status must
+// be one of True, False and Unknown.
+func (s ResourceStatus) Validate() error {
+	errs := []error{}
+	for i, cond := range s.Conditions {
+		if len(cond.Type) == 0 {
+			errs = append(errs, fmt.Errorf("conditions[%d].type is required", i))
+		}
+
+		switch cond.Status {
+		case metav1.ConditionTrue, metav1.ConditionFalse, metav1.ConditionUnknown:
+		case "":
+			errs = append(errs, fmt.Errorf("conditions[%d].status is required", i))
+		default:
+			errs = append(errs, fmt.Errorf("conditions[%d].status %q is invalid, it must be one of %q, %q and %q",
+				i, cond.Status, metav1.ConditionTrue, metav1.ConditionFalse, metav1.ConditionUnknown))
+		}
+	}
+
+	return errors.NewAggregate(errs)
+}
+
 type Resource struct {
+	// DataType is the cloudevents data type of the resource, the resource without data type is treated as a manifest.
+	DataType          types.CloudEventsDataType
 	Source            string
 	ResourceID        string
 	ResourceVersion   int64
@@ -24,6 +57,31 @@ type Resource struct {
 	DeletionTimestamp *metav1.Time
 	Spec              unstructured.Unstructured
 	Status            ResourceStatus
+	// EventID and EventTime are the id and the time of the cloudevent that the resource is decoded from, they can
+	// be used to deduplicate the replayed events and to order the events.
+	EventID   string
+	EventTime time.Time
+	// Sequence is the sequence of the status event of the resource that is assigned by the event broadcaster when
+	// the replay buffer is enabled, otherwise, it is zero.
+	Sequence uint64
+	// SubscriptionSequence is the sequence of the status event of the resource in the subscription of the client that
+	// handles it, it is assigned by the event broadcaster when the event is handled by a client, otherwise, it is zero.
+	SubscriptionSequence uint64
+	// AgentID is the id of the agent that produces the event of the resource, it is empty if the event is not
+	// produced by an agent.
+	AgentID string
+	// ObservedGeneration is the generation of the spec that the status of the resource is observed from, it is
+	// reported by the agent with the status, so the controllers know if the status reflects the latest spec. It is
+	// zero if it is not reported.
+	ObservedGeneration int64
+	// Labels and Annotations are the metadata labels and annotations of the manifest of the resource, they are
+	// captured when the manifest is decoded, so the subscribers can be filtered by the labels without the manifest.
+	Labels      map[string]string
+	Annotations map[string]string
+	// TraceParent and TraceState are the trace context of the last publish of the resource, the status events of the
+	// resource are delivered in the same trace.
+	TraceParent string
+	TraceState  string
 }
 
 var _ generic.ResourceObject = &Resource{}
@@ -46,6 +104,34 @@ func NewResource(namespace, name string) *Resource {
 	}
 }
 
+// DeepCopy returns a deep copy of the resource.
+func (r *Resource) DeepCopy() *Resource {
+	copied := *r
+	copied.DeletionTimestamp = r.DeletionTimestamp.DeepCopy()
+	copied.Spec = *r.Spec.DeepCopy()
+	copied.Labels = maps.Clone(r.Labels)
+	copied.Annotations = maps.Clone(r.Annotations)
+	if r.Status.Conditions != nil {
+		copied.Status.Conditions = make([]metav1.Condition, len(r.Status.Conditions))
+		for i := range r.Status.Conditions {
+			r.Status.Conditions[i].DeepCopyInto(&copied.Status.Conditions[i])
+		}
+	}
+	return &copied
+}
+
+// Equal returns true if the resource has the same spec, status conditions and deletion timestamp with the other one,
+// the versions and the metadata of the events are not compared.
+func (r *Resource) Equal(other *Resource) bool {
+	if r == nil || other == nil {
+		return r == other
+	}
+
+	return r.DeletionTimestamp.Equal(other.DeletionTimestamp) &&
+		equality.Semantic.DeepEqual(r.Spec, other.Spec) &&
+		equality.Semantic.DeepEqual(r.Status.Conditions, other.Status.Conditions)
+}
+
 func (r *Resource) GetUID() kubetypes.UID {
 	return kubetypes.UID(r.ResourceID)
 }
@@ -61,3 +147,96 @@ func (r *Resource) GetDeletionTimestamp() *metav1.Time {
 func ResourceID(namespace, name string) string {
 	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprintf("resource-%s-%s", namespace, name))).String()
 }
+
+// ResourceBuilder builds a Resource with fluent setters, e.g. in the tests and the tools, the resource is validated
+// when it is built.
+type ResourceBuilder struct {
+	source            string
+	resourceID        string
+	resourceVersion   int64
+	namespace         string
+	spec              *unstructured.Unstructured
+	conditions        []metav1.Condition
+	deletionTimestamp *metav1.Time
+}
+
+// NewResourceBuilder creates a builder of the resources of the source, the version of the resources is 1 unless it is
+// set.
+func NewResourceBuilder(source string) *ResourceBuilder {
+	return &ResourceBuilder{
+		source:          source,
+		resourceVersion: 1,
+	}
+}
+
+func (b *ResourceBuilder) WithResourceID(resourceID string) *ResourceBuilder {
+	b.resourceID = resourceID
+	return b
+}
+
+func (b *ResourceBuilder) WithResourceVersion(resourceVersion int64) *ResourceBuilder {
+	b.resourceVersion = resourceVersion
+	return b
+}
+
+// WithNamespace sets the namespace of the resource, that is the cluster of the resource.
+func (b *ResourceBuilder) WithNamespace(namespace string) *ResourceBuilder {
+	b.namespace = namespace
+	return b
+}
+
+func (b *ResourceBuilder) WithSpec(spec unstructured.Unstructured) *ResourceBuilder {
+	b.spec = &spec
+	return b
+}
+
+func (b *ResourceBuilder) WithConditions(conditions ...metav1.Condition) *ResourceBuilder {
+	b.conditions = conditions
+	return b
+}
+
+func (b *ResourceBuilder) WithDeletionTimestamp(deletionTimestamp metav1.Time) *ResourceBuilder {
+	b.deletionTimestamp = &deletionTimestamp
+	return b
+}
+
+// Build returns a new resource of the builder, the source, the resource ID, the namespace and the spec are required,
+// the resource version must be positive, and the conditions must be valid. The builder can be reused, the resources
+// that it builds do not share any field.
+func (b *ResourceBuilder) Build() (*Resource, error) {
+	errs := []error{}
+	if len(b.source) == 0 {
+		errs = append(errs, fmt.Errorf("source is required"))
+	}
+	if len(b.resourceID) == 0 {
+		errs = append(errs, fmt.Errorf("resourceID is required"))
+	}
+	if b.resourceVersion <= 0 {
+		errs = append(errs, fmt.Errorf("resourceVersion %d is invalid, it must be positive", b.resourceVersion))
+	}
+	if len(b.namespace) == 0 {
+		errs = append(errs, fmt.Errorf("namespace is required"))
+	}
+	if b.spec == nil || len(b.spec.Object) == 0 {
+		errs = append(errs, fmt.Errorf("spec is required"))
+	}
+
+	status := ResourceStatus{Conditions: b.conditions}
+	if err := status.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) != 0 {
+		return nil, errors.NewAggregate(errs)
+	}
+
+	res := &Resource{
+		Source:            b.source,
+		ResourceID:        b.resourceID,
+		ResourceVersion:   b.resourceVersion,
+		Namespace:         b.namespace,
+		DeletionTimestamp: b.deletionTimestamp,
+		Spec:              *b.spec,
+		Status:            status,
+	}
+	return res.DeepCopy(), nil
+}
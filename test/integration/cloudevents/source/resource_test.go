@@ -0,0 +1,114 @@
+package source
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourceBuilder(t *testing.T) {
+	spec := NewResource("cluster1", "resource1").Spec
+	applied := metav1.Condition{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied"}
+	deletionTimestamp := metav1.NewTime(time.Now().Truncate(time.Second))
+
+	cases := []struct {
+		name             string
+		builder          *ResourceBuilder
+		expectedErrs     []string
+		expectedResource *Resource
+	}{
+		{
+			name: "all fields",
+			builder: NewResourceBuilder("test-source").
+				WithResourceID(ResourceID("cluster1", "resource1")).
+				WithResourceVersion(2).
+				WithNamespace("cluster1").
+				WithSpec(spec).
+				WithConditions(applied).
+				WithDeletionTimestamp(deletionTimestamp),
+			expectedResource: &Resource{
+				Source:            "test-source",
+				ResourceID:        ResourceID("cluster1", "resource1"),
+				ResourceVersion:   2,
+				Namespace:         "cluster1",
+				DeletionTimestamp: &deletionTimestamp,
+				Spec:              spec,
+				Status:            ResourceStatus{Conditions: []metav1.Condition{applied}},
+			},
+		},
+		{
+			name: "default version",
+			builder: NewResourceBuilder("test-source").
+				WithResourceID(ResourceID("cluster1", "resource1")).
+				WithNamespace("cluster1").
+				WithSpec(spec),
+			expectedResource: &Resource{
+				Source:          "test-source",
+				ResourceID:      ResourceID("cluster1", "resource1"),
+				ResourceVersion: 1,
+				Namespace:       "cluster1",
+				Spec:            spec,
+			},
+		},
+		{
+			name:    "missing required fields",
+			builder: NewResourceBuilder(""),
+			expectedErrs: []string{
+				"source is required",
+				"resourceID is required",
+				"namespace is required",
+				"spec is required",
+			},
+		},
+		{
+			name: "invalid version and conditions",
+			builder: NewResourceBuilder("test-source").
+				WithResourceID(ResourceID("cluster1", "resource1")).
+				WithResourceVersion(0).
+				WithNamespace("cluster1").
+				WithSpec(spec).
+				WithConditions(metav1.Condition{Type: "Applied"}),
+			expectedErrs: []string{
+				"resourceVersion 0 is invalid, it must be positive",
+				"conditions[0].status is required",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := c.builder.Build()
+			if len(c.expectedErrs) != 0 {
+				if err == nil {
+					t.Fatalf("expected errors %v, but got resource %v", c.expectedErrs, res)
+				}
+				for _, expected := range c.expectedErrs {
+					if !strings.Contains(err.Error(), expected) {
+						t.Errorf("expected error %q in %v", expected, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(res, c.expectedResource) {
+				t.Errorf("expected resource %v, but got %v", c.expectedResource, res)
+			}
+
+			// the built resources do not share the fields with the builder
+			another, err := c.builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			another.Spec.SetName("another")
+			if res.Spec.GetName() == "another" {
+				t.Errorf("expected the built resources do not share the spec")
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// dataSerializer serializes the data of the events of a content type. The data is serialized from and to its JSON
+// representation, so the data, e.g. a manifest or a status, means the same whichever content type it is serialized
+// in.
+type dataSerializer struct {
+	marshal   func(obj interface{}) ([]byte, error)
+	unmarshal func(data []byte, obj interface{}) error
+}
+
+// dataSerializers are the serializers of the supported content types of the event data.
+var dataSerializers = map[string]dataSerializer{
+	cloudevents.ApplicationJSON: {
+		marshal:   json.Marshal,
+		unmarshal: json.Unmarshal,
+	},
+	ApplicationProtobuf: {
+		marshal:   marshalProtobuf,
+		unmarshal: unmarshalProtobuf,
+	},
+	ApplicationCBOR: {
+		marshal:   marshalCBOR,
+		unmarshal: unmarshalCBOR,
+	},
+}
+
+// cborDecMode decodes the CBOR maps to the JSON objects, so the decoded data can be converted to JSON.
+var cborDecMode = func() cbor.DecMode {
+	mode, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}{})}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+// marshalProtobuf encodes the JSON representation of the obj as a protobuf google.protobuf.Struct. The numbers in a
+// protobuf data are doubles, so the integers that are larger than 2^53 lose the precision.
+func marshalProtobuf(obj interface{}) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	pbStruct, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(pbStruct)
+}
+
+func unmarshalProtobuf(data []byte, obj interface{}) error {
+	pbStruct := &structpb.Struct{}
+	if err := proto.Unmarshal(data, pbStruct); err != nil {
+		return err
+	}
+
+	return fromJSONValue(pbStruct.AsMap(), obj)
+}
+
+// marshalCBOR encodes the JSON representation of the obj in CBOR, the integers are encoded as the CBOR integers, so
+// they keep their precision.
+func marshalCBOR(obj interface{}) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return cbor.Marshal(numbersOf(value))
+}
+
+func unmarshalCBOR(data []byte, obj interface{}) error {
+	var value interface{}
+	if err := cborDecMode.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	return fromJSONValue(value, obj)
+}
+
+// fromJSONValue converts the JSON representation back to the obj.
+func fromJSONValue(value interface{}, obj interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, obj)
+}
+
+// numbersOf replaces the json.Number in the JSON representation with the integers or the floats they represent.
+func numbersOf(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = numbersOf(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = numbersOf(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// serializerOf returns the serializer of the content type, the data is serialized in JSON if the content type is
+// empty.
+func serializerOf(contentType string) (dataSerializer, error) {
+	if len(contentType) == 0 {
+		contentType = cloudevents.ApplicationJSON
+	}
+
+	serializer, ok := dataSerializers[contentType]
+	if !ok {
+		return dataSerializer{}, fmt.Errorf("unsupported data content type %s", contentType)
+	}
+	return serializer, nil
+}
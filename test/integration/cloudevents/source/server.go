@@ -2,17 +2,42 @@ package source
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"math"
 	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/cloudevents/sdk-go/v2/binding"
-	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
-	"google.golang.org/protobuf/types/known/emptypb"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // register the gzip compressor
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/clock"
 
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options"
 	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
 	grpcprotocol "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protocol"
 	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
@@ -21,145 +46,1508 @@ import (
 
 type GRPCServer struct {
 	pbv1.UnimplementedCloudEventServiceServer
-	store            *MemoryStore
-	eventBroadcaster *EventBroadcaster
+	source            string
+	store             Store
+	eventBroadcaster  *EventBroadcaster
+	allowedClients    sets.Set[string]
+	sendCompressor    string
+	codecs            map[types.CloudEventsDataType]Codec
+	metrics           *serverMetrics
+	rateLimiter       *publishRateLimiter
+	publishLimiter    *publishLimiter
+	registerer        prometheus.Registerer
+	statusEventType   StatusEventTypeFunc
+	heartbeatInterval time.Duration
+	agentClusters     map[string]string
+	dataContentType   string
+	logger            logr.Logger
+	maxRecvMsgSize    int
+	maxConditions     int
+	maxSendMsgSize    int
+	servingCertFile   string
+	servingKeyFile    string
+	tokenVerifier     TokenVerifier
+	sendBackoff       wait.Backoff
+	keepaliveParams   keepalive.ServerParameters
+	keepalivePolicy   keepalive.EnforcementPolicy
+	// subscribeAuthorizer authorizes the subscriptions by their sources, all of them are allowed if it is nil.
+	subscribeAuthorizer SubscribeAuthorizer
+	// persistenceFile is the file that the resources of the store are persisted to, they are not persisted if it is
+	// empty.
+	persistenceFile string
+	// eventTypeNamespace is the namespace of the types of the resource events, the types are not namespaced if it is
+	// empty.
+	eventTypeNamespace string
+	// deadLetters retains the rejected published events, they are not retained if it is nil.
+	deadLetters *DeadLetterStore
+	// interceptors assembles the interceptor chains of the server, the interceptors of the options are added to the
+	// stages after the ones of the server.
+	interceptors *InterceptorChainBuilder
+	// codecBuilders build the codecs that encode the status events with the options of the server, e.g. the
+	// TypedCodec of WithTypedCodec.
+	codecBuilders []func(encoding statusEncoding) Codec
+	// manifestEcho echoes the manifests of the resources in the manifest status events, see WithManifestEcho.
+	manifestEcho bool
+	// dataContentEncoding is the encoding that the data of the manifest status events is compressed with.
+	dataContentEncoding string
+	// subscribeBatchSize and subscribeBatchLinger are the max size and the linger of the batches of SubscribeBatch.
+	subscribeBatchSize   int
+	subscribeBatchLinger time.Duration
+	// sendBreakerThreshold and sendBreakerCooldown configure the circuit breakers of the deliveries to the subscribers.
+	sendBreakerThreshold int
+	sendBreakerCooldown  time.Duration
+	tracerProvider       trace.TracerProvider
+	clock                clock.Clock
+	eventIDGenerator     EventIDGenerator
+	tracer               trace.Tracer
+
+	mu           sync.Mutex
+	grpcServer   *grpc.Server
+	healthServer *health.Server
+	// subscriptions are the active subscriptions by their ids.
+	subscriptions map[string]*subscription
+}
+
+const (
+	// defaultMaxRecvMsgSize and defaultMaxSendMsgSize are the same as the defaults of the grpc server.
+	defaultMaxRecvMsgSize = 4 * 1024 * 1024
+	defaultMaxSendMsgSize = math.MaxInt32
+
+	// defaultMaxConditions is much more than the conditions of a real resource.
+	defaultMaxConditions = 1000
+)
+
+// defaultSubscribeBatchSize and defaultSubscribeBatchLinger coalesce the events of a busy subscriber without delaying
+// the events of an idle one for long.
+const (
+	defaultSubscribeBatchSize   = 100
+	defaultSubscribeBatchLinger = 10 * time.Millisecond
+)
+
+// defaultKeepaliveParams closes the connections that have no RPC for 30 minutes, the other parameters are the defaults
+// of the grpc server. defaultKeepalivePolicy permits the clients to ping every 10s, which is the minimum ping interval
+// of the grpc clients, even if they have no RPC, so a well-behaved client is never closed for pinging.
+var (
+	defaultKeepaliveParams = keepalive.ServerParameters{MaxConnectionIdle: 30 * time.Minute}
+	defaultKeepalivePolicy = keepalive.EnforcementPolicy{MinTime: 10 * time.Second, PermitWithoutStream: true}
+)
+
+// defaultSendBackoff retries to send an event after 100ms and then 200ms.
+var defaultSendBackoff = wait.Backoff{Duration: 100 * time.Millisecond, Factor: 2, Steps: 3}
+
+// SubscriptionIDHeader is the header of the Subscribe response that carries the id of the subscription, it is sent
+// before any event, so the subscriber can log it. The id is the "clientID" in the server logs and it is used to
+// unsubscribe the subscription.
+const SubscriptionIDHeader = "subscription-id"
+
+// subscription is an active subscription, it is unsubscribed by closing the unsubscribe channel, and the stopped
+// channel is closed once the subscription is unregistered from the event broadcaster.
+type subscription struct {
+	unsubscribe chan struct{}
+	stopped     chan struct{}
+	// window is the send window of the subscription, it is nil if the subscription is not flow controlled.
+	window *sendWindow
+}
+
+// GRPCServerOption configures the GRPCServer.
+type GRPCServerOption func(*GRPCServer)
+
+// WithCodecs registers the codecs for the data types that the server handles, the codec of manifests is registered
+// by default, it can be replaced by a codec with the same data type.
+func WithCodecs(codecs ...Codec) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		for _, codec := range codecs {
+			svr.codecs[codec.EventDataType()] = codec
+		}
+	}
+}
+
+// WithSource sets the source of the events that are sent by the server, it defaults to a name derived from the
+// hostname.
+func WithSource(source string) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.source = source
+	}
+}
+
+// WithEventTypeNamespace scopes the types of the resource events to the namespace, e.g. a tenant of a shared broker.
+// The types of the status events that are sent by the server are prefixed with the namespace and a dot, and the
+// published events must have the prefixed types, the events of the other namespaces or without a namespace are
+// rejected. The snapshot done and the heartbeat events of the subscriptions are not namespaced, since they are only
+// sent to the subscribers of the server.
+func WithEventTypeNamespace(namespace string) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.eventTypeNamespace = namespace
+	}
+}
+
+// WithDeadLetterStore retains the published events that are rejected by the server in the dead letter store, e.g.
+// the events that are malformed, cannot be decoded or are not allowed for their agents, with the reasons that they
+// are rejected. The events of the dry run publishes and the events that fail for the other reasons, e.g. the rate
+// limit, are not retained.
+func WithDeadLetterStore(store *DeadLetterStore) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.deadLetters = store
+	}
+}
+
+// WithStatusEventType sets the function that decides the sub-resource and the action of the manifest status events by
+// the state of the resources, by default, the resources that are being deleted are sent with the status_delete
+// action, the others are sent with the status_update action. It is not used if the manifest codec is replaced.
+func WithStatusEventType(statusEventType StatusEventTypeFunc) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.statusEventType = statusEventType
+	}
+}
+
+// WithManifestEcho echoes the manifests of the resources in the data of the manifest status events, so a subscriber
+// gets the manifest that a status is reported for without getting the resource, the manifests are not sent by default.
+// It is not used if the manifest codec is replaced.
+func WithManifestEcho() GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.manifestEcho = true
+	}
+}
+
+// WithHeartbeatInterval enables the heartbeat of the subscriptions, a HeartbeatEventType event is sent to a subscriber
+// once nothing is sent to it in the interval, so the subscriber can tell an idle subscription from a broken one.
+func WithHeartbeatInterval(interval time.Duration) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.heartbeatInterval = interval
+	}
+}
+
+// WithDataContentType sets the content type of the data of the manifest status events, it is one of
+// cloudevents.ApplicationJSON, ApplicationProtobuf and ApplicationCBOR, the default is cloudevents.ApplicationJSON. It
+// is not used if the manifest codec is replaced.
+func WithDataContentType(contentType string) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.dataContentType = contentType
+	}
+}
+
+// WithDataContentEncoding sets the encoding that the data of the manifest status events is compressed with, it is
+// ContentEncodingGzip, the data is not compressed by default. The compression is applied to the data of the events
+// rather than the messages, so the data is compact wherever the events are kept. It is not used if the manifest codec
+// is replaced.
+func WithDataContentEncoding(contentEncoding string) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.dataContentEncoding = contentEncoding
+	}
+}
+
+// WithSendCompressor sets the name of the compressor that the server uses to compress the responses, it is only used
+// for the clients that support it, otherwise, the server responds with the compressor of the request. The gzip
+// compressor is registered by default, other compressors must be registered with encoding.RegisterCompressor before
+// the server is started.
+func WithSendCompressor(name string) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.sendCompressor = name
+	}
+}
+
+// WithMaxRecvMsgSize sets the max size in bytes of the messages that the server can receive, the default is 4MB. The
+// requests that exceed the limit are rejected with the ResourceExhausted code.
+func WithMaxRecvMsgSize(size int) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.maxRecvMsgSize = size
+	}
+}
+
+// WithMaxSendMsgSize sets the max size in bytes of the messages that the server can send, the default is unlimited.
+// The subscriptions whose events exceed the limit are closed with the ResourceExhausted code. The clients have their
+// own receive limits, which are 4MB by default.
+func WithMaxSendMsgSize(size int) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.maxSendMsgSize = size
+	}
+}
+
+// WithMaxConditions sets the max number of the conditions in the status of a resource, the default is 1000. The
+// published events whose resources exceed the limit are rejected with the InvalidArgument code, and the resources
+// that exceed the limit are not encoded for the subscribers. The conditions are not limited if max is not positive.
+func WithMaxConditions(max int) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.maxConditions = max
+	}
+}
+
+// WithSendBackoff sets the backoff of retrying to send an event to a subscriber when the error is transient, e.g. the
+// transport is unavailable, the Steps of the backoff is the max number of the attempts. The subscription is closed
+// once an event cannot be sent. By default, an event is sent at most 3 times in 300ms.
+func WithSendBackoff(backoff wait.Backoff) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.sendBackoff = backoff
+	}
+}
+
+// WithSubscribeBatch sets the max number of the events in a batch of SubscribeBatch and how long a batch lingers for
+// more events after its first event, the defaults are 100 events and 10ms. The events that are already queued for
+// the subscriber are batched without lingering, so a zero linger only coalesces the queued events.
+func WithSubscribeBatch(size int, linger time.Duration) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.subscribeBatchSize = size
+		svr.subscribeBatchLinger = linger
+	}
+}
+
+// WithSendCircuitBreaker skips the deliveries to a subscriber for the cooldown once the events cannot be sent to it
+// the threshold times in a row, instead of ending the subscription at the first failure, so the server does not
+// encode and send the events for a subscriber that cannot receive them. Only the transient failures, which are
+// still failing after the retries of the send backoff, are counted. Once the cooldown elapses, the next event is sent
+// as a probe. When an event is sent after the failures, the subscriber is resynced, since the events that failed or
+// were skipped are missed by the subscriber. The circuit breaker is disabled by default.
+func WithSendCircuitBreaker(threshold int, cooldown time.Duration) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.sendBreakerThreshold = threshold
+		svr.sendBreakerCooldown = cooldown
+	}
+}
+
+// WithKeepaliveParams sets the keepalive parameters of the server, e.g. how long a connection without RPC is kept and
+// how often the server pings the clients. By default, the connections without RPC for 30 minutes are closed.
+func WithKeepaliveParams(params keepalive.ServerParameters) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.keepaliveParams = params
+	}
+}
+
+// WithKeepaliveEnforcementPolicy sets the keepalive enforcement policy of the server, the connection of a client that
+// pings more often than the policy permits is closed with the GOAWAY "too_many_pings". By default, the clients can
+// ping every 10s even if they have no RPC.
+func WithKeepaliveEnforcementPolicy(policy keepalive.EnforcementPolicy) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.keepalivePolicy = policy
+	}
+}
+
+// WithMetricsRegisterer registers the metrics of the server with the given registerer.
+func WithMetricsRegisterer(registerer prometheus.Registerer) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.registerer = registerer
+	}
+}
+
+// WithGlobalPublishRateLimit limits the publish requests of all of the sources, the requests that exceed the limit
+// are rejected with the ResourceExhausted code.
+func WithGlobalPublishRateLimit(limit options.EventRateLimit) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.rateLimiter.global = generic.NewRateLimiter(limit)
+	}
+}
+
+// WithSourcePublishRateLimit limits the publish requests of each source, the requests that exceed the limit are
+// rejected with the ResourceExhausted code.
+func WithSourcePublishRateLimit(limit options.EventRateLimit) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.rateLimiter.sourceLimit = &limit
+	}
+}
+
+// WithMaxConcurrentPublishes limits the number of the publishes that are executed at the same time, the publishes
+// beyond the limit wait for a running one to finish, at most queueSize publishes wait, the others are rejected with
+// the ResourceExhausted code. A batch is one publish. The publishes are not limited by default.
+func WithMaxConcurrentPublishes(limit, queueSize int) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.publishLimiter = newPublishLimiter(limit, queueSize)
+	}
+}
+
+// WithLogger sets the logger of the server, the server does not log by default. The publishing and subscribing are
+// logged at the verbosity level 4.
+func WithLogger(logger logr.Logger) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.logger = logger
+	}
+}
+
+// WithTracerProvider sets the provider of the tracer that traces the publishes and the deliveries of the events, the
+// global provider is used by default. The trace context is propagated with the distributed tracing extension of the
+// events, a publish is a child of the trace context of its event, and the deliveries of the status events of a
+// resource are the children of the last publish of the resource.
+func WithTracerProvider(provider trace.TracerProvider) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.tracerProvider = provider
+	}
+}
+
+// WithClock sets the clock of the server, the times of the events that are sent by the server, the heartbeats, the
+// retries of the sends, the liveness of the subscribers and the linger of their batches in the event broadcaster, and
+// the timeouts of the InMemoryServer subscribers are based on it, the default is the real clock. It is mostly used by
+// the tests to control the time.
+func WithClock(clock clock.Clock) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.clock = clock
+	}
+}
+
+// EventIDGenerator generates the IDs of the events that are sent by the server.
+type EventIDGenerator func() string
+
+// WithEventIDGenerator sets the generator of the IDs of the events that are sent by the server, e.g. uuid.NewV7 for
+// the time-sortable IDs or a sequence for the deterministic IDs in tests, the IDs are random UUIDs by default. The
+// generator is called by the subscriptions concurrently.
+func WithEventIDGenerator(generator EventIDGenerator) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.eventIDGenerator = generator
+	}
+}
+
+// WithAgentCluster registers the agent to the cluster, the events that are produced by the agent can only update the
+// resources of the cluster, the events of the agents which are not registered are rejected with the PermissionDenied
+// code.
+func WithAgentCluster(agentID, clusterName string) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.agentClusters[agentID] = clusterName
+	}
+}
+
+// WithServingCertFiles serves the TLS connections with the certificate and the key files instead of the certificates
+// of the TLS config. The files are reloaded by the new connections at most once every second, so the certificate can
+// be rotated without restarting the server, the existing connections keep working with the old certificate.
+func WithServingCertFiles(certFile, keyFile string) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.servingCertFile = certFile
+		svr.servingKeyFile = keyFile
+	}
+}
+
+// WithTokenVerifier authenticates the requests with the bearer tokens in their "authorization" metadata, the requests
+// without a valid token are rejected with the Unauthenticated code. The identity of a request can be got with
+// IdentityFromContext. The health checks are not authenticated.
+func WithTokenVerifier(verifier TokenVerifier) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.tokenVerifier = verifier
+	}
+}
+
+// WithSubscribeAuthorizer authorizes the subscriptions with the authorizer after they are authenticated, so an identity
+// only subscribes to the sources that it is allowed to, the subscriptions that are not authorized are rejected with
+// the PermissionDenied code. It is used with WithTokenVerifier, the identities of the subscriptions are the ones that
+// their bearer tokens are issued to.
+func WithSubscribeAuthorizer(authorizer SubscribeAuthorizer) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.subscribeAuthorizer = authorizer
+	}
+}
+
+// WithPersistenceFile persists the resources of the store to the JSON file at the path once the server is stopped,
+// and restores them from the file when the server is created, so the resources survive a restart of the server, e.g.
+// in a demo or an integration environment. The resources are restored by upserting them into the store, and nothing
+// is restored if the file does not exist. A failure to restore or persist the resources is logged. The resources are
+// only kept in the store by default.
+func WithPersistenceFile(path string) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.persistenceFile = path
+	}
+}
+
+// WithAllowedClients restricts the clients that can connect to the server with mutual TLS, the common name or one
+// of the subject alternative names of the client certificate must be in the given names.
+func WithAllowedClients(names ...string) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.allowedClients.Insert(names...)
+	}
+}
+
+// NewGRPCServer creates a server that serves the resources of the store, the status changes of the resources are
+// broadcast to the subscribers by the event broadcaster.
+func NewGRPCServer(store Store, eventBroadcaster *EventBroadcaster, opts ...GRPCServerOption) *GRPCServer {
+	svr := &GRPCServer{
+		store:                store,
+		eventBroadcaster:     eventBroadcaster,
+		allowedClients:       sets.New[string](),
+		codecs:               map[types.CloudEventsDataType]Codec{},
+		agentClusters:        map[string]string{},
+		subscriptions:        map[string]*subscription{},
+		metrics:              newServerMetrics(),
+		rateLimiter:          newPublishRateLimiter(),
+		logger:               logr.Discard(),
+		maxRecvMsgSize:       defaultMaxRecvMsgSize,
+		maxConditions:        defaultMaxConditions,
+		maxSendMsgSize:       defaultMaxSendMsgSize,
+		sendBackoff:          defaultSendBackoff,
+		keepaliveParams:      defaultKeepaliveParams,
+		keepalivePolicy:      defaultKeepalivePolicy,
+		subscribeBatchSize:   defaultSubscribeBatchSize,
+		subscribeBatchLinger: defaultSubscribeBatchLinger,
+		tracerProvider:       otel.GetTracerProvider(),
+		clock:                clock.RealClock{},
+		eventIDGenerator:     uuid.NewString,
+		interceptors:         NewInterceptorChainBuilder(),
+	}
+	svr.interceptors.
+		Unary(RecoveryStage, svr.unaryRecoveryInterceptor).
+		Stream(RecoveryStage, svr.streamRecoveryInterceptor).
+		Unary(RequestIDStage, svr.unaryRequestIDInterceptor).
+		Stream(RequestIDStage, svr.streamRequestIDInterceptor).
+		Unary(AuthStage, svr.unaryAuthInterceptor).
+		Stream(AuthStage, svr.streamAuthInterceptor)
+
+	for _, opt := range opts {
+		opt(svr)
+	}
+
+	if len(svr.source) == 0 {
+		svr.source = defaultSource()
+	}
+
+	svr.tracer = svr.tracerProvider.Tracer(tracerName)
+
+	// the codecs that encode the status events like the server are built once all of the options are applied
+	encoding := statusEncoding{
+		source:              svr.source,
+		statusEventType:     svr.statusEventType,
+		dataContentType:     svr.dataContentType,
+		dataContentEncoding: svr.dataContentEncoding,
+		clock:               svr.clock,
+	}
+	for _, newCodec := range svr.codecBuilders {
+		codec := newCodec(encoding)
+		svr.codecs[codec.EventDataType()] = codec
+	}
+	if _, ok := svr.codecs[payload.ManifestEventDataType]; !ok {
+		svr.codecs[payload.ManifestEventDataType] = &manifestCodec{statusEncoding: encoding, echoManifest: svr.manifestEcho}
+	}
+
+	if svr.registerer != nil {
+		svr.metrics.register(svr.registerer)
+	}
+
+	eventBroadcaster.setClock(svr.clock)
+	eventBroadcaster.setDroppedHandler(func(res *Resource) {
+		svr.metrics.droppedEvents.WithLabelValues(res.Source, resourceDataType(res).String()).Inc()
+	})
+	eventBroadcaster.setGapHandler(func(res *Resource, missed uint64) {
+		svr.logger.Info("found a gap in the outbound sequence of a subscriber", "source", res.Source,
+			"resourceID", res.ResourceID, "missed", missed)
+		svr.metrics.sequenceGaps.WithLabelValues(res.Source).Inc()
+	})
+
+	if len(svr.persistenceFile) != 0 {
+		if err := restoreResources(svr.store, svr.persistenceFile); err != nil {
+			svr.logger.Error(err, "failed to restore the resources", "file", svr.persistenceFile)
+		}
+	}
+
+	return svr
+}
+
+// defaultSource returns the default source of the server, it is derived from the hostname.
+func defaultSource() string {
+	hostname, err := os.Hostname()
+	if err != nil || len(hostname) == 0 {
+		return "grpc-server"
+	}
+
+	return fmt.Sprintf("%s-grpc-server", hostname)
+}
+
+func (svr *GRPCServer) Publish(ctx context.Context, pubReq *pbv1.PublishRequest) (_ *pbv1.PublishResponse, err error) {
+	svr.setSendCompressor(ctx)
+	defer func() {
+		// the events of the dry runs are expected to be rejected, they are not retained
+		if err != nil && !pubReq.DryRun {
+			svr.addDeadLetter(pubReq.Event, err)
+		}
+	}()
+
+	evt, err := fromPBEvent(ctx, pubReq.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	res, result, err := svr.publish(ctx, evt, pubReq.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv1.PublishResponse{
+		ResourceId:      res.ResourceID,
+		ResourceVersion: res.ResourceVersion,
+		Result:          toPublishResult(result),
+	}, nil
+}
+
+// PublishBatch receives the CloudEvents from the stream until the client closes it, then upserts the resources of
+// them in one batch, the failed CloudEvents are reported in the response.
+func (svr *GRPCServer) PublishBatch(stream pbv1.CloudEventService_PublishBatchServer) error {
+	ctx := stream.Context()
+	svr.setSendCompressor(ctx)
+
+	allOrNothing := false
+	resources := []*Resource{}
+	indexes := []int32{}
+	eventIDs := []string{}
+	failures := []*pbv1.PublishFailure{}
+	for index := int32(0); ; index++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if index == 0 {
+			allOrNothing = req.AllOrNothing
+		}
+
+		res, err := svr.toResource(ctx, req.Event)
+		if err != nil {
+			svr.addDeadLetter(req.Event, err)
+			failures = append(failures, &pbv1.PublishFailure{
+				Index:   index,
+				EventId: req.Event.GetId(),
+				Message: err.Error(),
+			})
+			continue
+		}
+
+		resources = append(resources, res)
+		indexes = append(indexes, index)
+		eventIDs = append(eventIDs, req.Event.GetId())
+	}
+
+	if allOrNothing && len(failures) != 0 {
+		return stream.SendAndClose(&pbv1.PublishBatchResponse{Failures: failures})
+	}
+
+	if err := svr.publishLimiter.acquire(ctx); err != nil {
+		return err
+	}
+	applied := []*Resource{}
+	_, errs := svr.store.UpSertBatch(ctx, resources, allOrNothing)
+	svr.publishLimiter.release()
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, &pbv1.PublishFailure{
+				Index:   indexes[i],
+				EventId: eventIDs[i],
+				Message: toUpSertStatusError(resources[i], err).Error(),
+			})
+			continue
+		}
+
+		applied = append(applied, resources[i])
+	}
+
+	if allOrNothing && len(failures) != 0 {
+		// none of the resources is applied
+		applied = nil
+	}
+
+	for _, res := range applied {
+		svr.metrics.publishedEvents.WithLabelValues(res.Source, resourceDataType(res).String()).Inc()
+	}
+
+	// the failures are reported in the order of the CloudEvents
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Index < failures[j].Index
+	})
+
+	return stream.SendAndClose(&pbv1.PublishBatchResponse{
+		Succeeded: int32(len(applied)),
+		Failures:  failures,
+	})
 }
 
-func NewGRPCServer(store *MemoryStore, eventBroadcaster *EventBroadcaster) *GRPCServer {
-	return &GRPCServer{
-		store:            store,
-		eventBroadcaster: eventBroadcaster,
+// publish applies a published cloudevent to the store, the errors are grpc status errors. It is shared by the
+// transports, so a cloudevent is applied in the same way whichever protocol it is published over. If dryRun is true,
+// the cloudevent is decoded and validated, and the result of applying it is returned, but the store is not changed.
+func (svr *GRPCServer) publish(ctx context.Context, evt *cloudevents.Event, dryRun bool) (_ *Resource, _ UpSertResult, err error) {
+	logger := svr.requestLogger(ctx)
+	ctx, span := svr.startPublishSpan(ctx, evt)
+	defer func() {
+		endSpan(span, err)
+		if err != nil {
+			logger.V(4).Info("failed to publish the resource", "source", evt.Source(), "eventID", evt.ID(),
+				"error", err.Error())
+		}
+	}()
+
+	ctx, err = svr.withCloudEventsType(ctx, evt)
+	if err != nil {
+		return nil, "", toStatusError(err)
+	}
+
+	if err := svr.publishLimiter.acquire(ctx); err != nil {
+		return nil, "", err
+	}
+	defer svr.publishLimiter.release()
+
+	res, err := svr.eventToResource(ctx, evt)
+	if err != nil {
+		return nil, "", toStatusError(err)
 	}
+
+	// the trace context of the publish is kept with the resource, so the status events of the resource are
+	// delivered in the same trace
+	span.SetAttributes(resourceAttributes(res)...)
+	traceContext.Inject(ctx, resourceCarrier{res})
+
+	if dryRun {
+		result, err := svr.store.DryRunUpSert(ctx, res)
+		if err != nil {
+			return nil, "", toUpSertStatusError(res, err)
+		}
+
+		logger.V(4).Info("resource is validated in dry run", "source", res.Source, "resourceID", res.ResourceID,
+			"resourceVersion", res.ResourceVersion, "result", result)
+		return res, result, nil
+	}
+
+	// the resource is only left unchanged when the same version is already applied, so the resource version of
+	// the published resource is always the committed one.
+	result, err := svr.store.UpSert(ctx, res)
+	if err != nil {
+		return nil, "", toUpSertStatusError(res, err)
+	}
+
+	svr.metrics.publishedEvents.WithLabelValues(res.Source, resourceDataType(res).String()).Inc()
+	logger.V(4).Info("resource is published", "source", res.Source, "resourceID", res.ResourceID,
+		"resourceVersion", res.ResourceVersion, "result", result)
+
+	return res, result, nil
 }
 
-func (svr *GRPCServer) Publish(ctx context.Context, pubReq *pbv1.PublishRequest) (*emptypb.Empty, error) {
-	// WARNING: don't use "evt, err := pb.FromProto(pubReq.Event)" to convert protobuf to cloudevent
-	evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pubReq.Event))
+// toResource converts a published CloudEvent to the resource.
+func (svr *GRPCServer) toResource(ctx context.Context, pbEvt *pbv1.CloudEvent) (*Resource, error) {
+	evt, err := fromPBEvent(ctx, pbEvt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert protobuf to cloudevent: %v", err)
+		return nil, err
 	}
 
-	res, err := decode(evt)
+	ctx, err = svr.withCloudEventsType(ctx, evt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode cloudevent: %v", err)
+		return nil, err
 	}
 
-	store.UpSert(res)
-	return &emptypb.Empty{}, nil
+	return svr.eventToResource(ctx, evt)
+}
+
+type cloudEventsTypeKey struct{}
+
+// CloudEventsTypeFromContext returns the type of the published CloudEvent that the request is publishing, it is false
+// if the context is not of a publish. The type is parsed once when the CloudEvent is published, so the hooks that are
+// called with the context of the publish, e.g. the store, can read it without parsing the event type again.
+func CloudEventsTypeFromContext(ctx context.Context) (types.CloudEventsType, bool) {
+	eventType, ok := ctx.Value(cloudEventsTypeKey{}).(types.CloudEventsType)
+	return eventType, ok
 }
 
+// withCloudEventsType parses the type of the published CloudEvent and returns the context with it. If the types are
+// namespaced, the type must be in the namespace of the server, and the namespace is stripped from the type of the
+// CloudEvent, so it is decoded by the codecs as the type that is not namespaced.
+func (svr *GRPCServer) withCloudEventsType(ctx context.Context, evt *cloudevents.Event) (context.Context, error) {
+	if len(svr.eventTypeNamespace) != 0 {
+		eventType, ok := strings.CutPrefix(evt.Type(), svr.eventTypeNamespace+".")
+		if !ok {
+			return nil, fmt.Errorf("%w: the cloud event type %s is not in the namespace %s",
+				ErrDecode, evt.Type(), svr.eventTypeNamespace)
+		}
+		evt.SetType(eventType)
+	}
+
+	eventType, err := types.ParseCloudEventsType(evt.Type())
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse cloud event type %s, %v", ErrDecode, evt.Type(), err)
+	}
+
+	return context.WithValue(ctx, cloudEventsTypeKey{}, *eventType), nil
+}
+
+// eventToResource converts a published CloudEvent to the resource, the context must have its CloudEventsType.
+func (svr *GRPCServer) eventToResource(ctx context.Context, evt *cloudevents.Event) (*Resource, error) {
+	if !svr.rateLimiter.tryAccept(evt.Source()) {
+		return nil, status.Errorf(codes.ResourceExhausted, "the publish rate limit of the source %s is exceeded", evt.Source())
+	}
+
+	if err := types.ValidateEventExtensions(evt); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	eventType, _ := CloudEventsTypeFromContext(ctx)
+	res, err := svr.decode(evt, eventType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+
+	if err := svr.validateAgent(res); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return res, nil
+}
+
+// validateAgent validates the agent of the resource owns the resource, the resource is owned by the cluster that it
+// is stored in, or the cluster of the event if it is not stored yet.
+func (svr *GRPCServer) validateAgent(res *Resource) error {
+	if len(res.AgentID) == 0 {
+		return nil
+	}
+
+	agentCluster, ok := svr.agentClusters[res.AgentID]
+	if !ok {
+		return fmt.Errorf("the agent %s is not registered to any cluster", res.AgentID)
+	}
+
+	owner := res.Namespace
+	if last, err := svr.store.Get(res.ResourceID); err == nil {
+		owner = last.Namespace
+	}
+
+	if agentCluster != owner {
+		return fmt.Errorf("the agent %s of the cluster %s cannot update the resource %s of the cluster %s",
+			res.AgentID, agentCluster, res.ResourceID, owner)
+	}
+	return nil
+}
+
+// sortResources sorts the resources by their resource IDs and then their versions, so the resources that are listed
+// from the store, whose order is not defined, are sent in a stable order.
+func sortResources(resources []*Resource) []*Resource {
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].ResourceID != resources[j].ResourceID {
+			return resources[i].ResourceID < resources[j].ResourceID
+		}
+		return resources[i].ResourceVersion < resources[j].ResourceVersion
+	})
+	return resources
+}
+
+// toUpSertStatusError converts the error of upserting the resource to a grpc status error.
+func toUpSertStatusError(res *Resource, err error) error {
+	return toStatusError(fmt.Errorf("failed to upsert resource %s: %w", res.ResourceID, err))
+}
+
+func toPublishResult(result UpSertResult) pbv1.PublishResult {
+	switch result {
+	case ResourceCreated:
+		return pbv1.PublishResult_PUBLISH_RESULT_CREATED
+	case ResourceUpdated:
+		return pbv1.PublishResult_PUBLISH_RESULT_UPDATED
+	case ResourceUnchanged:
+		return pbv1.PublishResult_PUBLISH_RESULT_NO_OP
+	default:
+		return pbv1.PublishResult_PUBLISH_RESULT_UNSPECIFIED
+	}
+}
+
+// Subscribe sends the current resources of the sources to the subscriber first, then a snapshot done event, then the
+// live events. The resources of the snapshot are sent in the order of their resource IDs and then their versions, so
+// the subscriber receives the same snapshot in the same order from the same resources, and can process it
+// deterministically. The resyncs of the subscriber are sent in the same order. If the subscription has an ack window,
+// the server sends no more events than the window ahead of the acknowledgements of the subscriber, see Acknowledge.
 func (svr *GRPCServer) Subscribe(subReq *pbv1.SubscriptionRequest, subServer pbv1.CloudEventService_SubscribeServer) error {
-	clientID, errChan := svr.eventBroadcaster.Register(subReq.Source, func(res *Resource) error {
-		evt, err := encode(res)
+	return svr.subscribe(pbv1.CloudEventService_Subscribe_FullMethodName, subReq, subServer,
+		newSubscribeStream(subServer, svr.sendBackoff, svr.clock))
+}
+
+// SubscribeBatch subscribes the resources like Subscribe, but the live events that are queued for the subscriber are
+// coalesced into batches, a message of the stream carries a batch of the events in order. The size and the linger of
+// the batches are set by WithSubscribeBatch. The snapshot, the snapshot done and the heartbeat events are sent in
+// the batches of one event.
+func (svr *GRPCServer) SubscribeBatch(subReq *pbv1.SubscriptionRequest,
+	subServer pbv1.CloudEventService_SubscribeBatchServer) error {
+	return svr.subscribe(pbv1.CloudEventService_SubscribeBatch_FullMethodName, subReq, subServer,
+		newBatchSubscribeStream(subServer, svr.sendBackoff, svr.clock))
+}
+
+// subscribe serves a subscription of the method, the events are sent to the stream, and the subServer is the server
+// stream of the subscription.
+func (svr *GRPCServer) subscribe(method string, subReq *pbv1.SubscriptionRequest, subServer grpc.ServerStream,
+	stream *subscribeStream) error {
+	// the stream is closed before the handler returns, so an event that is being delivered when the subscriber is
+	// unregistered, or a heartbeat, is not sent to the stream after it is finished by grpc
+	defer stream.close()
+
+	svr.setSendCompressor(subServer.Context())
+
+	filter, err := NewSourceFilter(subReq.Source)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := svr.authorizeSubscription(subServer.Context(), subReq.Source); err != nil {
+		return err
+	}
+
+	if _, ok := pbv1.Projection_name[int32(subReq.Projection)]; !ok {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("unsupported projection %d", subReq.Projection))
+	}
+
+	var dataType types.CloudEventsDataType
+	if len(subReq.DataType) != 0 {
+		parsed, err := types.ParseCloudEventsDataType(subReq.DataType)
 		if err != nil {
-			return fmt.Errorf("failed to encode resource %s to cloudevent: %v", res.ResourceID, err)
+			return status.Error(codes.InvalidArgument, fmt.Sprintf("invalid data type %q: %v", subReq.DataType, err))
+		}
+		if _, ok := svr.codecs[*parsed]; !ok {
+			return toStatusError(svr.unsupportedDataTypeError(*parsed))
 		}
+		dataType = *parsed
+	}
 
-		// WARNING: don't use "pbEvt, err := pb.ToProto(evt)" to convert cloudevent to protobuf
-		pbEvt := &pbv1.CloudEvent{}
-		if err = grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(evt), pbEvt); err != nil {
-			return fmt.Errorf("failed to convert cloudevent to protobuf: %v", err)
+	var labelSelector labels.Selector
+	if len(subReq.LabelSelector) != 0 {
+		labelSelector, err = labels.Parse(subReq.LabelSelector)
+		if err != nil {
+			return status.Error(codes.InvalidArgument,
+				fmt.Sprintf("invalid label selector %q: %v", subReq.LabelSelector, err))
 		}
+	}
 
-		// send the cloudevent to the subscriber
-		// TODO: error handling to address errors beyond network issues.
-		if err := subServer.Send(pbEvt); err != nil {
+	// the subscription id is sent in the header before any event, so the subscriber can unsubscribe with it
+	clientID := uuid.NewString()
+	stream.window = newSendWindow(subReq.AckWindow)
+	sub := svr.addSubscription(clientID, stream.window)
+	defer svr.removeSubscription(clientID, sub)
+
+	if err := subServer.SendHeader(metadata.Pairs(SubscriptionIDHeader, clientID)); err != nil {
+		return err
+	}
+
+	logger := svr.requestLogger(subServer.Context()).WithValues("source", subReq.Source,
+		"clusterName", subReq.ClusterName, "clientID", clientID)
+	breaker := newSendBreaker(svr.sendBreakerThreshold, svr.sendBreakerCooldown, svr.clock)
+
+	// in the snapshot only mode, the stream is finished once the snapshot done event is sent, and no event is sent
+	// after it. The snapshot done and the deliver are called by the event broadcaster one by one, so the snapshotSent
+	// is not guarded.
+	snapshotSent := false
+	snapshotOnlyDone := make(chan struct{})
+	snapshotDone := func() error {
+		if snapshotSent {
+			return nil
+		}
+
+		evt := types.NewEventBuilder(svr.source, SnapshotDoneEventType).NewEvent()
+		evt.SetID(svr.eventIDGenerator())
+		evt.SetTime(svr.clock.Now())
+		if err := stream.send(&evt); err != nil {
 			return err
 		}
 
+		if subReq.SnapshotOnly {
+			snapshotSent = true
+			close(snapshotOnlyDone)
+		}
 		return nil
-	})
+	}
+	deliver := func(resources []*Resource) (err error) {
+		defer svr.recoverPanic(method, &err)
+
+		if snapshotSent {
+			return nil
+		}
+
+		if breaker != nil && !breaker.allow() {
+			return nil
+		}
+
+		spans := make([]trace.Span, 0, len(resources))
+		defer func() {
+			for _, span := range spans {
+				endSpan(span, err)
+			}
+		}()
+
+		evts := make([]*cloudevents.Event, 0, len(resources))
+		for _, res := range resources {
+			ctx, span := svr.startDeliverySpan(res)
+			spans = append(spans, span)
+
+			// the fields out of the projection are stripped before encoding, so they are not sent to the subscriber
+			evt, err := svr.encodeStatusEvent(project(res, subReq.Projection))
+			if err != nil {
+				return err
+			}
+
+			// the subscriber continues the trace of the delivery
+			traceContext.Inject(ctx, eventCarrier{evt})
+			evts = append(evts, evt)
+		}
+
+		if err := stream.send(evts...); err != nil {
+			if breaker == nil || !isTransientSendError(err) {
+				return err
+			}
+
+			if breaker.fail() {
+				logger.Error(err, "the deliveries to the subscriber are skipped", "cooldown", svr.sendBreakerCooldown)
+			}
+			return nil
+		}
+
+		if breaker != nil && breaker.succeed() {
+			// the events that failed or were skipped while the breaker was open are sent by the resync
+			logger.V(4).Info("the deliveries to the subscriber are resumed")
+			if err := svr.eventBroadcaster.Resync(clientID); err != nil {
+				return err
+			}
+		}
+
+		for _, res := range resources {
+			svr.metrics.deliveredEvents.WithLabelValues(res.Source, resourceDataType(res).String()).Inc()
+		}
+		return nil
+	}
+	handler := func(res *Resource) error {
+		return deliver([]*Resource{res})
+	}
+
+	list := func() []*Resource {
+		return sortResources(svr.store.ListBySourceAndCluster(filter, subReq.ClusterName))
+	}
+
+	// the events are scoped to the cluster, the data type, the condition type and the label selector of the subscriber
+	// if they are specified, and the subscriber can be resynced with the current resources.
+	registerOpts := []RegisterOption{WithClusterName(subReq.ClusterName), WithDataType(dataType),
+		WithConditionType(subReq.ConditionType), WithLabelSelector(labelSelector), WithResync(list),
+		withClientID(clientID)}
+	if stream.batched {
+		registerOpts = append(registerOpts, WithBatchHandler(deliver, svr.subscribeBatchSize, svr.subscribeBatchLinger))
+	}
+	var registeredID string
+	var errChan <-chan error
+	if len(subReq.ResumeToken) == 0 {
+		// the current resources are sent to the subscriber first, then a snapshot done event, then the live events.
+		registeredID, errChan = svr.eventBroadcaster.RegisterWithSnapshot(filter, list, snapshotDone, handler, registerOpts...)
+	} else {
+		sequence, err := strconv.ParseUint(subReq.ResumeToken, 10, 64)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, fmt.Sprintf("invalid resume token %q", subReq.ResumeToken))
+		}
+
+		// the events that are missed by the subscriber are sent first, then a snapshot done event, then the live
+		// events.
+		registeredID, errChan, err = svr.eventBroadcaster.RegisterWithResume(filter, sequence,
+			snapshotDone, handler, registerOpts...)
+		if errors.Is(err, ErrResumeTokenExpired) {
+			return toSubscriptionError(status.Error(codes.OutOfRange, fmt.Sprintf(
+				"the events after the resume token %s are not kept, subscribe without the token to resync",
+				subReq.ResumeToken)), ReasonResumeTokenExpired, true)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(registeredID) == 0 {
+		// the subscriber is rejected by the event broadcaster, the error is sent to its error channel
+		logger.Error(<-errChan, "subscriber is rejected")
+		return toSubscriptionError(status.Error(codes.ResourceExhausted, "too many subscribers, retry later"),
+			ReasonTooManySubscribers, true)
+	}
+
+	logger.V(4).Info("subscriber is registered")
+
+	if svr.heartbeatInterval > 0 {
+		heartbeatCtx, stopHeartbeat := context.WithCancel(subServer.Context())
+		defer stopHeartbeat()
+		go stream.heartbeat(heartbeatCtx, svr.source, svr.eventIDGenerator, svr.heartbeatInterval)
+	}
+
+	activeSubscribers := svr.metrics.activeSubscribers.WithLabelValues(subReq.Source)
+	activeSubscribers.Inc()
+	defer activeSubscribers.Dec()
+
+	// the event that waits for the window is failed first, so the subscriber is unregistered without waiting for its
+	// acknowledgements
+	unregister := func() {
+		if stream.window != nil {
+			stream.window.stop()
+		}
+		svr.eventBroadcaster.Unregister(registeredID)
+	}
 
 	select {
-	case err := <-errChan:
-		svr.eventBroadcaster.Unregister(clientID)
-		return err
+	case err, ok := <-errChan:
+		if !ok {
+			// the client is unregistered by the server, e.g. the server is shutting down
+			logger.V(4).Info("subscriber is unregistered by the server")
+			return toSubscriptionError(status.Error(codes.Unavailable, "the subscription is closed by the server"),
+				ReasonSubscriptionClosed, true)
+		}
+		if errors.Is(err, ErrSubscriberNotAlive) {
+			// the client is unregistered by the event broadcaster and its send may never return, so the stream is
+			// finished without waiting for the send, then the send fails.
+			stream.abandon()
+			logger.Error(err, "subscriber is unregistered")
+			return toSubscriptionError(status.Error(codes.Unavailable, "the subscriber does not receive the events in time"),
+				ReasonSubscriberNotAlive, true)
+		}
+		unregister()
+		logger.Error(err, "subscriber is unregistered")
+		if errors.Is(err, ErrSubscriberBufferFull) {
+			return toSubscriptionError(status.Error(codes.ResourceExhausted, "the subscriber is too slow to receive the events"),
+				ReasonSubscriberTooSlow, true)
+		}
+		// the subscriber may subscribe again if the events failed to be sent transiently
+		return toSubscriptionError(err, ReasonSubscriptionFailed, isTransientSendError(err))
+	case <-sub.unsubscribe:
+		unregister()
+		logger.V(4).Info("subscriber is unsubscribed")
+		return nil
+	case <-snapshotOnlyDone:
+		unregister()
+		logger.V(4).Info("snapshot is sent to the subscriber")
+		return nil
 	case <-subServer.Context().Done():
-		svr.eventBroadcaster.Unregister(clientID)
+		unregister()
+		logger.V(4).Info("subscriber is unregistered")
 		return nil
 	}
 }
 
-func (svr *GRPCServer) Start(addr string) error {
-	lis, err := net.Listen("tcp", addr)
+// Resync resends the current resources of the subscription to its subscriber in the stream of the subscription, and
+// then a snapshot done event, so an agent that recovers from a crash can resync without subscribing again. The events
+// that are being sent are sent before the resync, and the response is sent once the resync is requested.
+func (svr *GRPCServer) Resync(ctx context.Context, req *pbv1.ResyncRequest) (*pbv1.ResyncResponse, error) {
+	svr.mu.Lock()
+	_, ok := svr.subscriptions[req.SubscriptionId]
+	svr.mu.Unlock()
+
+	// the subscription may be unregistered from the event broadcaster in the meantime
+	var err error
+	if ok {
+		err = svr.eventBroadcaster.Resync(req.SubscriptionId)
+	}
+	if !ok || errors.Is(err, ErrClientNotFound) {
+		return nil, status.Errorf(codes.NotFound, "the subscription %s is not found", req.SubscriptionId)
+	}
 	if err != nil {
-		log.Printf("failed to listen: %v", err)
-		return err
+		return nil, toStatusError(err)
 	}
-	grpcServer := grpc.NewServer()
-	pbv1.RegisterCloudEventServiceServer(grpcServer, svr)
-	return grpcServer.Serve(lis)
+
+	return &pbv1.ResyncResponse{}, nil
 }
 
-func encode(resource *Resource) (*cloudevents.Event, error) {
-	source := "test-source"
-	eventType := types.CloudEventsType{
-		CloudEventsDataType: payload.ManifestEventDataType,
-		SubResource:         types.SubResourceStatus,
-		Action:              "status_update",
+// Acknowledge acknowledges the events that the subscriber of a subscription has received, so the server sends more
+// events to it once the window of the subscription is full. The FailedPrecondition code is returned if the
+// subscription is not flow controlled.
+func (svr *GRPCServer) Acknowledge(ctx context.Context, req *pbv1.AcknowledgeRequest) (*pbv1.AcknowledgeResponse, error) {
+	svr.mu.Lock()
+	sub, ok := svr.subscriptions[req.SubscriptionId]
+	svr.mu.Unlock()
+
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "the subscription %s is not found", req.SubscriptionId)
+	}
+	if sub.window == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "the subscription %s is not flow controlled",
+			req.SubscriptionId)
+	}
+	if err := sub.window.ack(req.Received); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	eventBuilder := types.NewEventBuilder(source, eventType).
-		WithResourceID(resource.ResourceID).
-		WithResourceVersion(resource.ResourceVersion).
-		WithClusterName(resource.Namespace)
+	return &pbv1.AcknowledgeResponse{}, nil
+}
 
-	evt := eventBuilder.NewEvent()
+// Unsubscribe unsubscribes the subscription and responds once the subscription is unregistered from the event
+// broadcaster, the stream of the subscription is closed with the OK status.
+func (svr *GRPCServer) Unsubscribe(ctx context.Context, req *pbv1.UnsubscribeRequest) (*pbv1.UnsubscribeResponse, error) {
+	svr.mu.Lock()
+	sub, ok := svr.subscriptions[req.SubscriptionId]
+	if ok {
+		// the subscription is removed, so it is only unsubscribed once
+		delete(svr.subscriptions, req.SubscriptionId)
+		close(sub.unsubscribe)
+	}
+	svr.mu.Unlock()
 
-	if err := evt.SetData(cloudevents.ApplicationJSON, &payload.ManifestStatus{Conditions: resource.Status.Conditions}); err != nil {
-		return nil, fmt.Errorf("failed to encode manifest status to cloud event: %v", err)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "the subscription %s is not found", req.SubscriptionId)
 	}
 
-	return &evt, nil
+	select {
+	case <-sub.stopped:
+		return &pbv1.UnsubscribeResponse{}, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
 }
 
-func decode(evt *cloudevents.Event) (*Resource, error) {
-	eventType, err := types.ParseCloudEventsType(evt.Type())
+// GetResource returns the status cloudevent of the resource, the NotFound code is returned if the resource does not
+// exist.
+func (svr *GRPCServer) GetResource(ctx context.Context, req *pbv1.GetResourceRequest) (*pbv1.GetResourceResponse, error) {
+	res, err := svr.store.Get(req.ResourceId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse cloud event type %s, %v", evt.Type(), err)
+		return nil, toStatusError(err)
 	}
 
-	if eventType.CloudEventsDataType != payload.ManifestEventDataType {
-		return nil, fmt.Errorf("unsupported cloudevents data type %s", eventType.CloudEventsDataType)
+	pbEvt, err := svr.encodeToPBEvent(res)
+	if err != nil {
+		return nil, toStatusError(err)
 	}
 
-	evtExtensions := evt.Context.GetExtensions()
+	return &pbv1.GetResourceResponse{Event: pbEvt}, nil
+}
 
-	resourceID, err := cloudeventstypes.ToString(evtExtensions[types.ExtensionResourceID])
+// ListResources returns the status cloudevents of the resources of the sources, the resources are scoped to the
+// cluster if the cluster is specified, so the clients that cannot hold a stream can poll the current resources.
+func (svr *GRPCServer) ListResources(ctx context.Context, req *pbv1.ListResourcesRequest) (*pbv1.ListResourcesResponse, error) {
+	filter, err := NewSourceFilter(req.Source)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get resourceid extension: %v", err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resources := sortResources(svr.store.ListBySourceAndCluster(filter, req.ClusterName))
+
+	events := []*pbv1.CloudEvent{}
+	for _, res := range resources {
+		pbEvt, err := svr.encodeToPBEvent(res)
+		if err != nil {
+			return nil, toStatusError(err)
+		}
+		events = append(events, pbEvt)
 	}
 
-	resourceVersion, err := cloudeventstypes.ToInteger(evtExtensions[types.ExtensionResourceVersion])
+	return &pbv1.ListResourcesResponse{Events: events}, nil
+}
+
+// encodeToPBEvent encodes the resource to a protobuf cloudevent.
+func (svr *GRPCServer) encodeToPBEvent(res *Resource) (*pbv1.CloudEvent, error) {
+	evt, err := svr.encode(res)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get resourceversion extension: %v", err)
+		return nil, fmt.Errorf("%w %s to cloudevent: %w", ErrEncode, res.ResourceID, err)
 	}
 
-	clusterName, err := cloudeventstypes.ToString(evtExtensions[types.ExtensionClusterName])
+	return toPBEvent(evt)
+}
+
+// encodeStatusEvent encodes the status event of a broadcast resource, the resume token and the subscription sequence
+// of the event are set if they are assigned by the event broadcaster. It is shared by the transports.
+func (svr *GRPCServer) encodeStatusEvent(res *Resource) (*cloudevents.Event, error) {
+	evt, err := svr.encode(res)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get clustername extension: %v", err)
+		return nil, fmt.Errorf("%w %s to cloudevent: %w", ErrEncode, res.ResourceID, err)
 	}
 
-	manifest := &payload.Manifest{}
-	if err := evt.DataAs(manifest); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal event data %s, %v", string(evt.Data()), err)
+	if res.Sequence != 0 {
+		evt.SetExtension(ExtensionResumeToken, strconv.FormatUint(res.Sequence, 10))
 	}
+	if res.SubscriptionSequence != 0 {
+		evt.SetExtension(ExtensionSubscriptionSequence, strconv.FormatUint(res.SubscriptionSequence, 10))
+	}
+	return evt, nil
+}
 
-	resource := &Resource{
-		Source:          evt.Source(),
-		ResourceID:      resourceID,
-		ResourceVersion: int64(resourceVersion),
-		Namespace:       clusterName,
-		Spec:            manifest.Manifest,
+// toPBEvent converts the cloudevent to a protobuf cloudevent.
+func toPBEvent(evt *cloudevents.Event) (*pbv1.CloudEvent, error) {
+	// WARNING: don't use "pbEvt, err := pb.ToProto(evt)" to convert cloudevent to protobuf
+	pbEvt := &pbv1.CloudEvent{}
+	if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(evt), pbEvt); err != nil {
+		return nil, fmt.Errorf("%w, failed to convert cloudevent to protobuf: %v", ErrEncode, err)
 	}
 
-	if deletionTimestampValue, exists := evtExtensions[types.ExtensionDeletionTimestamp]; exists {
-		deletionTimestamp, err := cloudeventstypes.ToTime(deletionTimestampValue)
+	return pbEvt, nil
+}
+
+func (svr *GRPCServer) addSubscription(id string, window *sendWindow) *subscription {
+	svr.mu.Lock()
+	defer svr.mu.Unlock()
+
+	sub := &subscription{
+		unsubscribe: make(chan struct{}),
+		stopped:     make(chan struct{}),
+		window:      window,
+	}
+	svr.subscriptions[id] = sub
+	return sub
+}
+
+// removeSubscription removes the subscription once it is stopped.
+func (svr *GRPCServer) removeSubscription(id string, sub *subscription) {
+	svr.mu.Lock()
+	defer svr.mu.Unlock()
+
+	if svr.subscriptions[id] == sub {
+		delete(svr.subscriptions, id)
+	}
+	close(sub.stopped)
+}
+
+// setSendCompressor sets the send compressor of the server for the RPC if the client supports it.
+func (svr *GRPCServer) setSendCompressor(ctx context.Context) {
+	if len(svr.sendCompressor) == 0 {
+		return
+	}
+
+	compressors, err := grpc.ClientSupportedCompressors(ctx)
+	if err != nil {
+		svr.requestLogger(ctx).Error(err, "failed to get the supported compressors of the client")
+		return
+	}
+
+	for _, compressor := range compressors {
+		if compressor != svr.sendCompressor {
+			continue
+		}
+
+		if err := grpc.SetSendCompressor(ctx, svr.sendCompressor); err != nil {
+			svr.requestLogger(ctx).Error(err, "failed to set the send compressor", "compressor", svr.sendCompressor)
+		}
+		return
+	}
+}
+
+// send sends the cloudevents to the subscriber in one message with the sendMsg, the transient errors are retried with
+// the backoff until the steps of the backoff are exhausted or the subscriber is gone, that is the ctx is done, the
+// other errors are returned immediately.
+func send(ctx context.Context, sendMsg func(events []*pbv1.CloudEvent) error, evts []*cloudevents.Event,
+	backoff wait.Backoff, clock clock.Clock) error {
+	pbEvts := make([]*pbv1.CloudEvent, 0, len(evts))
+	for _, evt := range evts {
+		pbEvt, err := toPBEvent(evt)
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert deletion timestamp %v to time.Time: %v", deletionTimestampValue, err)
+			return err
+		}
+		pbEvts = append(pbEvts, pbEvt)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := sendMsg(pbEvts)
+		if err == nil || !isTransientSendError(err) || attempt >= backoff.Steps {
+			return err
 		}
-		resource.DeletionTimestamp = &metav1.Time{Time: deletionTimestamp}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-clock.After(backoff.Step()):
+		}
+	}
+}
+
+// isTransientSendError returns true if sending an event may succeed when it is retried, the stream is still usable
+// when the transport is temporarily unavailable, the other errors, e.g. the event is too large, are terminal.
+func isTransientSendError(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+// Start starts the server on the given address, the server will be stopped once the context is done. The address is a
+// tcp address, e.g. "127.0.0.1:8080", or a unix socket address in the gRPC naming, e.g. "unix:///var/run/grpc.sock"
+// or "unix:relative/grpc.sock", so the co-located clients can connect to the server without the network stack.
+func (svr *GRPCServer) Start(ctx context.Context, addr string) error {
+	return svr.listenAndServe(ctx, addr)
+}
+
+// StartTLS starts the server with the given TLS config. If the client CAs are set in the TLS config, the mutual TLS
+// is required, the clients whose certificates are expired, untrusted or not allowed will be rejected with a
+// ClientCertificateError.
+func (svr *GRPCServer) StartTLS(ctx context.Context, addr string, tlsConfig *tls.Config) error {
+	if tlsConfig == nil {
+		return fmt.Errorf("the tls config must not be nil")
+	}
+
+	config, err := svr.tlsConfig(tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	return svr.listenAndServe(ctx, addr, grpc.Creds(credentials.NewTLS(config)))
+}
+
+// Stop stops the server gracefully, the health status of the server is reported as NOT_SERVING, then all of the
+// subscribers are unregistered and their streams are closed with the Unavailable status. If the context is done before
+// the in-flight requests are finished, the server will be stopped immediately. The resources are persisted once the
+// server is stopped if WithPersistenceFile is set.
+func (svr *GRPCServer) Stop(ctx context.Context) {
+	defer svr.persistResources()
+
+	svr.mu.Lock()
+	grpcServer := svr.grpcServer
+	healthServer := svr.healthServer
+	svr.mu.Unlock()
+
+	if grpcServer == nil {
+		return
+	}
+
+	// report the server is not serving before it is stopped, so the clients stop sending new requests to it
+	healthServer.Shutdown()
+
+	svr.eventBroadcaster.UnregisterAll()
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		svr.logger.Error(ctx.Err(), "failed to stop the server gracefully")
+		grpcServer.Stop()
+	}
+}
+
+func (svr *GRPCServer) listenAndServe(ctx context.Context, addr string, opts ...grpc.ServerOption) error {
+	network, address := listenAddress(addr)
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			svr.logger.Error(err, "failed to remove the stale unix socket", "address", addr)
+			return err
+		}
+	}
+
+	lis, err := net.Listen(network, address)
+	if err != nil {
+		svr.logger.Error(err, "failed to listen", "address", addr)
+		return err
+	}
+	return svr.serve(ctx, lis, opts...)
+}
+
+// listenAddress returns the network and the address to listen on of the given address, the unix socket addresses are
+// in the gRPC naming, i.e. "unix://absolute_path" or "unix:relative_path", the others are tcp addresses.
+func listenAddress(addr string) (string, string) {
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return "unix", strings.TrimPrefix(addr, "unix://")
+	case strings.HasPrefix(addr, "unix:"):
+		return "unix", strings.TrimPrefix(addr, "unix:")
+	default:
+		return "tcp", addr
+	}
+}
+
+// removeStaleSocket removes the socket file that is left by a server that is not stopped gracefully, otherwise the
+// server cannot listen on it again. The files that are not sockets are not removed.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("the file %s exists and it is not a unix socket", path)
+	}
+	return os.Remove(path)
+}
+
+func (svr *GRPCServer) serve(ctx context.Context, lis net.Listener, opts ...grpc.ServerOption) error {
+	// the options of the caller are appended, so they take precedence over the options of the server
+	opts = append(append([]grpc.ServerOption{
+		grpc.MaxRecvMsgSize(svr.maxRecvMsgSize),
+		grpc.MaxSendMsgSize(svr.maxSendMsgSize),
+		grpc.KeepaliveParams(svr.keepaliveParams),
+		grpc.KeepaliveEnforcementPolicy(svr.keepalivePolicy),
+	}, svr.interceptors.ServerOptions()...), opts...)
+	grpcServer := grpc.NewServer(opts...)
+	pbv1.RegisterCloudEventServiceServer(grpcServer, svr)
+
+	// the store and the event broadcaster are ready once the server is created, so the server is serving once it
+	// is started
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(pbv1.CloudEventService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	svr.mu.Lock()
+	svr.grpcServer = grpcServer
+	svr.healthServer = healthServer
+	svr.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		svr.Stop(context.Background())
+	}()
+
+	return grpcServer.Serve(lis)
+}
+
+// encode encodes the resource status to a cloudevent with the codec of the resource data type.
+func (svr *GRPCServer) encode(res *Resource) (*cloudevents.Event, error) {
+	dataType := resourceDataType(res)
+	codec, ok := svr.codecs[dataType]
+	if !ok {
+		return nil, svr.unsupportedDataTypeError(dataType)
+	}
+
+	if err := svr.validateConditions(res); err != nil {
+		return nil, err
+	}
+
+	start := svr.clock.Now()
+	defer func() {
+		svr.metrics.encodeDuration.WithLabelValues(res.Source, dataType.String()).Observe(svr.clock.Since(start).Seconds())
+	}()
+
+	evt, err := codec.Encode(res)
+	if err != nil {
+		return nil, err
+	}
+	svr.metrics.observeEventDataSize(res.Source, dataType.String(), len(evt.Data()))
+
+	if len(svr.eventTypeNamespace) != 0 {
+		evt.SetType(svr.eventTypeNamespace + "." + evt.Type())
+	}
+
+	evt.SetID(svr.eventIDGenerator())
+	return evt, nil
+}
+
+// decode decodes the resource spec from a cloudevent with the codec of the event data type.
+func (svr *GRPCServer) decode(evt *cloudevents.Event, eventType types.CloudEventsType) (*Resource, error) {
+	codec, ok := svr.codecs[eventType.CloudEventsDataType]
+	if !ok {
+		return nil, svr.unsupportedDataTypeError(eventType.CloudEventsDataType)
+	}
+
+	start := svr.clock.Now()
+	defer func() {
+		svr.metrics.decodeDuration.WithLabelValues(evt.Source(), eventType.CloudEventsDataType.String()).
+			Observe(svr.clock.Since(start).Seconds())
+	}()
+
+	res, err := codec.Decode(evt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svr.validateConditions(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// validateConditions returns ErrTooManyConditions if the status of the resource has more conditions than the limit.
+func (svr *GRPCServer) validateConditions(res *Resource) error {
+	if svr.maxConditions <= 0 || len(res.Status.Conditions) <= svr.maxConditions {
+		return nil
+	}
+
+	return fmt.Errorf("%w: the resource %s has %d conditions, the limit is %d", ErrTooManyConditions,
+		res.ResourceID, len(res.Status.Conditions), svr.maxConditions)
+}
+
+// unsupportedDataTypeError returns the error for the data type that has no codec, the error lists the data types of
+// the registered codecs.
+func (svr *GRPCServer) unsupportedDataTypeError(dataType types.CloudEventsDataType) error {
+	supported := []string{}
+	for registered := range svr.codecs {
+		supported = append(supported, registered.String())
+	}
+	sort.Strings(supported)
+
+	return fmt.Errorf("%w %s, the supported data types are %s", ErrUnsupportedDataType, dataType,
+		strings.Join(supported, ", "))
+}
+
+// resourceDataType returns the data type of the resource, the resources without data type are treated as the
+// manifests.
+func resourceDataType(res *Resource) types.CloudEventsDataType {
+	if len(res.DataType.Resource) == 0 {
+		return payload.ManifestEventDataType
 	}
 
-	return resource, nil
+	return res.DataType
 }
@@ -0,0 +1,4143 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	"github.com/go-logr/logr/funcr"
+	"golang.org/x/net/http2"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	testingclock "k8s.io/utils/clock/testing"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options"
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	grpcprotocol "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protocol"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/work/payload"
+)
+
+var testSpecEventType = types.CloudEventsType{
+	CloudEventsDataType: payload.ManifestEventDataType,
+	SubResource:         types.SubResourceSpec,
+	Action:              "test_create_request",
+}
+
+// newPublishRequest encodes the resource to a spec cloudevent and wraps it into a publish request.
+func newPublishRequest(t *testing.T, res *Resource) *pbv1.PublishRequest {
+	return newPublishRequestFromSource(t, "test-source", res)
+}
+
+// newPublishRequestFromSource is like newPublishRequest, but the cloudevent is from the given source.
+func newPublishRequestFromSource(t *testing.T, source string, res *Resource) *pbv1.PublishRequest {
+	evt, err := (&ResourceCodec{}).Encode(source, testSpecEventType, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pbEvt := &pbv1.CloudEvent{}
+	if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(evt), pbEvt); err != nil {
+		t.Fatal(err)
+	}
+
+	return &pbv1.PublishRequest{Event: pbEvt}
+}
+
+// startTestServer starts the server in memory and returns a client connected to it.
+func startTestServer(t *testing.T, svr *GRPCServer, opts ...grpc.ServerOption) (pbv1.CloudEventServiceClient, <-chan error) {
+	inMemoryServer, err := StartInMemoryServer(svr, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		inMemoryServer.Close(context.Background())
+	})
+
+	return inMemoryServer.Client(), inMemoryServer.ServeErr()
+}
+
+// waitForSubscribers waits until the given number of subscribers are registered in the event broadcaster.
+func waitForSubscribers(t *testing.T, eventBroadcaster *EventBroadcaster, count int) {
+	err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true,
+		func(ctx context.Context) (bool, error) {
+			eventBroadcaster.mu.RLock()
+			defer eventBroadcaster.mu.RUnlock()
+			return len(eventBroadcaster.clients) == count, nil
+		})
+	if err != nil {
+		t.Fatalf("expected %d subscribers, %v", count, err)
+	}
+}
+
+func TestStop(t *testing.T) {
+	eventBroadcaster := NewEventBroadcaster()
+	svr := NewGRPCServer(NewMemoryStore(), eventBroadcaster)
+	client, serveErr := startTestServer(t, svr)
+
+	stream, err := client.Subscribe(context.Background(), &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			// the snapshot done event is received first
+			if _, err := stream.Recv(); err != nil {
+				recvErr <- err
+				return
+			}
+		}
+	}()
+
+	waitForSubscribers(t, eventBroadcaster, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	svr.Stop(ctx)
+
+	select {
+	case err := <-recvErr:
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("expected unavailable status, but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the subscription is not closed after the server is stopped")
+	}
+
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the server is not stopped")
+	}
+
+	waitForSubscribers(t, eventBroadcaster, 0)
+}
+
+func TestPublish(t *testing.T) {
+	store := NewMemoryStore()
+	client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+
+	res := NewResource("cluster1", "resource1")
+	res.ResourceVersion = 2
+
+	resp, err := client.Publish(context.Background(), newPublishRequest(t, res))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result != pbv1.PublishResult_PUBLISH_RESULT_CREATED {
+		t.Errorf("expected created, but got %v", resp.Result)
+	}
+	if resp.ResourceId != res.ResourceID || resp.ResourceVersion != 2 {
+		t.Errorf("unexpected response %v", resp)
+	}
+
+	// publish the same event again
+	resp, err = client.Publish(context.Background(), newPublishRequest(t, res))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result != pbv1.PublishResult_PUBLISH_RESULT_NO_OP {
+		t.Errorf("expected no-op, but got %v", resp.Result)
+	}
+	if resp.ResourceVersion != 2 {
+		t.Errorf("expected resource version 2, but got %d", resp.ResourceVersion)
+	}
+
+	res.ResourceVersion = 3
+	resp, err = client.Publish(context.Background(), newPublishRequest(t, res))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result != pbv1.PublishResult_PUBLISH_RESULT_UPDATED {
+		t.Errorf("expected updated, but got %v", resp.Result)
+	}
+
+	stored, err := store.Get(res.ResourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ResourceVersion != 3 {
+		t.Errorf("expected resource version 3, but got %d", stored.ResourceVersion)
+	}
+}
+
+func TestPublishWithInvalidExtensions(t *testing.T) {
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), NewEventBroadcaster()))
+
+	evt := types.NewEventBuilder("test-source", testSpecEventType).WithClusterName("cluster1").NewEvent()
+	pbEvt := &pbv1.CloudEvent{}
+	if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(&evt), pbEvt); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.Publish(context.Background(), &pbv1.PublishRequest{Event: pbEvt})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected invalid argument status, but got %v", err)
+	}
+	for _, key := range []string{types.ExtensionResourceID, types.ExtensionResourceVersion} {
+		if !strings.Contains(status.Convert(err).Message(), key) {
+			t.Errorf("expected %s is reported in %q", key, status.Convert(err).Message())
+		}
+	}
+}
+
+func TestStartWithUnixSocket(t *testing.T) {
+	cases := []struct {
+		name        string
+		address     func(path string) string
+		stale       bool
+		regularFile bool
+		expectedErr bool
+	}{
+		{
+			name:    "absolute path",
+			address: func(path string) string { return "unix://" + path },
+		},
+		{
+			name:    "stale socket",
+			address: func(path string) string { return "unix://" + path },
+			stale:   true,
+		},
+		{
+			name:        "regular file",
+			address:     func(path string) string { return "unix://" + path },
+			regularFile: true,
+			expectedErr: true,
+		},
+		{
+			name:    "unix scheme without slashes",
+			address: func(path string) string { return "unix:" + path },
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			path := filepath.Join(t.TempDir(), "grpc.sock")
+			if c.stale {
+				// the socket file is left behind, like the server is not stopped gracefully
+				lis, err := net.Listen("unix", path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lis.(*net.UnixListener).SetUnlinkOnClose(false)
+				lis.Close()
+			}
+			if c.regularFile {
+				if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			store := NewMemoryStore()
+			svr := NewGRPCServer(store, NewEventBroadcaster())
+			startErr := make(chan error, 1)
+			go func() {
+				startErr <- svr.Start(ctx, c.address(path))
+			}()
+
+			if c.expectedErr {
+				select {
+				case err := <-startErr:
+					if err == nil {
+						t.Errorf("expected the server fails to start")
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("expected the server fails to start, but it is started")
+				}
+				return
+			}
+
+			conn, err := grpc.DialContext(ctx, c.address(path), grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			res := NewResource("cluster1", "resource1")
+			publishCtx, publishCancel := context.WithTimeout(ctx, 5*time.Second)
+			defer publishCancel()
+			if _, err := pbv1.NewCloudEventServiceClient(conn).Publish(publishCtx, newPublishRequest(t, res),
+				grpc.WaitForReady(true)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Get(res.ResourceID); err != nil {
+				t.Errorf("expected the resource is published over the unix socket, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestPublishWithResourceVersion(t *testing.T) {
+	cases := []struct {
+		name            string
+		resourceVersion int64
+		expectedCode    codes.Code
+	}{
+		{
+			name:            "zero version",
+			resourceVersion: 0,
+			expectedCode:    codes.InvalidArgument,
+		},
+		{
+			name:            "negative version",
+			resourceVersion: -1,
+			expectedCode:    codes.InvalidArgument,
+		},
+		{
+			name:            "valid version",
+			resourceVersion: 1,
+			expectedCode:    codes.OK,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+
+			res := NewResource("cluster1", "resource1")
+			res.ResourceVersion = c.resourceVersion
+			_, err := client.Publish(context.Background(), newPublishRequest(t, res))
+			if status.Code(err) != c.expectedCode {
+				t.Fatalf("expected code %s, but got %v", c.expectedCode, err)
+			}
+			if err != nil && !strings.Contains(status.Convert(err).Message(), types.ExtensionResourceVersion) {
+				t.Errorf("expected %s is reported in %q", types.ExtensionResourceVersion, status.Convert(err).Message())
+			}
+
+			_, err = store.Get(res.ResourceID)
+			if stored := err == nil; stored != (c.expectedCode == codes.OK) {
+				t.Errorf("expected the resource is stored %t, but got %v", c.expectedCode == codes.OK, err)
+			}
+		})
+	}
+}
+
+func TestPublishWithInvalidNames(t *testing.T) {
+	cases := []struct {
+		name              string
+		clusterName       string
+		resourceID        string
+		expectedCode      codes.Code
+		expectedExtension string
+	}{
+		{
+			name:         "valid names",
+			clusterName:  "cluster1",
+			resourceID:   ResourceID("cluster1", "resource1"),
+			expectedCode: codes.OK,
+		},
+		{
+			name:              "cluster name with path traversal",
+			clusterName:       "../cluster1",
+			resourceID:        ResourceID("cluster1", "resource1"),
+			expectedCode:      codes.InvalidArgument,
+			expectedExtension: types.ExtensionClusterName,
+		},
+		{
+			name:              "cluster name with control characters",
+			clusterName:       "cluster1\n",
+			resourceID:        ResourceID("cluster1", "resource1"),
+			expectedCode:      codes.InvalidArgument,
+			expectedExtension: types.ExtensionClusterName,
+		},
+		{
+			name:              "cluster name with uppercase characters",
+			clusterName:       "Cluster1",
+			resourceID:        ResourceID("cluster1", "resource1"),
+			expectedCode:      codes.InvalidArgument,
+			expectedExtension: types.ExtensionClusterName,
+		},
+		{
+			name:              "resource id with invalid characters",
+			clusterName:       "cluster1",
+			resourceID:        "resource/1",
+			expectedCode:      codes.InvalidArgument,
+			expectedExtension: types.ExtensionResourceID,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+
+			res := NewResource("cluster1", "resource1")
+			res.Namespace = c.clusterName
+			res.ResourceID = c.resourceID
+			_, err := client.Publish(context.Background(), newPublishRequest(t, res))
+			if status.Code(err) != c.expectedCode {
+				t.Fatalf("expected code %s, but got %v", c.expectedCode, err)
+			}
+			if err != nil && !strings.Contains(status.Convert(err).Message(), c.expectedExtension) {
+				t.Errorf("expected %s is reported in %q", c.expectedExtension, status.Convert(err).Message())
+			}
+
+			_, err = store.Get(res.ResourceID)
+			if stored := err == nil; stored != (c.expectedCode == codes.OK) {
+				t.Errorf("expected the resource is stored %t, but got %v", c.expectedCode == codes.OK, err)
+			}
+		})
+	}
+}
+
+func TestPublishWithDoneContext(t *testing.T) {
+	cases := []struct {
+		name         string
+		ctx          func() (context.Context, context.CancelFunc)
+		expectedCode codes.Code
+	}{
+		{
+			name: "cancelled context",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			expectedCode: codes.Canceled,
+		},
+		{
+			name: "deadline exceeded",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+			},
+			expectedCode: codes.DeadlineExceeded,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			svr := NewGRPCServer(store, NewEventBroadcaster())
+
+			ctx, cancel := c.ctx()
+			defer cancel()
+
+			res := NewResource("cluster1", "resource1")
+			_, err := svr.Publish(ctx, newPublishRequest(t, res))
+			if status.Code(err) != c.expectedCode {
+				t.Errorf("expected %s status, but got %v", c.expectedCode, err)
+			}
+
+			if _, err := store.Get(res.ResourceID); err == nil {
+				t.Errorf("expected the store is unchanged, but the resource is created")
+			}
+		})
+	}
+}
+
+func TestPublishWithRateLimit(t *testing.T) {
+	limit := options.EventRateLimit{QPS: 5, Burst: 2}
+
+	cases := []struct {
+		name                  string
+		opt                   GRPCServerOption
+		anotherSourceAccepted bool
+	}{
+		{
+			name:                  "source rate limit",
+			opt:                   WithSourcePublishRateLimit(limit),
+			anotherSourceAccepted: true,
+		},
+		{
+			name: "global rate limit",
+			opt:  WithGlobalPublishRateLimit(limit),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(), c.opt))
+			res := NewResource("cluster1", "resource1")
+
+			rejected := 0
+			for i := 0; i < 10; i++ {
+				_, err := client.Publish(context.Background(), newPublishRequest(t, res))
+				if status.Code(err) == codes.ResourceExhausted {
+					rejected++
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if rejected == 0 {
+				t.Errorf("expected some requests are rejected")
+			}
+
+			_, err := client.Publish(context.Background(), newPublishRequestFromSource(t, "another-source", res))
+			if c.anotherSourceAccepted && err != nil {
+				t.Errorf("expected the requests of another source are accepted, but got %v", err)
+			}
+			if !c.anotherSourceAccepted && status.Code(err) != codes.ResourceExhausted {
+				t.Errorf("expected the requests of another source are rejected, but got %v", err)
+			}
+
+			// the bucket is refilled
+			time.Sleep(500 * time.Millisecond)
+			if _, err := client.Publish(context.Background(), newPublishRequest(t, res)); err != nil {
+				t.Errorf("expected the request is accepted after the bucket is refilled, but got %v", err)
+			}
+		})
+	}
+}
+
+// inflightCodec is a manifest codec that records the max number of the concurrent decodes, each decode waits until
+// it is released.
+type inflightCodec struct {
+	manifestCodec
+
+	release  chan struct{}
+	inflight atomic.Int32
+	max      atomic.Int32
+}
+
+func (c *inflightCodec) Decode(evt *cloudevents.Event) (*Resource, error) {
+	inflight := c.inflight.Add(1)
+	defer c.inflight.Add(-1)
+	for {
+		last := c.max.Load()
+		if inflight <= last || c.max.CompareAndSwap(last, inflight) {
+			break
+		}
+	}
+
+	<-c.release
+	return c.manifestCodec.Decode(evt)
+}
+
+func TestPublishWithMaxConcurrentPublishes(t *testing.T) {
+	const limit = 3
+
+	cases := []struct {
+		name             string
+		publishes        int
+		queueSize        int
+		expectedRejected int
+	}{
+		{
+			name:      "queued publishes",
+			publishes: 50,
+			queueSize: 50,
+		},
+		{
+			name:             "rejected publishes",
+			publishes:        10,
+			queueSize:        2,
+			expectedRejected: 5,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			codec := &inflightCodec{release: make(chan struct{})}
+			svr := NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(),
+				WithCodecs(codec), WithMaxConcurrentPublishes(limit, c.queueSize))
+			client, _ := startTestServer(t, svr)
+
+			var wg sync.WaitGroup
+			var rejected atomic.Int32
+			for i := 0; i < c.publishes; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					res := NewResource("cluster1", fmt.Sprintf("resource%d", i))
+					_, err := client.Publish(context.Background(), newPublishRequest(t, res))
+					if status.Code(err) == codes.ResourceExhausted {
+						rejected.Add(1)
+						return
+					}
+					if err != nil {
+						t.Error(err)
+					}
+				}(i)
+			}
+
+			// the publishes are released once all of them are either running, waiting or rejected
+			err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true,
+				func(ctx context.Context) (bool, error) {
+					return codec.inflight.Load() == limit &&
+						svr.publishLimiter.waiting.Load() == int64(c.publishes-limit-c.expectedRejected), nil
+				})
+			if err != nil {
+				t.Fatalf("expected %d running publishes, but got %d", limit, codec.inflight.Load())
+			}
+			close(codec.release)
+			wg.Wait()
+
+			if maxInflight := codec.max.Load(); maxInflight > limit {
+				t.Errorf("expected at most %d concurrent publishes, but got %d", limit, maxInflight)
+			}
+			if int(rejected.Load()) != c.expectedRejected {
+				t.Errorf("expected %d rejected publishes, but got %d", c.expectedRejected, rejected.Load())
+			}
+		})
+	}
+}
+
+func TestPublishBatch(t *testing.T) {
+	cases := []struct {
+		name              string
+		allOrNothing      bool
+		expectedSucceeded int32
+		expectedResources int
+	}{
+		{
+			name:              "partial failures",
+			expectedSucceeded: 99,
+			expectedResources: 99,
+		},
+		{
+			name:         "all or nothing",
+			allOrNothing: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+
+			stream, err := client.PublishBatch(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 100; i++ {
+				req := &pbv1.PublishBatchRequest{AllOrNothing: c.allOrNothing}
+				if i == 50 {
+					// the malformed event without resource id
+					evt := types.NewEventBuilder("test-source", testSpecEventType).WithClusterName("cluster1").NewEvent()
+					req.Event = &pbv1.CloudEvent{}
+					if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(&evt), req.Event); err != nil {
+						t.Fatal(err)
+					}
+				} else {
+					req.Event = newPublishRequest(t, NewResource("cluster1", fmt.Sprintf("resource%d", i))).Event
+				}
+
+				if err := stream.Send(req); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resp, err := stream.CloseAndRecv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Succeeded != c.expectedSucceeded {
+				t.Errorf("expected %d successes, but got %d", c.expectedSucceeded, resp.Succeeded)
+			}
+			if len(resp.Failures) != 1 || resp.Failures[0].Index != 50 {
+				t.Errorf("expected the failure of the event 50, but got %v", resp.Failures)
+			}
+			if len(store.List("cluster1")) != c.expectedResources {
+				t.Errorf("expected %d resources, but got %d", c.expectedResources, len(store.List("cluster1")))
+			}
+		})
+	}
+}
+
+func TestPublishWithStaleResourceVersion(t *testing.T) {
+	store := NewMemoryStore()
+	client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+
+	res := NewResource("cluster1", "resource1")
+	res.ResourceVersion = 5
+	if _, err := client.Publish(context.Background(), newPublishRequest(t, res)); err != nil {
+		t.Fatal(err)
+	}
+
+	res.ResourceVersion = 3
+	_, err := client.Publish(context.Background(), newPublishRequest(t, res))
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected failed precondition status, but got %v", err)
+	}
+
+	stored, err := store.Get(res.ResourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ResourceVersion != 5 {
+		t.Errorf("expected resource version 5, but got %d", stored.ResourceVersion)
+	}
+}
+
+func TestPublishWithDuplicateEvent(t *testing.T) {
+	store := NewMemoryStore()
+	client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+
+	res := NewResource("cluster1", "resource1")
+	req := newPublishRequest(t, res)
+	resp, err := client.Publish(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result != pbv1.PublishResult_PUBLISH_RESULT_CREATED {
+		t.Errorf("expected created result, but got %s", resp.Result)
+	}
+
+	// the replayed event has the same id, it is not applied even though its content is changed
+	res.ResourceVersion = 2
+	res.Spec.Object["data"] = map[string]interface{}{"key": "value"}
+	replayed := newPublishRequest(t, res)
+	replayed.Event.Id = req.Event.Id
+	resp, err = client.Publish(context.Background(), replayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result != pbv1.PublishResult_PUBLISH_RESULT_NO_OP {
+		t.Errorf("expected no-op result, but got %s", resp.Result)
+	}
+
+	stored, err := store.Get(res.ResourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ResourceVersion != 1 {
+		t.Errorf("expected resource version 1, but got %d", stored.ResourceVersion)
+	}
+	if stored.EventID != req.Event.Id {
+		t.Errorf("expected event id %s, but got %s", req.Event.Id, stored.EventID)
+	}
+	if stored.EventTime.IsZero() {
+		t.Errorf("expected the event time is stored")
+	}
+}
+
+func TestPublishWithDryRun(t *testing.T) {
+	invalidEvent := func(t *testing.T) *pbv1.PublishRequest {
+		evt := types.NewEventBuilder("test-source", testSpecEventType).WithClusterName("cluster1").NewEvent()
+		pbEvt := &pbv1.CloudEvent{}
+		if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(&evt), pbEvt); err != nil {
+			t.Fatal(err)
+		}
+		return &pbv1.PublishRequest{Event: pbEvt}
+	}
+
+	cases := []struct {
+		name            string
+		storedVersion   int64
+		request         func(t *testing.T) *pbv1.PublishRequest
+		expectedResult  pbv1.PublishResult
+		expectedCode    codes.Code
+		expectedVersion int64
+	}{
+		{
+			name: "create",
+			request: func(t *testing.T) *pbv1.PublishRequest {
+				return newPublishRequest(t, NewResource("cluster1", "resource1"))
+			},
+			expectedResult: pbv1.PublishResult_PUBLISH_RESULT_CREATED,
+		},
+		{
+			name:          "update",
+			storedVersion: 1,
+			request: func(t *testing.T) *pbv1.PublishRequest {
+				res := NewResource("cluster1", "resource1")
+				res.ResourceVersion = 2
+				res.Spec.Object["data"] = map[string]interface{}{"key": "value"}
+				return newPublishRequest(t, res)
+			},
+			expectedResult:  pbv1.PublishResult_PUBLISH_RESULT_UPDATED,
+			expectedVersion: 1,
+		},
+		{
+			name:          "stale resource version",
+			storedVersion: 5,
+			request: func(t *testing.T) *pbv1.PublishRequest {
+				res := NewResource("cluster1", "resource1")
+				res.ResourceVersion = 3
+				return newPublishRequest(t, res)
+			},
+			expectedCode:    codes.FailedPrecondition,
+			expectedVersion: 5,
+		},
+		{
+			name:         "invalid extensions",
+			request:      invalidEvent,
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			eventBroadcaster := NewEventBroadcaster()
+			store := newMemoryStore(eventBroadcaster, WithDedupWindow(time.Minute, 10))
+			notified := make(chan *Resource, 1)
+			go func() {
+				for res := range store.GetResourceSpecChan() {
+					notified <- res
+				}
+			}()
+			client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster))
+
+			if c.storedVersion != 0 {
+				res := NewResource("cluster1", "resource1")
+				res.ResourceVersion = c.storedVersion
+				if _, err := client.Publish(context.Background(), newPublishRequest(t, res)); err != nil {
+					t.Fatal(err)
+				}
+				<-notified
+			}
+
+			req := c.request(t)
+			req.DryRun = true
+			resp, err := client.Publish(context.Background(), req)
+			if status.Code(err) != c.expectedCode {
+				t.Fatalf("expected %s status, but got %v", c.expectedCode, err)
+			}
+			if err == nil && resp.Result != c.expectedResult {
+				t.Errorf("expected %s result, but got %s", c.expectedResult, resp.Result)
+			}
+
+			// the store is not changed by the dry run
+			stored, err := store.Get(ResourceID("cluster1", "resource1"))
+			switch {
+			case c.expectedVersion == 0 && !errors.Is(err, ErrResourceNotFound):
+				t.Errorf("expected the resource is not stored, but got %v", err)
+			case c.expectedVersion != 0 && err != nil:
+				t.Fatal(err)
+			case c.expectedVersion != 0 && stored.ResourceVersion != c.expectedVersion:
+				t.Errorf("expected resource version %d, but got %d", c.expectedVersion, stored.ResourceVersion)
+			}
+			select {
+			case res := <-notified:
+				t.Errorf("expected no notification, but got resource %s", res.ResourceID)
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			// the event of the dry run is not deduplicated, it can be applied later
+			if c.expectedCode == codes.OK {
+				req.DryRun = false
+				resp, err := client.Publish(context.Background(), req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.Result != c.expectedResult {
+					t.Errorf("expected %s result, but got %s", c.expectedResult, resp.Result)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishWithLogger(t *testing.T) {
+	var mu sync.Mutex
+	entries := []map[string]interface{}{}
+	logger := funcr.NewJSON(func(obj string) {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(obj), &entry); err != nil {
+			t.Errorf("failed to unmarshal log entry %s, %v", obj, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		entries = append(entries, entry)
+	}, funcr.Options{Verbosity: 4})
+
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(), WithLogger(logger)))
+
+	res := NewResource("cluster1", "resource1")
+	if _, err := client.Publish(context.Background(), newPublishRequest(t, res)); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, entry := range entries {
+		if entry["msg"] != "resource is published" {
+			continue
+		}
+
+		if entry["source"] != "test-source" {
+			t.Errorf("expected source test-source, but got %v", entry["source"])
+		}
+		if entry["resourceID"] != res.ResourceID {
+			t.Errorf("expected resource id %s, but got %v", res.ResourceID, entry["resourceID"])
+		}
+		// the numbers are unmarshalled as float64
+		if entry["resourceVersion"] != float64(res.ResourceVersion) {
+			t.Errorf("expected resource version %d, but got %v", res.ResourceVersion, entry["resourceVersion"])
+		}
+		return
+	}
+	t.Errorf("expected the publishing is logged, but got %v", entries)
+}
+
+func TestRequestID(t *testing.T) {
+	var mu sync.Mutex
+	entries := []map[string]interface{}{}
+	logger := funcr.NewJSON(func(obj string) {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(obj), &entry); err != nil {
+			t.Errorf("failed to unmarshal log entry %s, %v", obj, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		entries = append(entries, entry)
+	}, funcr.Options{Verbosity: 4})
+	// requestIDs returns the request ids of the log lines with the given message
+	requestIDs := func(msg string) []interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		ids := []interface{}{}
+		for _, entry := range entries {
+			if entry["msg"] == msg {
+				ids = append(ids, entry["requestID"])
+			}
+		}
+		return ids
+	}
+
+	eventBroadcaster := NewEventBroadcaster()
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster, WithLogger(logger)))
+
+	t.Run("request id from metadata", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), RequestIDHeader, "request-1")
+		var header metadata.MD
+		if _, err := client.Publish(ctx, newPublishRequest(t, NewResource("cluster1", "resource1")),
+			grpc.Header(&header)); err != nil {
+			t.Fatal(err)
+		}
+
+		if ids := header.Get(RequestIDHeader); len(ids) != 1 || ids[0] != "request-1" {
+			t.Errorf("expected request id request-1 in the header, but got %v", ids)
+		}
+		if ids := requestIDs("resource is published"); !reflect.DeepEqual(ids, []interface{}{"request-1"}) {
+			t.Errorf("expected request id request-1 in the log lines, but got %v", ids)
+		}
+	})
+
+	t.Run("generated request id", func(t *testing.T) {
+		// the resource version is invalid, so the publishing fails
+		res := NewResource("cluster1", "resource2")
+		res.ResourceVersion = 0
+		var header metadata.MD
+		if _, err := client.Publish(context.Background(), newPublishRequest(t, res), grpc.Header(&header)); err == nil {
+			t.Fatal("expected the publishing fails")
+		}
+
+		ids := header.Get(RequestIDHeader)
+		if len(ids) != 1 || len(ids[0]) == 0 {
+			t.Fatalf("expected a generated request id in the header, but got %v", ids)
+		}
+		if logged := requestIDs("failed to publish the resource"); !reflect.DeepEqual(logged, []interface{}{ids[0]}) {
+			t.Errorf("expected request id %s in the log lines, but got %v", ids[0], logged)
+		}
+	})
+
+	t.Run("subscribe", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, err := stream.Header()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := header.Get(RequestIDHeader)
+		if len(ids) != 1 || len(ids[0]) == 0 {
+			t.Fatalf("expected a generated request id in the header, but got %v", ids)
+		}
+
+		waitForSubscribers(t, eventBroadcaster, 1)
+		cancel()
+
+		// the log lines of the subscription have the same request id
+		err = wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true,
+			func(ctx context.Context) (bool, error) {
+				return len(requestIDs("subscriber is unregistered")) != 0, nil
+			})
+		if err != nil {
+			t.Fatalf("expected the unregistration is logged, %v", err)
+		}
+		for _, msg := range []string{"subscriber is registered", "subscriber is unregistered"} {
+			if logged := requestIDs(msg); !reflect.DeepEqual(logged, []interface{}{ids[0]}) {
+				t.Errorf("expected request id %s in the log lines %q, but got %v", ids[0], msg, logged)
+			}
+		}
+	})
+}
+
+func TestSubscribeWithStatusEventType(t *testing.T) {
+	deletionTimestamp := metav1.Now()
+
+	cases := []struct {
+		name              string
+		opts              []GRPCServerOption
+		deletionTimestamp *metav1.Time
+		expectedAction    types.EventAction
+	}{
+		{
+			name:           "default update action",
+			expectedAction: statusUpdateAction,
+		},
+		{
+			name:              "default delete action",
+			deletionTimestamp: &deletionTimestamp,
+			expectedAction:    statusDeleteAction,
+		},
+		{
+			name: "custom action",
+			opts: []GRPCServerOption{WithStatusEventType(func(res *Resource) (types.EventSubResource, types.EventAction) {
+				return types.SubResourceStatus, "status_resync"
+			})},
+			expectedAction: "status_resync",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			store := NewMemoryStore()
+			res := NewResource("cluster1", "resource1")
+			res.Source = "test-source"
+			res.DeletionTimestamp = c.deletionTimestamp
+			if _, err := store.UpSert(ctx, res); err != nil {
+				t.Fatal(err)
+			}
+
+			eventBroadcaster := NewEventBroadcaster()
+			go eventBroadcaster.Start(ctx)
+			client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster, c.opts...))
+
+			stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			pbEvt, err := stream.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			eventType, err := types.ParseCloudEventsType(evt.Type())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if eventType.SubResource != types.SubResourceStatus {
+				t.Errorf("expected status sub-resource, but got %s", eventType.SubResource)
+			}
+			if eventType.Action != c.expectedAction {
+				t.Errorf("expected action %s, but got %s", c.expectedAction, eventType.Action)
+			}
+		})
+	}
+}
+
+func TestSubscribeWithHeartbeat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster,
+		WithHeartbeatInterval(50*time.Millisecond)))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the snapshot done event is received first, then the heartbeats since no resource is changed
+	for i := 0; i < 3; i++ {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 0 {
+			if evt.Type() != SnapshotDoneEventType.String() {
+				t.Fatalf("expected snapshot done event, but got %s", evt.Type())
+			}
+			continue
+		}
+
+		if evt.Type() != HeartbeatEventType.String() {
+			t.Fatalf("expected heartbeat event, but got %s", evt.Type())
+		}
+		if _, err := (&ResourceCodec{}).Decode(evt); err == nil {
+			t.Errorf("expected the heartbeat event is not decoded to a resource")
+		}
+	}
+}
+
+func TestSubscribeWithEventIDGenerator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewMemoryStore()
+	res := NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	if _, err := store.UpSert(ctx, res); err != nil {
+		t.Fatal(err)
+	}
+
+	sequence := 0
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster,
+		WithHeartbeatInterval(200*time.Millisecond),
+		WithEventIDGenerator(func() string {
+			// the events of a subscription are sent one by one, so the generator is not called concurrently
+			sequence++
+			return fmt.Sprintf("event-%d", sequence)
+		})))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the snapshot of the resource, the snapshot done event, the heartbeat and then the status update
+	expectedTypes := []string{"", SnapshotDoneEventType.String(), HeartbeatEventType.String(), ""}
+	for i, expectedType := range expectedTypes {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(expectedType) != 0 && evt.Type() != expectedType {
+			t.Errorf("expected %s event, but got %s", expectedType, evt.Type())
+		}
+		if expectedID := fmt.Sprintf("event-%d", i+1); evt.ID() != expectedID {
+			t.Errorf("expected event id %s, but got %s", expectedID, evt.ID())
+		}
+
+		if i == 2 {
+			eventBroadcaster.Broadcast(res)
+		}
+	}
+}
+
+func TestSubscribeWithClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := testingclock.NewFakeClock(now)
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster,
+		WithClock(fakeClock), WithHeartbeatInterval(time.Minute)))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func(eventType types.CloudEventsType) *cloudevents.Event {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evt.Type() != eventType.String() {
+			t.Fatalf("expected %s event, but got %s", eventType, evt.Type())
+		}
+		return evt
+	}
+
+	if evt := recv(SnapshotDoneEventType); !evt.Time().Equal(now) {
+		t.Errorf("expected the snapshot done event at %v, but got %v", now, evt.Time())
+	}
+
+	res := NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	eventBroadcaster.Broadcast(res)
+	if evt := recv(types.CloudEventsType{
+		CloudEventsDataType: payload.ManifestEventDataType,
+		SubResource:         types.SubResourceStatus,
+		Action:              statusUpdateAction,
+	}); !evt.Time().Equal(now) {
+		t.Errorf("expected the status event at %v, but got %v", now, evt.Time())
+	}
+
+	// the heartbeat is sent once the fake clock passes the interval
+	err = wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true,
+		func(ctx context.Context) (bool, error) {
+			return fakeClock.HasWaiters(), nil
+		})
+	if err != nil {
+		t.Fatalf("expected the heartbeat timer, %v", err)
+	}
+	fakeClock.Step(time.Minute)
+	if evt := recv(HeartbeatEventType); !evt.Time().Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the heartbeat event at %v, but got %v", now.Add(time.Minute), evt.Time())
+	}
+}
+
+func TestSubscribeWithResumeToken(t *testing.T) {
+	cases := []struct {
+		name             string
+		replayBufferSize int
+		expectedCode     codes.Code
+	}{
+		{
+			name:             "replay the missed events",
+			replayBufferSize: 10,
+			expectedCode:     codes.OK,
+		},
+		{
+			name:             "the missed events are not kept",
+			replayBufferSize: 1,
+			expectedCode:     codes.OutOfRange,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			eventBroadcaster := NewEventBroadcaster(WithReplayBufferSize(c.replayBufferSize))
+			go eventBroadcaster.Start(ctx)
+			client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster))
+
+			recv := func(stream pbv1.CloudEventService_SubscribeClient) (*cloudevents.Event, error) {
+				pbEvt, err := stream.Recv()
+				if err != nil {
+					return nil, err
+				}
+				return binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+			}
+
+			broadcast := func(name string) *Resource {
+				res := NewResource("cluster1", name)
+				res.Source = "test-source"
+				eventBroadcaster.Broadcast(res)
+				return res
+			}
+
+			subCtx, stopSubscription := context.WithCancel(ctx)
+			stream, err := client.Subscribe(subCtx, &pbv1.SubscriptionRequest{Source: "test-source"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evt, err := recv(stream); err != nil || evt.Type() != SnapshotDoneEventType.String() {
+				t.Fatalf("expected snapshot done event, but got %v, %v", evt, err)
+			}
+
+			broadcast("resource1")
+			evt, err := recv(stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := cloudeventstypes.ToString(evt.Extensions()[ExtensionResumeToken])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the events are missed after the subscriber is disconnected
+			stopSubscription()
+			waitForSubscribers(t, eventBroadcaster, 0)
+			missed := []*Resource{broadcast("resource2"), broadcast("resource3")}
+
+			stream, err = client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", ResumeToken: token})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.expectedCode != codes.OK {
+				if _, err := recv(stream); status.Code(err) != c.expectedCode {
+					t.Errorf("expected %s status, but got %v", c.expectedCode, err)
+				}
+				return
+			}
+
+			for _, res := range missed {
+				evt, err := recv(stream)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resourceID, _ := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+				if resourceID != res.ResourceID {
+					t.Errorf("expected missed event of %s, but got %s", res.ResourceID, resourceID)
+				}
+			}
+			if evt, err := recv(stream); err != nil || evt.Type() != SnapshotDoneEventType.String() {
+				t.Fatalf("expected snapshot done event, but got %v, %v", evt, err)
+			}
+		})
+	}
+}
+
+func TestSubscribeWithErrorDetails(t *testing.T) {
+	cases := []struct {
+		name                string
+		opts                []EventBroadcasterOption
+		resumeToken         string
+		setup               func(eventBroadcaster *EventBroadcaster)
+		stop                func(eventBroadcaster *EventBroadcaster)
+		expectedCode        codes.Code
+		expectedReason      string
+		expectedResubscribe bool
+	}{
+		{
+			name: "closed by the server",
+			stop: func(eventBroadcaster *EventBroadcaster) {
+				waitForSubscribers(t, eventBroadcaster, 1)
+				eventBroadcaster.UnregisterAll()
+			},
+			expectedCode:        codes.Unavailable,
+			expectedReason:      ReasonSubscriptionClosed,
+			expectedResubscribe: true,
+		},
+		{
+			name:                "resume token expired",
+			resumeToken:         "1",
+			expectedCode:        codes.OutOfRange,
+			expectedReason:      ReasonResumeTokenExpired,
+			expectedResubscribe: true,
+		},
+		{
+			name: "too many subscribers",
+			opts: []EventBroadcasterOption{WithMaxSubscribers(1)},
+			setup: func(eventBroadcaster *EventBroadcaster) {
+				eventBroadcaster.Register(mustSourceFilter(t, "test-source"), func(res *Resource) error { return nil })
+			},
+			expectedCode:        codes.ResourceExhausted,
+			expectedReason:      ReasonTooManySubscribers,
+			expectedResubscribe: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			eventBroadcaster := NewEventBroadcaster(append(c.opts, WithReplayBufferSize(1))...)
+			go eventBroadcaster.Start(ctx)
+			client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster))
+			if c.setup != nil {
+				c.setup(eventBroadcaster)
+			}
+
+			// the first events are no longer kept in the replay buffer
+			for _, name := range []string{"resource1", "resource2", "resource3"} {
+				res := NewResource("cluster1", name)
+				res.Source = "test-source"
+				eventBroadcaster.Broadcast(res)
+			}
+
+			stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source",
+				ResumeToken: c.resumeToken})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.stop != nil {
+				c.stop(eventBroadcaster)
+			}
+
+			for {
+				_, err = stream.Recv()
+				if err != nil {
+					break
+				}
+			}
+
+			st := status.Convert(err)
+			if st.Code() != c.expectedCode {
+				t.Errorf("expected %s status, but got %v", c.expectedCode, err)
+			}
+
+			var errorInfo *errdetails.ErrorInfo
+			var retryInfo *errdetails.RetryInfo
+			for _, detail := range st.Details() {
+				switch detail := detail.(type) {
+				case *errdetails.ErrorInfo:
+					errorInfo = detail
+				case *errdetails.RetryInfo:
+					retryInfo = detail
+				}
+			}
+			if errorInfo == nil {
+				t.Fatalf("expected the error info detail in %v", st.Details())
+			}
+			if errorInfo.Domain != SubscriptionErrorDomain || errorInfo.Reason != c.expectedReason {
+				t.Errorf("expected reason %s of %s, but got %s of %s",
+					c.expectedReason, SubscriptionErrorDomain, errorInfo.Reason, errorInfo.Domain)
+			}
+			if resubscribe := errorInfo.Metadata[ResubscribeMetadataKey]; resubscribe != strconv.FormatBool(c.expectedResubscribe) {
+				t.Errorf("expected resubscribe %t, but got %s", c.expectedResubscribe, resubscribe)
+			}
+			if c.expectedResubscribe && (retryInfo == nil || retryInfo.RetryDelay.AsDuration() != resubscribeDelay) {
+				t.Errorf("expected the retry info with delay %s, but got %v", resubscribeDelay, retryInfo)
+			}
+		})
+	}
+}
+
+func TestPublishWithAgentID(t *testing.T) {
+	cases := []struct {
+		name         string
+		agentID      string
+		clusterName  string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "the agent of the owning cluster",
+			agentID:      "agent-a",
+			clusterName:  "cluster-a",
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "the agent of another cluster",
+			agentID:      "agent-b",
+			clusterName:  "cluster-a",
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "the agent of another cluster with its own cluster name",
+			agentID:      "agent-b",
+			clusterName:  "cluster-b",
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "unknown agent",
+			agentID:      "agent-c",
+			clusterName:  "cluster-a",
+			expectedCode: codes.PermissionDenied,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			owned := NewResource("cluster-a", "resource1")
+			if _, err := store.UpSert(context.Background(), owned); err != nil {
+				t.Fatal(err)
+			}
+
+			client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster(),
+				WithAgentCluster("agent-a", "cluster-a"), WithAgentCluster("agent-b", "cluster-b")))
+
+			res := NewResource(c.clusterName, "resource1")
+			res.ResourceID = owned.ResourceID
+			res.ResourceVersion = 2
+			evt, err := (&ResourceCodec{}).Encode("test-source", testSpecEventType, res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			evt.SetExtension(types.ExtensionAgentID, c.agentID)
+
+			pbEvt := &pbv1.CloudEvent{}
+			if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(evt), pbEvt); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.Publish(context.Background(), &pbv1.PublishRequest{Event: pbEvt})
+			if status.Code(err) != c.expectedCode {
+				t.Errorf("expected %s status, but got %v", c.expectedCode, err)
+			}
+
+			stored, err := store.Get(owned.ResourceID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.expectedCode != codes.OK && stored.ResourceVersion != owned.ResourceVersion {
+				t.Errorf("expected the resource is not updated, but got version %d", stored.ResourceVersion)
+			}
+		})
+	}
+}
+
+func TestPublishWithUndecodableEvents(t *testing.T) {
+	cases := []struct {
+		name         string
+		dataType     types.CloudEventsDataType
+		data         []byte
+		expectedCode codes.Code
+	}{
+		{
+			name:         "unsupported data type",
+			dataType:     types.CloudEventsDataType{Group: "test", Version: "v1", Resource: "tests"},
+			data:         []byte("{}"),
+			expectedCode: codes.Unimplemented,
+		},
+		{
+			name:         "malformed data",
+			dataType:     payload.ManifestEventDataType,
+			data:         []byte("{"),
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), NewEventBroadcaster()))
+
+			evt := types.NewEventBuilder("test-source", types.CloudEventsType{
+				CloudEventsDataType: c.dataType,
+				SubResource:         types.SubResourceSpec,
+				Action:              "create_request",
+			}).WithResourceID("resource1").WithResourceVersion(1).WithClusterName("cluster1").NewEvent()
+			if err := evt.SetData(cloudevents.ApplicationJSON, c.data); err != nil {
+				t.Fatal(err)
+			}
+			pbEvt := &pbv1.CloudEvent{}
+			if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(&evt), pbEvt); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := client.Publish(context.Background(), &pbv1.PublishRequest{Event: pbEvt})
+			if status.Code(err) != c.expectedCode {
+				t.Errorf("expected %s status, but got %v", c.expectedCode, err)
+			}
+		})
+	}
+}
+
+func TestSubscribeWithSubscriptionID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster))
+
+	ids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the header is received before any event is sent
+		header, err := stream.Header()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := header.Get(SubscriptionIDHeader)
+		if len(values) != 1 || len(values[0]) == 0 {
+			t.Fatalf("expected the subscription id in the header, but got %v", header)
+		}
+		ids[values[0]] = true
+	}
+
+	if len(ids) != 2 {
+		t.Errorf("expected the subscription ids are unique, but got %v", ids)
+	}
+
+	waitForSubscribers(t, eventBroadcaster, 2)
+	eventBroadcaster.mu.RLock()
+	defer eventBroadcaster.mu.RUnlock()
+	for id := range ids {
+		if _, ok := eventBroadcaster.clients[id]; !ok {
+			t.Errorf("expected the subscription id %s is the client id of the subscriber", id)
+		}
+	}
+}
+
+func TestSubscribeWithSameSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster))
+
+	type subscriber struct {
+		cancel context.CancelFunc
+		stream pbv1.CloudEventService_SubscribeClient
+	}
+	subscribers := []subscriber{}
+	for i := 0; i < 2; i++ {
+		subCtx, subCancel := context.WithCancel(ctx)
+		defer subCancel()
+		stream, err := client.Subscribe(subCtx, &pbv1.SubscriptionRequest{Source: "test-source"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		subscribers = append(subscribers, subscriber{cancel: subCancel, stream: stream})
+	}
+	waitForSubscribers(t, eventBroadcaster, 2)
+
+	recv := func(sub subscriber) (types.CloudEventsType, string) {
+		pbEvt, err := sub.stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		eventType, err := types.ParseCloudEventsType(evt.Type())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resourceID, _ := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+		return *eventType, resourceID
+	}
+	for _, sub := range subscribers {
+		if eventType, _ := recv(sub); eventType != SnapshotDoneEventType {
+			t.Errorf("expected snapshot done event, but got %s", eventType)
+		}
+	}
+
+	// both of the subscribers receive the event
+	res := NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	eventBroadcaster.Broadcast(res)
+	for _, sub := range subscribers {
+		if _, resourceID := recv(sub); resourceID != res.ResourceID {
+			t.Errorf("expected event of %s, but got %s", res.ResourceID, resourceID)
+		}
+	}
+
+	// the second subscriber still receives the events after the first one is torn down
+	subscribers[0].cancel()
+	waitForSubscribers(t, eventBroadcaster, 1)
+	res = NewResource("cluster1", "resource2")
+	res.Source = "test-source"
+	eventBroadcaster.Broadcast(res)
+	if _, resourceID := recv(subscribers[1]); resourceID != res.ResourceID {
+		t.Errorf("expected event of %s, but got %s", res.ResourceID, resourceID)
+	}
+
+	subscribers[1].cancel()
+	waitForSubscribers(t, eventBroadcaster, 0)
+}
+
+// flakySubscribeServer is a Subscribe stream whose sends fail with the errors in order, the sends succeed once the
+// errors are used up.
+type flakySubscribeServer struct {
+	pbv1.CloudEventService_SubscribeServer
+
+	ctx  context.Context
+	mu   sync.Mutex
+	errs []error
+	sent chan *pbv1.CloudEvent
+}
+
+func (s *flakySubscribeServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *flakySubscribeServer) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *flakySubscribeServer) Send(evt *pbv1.CloudEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.errs) != 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return err
+	}
+
+	s.sent <- evt
+	return nil
+}
+
+// failingSubscribeServer is a Subscribe stream whose sends fail while it is failing, the attempts are recorded.
+type failingSubscribeServer struct {
+	pbv1.CloudEventService_SubscribeServer
+
+	ctx      context.Context
+	failing  atomic.Bool
+	attempts atomic.Int64
+	sent     chan *pbv1.CloudEvent
+}
+
+func (s *failingSubscribeServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *failingSubscribeServer) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *failingSubscribeServer) Send(evt *pbv1.CloudEvent) error {
+	s.attempts.Add(1)
+	if s.failing.Load() {
+		return status.Error(codes.Unavailable, "the transport is unavailable")
+	}
+
+	s.sent <- evt
+	return nil
+}
+
+// closedSubscribeServer is a Subscribe stream that records the events that are sent after it is finished, i.e. after
+// the Subscribe returns.
+type closedSubscribeServer struct {
+	pbv1.CloudEventService_SubscribeServer
+
+	ctx       context.Context
+	finished  atomic.Bool
+	sent      atomic.Int64
+	lateSends atomic.Int64
+}
+
+func (s *closedSubscribeServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *closedSubscribeServer) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *closedSubscribeServer) Send(evt *pbv1.CloudEvent) error {
+	// the send is slow, so the subscriber is likely unregistered while an event is being sent
+	time.Sleep(time.Millisecond)
+	if s.finished.Load() {
+		s.lateSends.Add(1)
+	}
+	s.sent.Add(1)
+	return nil
+}
+
+func TestSubscribeWithEventsInFlight(t *testing.T) {
+	cases := []struct {
+		name string
+		// stop stops the subscription while the events are flowing
+		stop func(cancel context.CancelFunc, eventBroadcaster *EventBroadcaster)
+	}{
+		{
+			name: "subscriber context is cancelled",
+			stop: func(cancel context.CancelFunc, _ *EventBroadcaster) { cancel() },
+		},
+		{
+			name: "subscriber is unregistered by the server",
+			stop: func(_ context.CancelFunc, eventBroadcaster *EventBroadcaster) { eventBroadcaster.UnregisterAll() },
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				eventBroadcaster := NewEventBroadcaster()
+				go eventBroadcaster.Start(ctx)
+				svr := NewGRPCServer(NewMemoryStore(), eventBroadcaster, WithHeartbeatInterval(time.Millisecond))
+
+				subCtx, subCancel := context.WithCancel(ctx)
+				subServer := &closedSubscribeServer{ctx: subCtx}
+				subscribeErr := make(chan error, 1)
+				go func() {
+					err := svr.Subscribe(&pbv1.SubscriptionRequest{Source: "test-source"}, subServer)
+					subServer.finished.Store(true)
+					subscribeErr <- err
+				}()
+				waitForSubscribers(t, eventBroadcaster, 1)
+
+				stopBroadcast := make(chan struct{})
+				broadcastStopped := make(chan struct{})
+				go func() {
+					defer close(broadcastStopped)
+					for version := int64(1); ; version++ {
+						select {
+						case <-stopBroadcast:
+							return
+						default:
+						}
+						res := NewResource("cluster1", "resource1")
+						res.Source = "test-source"
+						res.ResourceVersion = version
+						eventBroadcaster.Broadcast(res)
+					}
+				}()
+
+				// stop the subscription once the events are flowing
+				err := wait.PollUntilContextTimeout(ctx, time.Millisecond, 5*time.Second, true,
+					func(ctx context.Context) (bool, error) { return subServer.sent.Load() > 5, nil })
+				if err != nil {
+					t.Fatalf("expected the events are sent, %v", err)
+				}
+				c.stop(subCancel, eventBroadcaster)
+
+				select {
+				case <-subscribeErr:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("the subscription is not finished")
+				}
+				close(stopBroadcast)
+				<-broadcastStopped
+
+				// give the in-flight deliveries and heartbeats time to be sent if they are not stopped
+				time.Sleep(20 * time.Millisecond)
+				if late := subServer.lateSends.Load(); late != 0 {
+					t.Fatalf("expected no event is sent after the subscription is finished, but got %d", late)
+				}
+				subCancel()
+			}
+		})
+	}
+}
+
+// deadSubscribeServer is a Subscribe stream whose sends are blocked until it is released, like the stream of a
+// subscriber whose network is dead.
+type deadSubscribeServer struct {
+	pbv1.CloudEventService_SubscribeServer
+
+	ctx     context.Context
+	release chan struct{}
+}
+
+func (s *deadSubscribeServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *deadSubscribeServer) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *deadSubscribeServer) Send(evt *pbv1.CloudEvent) error {
+	<-s.release
+	return status.Error(codes.Canceled, "the stream is finished")
+}
+
+func TestSubscribeWithDeadSubscriber(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the liveness is checked with the clock of the server, the broadcaster is started after the server is created, so
+	// the checks tick with the clock too
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	eventBroadcaster := NewEventBroadcaster(WithLivenessTimeout(time.Minute))
+	svr := NewGRPCServer(NewMemoryStore(), eventBroadcaster, WithClock(fakeClock))
+	go eventBroadcaster.Start(ctx)
+
+	subServer := &deadSubscribeServer{ctx: ctx, release: make(chan struct{})}
+	defer close(subServer.release)
+	subscribeErr := make(chan error, 1)
+	go func() {
+		subscribeErr <- svr.Subscribe(&pbv1.SubscriptionRequest{Source: "test-source"}, subServer)
+	}()
+
+	// wait until the send of the snapshot done event is blocked and the liveness check is ticking
+	err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true,
+		func(ctx context.Context) (bool, error) {
+			eventBroadcaster.mu.RLock()
+			defer eventBroadcaster.mu.RUnlock()
+			for _, client := range eventBroadcaster.clients {
+				if client.handlingSince.Load() != 0 {
+					return fakeClock.HasWaiters(), nil
+				}
+			}
+			return false, nil
+		})
+	if err != nil {
+		t.Fatalf("expected the send is blocked, %v", err)
+	}
+
+	// the subscriber is alive within the timeout
+	fakeClock.Step(30 * time.Second)
+	select {
+	case err := <-subscribeErr:
+		t.Fatalf("unexpected subscription end within the liveness timeout %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the subscription is finished even though the send of the snapshot done event is still blocked
+	fakeClock.Step(31 * time.Second)
+	select {
+	case err := <-subscribeErr:
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("expected unavailable status, but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the dead subscriber is cleaned up")
+	}
+	waitForSubscribers(t, eventBroadcaster, 0)
+}
+
+func TestSubscribeWithFlakySend(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "the transport is unavailable")
+
+	cases := []struct {
+		name         string
+		errs         []error
+		expectedCode codes.Code
+	}{
+		{
+			name: "transient error",
+			errs: []error{unavailable},
+		},
+		{
+			name:         "transient errors exhaust the retries",
+			errs:         []error{unavailable, unavailable, unavailable},
+			expectedCode: codes.Unavailable,
+		},
+		{
+			name:         "terminal error",
+			errs:         []error{status.Error(codes.Internal, "the stream is broken")},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			eventBroadcaster := NewEventBroadcaster()
+			go eventBroadcaster.Start(ctx)
+			svr := NewGRPCServer(NewMemoryStore(), eventBroadcaster,
+				WithSendBackoff(wait.Backoff{Duration: 10 * time.Millisecond, Factor: 2, Steps: 3}))
+
+			subServer := &flakySubscribeServer{ctx: ctx, errs: c.errs, sent: make(chan *pbv1.CloudEvent, 1)}
+			subscribeErr := make(chan error, 1)
+			go func() {
+				subscribeErr <- svr.Subscribe(&pbv1.SubscriptionRequest{Source: "test-source"}, subServer)
+			}()
+
+			if c.expectedCode != codes.OK {
+				select {
+				case err := <-subscribeErr:
+					if status.Code(err) != c.expectedCode {
+						t.Errorf("expected code %s, but got %v", c.expectedCode, err)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("expected the subscription is closed")
+				}
+				waitForSubscribers(t, eventBroadcaster, 0)
+				return
+			}
+
+			// the snapshot done event is sent with a retry
+			select {
+			case <-subServer.sent:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected the snapshot done event is sent")
+			}
+
+			res := NewResource("cluster1", "resource1")
+			res.Source = "test-source"
+			eventBroadcaster.Broadcast(res)
+			select {
+			case <-subServer.sent:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected the subscriber is still registered")
+			}
+			waitForSubscribers(t, eventBroadcaster, 1)
+		})
+	}
+}
+
+func TestSubscribeWithSendCircuitBreaker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	svr := NewGRPCServer(NewMemoryStore(), eventBroadcaster, WithClock(fakeClock),
+		WithSendBackoff(wait.Backoff{Duration: 10 * time.Millisecond, Steps: 1}),
+		WithSendCircuitBreaker(2, time.Minute))
+
+	subServer := &failingSubscribeServer{ctx: ctx, sent: make(chan *pbv1.CloudEvent, 10)}
+	subscribeErr := make(chan error, 1)
+	go func() {
+		subscribeErr <- svr.Subscribe(&pbv1.SubscriptionRequest{Source: "test-source"}, subServer)
+	}()
+
+	recvType := func() string {
+		select {
+		case pbEvt := <-subServer.sent:
+			return pbEvt.Type
+		case err := <-subscribeErr:
+			t.Fatalf("unexpected subscription end %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected an event is sent")
+		}
+		return ""
+	}
+	waitForAttempts := func(attempts int64) {
+		err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true,
+			func(ctx context.Context) (bool, error) {
+				return subServer.attempts.Load() == attempts, nil
+			})
+		if err != nil {
+			t.Fatalf("expected %d attempts, but got %d", attempts, subServer.attempts.Load())
+		}
+	}
+	broadcast := func(name string) {
+		res := NewResource("cluster1", name)
+		res.Source = "test-source"
+		eventBroadcaster.Broadcast(res)
+	}
+
+	if eventType := recvType(); eventType != SnapshotDoneEventType.String() {
+		t.Fatalf("expected snapshot done event, but got %s", eventType)
+	}
+
+	// the breaker is opened by two failures in a row, and the subscription is not ended
+	subServer.failing.Store(true)
+	broadcast("resource1")
+	broadcast("resource2")
+	waitForAttempts(3)
+
+	// the events are not sent in the cooldown
+	subServer.failing.Store(false)
+	broadcast("resource3")
+	time.Sleep(100 * time.Millisecond)
+	if attempts := subServer.attempts.Load(); attempts != 3 {
+		t.Errorf("expected no attempt in the cooldown, but got %d attempts", attempts)
+	}
+
+	// the probe is sent after the cooldown, then the subscriber is resynced
+	fakeClock.Step(time.Minute)
+	broadcast("resource4")
+	if eventType := recvType(); eventType == SnapshotDoneEventType.String() {
+		t.Errorf("expected the probe event, but got %s", eventType)
+	}
+	if eventType := recvType(); eventType != SnapshotDoneEventType.String() {
+		t.Errorf("expected the snapshot done event of the resync, but got %s", eventType)
+	}
+	waitForSubscribers(t, eventBroadcaster, 1)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := stream.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := header.Get(SubscriptionIDHeader)
+	if len(ids) != 1 {
+		t.Fatalf("expected the subscription id in the header, but got %v", header)
+	}
+	waitForSubscribers(t, eventBroadcaster, 1)
+
+	if _, err := client.Unsubscribe(ctx, &pbv1.UnsubscribeRequest{SubscriptionId: ids[0]}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the subscriber is unregistered once the unsubscribe is acknowledged
+	eventBroadcaster.mu.RLock()
+	subscribers := len(eventBroadcaster.clients)
+	eventBroadcaster.mu.RUnlock()
+	if subscribers != 0 {
+		t.Errorf("expected no subscriber, but got %d", subscribers)
+	}
+
+	// the stream is closed with the OK status after the snapshot done event
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+	}
+	if err != io.EOF {
+		t.Errorf("expected the stream is closed, but got %v", err)
+	}
+
+	// the subscription can only be unsubscribed once
+	_, err = client.Unsubscribe(ctx, &pbv1.UnsubscribeRequest{SubscriptionId: ids[0]})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected not found status, but got %v", err)
+	}
+}
+
+func TestResync(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	applied := metav1.Condition{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied"}
+	store := NewMemoryStore()
+	for _, name := range []string{"resource1", "resource2"} {
+		res := NewResource("cluster1", name)
+		res.Source = "test-source"
+		res.Status.Conditions = []metav1.Condition{applied}
+		if _, err := store.UpSert(context.Background(), res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster))
+
+	// the subscriber only receives the changes of the Applied condition, but a resync sends all of the resources
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", ConditionType: "Applied"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := stream.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := header.Get(SubscriptionIDHeader)
+	if len(ids) != 1 {
+		t.Fatalf("expected the subscription id in the header, but got %v", header)
+	}
+
+	// recvSnapshot receives the events until the snapshot done event, and returns the status of the resources
+	recvSnapshot := func() map[string]metav1.ConditionStatus {
+		snapshot := map[string]metav1.ConditionStatus{}
+		for {
+			pbEvt, err := stream.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evt.Type() == SnapshotDoneEventType.String() {
+				return snapshot
+			}
+
+			res, err := (&ResourceCodec{}).Decode(evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			snapshot[res.ResourceID] = meta.FindStatusCondition(res.Status.Conditions, "Applied").Status
+		}
+	}
+
+	expected := map[string]metav1.ConditionStatus{
+		ResourceID("cluster1", "resource1"): metav1.ConditionTrue,
+		ResourceID("cluster1", "resource2"): metav1.ConditionTrue,
+	}
+	if snapshot := recvSnapshot(); !reflect.DeepEqual(snapshot, expected) {
+		t.Fatalf("expected snapshot %v, but got %v", expected, snapshot)
+	}
+
+	// the status change of the resource1 is missed by the subscriber, since it is not broadcast
+	updated := NewResource("cluster1", "resource1")
+	updated.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionFalse, Reason: "Failed"}}
+	if err := store.UpdateStatus(updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Resync(ctx, &pbv1.ResyncRequest{SubscriptionId: ids[0]}); err != nil {
+		t.Fatal(err)
+	}
+	expected[ResourceID("cluster1", "resource1")] = metav1.ConditionFalse
+	if snapshot := recvSnapshot(); !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("expected the current resources %v are resent, but got %v", expected, snapshot)
+	}
+
+	// the subscriber is still registered after the resync
+	waitForSubscribers(t, eventBroadcaster, 1)
+
+	_, err = client.Resync(ctx, &pbv1.ResyncRequest{SubscriptionId: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected not found status, but got %v", err)
+	}
+}
+
+func TestSubscribeWithSnapshot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewMemoryStore()
+	for _, name := range []string{"resource1", "resource2"} {
+		res := NewResource("cluster1", name)
+		res.Source = "test-source"
+		if _, err := store.UpSert(context.Background(), res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the resource of another source is not in the snapshot
+	another := NewResource("cluster1", "resource3")
+	another.Source = "another-source"
+	if _, err := store.UpSert(context.Background(), another); err != nil {
+		t.Fatal(err)
+	}
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recv := func() (types.CloudEventsType, string) {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		eventType, err := types.ParseCloudEventsType(evt.Type())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resourceID, _ := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+		return *eventType, resourceID
+	}
+
+	snapshot := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		eventType, resourceID := recv()
+		if eventType.Action != statusUpdateAction {
+			t.Errorf("expected snapshot event, but got %s", eventType)
+		}
+		snapshot[resourceID] = true
+	}
+	if !snapshot[ResourceID("cluster1", "resource1")] || !snapshot[ResourceID("cluster1", "resource2")] {
+		t.Errorf("unexpected snapshot %v", snapshot)
+	}
+
+	if eventType, _ := recv(); eventType != SnapshotDoneEventType {
+		t.Errorf("expected snapshot done event, but got %s", eventType)
+	}
+
+	live := NewResource("cluster1", "resource4")
+	live.Source = "test-source"
+	eventBroadcaster.Broadcast(live)
+	if _, resourceID := recv(); resourceID != live.ResourceID {
+		t.Errorf("expected live event of %s, but got %s", live.ResourceID, resourceID)
+	}
+}
+
+func TestSubscribeWithTombstone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewMemoryStore(WithDeletedResourceRetention(time.Minute))
+	res := NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	if _, err := store.UpSert(ctx, res); err != nil {
+		t.Fatal(err)
+	}
+	store.Delete(res.ResourceID)
+	tombstone, err := store.Get(res.ResourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the subscriber subscribes after the deletion
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster))
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recv := func() (*cloudevents.Event, types.CloudEventsType) {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		eventType, err := types.ParseCloudEventsType(evt.Type())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return evt, *eventType
+	}
+
+	evt, eventType := recv()
+	if eventType.Action != statusDeleteAction {
+		t.Errorf("expected the tombstone is delivered with the delete action, but got %s", eventType)
+	}
+	if resourceID, _ := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID]); resourceID != res.ResourceID {
+		t.Errorf("expected the tombstone of %s, but got %s", res.ResourceID, resourceID)
+	}
+	deletionTimestamp, err := cloudeventstypes.ToTime(evt.Extensions()[types.ExtensionDeletionTimestamp])
+	if err != nil {
+		t.Fatalf("expected the tombstone has the deletion timestamp, %v", err)
+	}
+	if !deletionTimestamp.Equal(tombstone.DeletionTimestamp.Time) {
+		t.Errorf("expected the deletion timestamp %v, but got %v", tombstone.DeletionTimestamp.Time, deletionTimestamp)
+	}
+
+	if _, eventType := recv(); eventType != SnapshotDoneEventType {
+		t.Errorf("expected snapshot done event, but got %s", eventType)
+	}
+}
+
+func TestSubscribeWithSnapshotOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewMemoryStore()
+	for _, name := range []string{"resource1", "resource2"} {
+		res := NewResource("cluster1", name)
+		res.Source = "test-source"
+		if _, err := store.UpSert(ctx, res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", SnapshotOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recv := func() (*cloudevents.Event, error) {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+	}
+
+	for i := 0; i < 2; i++ {
+		evt, err := recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evt.Type() == SnapshotDoneEventType.String() {
+			t.Fatalf("expected the snapshot of the resources before the snapshot done event")
+		}
+	}
+	evt, err := recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.Type() != SnapshotDoneEventType.String() {
+		t.Fatalf("expected snapshot done event, but got %s", evt.Type())
+	}
+
+	// the live events are not sent, the stream is closed after the snapshot
+	res := NewResource("cluster1", "resource3")
+	res.Source = "test-source"
+	eventBroadcaster.Broadcast(res)
+
+	if evt, err := recv(); err != io.EOF {
+		t.Errorf("expected the stream is closed after the snapshot, but got %v, %v", evt, err)
+	}
+}
+
+func TestSubscribeWithSnapshotOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewMemoryStore()
+	expected := []string{}
+	for _, cluster := range []string{"cluster3", "cluster1", "cluster2"} {
+		for _, name := range []string{"resource5", "resource2", "resource4", "resource1", "resource3"} {
+			res := NewResource(cluster, name)
+			res.Source = "test-source"
+			if _, err := store.UpSert(ctx, res); err != nil {
+				t.Fatal(err)
+			}
+			expected = append(expected, res.ResourceID)
+		}
+	}
+	sort.Strings(expected)
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster))
+
+	// the resources are listed from the maps of the store, so the order is checked with several subscriptions
+	for i := 0; i < 5; i++ {
+		stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", SnapshotOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resourceIDs := []string{}
+		for {
+			pbEvt, err := stream.Recv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evt.Type() == SnapshotDoneEventType.String() {
+				break
+			}
+
+			resourceID, _ := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+			resourceIDs = append(resourceIDs, resourceID)
+		}
+
+		if !reflect.DeepEqual(resourceIDs, expected) {
+			t.Errorf("expected the snapshot in the order of %v, but got %v", expected, resourceIDs)
+		}
+	}
+}
+
+func TestSortResources(t *testing.T) {
+	newResource := func(id string, version int64) *Resource {
+		return &Resource{ResourceID: id, ResourceVersion: version}
+	}
+
+	resources := sortResources([]*Resource{
+		newResource("b", 1), newResource("a", 2), newResource("c", 1), newResource("a", 1),
+	})
+
+	expected := []*Resource{newResource("a", 1), newResource("a", 2), newResource("b", 1), newResource("c", 1)}
+	if !reflect.DeepEqual(resources, expected) {
+		t.Errorf("expected %v, but got %v", expected, resources)
+	}
+}
+
+func TestSubscribeWithSubscriptionSequence(t *testing.T) {
+	const events = 100
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func() *cloudevents.Event {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+	if evt := recv(); evt.Type() != SnapshotDoneEventType.String() {
+		t.Fatalf("expected snapshot done event, but got %s", evt.Type())
+	}
+
+	for i := 0; i < events; i++ {
+		res := NewResource("cluster1", fmt.Sprintf("resource%d", i))
+		res.Source = "test-source"
+		eventBroadcaster.Broadcast(res)
+	}
+
+	var last uint64
+	for i := 0; i < events; i++ {
+		value, err := cloudeventstypes.ToString(recv().Extensions()[ExtensionSubscriptionSequence])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sequence, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sequence != last+1 {
+			t.Fatalf("expected sequence %d after %d, but got %d", last+1, last, sequence)
+		}
+		last = sequence
+	}
+}
+
+func TestSubscribeBatch(t *testing.T) {
+	const (
+		events    = 95
+		batchSize = 10
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster,
+		WithSubscribeBatch(batchSize, 50*time.Millisecond)))
+
+	stream, err := client.SubscribeBatch(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func() []*cloudevents.Event {
+		batch, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evts := []*cloudevents.Event{}
+		for _, pbEvt := range batch.Events {
+			evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			evts = append(evts, evt)
+		}
+		return evts
+	}
+	if evts := recv(); len(evts) != 1 || evts[0].Type() != SnapshotDoneEventType.String() {
+		t.Fatalf("expected a batch of the snapshot done event, but got %v", evts)
+	}
+
+	for i := 0; i < events; i++ {
+		res := NewResource("cluster1", fmt.Sprintf("resource%d", i))
+		res.Source = "test-source"
+		eventBroadcaster.Broadcast(res)
+	}
+
+	batches := 0
+	received := []*cloudevents.Event{}
+	for len(received) < events {
+		evts := recv()
+		if len(evts) == 0 || len(evts) > batchSize {
+			t.Fatalf("expected a batch of 1 to %d events, but got %d", batchSize, len(evts))
+		}
+		batches++
+		received = append(received, evts...)
+	}
+	if batches == events {
+		t.Errorf("expected the events are coalesced into batches, but got %d batches", batches)
+	}
+
+	// the events are received in the order that they are broadcast, within and across the batches
+	for i, evt := range received {
+		res, err := (&ResourceCodec{}).Decode(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := ResourceID("cluster1", fmt.Sprintf("resource%d", i)); res.ResourceID != expected {
+			t.Errorf("expected the event %d is of resource %s, but got %s", i, expected, res.ResourceID)
+		}
+
+		value, err := cloudeventstypes.ToString(evt.Extensions()[ExtensionSubscriptionSequence])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != strconv.Itoa(i+1) {
+			t.Errorf("expected the event %d has sequence %d, but got %s", i, i+1, value)
+		}
+	}
+}
+
+// BenchmarkPublishConversion measures the conversions per second of the published protobuf cloudevents to the
+// cloudevents. The binding conversion reads the message with the binding writers, which box every attribute into an
+// interface and wrap the data into a buffer for them, so it allocates about a half more and takes about a third more
+// time than the direct conversion of the grpc protocol, which is used by the server. The decoding of the resource
+// from the cloudevent, which unmarshals the manifest, still costs several times more than either of them.
+func BenchmarkPublishConversion(b *testing.B) {
+	evt, err := (&ResourceCodec{}).Encode("test-source", testSpecEventType, NewResource("cluster1", "resource1"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	pbEvt := &pbv1.CloudEvent{}
+	if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(evt), pbEvt); err != nil {
+		b.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		convert func(ctx context.Context, pbEvt *pbv1.CloudEvent) (*cloudevents.Event, error)
+	}{
+		{
+			name: "binding",
+			convert: func(ctx context.Context, pbEvt *pbv1.CloudEvent) (*cloudevents.Event, error) {
+				return binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+			},
+		},
+		{
+			name:    "direct",
+			convert: grpcprotocol.ToEvent,
+		},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.convert(ctx, pbEvt); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "conversions/s")
+		})
+	}
+}
+
+// BenchmarkSubscribe delivers the events to a subscriber one by one with Subscribe and in batches with SubscribeBatch,
+// an op is an event that is broadcast and received by the subscriber.
+func BenchmarkSubscribe(b *testing.B) {
+	cases := []struct {
+		name string
+		// subscribe subscribes the resources and returns a function that receives the next message and returns the
+		// number of its events.
+		subscribe func(ctx context.Context, client pbv1.CloudEventServiceClient) (func() (int, error), error)
+	}{
+		{
+			name: "per event",
+			subscribe: func(ctx context.Context, client pbv1.CloudEventServiceClient) (func() (int, error), error) {
+				stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+				if err != nil {
+					return nil, err
+				}
+				return func() (int, error) {
+					_, err := stream.Recv()
+					return 1, err
+				}, nil
+			},
+		},
+		{
+			name: "batched",
+			subscribe: func(ctx context.Context, client pbv1.CloudEventServiceClient) (func() (int, error), error) {
+				stream, err := client.SubscribeBatch(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+				if err != nil {
+					return nil, err
+				}
+				return func() (int, error) {
+					batch, err := stream.Recv()
+					return len(batch.GetEvents()), err
+				}, nil
+			},
+		},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// the buffer holds all of the events, so none of them is dropped
+			eventBroadcaster := NewEventBroadcaster(WithSubscriberBufferSize(b.N))
+			go eventBroadcaster.Start(ctx)
+			inMemoryServer, err := StartInMemoryServer(NewGRPCServer(NewMemoryStore(), eventBroadcaster,
+				WithSubscribeBatch(defaultSubscribeBatchSize, time.Millisecond)))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer inMemoryServer.Close(context.Background())
+
+			recv, err := c.subscribe(ctx, inMemoryServer.Client())
+			if err != nil {
+				b.Fatal(err)
+			}
+			// the snapshot done event
+			if _, err := recv(); err != nil {
+				b.Fatal(err)
+			}
+
+			resources := make([]*Resource, b.N)
+			for i := range resources {
+				resources[i] = NewResource("cluster1", fmt.Sprintf("resource%d", i))
+				resources[i].Source = "test-source"
+			}
+
+			b.ResetTimer()
+			go func() {
+				for _, res := range resources {
+					eventBroadcaster.Broadcast(res)
+				}
+			}()
+			for received := 0; received < b.N; {
+				n, err := recv()
+				if err != nil {
+					b.Fatal(err)
+				}
+				received += n
+			}
+		})
+	}
+}
+
+func TestSubscribeWithCompositeFilter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newResource := func(source, cluster, name string, dataType types.CloudEventsDataType) *Resource {
+		res := NewResource(cluster, name)
+		res.Source = source
+		res.DataType = dataType
+		return res
+	}
+
+	// the snapshot and the live events are filtered in the same way
+	store := NewMemoryStore()
+	for _, res := range []*Resource{
+		newResource("source1", "cluster1", "snapshot-matched", payload.ManifestEventDataType),
+		newResource("source2", "cluster1", "snapshot-other-source", payload.ManifestEventDataType),
+		newResource("source1", "cluster2", "snapshot-other-cluster", payload.ManifestEventDataType),
+		newResource("source1", "cluster1", "snapshot-other-data-type", testLeaseDataType),
+	} {
+		if _, err := store.UpSert(ctx, res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster, WithCodecs(&leaseCodec{})))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{
+		Source:      "source1",
+		ClusterName: "cluster1",
+		DataType:    payload.ManifestEventDataType.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func() *cloudevents.Event {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+
+	received := []string{}
+	for evt := recv(); evt.Type() != SnapshotDoneEventType.String(); evt = recv() {
+		received = append(received, evt.Extensions()[types.ExtensionResourceID].(string))
+	}
+
+	for _, res := range []*Resource{
+		newResource("source2", "cluster1", "live-other-source", payload.ManifestEventDataType),
+		newResource("source1", "cluster2", "live-other-cluster", payload.ManifestEventDataType),
+		newResource("source1", "cluster1", "live-other-data-type", testLeaseDataType),
+		newResource("source1", "cluster1", "live-matched", payload.ManifestEventDataType),
+	} {
+		eventBroadcaster.Broadcast(res)
+	}
+	// the events are delivered in order, the non-matching events before the matched one are suppressed
+	received = append(received, recv().Extensions()[types.ExtensionResourceID].(string))
+
+	expected := []string{ResourceID("cluster1", "snapshot-matched"), ResourceID("cluster1", "live-matched")}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected resources %v, but got %v", expected, received)
+	}
+}
+
+func TestSubscribeWithInvalidDataType(t *testing.T) {
+	cases := []struct {
+		name         string
+		dataType     string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "malformed data type",
+			dataType:     "manifests",
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "data type without codec",
+			dataType:     testLeaseDataType.String(),
+			expectedCode: codes.Unimplemented,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), NewEventBroadcaster()))
+
+			stream, err := client.Subscribe(context.Background(),
+				&pbv1.SubscriptionRequest{Source: "test-source", DataType: c.dataType})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := stream.Recv(); status.Code(err) != c.expectedCode {
+				t.Errorf("expected %s status, but got %v", c.expectedCode, err)
+			}
+		})
+	}
+}
+
+func TestSubscribeWithConditionType(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", ConditionType: "Applied"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func() *cloudevents.Event {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+	if evt := recv(); evt.Type() != SnapshotDoneEventType.String() {
+		t.Fatalf("expected snapshot done event, but got %s", evt.Type())
+	}
+
+	progressing := metav1.Condition{Type: "Progressing", Status: metav1.ConditionTrue, Reason: "Applying"}
+	notApplied := metav1.Condition{Type: "Applied", Status: metav1.ConditionFalse, Reason: "Applying"}
+	applied := metav1.Condition{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied"}
+	updates := [][]metav1.Condition{
+		// the condition is not added
+		{progressing},
+		// the condition is added
+		{progressing, notApplied},
+		// another condition is changed
+		{notApplied},
+		// the condition is changed
+		{applied},
+		// the condition is not changed
+		{applied, progressing},
+	}
+	for i, conditions := range updates {
+		res := NewResource("cluster1", "resource1")
+		res.Source = "test-source"
+		res.ResourceVersion = int64(i + 1)
+		res.Status.Conditions = conditions
+		eventBroadcaster.Broadcast(res)
+	}
+
+	// the resource with the condition is deleted
+	deleted := NewResource("cluster1", "resource1")
+	deleted.Source = "test-source"
+	deleted.ResourceVersion = int64(len(updates) + 1)
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	eventBroadcaster.Broadcast(deleted)
+
+	for _, expected := range []int64{2, 4, 6} {
+		version, err := cloudeventstypes.ToInteger(recv().Extensions()[types.ExtensionResourceVersion])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(version) != expected {
+			t.Errorf("expected the event of version %d, but got %d", expected, version)
+		}
+	}
+}
+
+func TestSubscribeWithLabelSelector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	store := newMemoryStore(eventBroadcaster)
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster))
+
+	// the agent reports the status of the published resources without their manifests
+	reported := make(chan struct{})
+	go func() {
+		for res := range store.GetResourceSpecChan() {
+			if err := store.UpdateStatus(&Resource{
+				Source:          res.Source,
+				ResourceID:      res.ResourceID,
+				ResourceVersion: res.ResourceVersion,
+				Namespace:       res.Namespace,
+				Status:          ResourceStatus{Conditions: []metav1.Condition{{Type: "Applied", Status: "True"}}},
+			}); err != nil {
+				t.Error(err)
+			}
+			reported <- struct{}{}
+		}
+	}()
+	publish := func(name string, labels map[string]string) {
+		res := NewResource("cluster1", name)
+		res.Spec.SetLabels(labels)
+		if _, err := client.Publish(ctx, newPublishRequest(t, res)); err != nil {
+			t.Fatal(err)
+		}
+		<-reported
+	}
+
+	publish("snapshot-matched", map[string]string{"app": "nginx", "tier": "web"})
+	publish("snapshot-other-label", map[string]string{"app": "redis"})
+	publish("snapshot-unlabeled", nil)
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", LabelSelector: "app=nginx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func() *cloudevents.Event {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+
+	received := []string{}
+	for evt := recv(); evt.Type() != SnapshotDoneEventType.String(); evt = recv() {
+		received = append(received, evt.Extensions()[types.ExtensionResourceID].(string))
+	}
+
+	// the status events of the live resources are filtered by the labels of their published manifests
+	publish("live-other-label", map[string]string{"app": "redis"})
+	publish("live-matched", map[string]string{"app": "nginx"})
+	received = append(received, recv().Extensions()[types.ExtensionResourceID].(string))
+
+	expected := []string{ResourceID("cluster1", "snapshot-matched"), ResourceID("cluster1", "live-matched")}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected resources %v, but got %v", expected, received)
+	}
+
+	invalid, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", LabelSelector: "app in ("})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invalid.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected %s status for the invalid label selector, but got %v", codes.InvalidArgument, err)
+	}
+}
+
+func TestSubscribeWithProjection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	store := newMemoryStore(eventBroadcaster)
+	go func() {
+		for range store.GetResourceSpecChan() {
+		}
+	}()
+
+	res := NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	res.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied"}}
+	if _, err := store.UpSert(context.Background(), res); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name               string
+		opts               []GRPCServerOption
+		projection         pbv1.Projection
+		expectedManifest   bool
+		expectedConditions bool
+	}{
+		{
+			name:               "full",
+			opts:               []GRPCServerOption{WithManifestEcho()},
+			projection:         pbv1.Projection_PROJECTION_FULL,
+			expectedManifest:   true,
+			expectedConditions: true,
+		},
+		{
+			name:               "full without manifest echo",
+			projection:         pbv1.Projection_PROJECTION_FULL,
+			expectedConditions: true,
+		},
+		{
+			name:               "status only",
+			opts:               []GRPCServerOption{WithManifestEcho()},
+			projection:         pbv1.Projection_PROJECTION_STATUS_ONLY,
+			expectedConditions: true,
+		},
+		{
+			name:             "spec only",
+			opts:             []GRPCServerOption{WithManifestEcho()},
+			projection:       pbv1.Projection_PROJECTION_SPEC_ONLY,
+			expectedManifest: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster, c.opts...))
+			stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", Projection: c.projection})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recv := func() *cloudevents.Event {
+				pbEvt, err := stream.Recv()
+				if err != nil {
+					t.Fatal(err)
+				}
+				evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return evt
+			}
+			validate := func(evt *cloudevents.Event) {
+				data := &manifestStatusData{}
+				if err := evt.DataAs(data); err != nil {
+					t.Fatal(err)
+				}
+				if hasManifest := len(data.Manifest) != 0; hasManifest != c.expectedManifest {
+					t.Errorf("expected the manifest is sent: %v, but got %v", c.expectedManifest, data.Manifest)
+				}
+				if hasConditions := len(data.Conditions) != 0; hasConditions != c.expectedConditions {
+					t.Errorf("expected the conditions are sent: %v, but got %v", c.expectedConditions, data.Conditions)
+				}
+			}
+
+			// the resource is received in the snapshot
+			validate(recv())
+			if evt := recv(); evt.Type() != SnapshotDoneEventType.String() {
+				t.Fatalf("expected snapshot done event, but got %s", evt.Type())
+			}
+
+			// the status is reported by the agent without the manifest, the live event echoes the stored one if the
+			// server echoes the manifests
+			if err := store.UpdateStatus(&Resource{
+				Source:          res.Source,
+				ResourceID:      res.ResourceID,
+				ResourceVersion: res.ResourceVersion,
+				Namespace:       res.Namespace,
+				Status:          res.Status,
+			}); err != nil {
+				t.Fatal(err)
+			}
+			validate(recv())
+		})
+	}
+
+	// the resource in the store is not changed by the projections
+	stored, err := store.Get(res.ResourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Spec.Object) == 0 || len(stored.Status.Conditions) == 0 {
+		t.Errorf("expected the resource is not changed, but got %v", stored)
+	}
+}
+
+func TestSubscribeWithUnsupportedProjection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", Projection: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected invalid argument, but got %v", err)
+	}
+}
+
+func TestEncodeWithSource(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name           string
+		source         string
+		expectedSource string
+	}{
+		{
+			name:           "configured source",
+			source:         "test-server",
+			expectedSource: "test-server",
+		},
+		{
+			name:           "default source",
+			expectedSource: hostname + "-grpc-server",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			svr := NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(), WithSource(c.source))
+			evt, err := svr.encode(NewResource("cluster1", "resource1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evt.Source() != c.expectedSource {
+				t.Errorf("expected source %q, but got %q", c.expectedSource, evt.Source())
+			}
+		})
+	}
+}
+
+// payloadRecorder records the payload sizes of the received messages.
+type payloadRecorder struct {
+	mu       sync.Mutex
+	payloads []*stats.InPayload
+}
+
+func (r *payloadRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *payloadRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *payloadRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func (r *payloadRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if in, ok := s.(*stats.InPayload); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.payloads = append(r.payloads, in)
+	}
+}
+
+func (r *payloadRecorder) last() *stats.InPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.payloads[len(r.payloads)-1]
+}
+
+func TestCompression(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the server records the payloads of the publish requests
+	serverRecorder := &payloadRecorder{}
+	store := NewMemoryStore()
+	svr := NewGRPCServer(store, NewEventBroadcaster(), WithSendCompressor(gzip.Name))
+	inMemoryServer, err := StartInMemoryServer(svr, grpc.StatsHandler(serverRecorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inMemoryServer.Close(context.Background())
+
+	// the client records the payloads of the subscribed events
+	clientRecorder := &payloadRecorder{}
+	conn, err := inMemoryServer.Dial(ctx,
+		grpc.WithStatsHandler(clientRecorder),
+		grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pbv1.NewCloudEventServiceClient(conn)
+
+	// a large manifest that can be compressed
+	res := NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	res.Spec.Object["data"] = map[string]interface{}{"key": strings.Repeat("value", 100000)}
+
+	if _, err := client.Publish(ctx, newPublishRequest(t, res)); err != nil {
+		t.Fatal(err)
+	}
+	if in := serverRecorder.last(); in.CompressedLength >= in.Length {
+		t.Errorf("expected the publish request is compressed, compressed %d, length %d", in.CompressedLength, in.Length)
+	}
+
+	stored, err := store.Get(res.ResourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(stored.Spec, res.Spec) {
+		t.Errorf("the published manifest is not equal to the original one")
+	}
+
+	// the stored resource is sent to the subscriber in the snapshot
+	res.Status.Conditions = []metav1.Condition{
+		{Type: "Applied", Status: metav1.ConditionTrue, Message: strings.Repeat("applied", 100000)},
+	}
+	if err := store.UpdateStatus(res); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbEvt, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in := clientRecorder.last(); in.CompressedLength >= in.Length {
+		t.Errorf("expected the subscribed event is compressed, compressed %d, length %d", in.CompressedLength, in.Length)
+	}
+
+	evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := (&ResourceCodec{}).Decode(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(received.Status.Conditions, res.Status.Conditions) {
+		t.Errorf("the received status is not equal to the original one")
+	}
+}
+
+var testLeaseDataType = types.CloudEventsDataType{
+	Group:    "coordination.k8s.io",
+	Version:  "v1",
+	Resource: "leases",
+}
+
+// leaseCodec is a codec for a data type other than the manifests.
+type leaseCodec struct{}
+
+func (c *leaseCodec) EventDataType() types.CloudEventsDataType {
+	return testLeaseDataType
+}
+
+func (c *leaseCodec) Encode(res *Resource) (*cloudevents.Event, error) {
+	evt := types.NewEventBuilder("test-source", types.CloudEventsType{
+		CloudEventsDataType: testLeaseDataType,
+		SubResource:         types.SubResourceStatus,
+		Action:              "update_request",
+	}).WithResourceID(res.ResourceID).WithClusterName(res.Namespace).NewEvent()
+	return &evt, nil
+}
+
+func (c *leaseCodec) Decode(evt *cloudevents.Event) (*Resource, error) {
+	resourceID, err := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+	if err != nil {
+		return nil, err
+	}
+	return &Resource{
+		DataType:   testLeaseDataType,
+		Source:     evt.Source(),
+		ResourceID: resourceID,
+		EventID:    evt.ID(),
+		EventTime:  evt.Time(),
+	}, nil
+}
+
+func TestPublishWithMaxRecvMsgSize(t *testing.T) {
+	cases := []struct {
+		name         string
+		opts         []GRPCServerOption
+		expectedCode codes.Code
+	}{
+		{
+			name:         "default limit",
+			expectedCode: codes.ResourceExhausted,
+		},
+		{
+			name:         "raised limit",
+			opts:         []GRPCServerOption{WithMaxRecvMsgSize(2 * defaultMaxRecvMsgSize)},
+			expectedCode: codes.OK,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(), c.opts...))
+
+			// the payload is just over the default limit
+			res := NewResource("cluster1", "resource1")
+			res.Spec.Object["data"] = map[string]interface{}{
+				"payload": strings.Repeat("x", defaultMaxRecvMsgSize),
+			}
+
+			_, err := client.Publish(context.Background(), newPublishRequest(t, res))
+			if status.Code(err) != c.expectedCode {
+				t.Errorf("expected code %v, but got %v", c.expectedCode, err)
+			}
+		})
+	}
+}
+
+func TestPublishWithCodecs(t *testing.T) {
+	store := NewMemoryStore()
+	client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster(), WithCodecs(&leaseCodec{})))
+
+	evt := types.NewEventBuilder("test-source", types.CloudEventsType{
+		CloudEventsDataType: testLeaseDataType,
+		SubResource:         types.SubResourceSpec,
+		Action:              "create_request",
+	}).WithResourceID("lease1").WithResourceVersion(1).WithClusterName("cluster1").NewEvent()
+	pbEvt := &pbv1.CloudEvent{}
+	if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(&evt), pbEvt); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Publish(context.Background(), &pbv1.PublishRequest{Event: pbEvt}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := store.Get("lease1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.DataType != testLeaseDataType {
+		t.Errorf("unexpected data type %s", res.DataType)
+	}
+
+	// the manifests are still supported
+	if _, err := client.Publish(context.Background(), newPublishRequest(t, NewResource("cluster1", "resource1"))); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// the unregistered data types are rejected
+	evt.SetType(types.CloudEventsType{
+		CloudEventsDataType: types.CloudEventsDataType{Group: "test", Version: "v1", Resource: "tests"},
+		SubResource:         types.SubResourceSpec,
+		Action:              "create_request",
+	}.String())
+	pbEvt = &pbv1.CloudEvent{}
+	if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(&evt), pbEvt); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Publish(context.Background(), &pbv1.PublishRequest{Event: pbEvt})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected unimplemented status, but got %v", err)
+	}
+	// the error tells which data types are supported
+	for _, dataType := range []types.CloudEventsDataType{testLeaseDataType, payload.ManifestEventDataType} {
+		if !strings.Contains(status.Convert(err).Message(), dataType.String()) {
+			t.Errorf("expected the supported data type %s in the error %v", dataType, err)
+		}
+	}
+}
+
+func TestServerWithEventTypeNamespace(t *testing.T) {
+	store := NewMemoryStore()
+	client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster(), WithEventTypeNamespace("tenant-a")))
+	ctx := context.Background()
+
+	cases := []struct {
+		name         string
+		namespace    string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "event of the namespace",
+			namespace:    "tenant-a.",
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "event of another namespace",
+			namespace:    "tenant-b.",
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "event without namespace",
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := NewResource("cluster1", fmt.Sprintf("resource%d", i))
+			pubReq := newPublishRequest(t, res)
+			pubReq.Event.Type = c.namespace + pubReq.Event.Type
+
+			_, err := client.Publish(ctx, pubReq)
+			if status.Code(err) != c.expectedCode {
+				t.Fatalf("expected code %s, but got %v", c.expectedCode, err)
+			}
+
+			_, err = store.Get(res.ResourceID)
+			if stored := err == nil; stored != (c.expectedCode == codes.OK) {
+				t.Errorf("expected the resource is stored %t, but got %t", c.expectedCode == codes.OK, stored)
+			}
+		})
+	}
+
+	// the status events of the server are in the namespace
+	resp, err := client.GetResource(ctx, &pbv1.GetResourceRequest{ResourceId: ResourceID("cluster1", "resource0")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventType, ok := strings.CutPrefix(resp.Event.Type, "tenant-a.")
+	if !ok {
+		t.Fatalf("expected the status event type in the namespace tenant-a, but got %s", resp.Event.Type)
+	}
+	if _, err := types.ParseCloudEventsType(eventType); err != nil {
+		t.Errorf("expected the status event type is namespaced from a cloud event type, but got %v", err)
+	}
+}
+
+// testPlacementSpec is the spec of a data type other than the manifests for the TypedCodec.
+type testPlacementSpec struct {
+	ClusterSets      []string `json:"clusterSets,omitempty"`
+	NumberOfClusters int64    `json:"numberOfClusters,omitempty"`
+}
+
+func TestServerWithTypedCodec(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	placementDataType := types.CloudEventsDataType{
+		Group:    "io.open-cluster-management.placements",
+		Version:  "v1beta1",
+		Resource: "placements",
+	}
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	store := NewTypedStore[testPlacementSpec](NewMemoryStore(), placementDataType)
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	// the typed codec is built with the options of the server whatever the order of the options
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster,
+		WithTypedCodec[testPlacementSpec](placementDataType),
+		WithSource("test-server"),
+		WithClock(testingclock.NewFakeClock(now))))
+
+	spec := testPlacementSpec{ClusterSets: []string{"global"}, NumberOfClusters: 3}
+	evt := types.NewEventBuilder("test-source", types.CloudEventsType{
+		CloudEventsDataType: placementDataType,
+		SubResource:         types.SubResourceSpec,
+		Action:              "create_request",
+	}).WithResourceID("placement1").WithResourceVersion(1).WithClusterName("cluster1").NewEvent()
+	if err := evt.SetData(cloudevents.ApplicationJSON, spec); err != nil {
+		t.Fatal(err)
+	}
+	pbEvt := &pbv1.CloudEvent{}
+	if err := grpcprotocol.WritePBMessage(ctx, binding.ToMessage(&evt), pbEvt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Publish(ctx, &pbv1.PublishRequest{Event: pbEvt}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the spec is stored as the resource spec and read back as the type of the store
+	typed, err := store.GetTyped("placement1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typed.DataType != placementDataType {
+		t.Errorf("unexpected data type %s", typed.DataType)
+	}
+	if !equality.Semantic.DeepEqual(*typed.TypedSpec, spec) {
+		t.Errorf("expected spec %v, but got %v", spec, *typed.TypedSpec)
+	}
+	listed, err := store.ListTyped(mustSourceFilter(t, "test-source"), "cluster1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].ResourceID != "placement1" {
+		t.Errorf("expected the typed resource placement1 is listed, but got %v", listed)
+	}
+
+	// the status event carries the conditions and the spec of the type
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source",
+		DataType: placementDataType.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func() *cloudevents.Event {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+	snapshot := recv()
+	if snapshot.Type() != (types.CloudEventsType{
+		CloudEventsDataType: placementDataType,
+		SubResource:         types.SubResourceStatus,
+		Action:              statusUpdateAction,
+	}).String() {
+		t.Errorf("unexpected event type %s", snapshot.Type())
+	}
+	if snapshot.Source() != "test-server" || !snapshot.Time().Equal(now) {
+		t.Errorf("expected the status event from test-server at %v, but got %s at %v", now, snapshot.Source(),
+			snapshot.Time())
+	}
+	if evt := recv(); evt.Type() != SnapshotDoneEventType.String() {
+		t.Fatalf("expected snapshot done event, but got %s", evt.Type())
+	}
+
+	// the typed resource is upserted with the new spec and broadcast with the new conditions
+	spec.NumberOfClusters = 5
+	typed.TypedSpec = &spec
+	typed.Status.Conditions = []metav1.Condition{{Type: "Satisfied", Status: metav1.ConditionTrue, Reason: "Test"}}
+	typed.ResourceVersion = 2
+	// it is not applied from an event, so it does not carry the ID of the applied event
+	typed.EventID = ""
+	if result, err := store.UpSertTyped(ctx, typed); err != nil || result != ResourceUpdated {
+		t.Fatalf("expected the typed resource is updated, but got %s, %v", result, err)
+	}
+	res, err := store.Get("placement1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventBroadcaster.Broadcast(res)
+
+	status := &typedStatusData[testPlacementSpec]{}
+	if err := recv().DataAs(status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Conditions) != 1 || status.Conditions[0].Type != "Satisfied" {
+		t.Errorf("unexpected conditions %v", status.Conditions)
+	}
+	if status.Spec == nil || !equality.Semantic.DeepEqual(*status.Spec, spec) {
+		t.Errorf("expected spec %v, but got %v", spec, status.Spec)
+	}
+}
+
+// conditionsCodec is a lease codec that decodes the conditions of the lease status from the event data.
+type conditionsCodec struct {
+	leaseCodec
+}
+
+func (c *conditionsCodec) Decode(evt *cloudevents.Event) (*Resource, error) {
+	res, err := c.leaseCodec.Decode(evt)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &payload.ManifestStatus{}
+	if err := evt.DataAs(status); err != nil {
+		return nil, err
+	}
+	res.Status.Conditions = status.Conditions
+	return res, nil
+}
+
+// newConditions returns the given number of conditions of different types.
+func newConditions(count int) []metav1.Condition {
+	conditions := make([]metav1.Condition, count)
+	for i := range conditions {
+		conditions[i] = metav1.Condition{Type: fmt.Sprintf("Type%d", i), Status: metav1.ConditionTrue, Reason: "Test"}
+	}
+	return conditions
+}
+
+func TestPublishWithTooManyConditions(t *testing.T) {
+	cases := []struct {
+		name         string
+		opts         []GRPCServerOption
+		expectedCode codes.Code
+	}{
+		{
+			name:         "default limit",
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "raised limit",
+			opts:         []GRPCServerOption{WithMaxConditions(2 * defaultMaxConditions)},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "unlimited",
+			opts:         []GRPCServerOption{WithMaxConditions(0)},
+			expectedCode: codes.OK,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			opts := append([]GRPCServerOption{WithCodecs(&conditionsCodec{})}, c.opts...)
+			client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster(), opts...))
+
+			// the conditions are just over the default limit
+			evt := types.NewEventBuilder("test-source", types.CloudEventsType{
+				CloudEventsDataType: testLeaseDataType,
+				SubResource:         types.SubResourceStatus,
+				Action:              "update_request",
+			}).WithResourceID("lease1").WithResourceVersion(1).WithClusterName("cluster1").NewEvent()
+			if err := evt.SetData(cloudevents.ApplicationJSON, &payload.ManifestStatus{
+				Conditions: newConditions(defaultMaxConditions + 1),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			pbEvt := &pbv1.CloudEvent{}
+			if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(&evt), pbEvt); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := client.Publish(context.Background(), &pbv1.PublishRequest{Event: pbEvt})
+			if status.Code(err) != c.expectedCode {
+				t.Fatalf("expected code %v, but got %v", c.expectedCode, err)
+			}
+			if c.expectedCode == codes.OK {
+				return
+			}
+
+			// the error tells how many conditions are accepted
+			expected := fmt.Sprintf("the resource lease1 has %d conditions, the limit is %d",
+				defaultMaxConditions+1, defaultMaxConditions)
+			if !strings.Contains(status.Convert(err).Message(), expected) {
+				t.Errorf("expected %q in the error %v", expected, err)
+			}
+			if _, err := store.Get("lease1"); !errors.Is(err, ErrResourceNotFound) {
+				t.Errorf("expected the resource is not stored, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestEncodeWithTooManyConditions(t *testing.T) {
+	svr := NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(), WithMaxConditions(2))
+
+	res := NewResource("cluster1", "resource1")
+	res.Status.Conditions = newConditions(2)
+	if _, err := svr.encodeStatusEvent(res); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	res.Status.Conditions = newConditions(3)
+	_, err := svr.encodeStatusEvent(res)
+	if !errors.Is(err, ErrTooManyConditions) || !errors.Is(err, ErrEncode) {
+		t.Errorf("expected the too many conditions error, but got %v", err)
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []GRPCServerOption
+		// pings is the number of the pings that the client sends back to back once it is connected.
+		pings           int
+		expectedErrCode http2.ErrCode
+		expectedDebug   string
+	}{
+		{
+			name: "client pings too frequently",
+			opts: []GRPCServerOption{
+				WithKeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: time.Minute}),
+			},
+			pings:           5,
+			expectedErrCode: http2.ErrCodeEnhanceYourCalm,
+			expectedDebug:   "too_many_pings",
+		},
+		{
+			name: "idle connection",
+			opts: []GRPCServerOption{
+				WithKeepaliveParams(keepalive.ServerParameters{MaxConnectionIdle: 100 * time.Millisecond}),
+			},
+			expectedErrCode: http2.ErrCodeNo,
+			expectedDebug:   "max_idle",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			inMemoryServer, err := StartInMemoryServer(NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(), c.opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer inMemoryServer.Close(context.Background())
+
+			// the grpc clients ping at most every 10s, so the pings are sent by a raw http2 client
+			conn, err := inMemoryServer.listener.DialContext(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := conn.Write([]byte(http2.ClientPreface)); err != nil {
+				t.Fatal(err)
+			}
+			framer := http2.NewFramer(conn, conn)
+			if err := framer.WriteSettings(); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < c.pings; i++ {
+				if err := framer.WritePing(false, [8]byte{byte(i)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// the connection is closed with a GOAWAY frame
+			for {
+				frame, err := framer.ReadFrame()
+				if err != nil {
+					t.Fatalf("expected a GOAWAY frame, but got %v", err)
+				}
+
+				goAway, ok := frame.(*http2.GoAwayFrame)
+				if !ok {
+					continue
+				}
+				if goAway.ErrCode != c.expectedErrCode || string(goAway.DebugData()) != c.expectedDebug {
+					t.Errorf("expected GOAWAY %s %q, but got %s %q",
+						c.expectedErrCode, c.expectedDebug, goAway.ErrCode, goAway.DebugData())
+				}
+				return
+			}
+		})
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	svr := NewGRPCServer(NewMemoryStore(), NewEventBroadcaster())
+	inMemoryServer, err := StartInMemoryServer(svr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inMemoryServer.Close(context.Background())
+
+	conn, err := inMemoryServer.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	healthClient := healthpb.NewHealthClient(conn)
+
+	resp, err := healthClient.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected serving status, but got %s", resp.Status)
+	}
+
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	watch, err := healthClient.Watch(watchCtx, &healthpb.HealthCheckRequest{
+		Service: pbv1.CloudEventService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := watch.Recv(); err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected serving status, but got %v, %v", resp, err)
+	}
+
+	// the server waits for the watch to be closed during the shutdown
+	stopped := make(chan struct{})
+	go func() {
+		svr.Stop(context.Background())
+		close(stopped)
+	}()
+
+	if resp, err := watch.Recv(); err != nil || resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expected not serving status during the shutdown, but got %v, %v", resp, err)
+	}
+
+	stopWatch()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the server is not stopped")
+	}
+}
+
+// panicCodec is a lease codec that panics when the events are encoded or decoded.
+type panicCodec struct {
+	leaseCodec
+}
+
+func (c *panicCodec) Encode(res *Resource) (*cloudevents.Event, error) {
+	panic("failed to encode")
+}
+
+func (c *panicCodec) Decode(evt *cloudevents.Event) (*Resource, error) {
+	panic("failed to decode")
+}
+
+func TestPanicRecovery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+
+	store := NewMemoryStore()
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster, WithCodecs(&panicCodec{})))
+
+	evt := types.NewEventBuilder("test-source", types.CloudEventsType{
+		CloudEventsDataType: testLeaseDataType,
+		SubResource:         types.SubResourceSpec,
+		Action:              "create_request",
+	}).WithResourceID("lease1").WithResourceVersion(1).WithClusterName("cluster1").NewEvent()
+	pbEvt := &pbv1.CloudEvent{}
+	if err := grpcprotocol.WritePBMessage(context.TODO(), binding.ToMessage(&evt), pbEvt); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.Publish(ctx, &pbv1.PublishRequest{Event: pbEvt})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected internal status, but got %v", err)
+	}
+
+	// the server survives the panic
+	if _, err := client.Publish(ctx, newPublishRequest(t, NewResource("cluster1", "resource1"))); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// the panic of encoding the events of a subscription closes the subscription
+	store.Add(&Resource{DataType: testLeaseDataType, Source: "test-source", ResourceID: "lease1", Namespace: "cluster1"})
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+	}
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected internal status, but got %v", err)
+	}
+}
+
+// mockStore is a Store that keeps the resources in a map and records the calls of the server.
+type mockStore struct {
+	mu        sync.Mutex
+	resources map[string]*Resource
+	calls     []string
+}
+
+var _ Store = &mockStore{}
+
+func (s *mockStore) record(call string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+}
+
+func (s *mockStore) UpSert(ctx context.Context, resource *Resource) (UpSertResult, error) {
+	s.record("UpSert " + resource.ResourceID)
+	return s.upSert(resource), nil
+}
+
+func (s *mockStore) upSert(resource *Resource) UpSertResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.resources[resource.ResourceID]
+	s.resources[resource.ResourceID] = resource.DeepCopy()
+	if ok {
+		return ResourceUpdated
+	}
+	return ResourceCreated
+}
+
+func (s *mockStore) UpSertBatch(ctx context.Context, resources []*Resource, allOrNothing bool) ([]UpSertResult, []error) {
+	s.record(fmt.Sprintf("UpSertBatch %d", len(resources)))
+	results := make([]UpSertResult, len(resources))
+	for i, resource := range resources {
+		results[i] = s.upSert(resource)
+	}
+	return results, make([]error, len(resources))
+}
+
+func (s *mockStore) DryRunUpSert(ctx context.Context, resource *Resource) (UpSertResult, error) {
+	s.record("DryRunUpSert " + resource.ResourceID)
+	return ResourceCreated, nil
+}
+
+func (s *mockStore) Get(resourceID string) (*Resource, error) {
+	s.record("Get " + resourceID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.resources[resourceID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, resourceID)
+	}
+	return res.DeepCopy(), nil
+}
+
+func (s *mockStore) ListBySourceAndCluster(filter SourceFilter, clusterName string) []*Resource {
+	s.record("ListBySourceAndCluster " + clusterName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resources := []*Resource{}
+	for _, res := range s.resources {
+		if filter(res.Source) && (len(clusterName) == 0 || res.Namespace == clusterName) {
+			resources = append(resources, res.DeepCopy())
+		}
+	}
+	return resources
+}
+
+func (s *mockStore) Delete(resourceID string) {
+	s.record("Delete " + resourceID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.resources, resourceID)
+}
+
+func TestServerWithStore(t *testing.T) {
+	store := &mockStore{resources: map[string]*Resource{}}
+	client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+	ctx := context.Background()
+
+	res := NewResource("cluster1", "resource1")
+	resp, err := client.Publish(ctx, newPublishRequest(t, res))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result != pbv1.PublishResult_PUBLISH_RESULT_CREATED {
+		t.Errorf("expected created result, but got %s", resp.Result)
+	}
+
+	dryRun := newPublishRequest(t, NewResource("cluster1", "resource2"))
+	dryRun.DryRun = true
+	if _, err := client.Publish(ctx, dryRun); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetResource(ctx, &pbv1.GetResourceRequest{ResourceId: res.ResourceID}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetResource(ctx, &pbv1.GetResourceRequest{ResourceId: ResourceID("cluster1", "resource2")})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected not found status, but got %v", err)
+	}
+
+	listResp, err := client.ListResources(ctx, &pbv1.ListResourcesRequest{Source: "test-source", ClusterName: "cluster1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listResp.Events) != 1 {
+		t.Errorf("expected 1 resource, but got %d", len(listResp.Events))
+	}
+
+	expected := []string{
+		"UpSert " + res.ResourceID,
+		"DryRunUpSert " + ResourceID("cluster1", "resource2"),
+		"Get " + res.ResourceID,
+		"Get " + ResourceID("cluster1", "resource2"),
+		"ListBySourceAndCluster cluster1",
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if !reflect.DeepEqual(store.calls, expected) {
+		t.Errorf("expected calls %v, but got %v", expected, store.calls)
+	}
+}
+
+// eventTypeStore is a MemoryStore that records the CloudEventsTypes in the contexts of the upserts.
+type eventTypeStore struct {
+	*MemoryStore
+
+	mu         sync.Mutex
+	eventTypes []types.CloudEventsType
+}
+
+func (s *eventTypeStore) UpSert(ctx context.Context, resource *Resource) (UpSertResult, error) {
+	s.record(ctx)
+	return s.MemoryStore.UpSert(ctx, resource)
+}
+
+func (s *eventTypeStore) DryRunUpSert(ctx context.Context, resource *Resource) (UpSertResult, error) {
+	s.record(ctx)
+	return s.MemoryStore.DryRunUpSert(ctx, resource)
+}
+
+func (s *eventTypeStore) record(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if eventType, ok := CloudEventsTypeFromContext(ctx); ok {
+		s.eventTypes = append(s.eventTypes, eventType)
+	}
+}
+
+func TestPublishWithCloudEventsTypeInContext(t *testing.T) {
+	store := &eventTypeStore{MemoryStore: NewMemoryStore()}
+	client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+	ctx := context.Background()
+
+	if _, err := client.Publish(ctx, newPublishRequest(t, NewResource("cluster1", "resource1"))); err != nil {
+		t.Fatal(err)
+	}
+	dryRun := newPublishRequest(t, NewResource("cluster1", "resource2"))
+	dryRun.DryRun = true
+	if _, err := client.Publish(ctx, dryRun); err != nil {
+		t.Fatal(err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	expected := []types.CloudEventsType{testSpecEventType, testSpecEventType}
+	if !reflect.DeepEqual(store.eventTypes, expected) {
+		t.Errorf("expected the event types %v in the contexts, but got %v", expected, store.eventTypes)
+	}
+
+	if _, ok := CloudEventsTypeFromContext(ctx); ok {
+		t.Errorf("expected no event type in the context that is not of a publish")
+	}
+}
+
+func TestGetResource(t *testing.T) {
+	store := NewMemoryStore()
+	res := NewResource("cluster1", "resource1")
+	res.Source = "test-source"
+	store.Add(res)
+
+	client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+
+	cases := []struct {
+		name         string
+		resourceID   string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "existing resource",
+			resourceID:   res.ResourceID,
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "missing resource",
+			resourceID:   ResourceID("cluster1", "resource2"),
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp, err := client.GetResource(context.Background(), &pbv1.GetResourceRequest{ResourceId: c.resourceID})
+			if status.Code(err) != c.expectedCode {
+				t.Fatalf("expected code %v, but got %v", c.expectedCode, err)
+			}
+			if err != nil {
+				return
+			}
+
+			resourceID := resourceIDOf(t, resp.Event)
+			if resourceID != c.resourceID {
+				t.Errorf("expected the event of resource %s, but got %s", c.resourceID, resourceID)
+			}
+		})
+	}
+}
+
+func TestListResources(t *testing.T) {
+	store := NewMemoryStore()
+	for _, res := range []*Resource{
+		NewResource("cluster1", "resource1"),
+		NewResource("cluster2", "resource2"),
+		NewResource("cluster1", "resource3"),
+	} {
+		res.Source = "test-source"
+		store.Add(res)
+	}
+	other := NewResource("cluster1", "resource4")
+	other.Source = "another-source"
+	store.Add(other)
+
+	client, _ := startTestServer(t, NewGRPCServer(store, NewEventBroadcaster()))
+
+	cases := []struct {
+		name                string
+		req                 *pbv1.ListResourcesRequest
+		expectedResourceIDs []string
+		expectedCode        codes.Code
+	}{
+		{
+			name: "list by source",
+			req:  &pbv1.ListResourcesRequest{Source: "test-source"},
+			expectedResourceIDs: []string{
+				ResourceID("cluster1", "resource1"), ResourceID("cluster2", "resource2"), ResourceID("cluster1", "resource3"),
+			},
+		},
+		{
+			name: "list by source and cluster",
+			req:  &pbv1.ListResourcesRequest{Source: "test-source", ClusterName: "cluster1"},
+			expectedResourceIDs: []string{
+				ResourceID("cluster1", "resource1"), ResourceID("cluster1", "resource3"),
+			},
+		},
+		{
+			name:         "invalid source",
+			req:          &pbv1.ListResourcesRequest{},
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp, err := client.ListResources(context.Background(), c.req)
+			if status.Code(err) != c.expectedCode {
+				t.Fatalf("expected code %v, but got %v", c.expectedCode, err)
+			}
+			if err != nil {
+				return
+			}
+
+			resourceIDs := []string{}
+			for _, evt := range resp.Events {
+				resourceIDs = append(resourceIDs, resourceIDOf(t, evt))
+			}
+			sort.Strings(c.expectedResourceIDs)
+			if !reflect.DeepEqual(resourceIDs, c.expectedResourceIDs) {
+				t.Errorf("expected resources %v, but got %v", c.expectedResourceIDs, resourceIDs)
+			}
+		})
+	}
+}
+
+// resourceIDOf returns the resource ID of the protobuf cloudevent.
+func resourceIDOf(t *testing.T, pbEvt *pbv1.CloudEvent) string {
+	evt, err := binding.ToEvent(context.TODO(), grpcprotocol.NewMessage(pbEvt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resourceID, err := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resourceID
+}
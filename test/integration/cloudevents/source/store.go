@@ -1,15 +1,203 @@
 package source
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/clock"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+// UpSertResult describes how a resource is applied to the store by UpSert.
+type UpSertResult string
+
+const (
+	// ResourceCreated means the resource does not exist in the store and it is created.
+	ResourceCreated UpSertResult = "Created"
+
+	// ResourceUpdated means the resource exists in the store and it is updated.
+	ResourceUpdated UpSertResult = "Updated"
+
+	// ResourceUnchanged means the same resource is already applied to the store, nothing is changed.
+	ResourceUnchanged UpSertResult = "Unchanged"
+)
+
+// StatusMergeStrategy decides how the incoming status of a resource is applied on top of the stored status.
+type StatusMergeStrategy string
+
+const (
+	// ReplaceStatus replaces the stored status with the incoming status.
+	ReplaceStatus StatusMergeStrategy = "Replace"
+
+	// MergeConditions merges the incoming conditions into the stored conditions by their types, the stored conditions
+	// of the same types are replaced and the others are kept, so a partial status does not lose the conditions that
+	// it does not include.
+	MergeConditions StatusMergeStrategy = "MergeConditions"
 )
 
+// ErrResourceNotFound is returned by Get when the resource does not exist in the store.
+var ErrResourceNotFound = errors.New("failed to find resource")
+
+// ErrResourceHistoryNotKept is returned by GetResourceAt when the store does not keep the history of the resources.
+var ErrResourceHistoryNotKept = errors.New("the history of the resources is not kept")
+
+// ErrStaleResourceVersion is returned by UpSert when the version of the resource is lower than the version that is
+// already committed to the store.
+var ErrStaleResourceVersion = errors.New("stale resource version")
+
+// Store keeps the resources that are served by the GRPCServer, the resources are copied when they are written to or
+// read from the store, so the server and the store do not share them. The MemoryStore is the default implementation,
+// the other backends, e.g. a database, can be plugged in by implementing it.
+type Store interface {
+	// UpSert creates or updates the resource, the resources of the lower versions than the stored ones are rejected
+	// with ErrStaleResourceVersion.
+	UpSert(ctx context.Context, resource *Resource) (UpSertResult, error)
+	// UpSertBatch upserts the resources in one batch, the results and the errors are in the order of the resources.
+	// If allOrNothing is true and one of the resources is rejected, none of the resources is applied.
+	UpSertBatch(ctx context.Context, resources []*Resource, allOrNothing bool) ([]UpSertResult, []error)
+	// DryRunUpSert returns how the resource would be applied by UpSert without changing the store.
+	DryRunUpSert(ctx context.Context, resource *Resource) (UpSertResult, error)
+	// Get returns the resource by its ID, ErrResourceNotFound is returned if the resource does not exist.
+	Get(resourceID string) (*Resource, error)
+	// ListBySourceAndCluster lists the resources whose sources match the filter, the resources are scoped to the
+	// cluster if it is not empty.
+	ListBySourceAndCluster(filter SourceFilter, clusterName string) []*Resource
+	// Delete deletes the resource by its ID, it does nothing if the resource does not exist.
+	Delete(resourceID string)
+}
+
+var _ Store = &MemoryStore{}
+
+// MemoryStore keeps the resources in memory. The resources are copied when they are written to or read from the store,
+// and the stored resources are replaced rather than changed in place, so the resources that are collected with the lock
+// are consistent and they are copied after the lock is released without blocking the writers.
 type MemoryStore struct {
 	sync.RWMutex
 	resources        map[string]*Resource
 	eventBroadcaster *EventBroadcaster
 	resourceSpecChan chan *Resource
+
+	// sourceIndex indexes the IDs of the resources by their sources and then their clusters, so the resources of a
+	// source are listed without scanning all of the resources.
+	sourceIndex map[string]map[string]sets.Set[string]
+
+	// dedup keeps the IDs of the recently applied events, it is nil if the events are not deduplicated.
+	dedup *dedupCache
+	clock clock.PassiveClock
+
+	// statusMergeStrategies are the status merge strategies of the data types, the status of the other data types is
+	// replaced.
+	statusMergeStrategies map[types.CloudEventsDataType]StatusMergeStrategy
+
+	// deletedRetention is how long the deleted resources are kept after their deletion timestamps, they are kept
+	// forever if it is zero.
+	deletedRetention time.Duration
+	// maxResources is the max number of the resources, the least recently used resources are evicted once it is
+	// exceeded, the lru is nil if the number of the resources is not limited.
+	maxResources int
+	lru          *lruList
+	// history keeps the last written resources of the resource IDs, it is nil if the history is not kept.
+	history *resourceHistory
+
+	metrics *storeMetrics
+}
+
+// MemoryStoreOption configures the MemoryStore.
+type MemoryStoreOption func(*MemoryStore)
+
+// WithDedupWindow keeps the IDs of the events that are applied by UpSert for the ttl, so a retried publishing of the
+// same event within the window is not applied again, even if the resource is changed or deleted in the meantime. At
+// most size events are kept, the oldest ones are forgotten first. The events are not deduplicated by default.
+func WithDedupWindow(ttl time.Duration, size int) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		if ttl > 0 && size > 0 {
+			s.dedup = newDedupCache(ttl, size)
+		}
+	}
+}
+
+// WithStatusMergeStrategy sets how the status of the resources of the data type is applied by UpSert and UpdateStatus,
+// the status is replaced by default.
+func WithStatusMergeStrategy(dataType types.CloudEventsDataType, strategy StatusMergeStrategy) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.statusMergeStrategies[dataType] = strategy
+	}
+}
+
+// WithDeletedResourceRetention keeps the deleted resources for the retention after their deletion timestamps, they are
+// removed by Evict or StartEviction once the retention is passed. The resources that are deleted by Delete are kept as
+// tombstones for the retention too. The deleted resources are kept by default, but the resources that are deleted by
+// Delete are removed at once.
+func WithDeletedResourceRetention(retention time.Duration) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.deletedRetention = retention
+	}
+}
+
+// WithMaxResources limits the number of the resources, the least recently written or read resource is evicted once a
+// new resource exceeds the limit. The number of the resources is not limited by default.
+func WithMaxResources(max int) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		if max > 0 {
+			s.maxResources = max
+			s.lru = newLRUList()
+		}
+	}
+}
+
+// WithResourceHistory keeps the last size resources that are written to the store for each resource ID, so the
+// resource that is active at a time can be queried with GetResourceAt, e.g. for the audit and the debugging. The
+// history of a resource is removed once the resource is removed from the store. The history is not kept by default.
+func WithResourceHistory(size int) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		if size > 0 {
+			s.history = newResourceHistory(size)
+		}
+	}
+}
+
+// WithStoreMetricsRegisterer registers the metrics of the store with the given registerer, i.e. the number of the
+// resources in total, of the sources and of the clusters.
+func WithStoreMetricsRegisterer(registerer prometheus.Registerer) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.metrics.register(registerer)
+	}
+}
+
+// withClock sets the clock that the dedup window and the deleted resource retention are measured with.
+func withClock(c clock.PassiveClock) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.clock = c
+	}
+}
+
+func newMemoryStore(eventBroadcaster *EventBroadcaster, opts ...MemoryStoreOption) *MemoryStore {
+	s := &MemoryStore{
+		resources:        make(map[string]*Resource),
+		sourceIndex:      make(map[string]map[string]sets.Set[string]),
+		eventBroadcaster: eventBroadcaster,
+		clock:            clock.RealClock{},
+		metrics:          newStoreMetrics(),
+
+		statusMergeStrategies: map[types.CloudEventsDataType]StatusMergeStrategy{},
+	}
+	if eventBroadcaster != nil {
+		s.resourceSpecChan = make(chan *Resource)
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 var (
@@ -18,25 +206,19 @@ var (
 	consumerStore *MemoryStore
 )
 
-func InitStore(eventBroadcaster *EventBroadcaster) (*MemoryStore, *MemoryStore) {
+// InitStore initializes the store of the source server and the store of the consumer once, the options are applied
+// to the store of the source server.
+func InitStore(eventBroadcaster *EventBroadcaster, opts ...MemoryStoreOption) (*MemoryStore, *MemoryStore) {
 	once.Do(func() {
-		store = &MemoryStore{
-			resources:        make(map[string]*Resource),
-			eventBroadcaster: eventBroadcaster,
-			resourceSpecChan: make(chan *Resource),
-		}
-		consumerStore = &MemoryStore{
-			resources: make(map[string]*Resource),
-		}
+		store = newMemoryStore(eventBroadcaster, opts...)
+		consumerStore = newMemoryStore(nil)
 	})
 
 	return store, consumerStore
 }
 
-func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{
-		resources: make(map[string]*Resource),
-	}
+func NewMemoryStore(opts ...MemoryStoreOption) *MemoryStore {
+	return newMemoryStore(nil, opts...)
 }
 
 func (s *MemoryStore) Add(resource *Resource) {
@@ -45,7 +227,7 @@ func (s *MemoryStore) Add(resource *Resource) {
 
 	_, ok := s.resources[resource.ResourceID]
 	if !ok {
-		s.resources[resource.ResourceID] = resource
+		s.put(resource.DeepCopy())
 	}
 	if s.eventBroadcaster != nil {
 		s.resourceSpecChan <- resource
@@ -61,24 +243,180 @@ func (s *MemoryStore) Update(resource *Resource) error {
 		return fmt.Errorf("the resource %s does not exist", resource.ResourceID)
 	}
 
-	s.resources[resource.ResourceID] = resource
+	s.put(resource.DeepCopy())
 	if s.eventBroadcaster != nil {
 		s.resourceSpecChan <- resource
 	}
 	return nil
 }
 
-func (s *MemoryStore) UpSert(resource *Resource) {
+// UpSert creates or updates the resource in the store. If the same resource version with the same spec is already
+// applied, or the resource is decoded from the same event that is already applied, the store is not changed, so the
+// retries and the replays of a publishing are idempotent. If the resource version is lower
+// than the committed one, the resource is rejected with ErrStaleResourceVersion, so an out-of-order event cannot
+// override a newer one. If the context is done before the resource is written, the store is not changed and the
+// context error is returned. The subscribers are not notified if the resource is equal to the stored one, e.g. the
+// same resource is published with a new version.
+func (s *MemoryStore) UpSert(ctx context.Context, resource *Resource) (UpSertResult, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	result, err := s.upSertResult(ctx, resource)
+	if err != nil {
+		return "", err
+	}
+
+	s.apply(result, resource)
+	return result, nil
+}
+
+// DryRunUpSert returns how the resource would be applied by UpSert, but the store is not changed and the subscribers
+// are not notified.
+func (s *MemoryStore) DryRunUpSert(ctx context.Context, resource *Resource) (UpSertResult, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.upSertResult(ctx, resource)
+}
+
+// upSertResult returns how the resource is applied on top of the stored one, it must be called with the lock held.
+func (s *MemoryStore) upSertResult(ctx context.Context, resource *Resource) (UpSertResult, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if s.deduplicated(resource) {
+		return ResourceUnchanged, nil
+	}
+
+	return upSertResult(s.resources[resource.ResourceID], resource)
+}
+
+// UpSertBatch upserts the resources like UpSert, but the resources are applied with one lock, so the others cannot
+// see a partially applied batch. The results and the errors are in the order of the resources. If allOrNothing is
+// true and one of the resources is rejected, none of the resources is applied, only the errors of the rejected
+// resources are returned.
+func (s *MemoryStore) UpSertBatch(ctx context.Context, resources []*Resource, allOrNothing bool) ([]UpSertResult, []error) {
 	s.Lock()
 	defer s.Unlock()
 
-	s.resources[resource.ResourceID] = resource
+	results := make([]UpSertResult, len(resources))
+	errs := make([]error, len(resources))
+
+	if err := ctx.Err(); err != nil {
+		for i := range resources {
+			errs[i] = err
+		}
+		return results, errs
+	}
+
+	// the resources in the batch are checked against the earlier ones in the same batch
+	staged := map[string]*Resource{}
+	failed := false
+	for i, resource := range resources {
+		if s.deduplicated(resource) {
+			results[i] = ResourceUnchanged
+			continue
+		}
+
+		last, ok := staged[resource.ResourceID]
+		if !ok {
+			last = s.resources[resource.ResourceID]
+		}
+
+		results[i], errs[i] = upSertResult(last, resource)
+		if errs[i] != nil {
+			failed = true
+			continue
+		}
+		staged[resource.ResourceID] = resource
+	}
+
+	if failed && allOrNothing {
+		return make([]UpSertResult, len(resources)), errs
+	}
+
+	for i, resource := range resources {
+		if errs[i] == nil {
+			s.apply(results[i], resource)
+		}
+	}
+	return results, errs
+}
+
+// upSertResult returns how the resource is applied on top of the last one.
+func upSertResult(last, resource *Resource) (UpSertResult, error) {
+	if last == nil {
+		return ResourceCreated, nil
+	}
+
+	// the event of the resource is already applied, it is a replay
+	if len(resource.EventID) != 0 && resource.EventID == last.EventID {
+		return ResourceUnchanged, nil
+	}
+
+	if resource.ResourceVersion < last.ResourceVersion {
+		return "", fmt.Errorf("%w: the resource %s version %d is lower than the committed version %d",
+			ErrStaleResourceVersion, resource.ResourceID, resource.ResourceVersion, last.ResourceVersion)
+	}
+
+	if isApplied(last, resource) {
+		return ResourceUnchanged, nil
+	}
+
+	return ResourceUpdated, nil
+}
+
+// deduplicated returns true if the event of the resource is applied within the dedup window. It must be called with
+// the lock held.
+func (s *MemoryStore) deduplicated(resource *Resource) bool {
+	if s.dedup == nil || len(resource.EventID) == 0 {
+		return false
+	}
+
+	return s.dedup.seen(resource.EventID, s.clock.Now())
+}
+
+// apply must be called with the lock held.
+func (s *MemoryStore) apply(result UpSertResult, resource *Resource) {
+	if s.dedup != nil && len(resource.EventID) != 0 {
+		s.dedup.add(resource.EventID, s.clock.Now())
+	}
+
+	if result == ResourceUnchanged {
+		return
+	}
+
+	stored := resource.DeepCopy()
+	last, ok := s.resources[resource.ResourceID]
+	if ok {
+		stored.Status = s.mergeStatus(last, stored.Status)
+		// the observed generation is reported with the status, it is kept if the resource does not report one
+		if stored.ObservedGeneration == 0 {
+			stored.ObservedGeneration = last.ObservedGeneration
+		}
+	}
+	s.put(stored)
+
+	// the version is committed, but the subscribers are not notified if nothing else is changed
+	if ok && last.Equal(stored) {
+		return
+	}
 	if s.eventBroadcaster != nil {
 		s.resourceSpecChan <- resource
 	}
 }
 
+// UpdateStatus updates the status of the resource and broadcasts it to the subscribers, the status with invalid
+// conditions is rejected.
 func (s *MemoryStore) UpdateStatus(resource *Resource) error {
+	if err := resource.Status.Validate(); err != nil {
+		return fmt.Errorf("the status of the resource %s is invalid: %v", resource.ResourceID, err)
+	}
+
+	// the resource of the caller is not changed, the broadcast one is a copy with the merged status
+	resource = resource.DeepCopy()
+
 	s.Lock()
 	defer s.Unlock()
 
@@ -87,19 +425,93 @@ func (s *MemoryStore) UpdateStatus(resource *Resource) error {
 		return fmt.Errorf("the resource %s does not exist", resource.ResourceID)
 	}
 
-	last.Status = resource.Status
-	s.resources[resource.ResourceID] = last
+	updated := *last
+	updated.Status = s.mergeStatus(last, resource.Status)
+	if resource.ObservedGeneration != 0 {
+		updated.ObservedGeneration = resource.ObservedGeneration
+	}
+	s.put(&updated)
+
+	// the subscribers receive the merged status and the generation that it is observed from
+	resource.Status = updated.DeepCopy().Status
+	resource.ObservedGeneration = updated.ObservedGeneration
+
+	// the status is reported by the agent without the deletion timestamp, keep the deletion timestamp of the
+	// stored resource, so that the subscribers know the resource is being deleted.
+	if resource.DeletionTimestamp == nil {
+		resource.DeletionTimestamp = last.DeletionTimestamp
+	}
+
+	// the spec, the labels and the annotations are of the manifest that is published by the source, the subscribers
+	// are filtered by the labels and the manifest is echoed with the status if the server is with WithManifestEcho
+	resource.Spec = last.Spec
+	resource.Labels, resource.Annotations = last.Labels, last.Annotations
+
+	// the status is delivered in the trace of the last publish of the resource
+	if resource.TraceParent == "" {
+		resource.TraceParent, resource.TraceState = last.TraceParent, last.TraceState
+	}
+
 	if s.eventBroadcaster != nil {
 		s.eventBroadcaster.Broadcast(resource)
 	}
 	return nil
 }
 
+// mergeStatus returns the status that is applied on top of the status of the last resource with the status merge
+// strategy of its data type.
+func (s *MemoryStore) mergeStatus(last *Resource, status ResourceStatus) ResourceStatus {
+	if s.statusMergeStrategies[resourceDataType(last)] != MergeConditions {
+		return status
+	}
+
+	conditions := make([]metav1.Condition, len(last.Status.Conditions))
+	for i := range last.Status.Conditions {
+		last.Status.Conditions[i].DeepCopyInto(&conditions[i])
+	}
+
+	for _, cond := range status.Conditions {
+		merged := false
+		for i := range conditions {
+			if conditions[i].Type == cond.Type {
+				conditions[i] = cond
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			conditions = append(conditions, cond)
+		}
+	}
+
+	return ResourceStatus{Conditions: conditions}
+}
+
+// Delete deletes the resource by its ID. If the deleted resource retention is set, the resource is kept as a tombstone,
+// i.e. the resource with its deletion timestamp, until the retention is passed, so the subscribers that subscribe after
+// the deletion still receive it in their snapshots and can reconcile it. Otherwise the resource is removed at once.
 func (s *MemoryStore) Delete(resourceID string) {
 	s.Lock()
 	defer s.Unlock()
 
-	delete(s.resources, resourceID)
+	last, ok := s.resources[resourceID]
+	if !ok {
+		return
+	}
+
+	if s.deletedRetention <= 0 {
+		s.remove(resourceID)
+		return
+	}
+
+	// the resource that is already deleted keeps its deletion timestamp, so its retention is not extended
+	if !last.DeletionTimestamp.IsZero() {
+		return
+	}
+
+	tombstone := *last
+	tombstone.DeletionTimestamp = &metav1.Time{Time: s.clock.Now()}
+	s.put(&tombstone)
 }
 
 func (s *MemoryStore) Get(resourceID string) (*Resource, error) {
@@ -108,10 +520,33 @@ func (s *MemoryStore) Get(resourceID string) (*Resource, error) {
 
 	resource, ok := s.resources[resourceID]
 	if !ok {
-		return nil, fmt.Errorf("failed to find resource %s", resourceID)
+		return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, resourceID)
+	}
+	if s.lru != nil {
+		s.lru.touch(resourceID)
 	}
 
-	return resource, nil
+	return resource.DeepCopy(), nil
+}
+
+// GetResourceAt returns the resource that is active at the given time, i.e. the last one that is written to the store
+// at or before the time. ErrResourceNotFound is returned if the resource is not written before the time or the
+// history of the time is not kept anymore, and ErrResourceHistoryNotKept is returned if the store does not keep the
+// history, see WithResourceHistory.
+func (s *MemoryStore) GetResourceAt(resourceID string, at time.Time) (*Resource, error) {
+	if s.history == nil {
+		return nil, ErrResourceHistoryNotKept
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	resource, ok := s.history.at(resourceID, at)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s at %s", ErrResourceNotFound, resourceID, at.Format(time.RFC3339Nano))
+	}
+
+	return resource.DeepCopy(), nil
 }
 
 func (s *MemoryStore) List(namespace string) []*Resource {
@@ -124,11 +559,74 @@ func (s *MemoryStore) List(namespace string) []*Resource {
 			continue
 		}
 
+		resources = append(resources, res.DeepCopy())
+	}
+	return resources
+}
+
+// ListBySource lists the copies of the resources whose sources match the filter from a snapshot of the store.
+func (s *MemoryStore) ListBySource(filter SourceFilter) []*Resource {
+	return s.ListBySourceAndCluster(filter, "")
+}
+
+// ListBySourceAndCluster lists the copies of the resources whose sources match the filter from a snapshot of the
+// store like ListBySource, the resources are scoped to the cluster if it is not empty. The resources are looked up
+// from the index of the sources, so the resources of the other sources and clusters are not scanned.
+func (s *MemoryStore) ListBySourceAndCluster(filter SourceFilter, clusterName string) []*Resource {
+	s.RLock()
+	resources := []*Resource{}
+	for source, clusters := range s.sourceIndex {
+		if !filter(source) {
+			continue
+		}
+
+		for cluster, ids := range clusters {
+			if len(clusterName) != 0 && cluster != clusterName {
+				continue
+			}
+
+			for id := range ids {
+				resources = append(resources, s.resources[id])
+			}
+		}
+	}
+	s.RUnlock()
+
+	for i, res := range resources {
+		resources[i] = res.DeepCopy()
+	}
+	return resources
+}
+
+// Snapshot returns the copies of all of the resources at a point in time. The store is only locked while the
+// resources are collected, they are copied after the lock is released, so the writers are not blocked by the copying.
+func (s *MemoryStore) Snapshot() []*Resource {
+	s.RLock()
+	resources := make([]*Resource, 0, len(s.resources))
+	for _, res := range s.resources {
 		resources = append(resources, res)
 	}
+	s.RUnlock()
+
+	for i, res := range resources {
+		resources[i] = res.DeepCopy()
+	}
 	return resources
 }
 
+// isApplied returns true if the resource has the same version, deletion timestamp and spec with the last one.
+func isApplied(last, resource *Resource) bool {
+	if last.ResourceVersion != resource.ResourceVersion {
+		return false
+	}
+
+	if !last.DeletionTimestamp.Equal(resource.DeletionTimestamp) {
+		return false
+	}
+
+	return equality.Semantic.DeepEqual(last.Spec, resource.Spec)
+}
+
 func (s *MemoryStore) GetResourceSpecChan() <-chan *Resource {
 	return s.resourceSpecChan
 }
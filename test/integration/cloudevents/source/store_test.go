@@ -0,0 +1,734 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	testingclock "k8s.io/utils/clock/testing"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/work/payload"
+)
+
+func TestUpSertBatch(t *testing.T) {
+	cases := []struct {
+		name              string
+		allOrNothing      bool
+		expectedResources int
+	}{
+		{
+			name:              "partial failures",
+			expectedResources: 2,
+		},
+		{
+			name:              "all or nothing",
+			allOrNothing:      true,
+			expectedResources: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryStore()
+
+			existing := NewResource("cluster1", "resource1")
+			existing.ResourceVersion = 5
+			if _, err := store.UpSert(context.Background(), existing); err != nil {
+				t.Fatal(err)
+			}
+
+			stale := NewResource("cluster1", "resource1")
+			stale.ResourceVersion = 3
+			results, errs := store.UpSertBatch(context.Background(),
+				[]*Resource{NewResource("cluster1", "resource2"), stale}, c.allOrNothing)
+
+			if !errors.Is(errs[1], ErrStaleResourceVersion) {
+				t.Errorf("expected stale resource version error, but got %v", errs[1])
+			}
+			if errs[0] != nil {
+				t.Errorf("unexpected error %v", errs[0])
+			}
+			if !c.allOrNothing && results[0] != ResourceCreated {
+				t.Errorf("expected the resource2 is created, but got %q", results[0])
+			}
+			if len(store.List("cluster1")) != c.expectedResources {
+				t.Errorf("expected %d resources, but got %d", c.expectedResources, len(store.List("cluster1")))
+			}
+		})
+	}
+}
+
+func TestUpSertWithDedupWindow(t *testing.T) {
+	fakeClock := testingclock.NewFakePassiveClock(time.Now())
+	store := NewMemoryStore(WithDedupWindow(time.Minute, 10), withClock(fakeClock))
+
+	res := NewResource("cluster1", "resource1")
+	res.EventID = "event1"
+
+	cases := []struct {
+		name           string
+		elapsed        time.Duration
+		expectedResult UpSertResult
+	}{
+		{
+			name:           "first publishing",
+			expectedResult: ResourceCreated,
+		},
+		{
+			name:           "retry within the window",
+			elapsed:        30 * time.Second,
+			expectedResult: ResourceUnchanged,
+		},
+		{
+			name:           "retry after the window",
+			elapsed:        time.Minute,
+			expectedResult: ResourceCreated,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeClock.SetTime(fakeClock.Now().Add(c.elapsed))
+
+			// the resource is deleted, so a retry that is not deduplicated creates it again
+			store.Delete(res.ResourceID)
+
+			result, err := store.UpSert(context.Background(), res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != c.expectedResult {
+				t.Errorf("expected result %q, but got %q", c.expectedResult, result)
+			}
+		})
+	}
+}
+
+func TestUpSertWithFullDedupWindow(t *testing.T) {
+	store := NewMemoryStore(WithDedupWindow(time.Minute, 2))
+
+	for i := 0; i < 3; i++ {
+		res := NewResource("cluster1", fmt.Sprintf("resource%d", i))
+		res.EventID = fmt.Sprintf("event%d", i)
+		if _, err := store.UpSert(context.Background(), res); err != nil {
+			t.Fatal(err)
+		}
+		store.Delete(res.ResourceID)
+	}
+
+	if len(store.dedup.ids) != 2 {
+		t.Errorf("expected 2 events are kept, but got %d", len(store.dedup.ids))
+	}
+
+	// the oldest event is evicted, so it is applied again
+	res := NewResource("cluster1", "resource0")
+	res.EventID = "event0"
+	result, err := store.UpSert(context.Background(), res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != ResourceCreated {
+		t.Errorf("expected result %q, but got %q", ResourceCreated, result)
+	}
+}
+
+func TestUpSertWithoutChanges(t *testing.T) {
+	store := newMemoryStore(NewEventBroadcaster())
+
+	notified := make(chan *Resource, 3)
+	go func() {
+		for res := range store.GetResourceSpecChan() {
+			notified <- res
+		}
+	}()
+
+	res := NewResource("cluster1", "resource1")
+	// the same resource is published twice with different versions, then its spec is changed
+	identical := withVersion(res, 2)
+	changed := withVersion(res, 3)
+	changed.Spec.Object["data"] = map[string]interface{}{"key": "value"}
+
+	for _, resource := range []*Resource{res, identical, changed} {
+		if _, err := store.UpSert(context.Background(), resource); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range []int64{1, 3} {
+		select {
+		case res := <-notified:
+			if res.ResourceVersion != expected {
+				t.Errorf("expected the notification of version %d, but got %d", expected, res.ResourceVersion)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected the notification of version %d, but got nothing", expected)
+		}
+	}
+	select {
+	case res := <-notified:
+		t.Errorf("unexpected notification of version %d", res.ResourceVersion)
+	default:
+	}
+
+	// the version is still committed
+	stored, err := store.Get(res.ResourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ResourceVersion != 3 {
+		t.Errorf("expected version 3, but got %d", stored.ResourceVersion)
+	}
+}
+
+func TestResourceEqual(t *testing.T) {
+	res := NewResource("cluster1", "resource1")
+	res.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue}}
+
+	cases := []struct {
+		name     string
+		other    func() *Resource
+		expected bool
+	}{
+		{
+			name:     "another version",
+			other:    func() *Resource { return withVersion(res, 2) },
+			expected: true,
+		},
+		{
+			name: "another spec",
+			other: func() *Resource {
+				other := res.DeepCopy()
+				other.Spec.Object["data"] = map[string]interface{}{"key": "value"}
+				return other
+			},
+		},
+		{
+			name: "another status",
+			other: func() *Resource {
+				other := res.DeepCopy()
+				other.Status.Conditions[0].Status = metav1.ConditionFalse
+				return other
+			},
+		},
+		{
+			name: "deleted",
+			other: func() *Resource {
+				other := res.DeepCopy()
+				other.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+				return other
+			},
+		},
+		{
+			name:  "nil",
+			other: func() *Resource { return nil },
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if equal := res.Equal(c.other()); equal != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, equal)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusWithInvalidConditions(t *testing.T) {
+	cases := []struct {
+		name          string
+		condition     metav1.Condition
+		expectedError string
+	}{
+		{
+			name:          "condition without type",
+			condition:     metav1.Condition{Status: metav1.ConditionTrue},
+			expectedError: "conditions[0].type is required",
+		},
+		{
+			name:          "condition with invalid status",
+			condition:     metav1.Condition{Type: "Applied", Status: "Yes"},
+			expectedError: `conditions[0].status "Yes" is invalid`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			res := NewResource("cluster1", "resource1")
+			if _, err := store.UpSert(context.Background(), res); err != nil {
+				t.Fatal(err)
+			}
+
+			err := store.UpdateStatus(&Resource{
+				ResourceID: res.ResourceID,
+				Status:     ResourceStatus{Conditions: []metav1.Condition{c.condition}},
+			})
+			if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+				t.Errorf("expected error %q, but got %v", c.expectedError, err)
+			}
+
+			stored, err := store.Get(res.ResourceID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored.Status.Conditions) != 0 {
+				t.Errorf("expected the status is not stored, but got %v", stored.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusWithObservedGeneration(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	res := NewResource("cluster1", "resource1")
+	if _, err := store.UpSert(ctx, res); err != nil {
+		t.Fatal(err)
+	}
+
+	// the observed generation is reported with the status
+	status := res.DeepCopy()
+	status.ObservedGeneration = 2
+	if err := store.UpdateStatus(status); err != nil {
+		t.Fatal(err)
+	}
+
+	// the status without the observed generation and the spec keep the last one, the status of the caller is not
+	// changed
+	status = res.DeepCopy()
+	if err := store.UpdateStatus(status); err != nil {
+		t.Fatal(err)
+	}
+	if status.ObservedGeneration != 0 {
+		t.Errorf("expected the status of the caller is not changed, but got the observed generation %d",
+			status.ObservedGeneration)
+	}
+	spec := res.DeepCopy()
+	spec.ResourceVersion = 2
+	if _, err := store.UpSert(ctx, spec); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := store.Get(res.ResourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ObservedGeneration != 2 {
+		t.Errorf("expected the observed generation 2, but got %d", stored.ObservedGeneration)
+	}
+}
+
+func TestStatusMergeStrategy(t *testing.T) {
+	updates := map[string]func(store *MemoryStore, res *Resource) error{
+		"update status": func(store *MemoryStore, res *Resource) error {
+			return store.UpdateStatus(res)
+		},
+		"upsert": func(store *MemoryStore, res *Resource) error {
+			res.ResourceVersion++
+			_, err := store.UpSert(context.Background(), res)
+			return err
+		},
+	}
+
+	cases := []struct {
+		name               string
+		opts               []MemoryStoreOption
+		expectedConditions map[string]metav1.ConditionStatus
+	}{
+		{
+			name: "replace status",
+			expectedConditions: map[string]metav1.ConditionStatus{
+				"Available": metav1.ConditionFalse,
+			},
+		},
+		{
+			name: "merge conditions",
+			opts: []MemoryStoreOption{WithStatusMergeStrategy(payload.ManifestEventDataType, MergeConditions)},
+			expectedConditions: map[string]metav1.ConditionStatus{
+				"Applied":   metav1.ConditionTrue,
+				"Available": metav1.ConditionFalse,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		for updateName, update := range updates {
+			t.Run(fmt.Sprintf("%s with %s", c.name, updateName), func(t *testing.T) {
+				store := NewMemoryStore(c.opts...)
+
+				res := NewResource("cluster1", "resource1")
+				res.Status.Conditions = []metav1.Condition{
+					{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied"},
+					{Type: "Available", Status: metav1.ConditionTrue, Reason: "Available"},
+				}
+				store.Add(res)
+
+				// the partial status only includes the Available condition
+				partial := NewResource("cluster1", "resource1")
+				partial.Status.Conditions = []metav1.Condition{
+					{Type: "Available", Status: metav1.ConditionFalse, Reason: "Unavailable"},
+				}
+				if err := update(store, partial); err != nil {
+					t.Fatal(err)
+				}
+
+				stored, err := store.Get(res.ResourceID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				conditions := map[string]metav1.ConditionStatus{}
+				for _, cond := range stored.Status.Conditions {
+					conditions[cond.Type] = cond.Status
+				}
+				if !reflect.DeepEqual(conditions, c.expectedConditions) {
+					t.Errorf("expected conditions %v, but got %v", c.expectedConditions, conditions)
+				}
+			})
+		}
+	}
+}
+
+func TestEvictDeletedResources(t *testing.T) {
+	fakeClock := testingclock.NewFakePassiveClock(time.Now())
+	store := NewMemoryStore(WithDeletedResourceRetention(time.Minute), withClock(fakeClock))
+
+	store.Add(NewResource("cluster1", "resource1"))
+	deleted := NewResource("cluster1", "resource2")
+	deleted.DeletionTimestamp = &metav1.Time{Time: fakeClock.Now()}
+	store.Add(deleted)
+
+	// the deleted resource is kept within the retention
+	fakeClock.SetTime(fakeClock.Now().Add(30 * time.Second))
+	store.Evict()
+	if _, err := store.Get(deleted.ResourceID); err != nil {
+		t.Errorf("expected the deleted resource is kept, but got %v", err)
+	}
+
+	fakeClock.SetTime(fakeClock.Now().Add(time.Minute))
+	store.Evict()
+	if _, err := store.Get(deleted.ResourceID); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("expected the deleted resource is evicted, but got %v", err)
+	}
+	if _, err := store.Get(ResourceID("cluster1", "resource1")); err != nil {
+		t.Errorf("expected the resource is kept, but got %v", err)
+	}
+}
+
+func TestDeleteWithTombstone(t *testing.T) {
+	fakeClock := testingclock.NewFakePassiveClock(time.Now().Truncate(time.Second))
+
+	cases := []struct {
+		name              string
+		opts              []MemoryStoreOption
+		expectedTombstone bool
+	}{
+		{
+			name: "removed without retention",
+		},
+		{
+			name:              "tombstone within retention",
+			opts:              []MemoryStoreOption{WithDeletedResourceRetention(time.Minute), withClock(fakeClock)},
+			expectedTombstone: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryStore(c.opts...)
+			res := NewResource("cluster1", "resource1")
+			store.Add(res)
+			store.Delete(res.ResourceID)
+
+			tombstone, err := store.Get(res.ResourceID)
+			if !c.expectedTombstone {
+				if !errors.Is(err, ErrResourceNotFound) {
+					t.Errorf("expected the resource is removed, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tombstone.DeletionTimestamp.Time.Equal(fakeClock.Now()) {
+				t.Errorf("expected the deletion timestamp %v, but got %v", fakeClock.Now(), tombstone.DeletionTimestamp)
+			}
+
+			// deleting the tombstone again does not extend its retention
+			fakeClock.SetTime(fakeClock.Now().Add(30 * time.Second))
+			store.Delete(res.ResourceID)
+			fakeClock.SetTime(fakeClock.Now().Add(30 * time.Second))
+			store.Evict()
+			if _, err := store.Get(res.ResourceID); !errors.Is(err, ErrResourceNotFound) {
+				t.Errorf("expected the tombstone is evicted, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestGetResourceAt(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+	fakeClock := testingclock.NewFakePassiveClock(start)
+	store := NewMemoryStore(WithResourceHistory(3), withClock(fakeClock))
+
+	// the versions 1 to 4 are written a minute apart, the version 1 is not kept in the history of 3 versions
+	res := NewResource("cluster1", "resource1")
+	for version := int64(1); version <= 4; version++ {
+		fakeClock.SetTime(start.Add(time.Duration(version) * time.Minute))
+		written := res.DeepCopy()
+		written.ResourceVersion = version
+		written.Spec.Object["data"] = map[string]interface{}{"version": version}
+		if _, err := store.UpSert(context.Background(), written); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name            string
+		at              time.Time
+		expectedVersion int64
+		expectedErr     error
+	}{
+		{
+			name:        "before the first version",
+			at:          start,
+			expectedErr: ErrResourceNotFound,
+		},
+		{
+			name:        "version out of the history",
+			at:          start.Add(90 * time.Second),
+			expectedErr: ErrResourceNotFound,
+		},
+		{
+			name:            "at the time of a version",
+			at:              start.Add(2 * time.Minute),
+			expectedVersion: 2,
+		},
+		{
+			name:            "between two versions",
+			at:              start.Add(3*time.Minute + 30*time.Second),
+			expectedVersion: 3,
+		},
+		{
+			name:            "after the last version",
+			at:              start.Add(time.Hour),
+			expectedVersion: 4,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := store.GetResourceAt(res.ResourceID, c.at)
+			if c.expectedErr != nil {
+				if !errors.Is(err, c.expectedErr) {
+					t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.ResourceVersion != c.expectedVersion {
+				t.Errorf("expected version %d, but got %d", c.expectedVersion, got.ResourceVersion)
+			}
+			data, _ := got.Spec.Object["data"].(map[string]interface{})
+			if data["version"] != c.expectedVersion {
+				t.Errorf("expected the spec of version %d, but got %v", c.expectedVersion, got.Spec.Object["data"])
+			}
+		})
+	}
+
+	// the history is removed with the resource
+	store.Delete(res.ResourceID)
+	if _, err := store.GetResourceAt(res.ResourceID, start.Add(time.Hour)); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("expected the history is removed, but got %v", err)
+	}
+
+	if _, err := NewMemoryStore().GetResourceAt(res.ResourceID, start); !errors.Is(err, ErrResourceHistoryNotKept) {
+		t.Errorf("expected the history is not kept, but got %v", err)
+	}
+}
+
+func TestEvictWithMaxResources(t *testing.T) {
+	store := NewMemoryStore(WithMaxResources(2))
+
+	store.Add(NewResource("cluster1", "resource1"))
+	store.Add(NewResource("cluster1", "resource2"))
+
+	// the resource1 is used, so the resource2 is the least recently used one
+	if _, err := store.Get(ResourceID("cluster1", "resource1")); err != nil {
+		t.Fatal(err)
+	}
+	store.Add(NewResource("cluster1", "resource3"))
+
+	for name, expectedErr := range map[string]error{
+		"resource1": nil,
+		"resource2": ErrResourceNotFound,
+		"resource3": nil,
+	} {
+		if _, err := store.Get(ResourceID("cluster1", name)); !errors.Is(err, expectedErr) {
+			t.Errorf("expected error %v for %s, but got %v", expectedErr, name, err)
+		}
+	}
+}
+
+func TestListBySourceAndCluster(t *testing.T) {
+	store := NewMemoryStore(WithMaxResources(5))
+	newResource := func(source, cluster, name string) *Resource {
+		res := NewResource(cluster, name)
+		res.Source = source
+		return res
+	}
+
+	for _, res := range []*Resource{
+		newResource("source1", "cluster1", "resource1"),
+		newResource("source1", "cluster2", "resource2"),
+		newResource("source2", "cluster1", "resource3"),
+		newResource("source2", "cluster2", "resource4"),
+	} {
+		if _, err := store.UpSert(context.Background(), res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Add(newResource("source3", "cluster1", "resource5"))
+
+	// the resource3 is moved to the source1 and the resource4 is deleted, so the source2 has no resources
+	if err := store.Update(newResource("source1", "cluster1", "resource3")); err != nil {
+		t.Fatal(err)
+	}
+	store.Delete(ResourceID("cluster2", "resource4"))
+
+	// the resource1 is the least recently used one, it is evicted
+	for _, res := range []*Resource{
+		newResource("source3", "cluster1", "resource6"),
+		newResource("source3", "cluster2", "resource7"),
+	} {
+		if _, err := store.UpSert(context.Background(), res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name        string
+		source      string
+		clusterName string
+		expected    []string
+	}{
+		{
+			name:     "all clusters of a source",
+			source:   "source1",
+			expected: []string{ResourceID("cluster1", "resource3"), ResourceID("cluster2", "resource2")},
+		},
+		{
+			name:        "a cluster of a source",
+			source:      "source1",
+			clusterName: "cluster1",
+			expected:    []string{ResourceID("cluster1", "resource3")},
+		},
+		{
+			name:   "source without resources",
+			source: "source2",
+		},
+		{
+			name:        "a cluster of the matched sources",
+			source:      "source*",
+			clusterName: "cluster2",
+			expected:    []string{ResourceID("cluster2", "resource2"), ResourceID("cluster2", "resource7")},
+		},
+		{
+			name:        "cluster without resources",
+			source:      "source3",
+			clusterName: "cluster3",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ids := sets.New[string]()
+			for _, res := range store.ListBySourceAndCluster(mustSourceFilter(t, c.source), c.clusterName) {
+				ids.Insert(res.ResourceID)
+			}
+			if !ids.Equal(sets.New(c.expected...)) {
+				t.Errorf("expected resources %v, but got %v", c.expected, sets.List(ids))
+			}
+		})
+	}
+
+	// the index does not keep the sources without resources
+	store.RLock()
+	defer store.RUnlock()
+	if _, ok := store.sourceIndex["source2"]; ok {
+		t.Errorf("expected the source2 is removed from the index")
+	}
+}
+
+func TestSnapshotWithConcurrentWrites(t *testing.T) {
+	store := NewMemoryStore()
+
+	const writers, versions = 4, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			for version := int64(1); version <= versions; version++ {
+				res := NewResource("cluster1", name)
+				res.ResourceVersion = version
+				if _, err := store.UpSert(context.Background(), res); err != nil {
+					t.Errorf("unexpected error %v", err)
+					return
+				}
+
+				res.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue}}
+				if err := store.UpdateStatus(res); err != nil {
+					t.Errorf("unexpected error %v", err)
+					return
+				}
+			}
+		}(fmt.Sprintf("resource%d", i))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for stop := false; !stop; {
+		select {
+		case <-done:
+			stop = true
+		default:
+		}
+
+		for _, res := range store.Snapshot() {
+			if res.ResourceVersion < 1 || res.ResourceVersion > versions {
+				t.Fatalf("unexpected resource version %d", res.ResourceVersion)
+			}
+
+			// the snapshot is a copy, changing it does not change the store
+			res.Status.Conditions = append(res.Status.Conditions, metav1.Condition{Type: "Changed"})
+		}
+	}
+
+	snapshot := store.Snapshot()
+	if len(snapshot) != writers {
+		t.Fatalf("expected %d resources, but got %d", writers, len(snapshot))
+	}
+	for _, res := range snapshot {
+		if res.ResourceVersion != versions {
+			t.Errorf("expected resource version %d, but got %d", versions, res.ResourceVersion)
+		}
+		if len(res.Status.Conditions) != 1 {
+			t.Errorf("expected the stored conditions are not changed, but got %v", res.Status.Conditions)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package source
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/cert"
+)
+
+// ClientCertificateError is returned by the TLS handshake when a client certificate is rejected, e.g. the
+// certificate is expired, is not signed by a trusted CA or its identity is not in the allowed client list.
+type ClientCertificateError struct {
+	// CommonName is the common name of the rejected client certificate, it is empty if the client does
+	// not provide a certificate.
+	CommonName string
+
+	// Reason describes why the client certificate is rejected.
+	Reason string
+
+	// Err is the underlying verification error, if any.
+	Err error
+}
+
+func (e *ClientCertificateError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("client certificate %q is rejected, %s: %v", e.CommonName, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("client certificate %q is rejected, %s", e.CommonName, e.Reason)
+}
+
+func (e *ClientCertificateError) Unwrap() error {
+	return e.Err
+}
+
+// tlsConfig returns the TLS config that the server is started with, the serving certificate is loaded from the serving
+// cert files if they are specified.
+func (svr *GRPCServer) tlsConfig(tlsConfig *tls.Config) (*tls.Config, error) {
+	config := serverTLSConfig(tlsConfig, svr.allowedClients)
+	if len(svr.servingCertFile) == 0 {
+		return config, nil
+	}
+
+	loadCertificate := cert.CachingCertificateLoader(svr.servingCertFile, svr.servingKeyFile)
+	if _, err := loadCertificate(); err != nil {
+		return nil, fmt.Errorf("failed to load the serving certificate: %v", err)
+	}
+
+	// the GetCertificate is only called if there is no certificate in the config
+	config.Certificates = nil
+	config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return loadCertificate()
+	}
+	return config, nil
+}
+
+// serverTLSConfig returns a copy of the given TLS config for the grpc server. If the config has the client CAs,
+// the mutual TLS is required, the client certificate will be verified against the client CAs by the server and the
+// common name or the subject alternative names of the client certificate must be in the allowed client names, if
+// the allowed client names are specified.
+func serverTLSConfig(tlsConfig *tls.Config, allowedClients sets.Set[string]) *tls.Config {
+	config := tlsConfig.Clone()
+	if config.ClientCAs == nil {
+		return config
+	}
+
+	// the client certificate is verified by ourselves, so that a typed error can be surfaced.
+	clientCAs := config.ClientCAs
+	config.ClientAuth = tls.RequireAnyClientCert
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		return verifyClientCertificate(clientCAs, allowedClients, state)
+	}
+	return config
+}
+
+func verifyClientCertificate(clientCAs *x509.CertPool, allowedClients sets.Set[string], state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return &ClientCertificateError{Reason: "no client certificate is provided"}
+	}
+
+	clientCert := state.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := clientCert.Verify(x509.VerifyOptions{
+		Roots:         clientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return &ClientCertificateError{
+			CommonName: clientCert.Subject.CommonName,
+			Reason:     "failed to verify the certificate",
+			Err:        err,
+		}
+	}
+
+	if allowedClients.Len() == 0 {
+		return nil
+	}
+
+	if allowedClients.Has(clientCert.Subject.CommonName) || allowedClients.HasAny(clientCert.DNSNames...) {
+		return nil
+	}
+
+	return &ClientCertificateError{
+		CommonName: clientCert.Subject.CommonName,
+		Reason:     "the client is not allowed",
+	}
+}
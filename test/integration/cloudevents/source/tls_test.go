@@ -0,0 +1,298 @@
+package source
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	certutil "k8s.io/client-go/util/cert"
+
+	"open-cluster-management.io/sdk-go/test/integration/cloudevents/util"
+)
+
+func TestStartTLS(t *testing.T) {
+	serverCertPairs, err := util.NewServerCertPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	untrustedCertPairs, err := util.NewServerCertPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(serverCertPairs.CA)
+
+	cases := []struct {
+		name           string
+		allowedClients []string
+		clientCA       *util.ServerCertPairs
+		expectedErr    bool
+	}{
+		{
+			name:           "mtls handshake succeeded",
+			allowedClients: []string{"test-client"},
+			clientCA:       serverCertPairs,
+		},
+		{
+			name:     "mtls handshake succeeded without allowed clients",
+			clientCA: serverCertPairs,
+		},
+		{
+			name:           "untrusted client",
+			allowedClients: []string{"test-client"},
+			clientCA:       untrustedCertPairs,
+			expectedErr:    true,
+		},
+		{
+			name:           "client is not allowed",
+			allowedClients: []string{"another-client"},
+			clientCA:       serverCertPairs,
+			expectedErr:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svr := NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(), WithAllowedClients(c.allowedClients...))
+			tlsConfig := serverTLSConfig(&tls.Config{
+				ClientCAs:    caPool,
+				Certificates: []tls.Certificate{serverCertPairs.ServerTLSCert},
+			}, svr.allowedClients)
+			go func() {
+				_ = svr.serve(context.Background(), lis, grpc.Creds(credentials.NewTLS(tlsConfig)))
+			}()
+			defer svr.Stop(context.Background())
+
+			clientCertPairs, err := util.SignClientCert(c.clientCA.CA, c.clientCA.CAKey, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clientCert, err := tls.X509KeyPair(clientCertPairs.ClientCert, clientCertPairs.ClientKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := grpc.DialContext(ctx, lis.Addr().String(),
+				grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+					RootCAs:      caPool,
+					Certificates: []tls.Certificate{clientCert},
+				})),
+				grpc.WithBlock(),
+				grpc.WithReturnConnectionError(),
+			)
+			if c.expectedErr {
+				if err == nil {
+					conn.Close()
+					t.Errorf("expected error, but failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+				return
+			}
+			conn.Close()
+		})
+	}
+}
+
+func TestStartTLSWithServingCertFiles(t *testing.T) {
+	oldCertPairs, err := util.NewServerCertPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCertPairs, err := util.NewServerCertPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeServingCertFiles(t, oldCertPairs.ServerTLSCert, certFile, keyFile)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr := NewGRPCServer(NewMemoryStore(), NewEventBroadcaster(), WithServingCertFiles(certFile, keyFile))
+	tlsConfig, err := svr.tlsConfig(&tls.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = svr.serve(context.Background(), lis, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}()
+	defer svr.Stop(context.Background())
+
+	// the client trusts both of the certificates
+	caPool := x509.NewCertPool()
+	caPool.AddCert(oldCertPairs.CA)
+	caPool.AddCert(newCertPairs.CA)
+
+	oldConn, serial := dialServingCert(t, lis.Addr().String(), caPool)
+	defer oldConn.Close()
+	if serial != oldCertPairs.CA.SerialNumber.String() {
+		t.Fatalf("expected the certificate issued by %s, but got %s", oldCertPairs.CA.SerialNumber, serial)
+	}
+
+	writeServingCertFiles(t, newCertPairs.ServerTLSCert, certFile, keyFile)
+
+	// the certificate files are reloaded by the new handshakes once the cached certificate is stale
+	err = wait.PollUntilContextTimeout(context.Background(), 100*time.Millisecond, 5*time.Second, true,
+		func(ctx context.Context) (bool, error) {
+			conn, serial := dialServingCert(t, lis.Addr().String(), caPool)
+			conn.Close()
+			return serial == newCertPairs.CA.SerialNumber.String(), nil
+		})
+	if err != nil {
+		t.Fatalf("expected the new certificate is served, %v", err)
+	}
+
+	// the existing connection keeps working
+	if _, err := healthpb.NewHealthClient(oldConn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// writeServingCertFiles writes the certificate and the key to the files.
+func writeServingCertFiles(t *testing.T, tlsCert tls.Certificate, certFile, keyFile string) {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: certutil.CertificateBlockType, Bytes: tlsCert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  util.RSAPrivateKeyBlockType,
+		Bytes: x509.MarshalPKCS1PrivateKey(tlsCert.PrivateKey.(*rsa.PrivateKey)),
+	})
+
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dialServingCert connects to the server and returns the connection and the serial number of the CA that issues the
+// serving certificate.
+func dialServingCert(t *testing.T, addr string, caPool *x509.CertPool) (*grpc.ClientConn, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var serial string
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			RootCAs: caPool,
+			VerifyConnection: func(state tls.ConnectionState) error {
+				serial = state.VerifiedChains[0][1].SerialNumber.String()
+				return nil
+			},
+		})),
+		grpc.WithBlock(),
+		grpc.WithReturnConnectionError(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn, serial
+}
+
+func TestVerifyClientCertificate(t *testing.T) {
+	serverCertPairs, err := util.NewServerCertPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(serverCertPairs.CA)
+
+	cases := []struct {
+		name           string
+		duration       time.Duration
+		allowedClients sets.Set[string]
+		noClientCert   bool
+		expectedReason string
+	}{
+		{
+			name:           "valid client certificate",
+			duration:       time.Hour,
+			allowedClients: sets.New[string]("test-client"),
+		},
+		{
+			name:           "expired client certificate",
+			duration:       -time.Hour,
+			allowedClients: sets.New[string]("test-client"),
+			expectedReason: "failed to verify the certificate",
+		},
+		{
+			name:           "no client certificate",
+			noClientCert:   true,
+			allowedClients: sets.New[string](),
+			expectedReason: "no client certificate is provided",
+		},
+		{
+			name:           "client is not allowed",
+			duration:       time.Hour,
+			allowedClients: sets.New[string]("another-client"),
+			expectedReason: "the client is not allowed",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			state := tls.ConnectionState{}
+			if !c.noClientCert {
+				clientCertPairs, err := util.SignClientCert(serverCertPairs.CA, serverCertPairs.CAKey, c.duration)
+				if err != nil {
+					t.Fatal(err)
+				}
+				clientCert, err := tls.X509KeyPair(clientCertPairs.ClientCert, clientCertPairs.ClientKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cert, err := x509.ParseCertificate(clientCert.Certificate[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				state.PeerCertificates = []*x509.Certificate{cert}
+			}
+
+			err := verifyClientCertificate(caPool, c.allowedClients, state)
+			if len(c.expectedReason) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+
+			var certErr *ClientCertificateError
+			if !errors.As(err, &certErr) {
+				t.Fatalf("expected ClientCertificateError, but got %v", err)
+			}
+			if certErr.Reason != c.expectedReason {
+				t.Errorf("expected reason %q, but got %q", c.expectedReason, certErr.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package source
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/extensions"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "open-cluster-management.io/sdk-go/test/integration/cloudevents/source"
+
+const (
+	// resourceIDAttribute and dataTypeAttribute are the attributes of the spans of the publishes and the deliveries.
+	resourceIDAttribute = "cloudevents.resource_id"
+	dataTypeAttribute   = "cloudevents.data_type"
+)
+
+// traceContext propagates the trace context in the W3C format, which is required by the distributed tracing
+// extension of the cloudevents.
+var traceContext = propagation.TraceContext{}
+
+// eventCarrier carries the trace context in the distributed tracing extension of a cloudevent.
+type eventCarrier struct {
+	evt *cloudevents.Event
+}
+
+var _ propagation.TextMapCarrier = eventCarrier{}
+
+func (c eventCarrier) Get(key string) string {
+	value, ok := c.evt.Extensions()[key]
+	if !ok {
+		return ""
+	}
+
+	str, err := cloudeventstypes.ToString(value)
+	if err != nil {
+		return ""
+	}
+	return str
+}
+
+func (c eventCarrier) Set(key, value string) {
+	c.evt.SetExtension(key, value)
+}
+
+func (c eventCarrier) Keys() []string {
+	return []string{extensions.TraceParentExtension, extensions.TraceStateExtension}
+}
+
+// resourceCarrier carries the trace context of the last publish of a resource.
+type resourceCarrier struct {
+	res *Resource
+}
+
+var _ propagation.TextMapCarrier = resourceCarrier{}
+
+func (c resourceCarrier) Get(key string) string {
+	switch key {
+	case extensions.TraceParentExtension:
+		return c.res.TraceParent
+	case extensions.TraceStateExtension:
+		return c.res.TraceState
+	default:
+		return ""
+	}
+}
+
+func (c resourceCarrier) Set(key, value string) {
+	switch key {
+	case extensions.TraceParentExtension:
+		c.res.TraceParent = value
+	case extensions.TraceStateExtension:
+		c.res.TraceState = value
+	}
+}
+
+func (c resourceCarrier) Keys() []string {
+	return []string{extensions.TraceParentExtension, extensions.TraceStateExtension}
+}
+
+// startPublishSpan starts the span of publishing the cloudevent, the span is a child of the trace context in the
+// distributed tracing extension of the cloudevent if it has one.
+func (svr *GRPCServer) startPublishSpan(ctx context.Context, evt *cloudevents.Event) (context.Context, trace.Span) {
+	return svr.tracer.Start(traceContext.Extract(ctx, eventCarrier{evt}), "Publish",
+		trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// startDeliverySpan starts the span of delivering the status event of the resource to a subscriber, the span is a
+// child of the span of the last publish of the resource, so a publish is linked to the deliveries that follow it.
+func (svr *GRPCServer) startDeliverySpan(res *Resource) (context.Context, trace.Span) {
+	return svr.tracer.Start(traceContext.Extract(context.Background(), resourceCarrier{res}), "Deliver",
+		trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(resourceAttributes(res)...))
+}
+
+func resourceAttributes(res *Resource) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String(resourceIDAttribute, res.ResourceID),
+		attribute.String(dataTypeAttribute, resourceDataType(res).String()),
+	}
+}
+
+// endSpan ends the span with the error status if the operation is failed.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,132 @@
+package source
+
+import (
+	"context"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	grpcprotocol "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protocol"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/work/payload"
+)
+
+func TestTracePublishToDelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	store := newMemoryStore(eventBroadcaster)
+	go func() {
+		for range store.GetResourceSpecChan() {
+		}
+	}()
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster, WithTracerProvider(provider)))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func() *cloudevents.Event {
+		pbEvt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+	if evt := recv(); evt.Type() != SnapshotDoneEventType.String() {
+		t.Fatalf("expected snapshot done event, but got %s", evt.Type())
+	}
+
+	// the published event carries the trace context of the caller
+	rootCtx, root := provider.Tracer("test").Start(ctx, "root")
+	res := NewResource("cluster1", "resource1")
+	evt, err := (&ResourceCodec{}).Encode("test-source", testSpecEventType, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	traceContext.Inject(rootCtx, eventCarrier{evt})
+	pbEvt := &pbv1.CloudEvent{}
+	if err := grpcprotocol.WritePBMessage(ctx, binding.ToMessage(evt), pbEvt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Publish(ctx, &pbv1.PublishRequest{Event: pbEvt}); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+
+	// the status is reported without the trace context
+	status := NewResource("cluster1", "resource1")
+	status.Source = "test-source"
+	status.Status.Conditions = []metav1.Condition{{Type: "Applied", Status: metav1.ConditionTrue, Reason: "Applied"}}
+	if err := store.UpdateStatus(status); err != nil {
+		t.Fatal(err)
+	}
+	delivered := recv()
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	publish, ok := spans["Publish"]
+	if !ok {
+		t.Fatalf("expected the publish span, but got %v", spans)
+	}
+	deliver, ok := spans["Deliver"]
+	if !ok {
+		t.Fatalf("expected the deliver span, but got %v", spans)
+	}
+
+	if publish.Parent.SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("expected the publish span is a child of %s, but got %s", root.SpanContext().SpanID(), publish.Parent.SpanID())
+	}
+	if deliver.Parent.SpanID() != publish.SpanContext.SpanID() {
+		t.Errorf("expected the deliver span is a child of %s, but got %s", publish.SpanContext.SpanID(), deliver.Parent.SpanID())
+	}
+	if deliver.SpanContext.TraceID() != root.SpanContext().TraceID() {
+		t.Errorf("expected the deliver span is in the trace %s, but got %s", root.SpanContext().TraceID(), deliver.SpanContext.TraceID())
+	}
+
+	expectedAttributes := []attribute.KeyValue{
+		attribute.String(resourceIDAttribute, res.ResourceID),
+		attribute.String(dataTypeAttribute, payload.ManifestEventDataType.String()),
+	}
+	for _, span := range []tracetest.SpanStub{publish, deliver} {
+		for _, expected := range expectedAttributes {
+			if !hasAttribute(span.Attributes, expected) {
+				t.Errorf("expected the %s span has the attribute %s=%s, but got %v",
+					span.Name, expected.Key, expected.Value.Emit(), span.Attributes)
+			}
+		}
+	}
+
+	// the subscriber continues the trace of the delivery
+	deliveredCtx := trace.SpanContextFromContext(traceContext.Extract(ctx, eventCarrier{delivered}))
+	if deliveredCtx.SpanID() != deliver.SpanContext.SpanID() {
+		t.Errorf("expected the delivered event carries the span %s, but got %s", deliver.SpanContext.SpanID(), deliveredCtx.SpanID())
+	}
+}
+
+func hasAttribute(attributes []attribute.KeyValue, expected attribute.KeyValue) bool {
+	for _, attr := range attributes {
+		if attr == expected {
+			return true
+		}
+	}
+	return false
+}
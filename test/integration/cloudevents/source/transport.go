@@ -0,0 +1,46 @@
+package source
+
+import (
+	"context"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// Transport serves the sources of a GRPCServer over a protocol, the sources publish the resource specs and receive the
+// resource statuses through it. The cloudevents are decoded and encoded with the codecs of the server whichever
+// transport is used, so the transports are interchangeable.
+type Transport interface {
+	// Serve serves the sources with the server until the context is done.
+	Serve(ctx context.Context, svr *GRPCServer) error
+}
+
+// GRPCTransport serves the sources over the grpc protocol on the address.
+type GRPCTransport struct {
+	Address string
+}
+
+var _ Transport = &GRPCTransport{}
+
+func (t *GRPCTransport) Serve(ctx context.Context, svr *GRPCServer) error {
+	return svr.Start(ctx, t.Address)
+}
+
+// publishReceived publishes an event that is received by a transport, the failure is logged and the rejected event is
+// retained in the dead letter store of the server if it has one.
+func (svr *GRPCServer) publishReceived(ctx context.Context, evt cloudevents.Event) {
+	// the event is kept as it is received, since it may be changed by the publish, e.g. its type namespace is stripped
+	var received *cloudevents.Event
+	if svr.deadLetters != nil {
+		copied := evt.Clone()
+		received = &copied
+	}
+
+	if _, _, err := svr.publish(ctx, &evt, false); err != nil {
+		svr.logger.Error(err, "failed to publish the resource", "source", evt.Source(), "eventID", evt.ID())
+		if received != nil {
+			// the event is retained without its protobuf form if it cannot be converted
+			pbEvt, _ := toPBEvent(received)
+			svr.addDeadLetter(pbEvt, err)
+		}
+	}
+}
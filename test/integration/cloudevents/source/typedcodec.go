@@ -0,0 +1,90 @@
+package source
+
+import (
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/work/payload"
+)
+
+// TypedCodec is the codec of a data type whose specs are the objects of the type T, e.g. a struct of an OCM resource
+// other than the manifests, so the server serves the data type without a hand-written codec. The event data of a
+// published cloudevent is decoded as a T and kept in the Spec of the resource in its unstructured form, so the
+// resources of all data types are stored, filtered and broadcast in the same way, and they are read and written as the
+// TypedResource[T] with a TypedStore[T]. The data of a status event is the status conditions and the spec of the
+// resource, the event is encoded like the status events of the manifests, e.g. with the source, the clock, the status
+// event type and the content type and encoding of the server.
+type TypedCodec[T any] struct {
+	statusEncoding
+	dataType types.CloudEventsDataType
+}
+
+var _ Codec = &TypedCodec[struct{}]{}
+
+// typedStatusData is the data of the status events of the TypedCodec.
+type typedStatusData[T any] struct {
+	payload.ManifestStatus
+	Spec *T `json:"spec,omitempty"`
+}
+
+// WithTypedCodec registers the TypedCodec[T] of the data type whose specs are the objects of the type T, the T must be
+// a struct that can be converted to and from the unstructured object. The codec is built once all of the options are
+// applied, so its status events are encoded with the options of the server whatever the order of the options, and it
+// replaces a codec of the same data type that is registered by WithCodecs.
+func WithTypedCodec[T any](dataType types.CloudEventsDataType) GRPCServerOption {
+	return func(svr *GRPCServer) {
+		svr.codecBuilders = append(svr.codecBuilders, func(encoding statusEncoding) Codec {
+			return &TypedCodec[T]{statusEncoding: encoding, dataType: dataType}
+		})
+	}
+}
+
+func (c *TypedCodec[T]) EventDataType() types.CloudEventsDataType {
+	return c.dataType
+}
+
+func (c *TypedCodec[T]) Encode(resource *Resource) (*cloudevents.Event, error) {
+	spec, err := SpecAs[T](resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.encodeStatus(c.dataType, resource, &typedStatusData[T]{
+		ManifestStatus: payload.ManifestStatus{Conditions: resource.Status.Conditions},
+		Spec:           spec,
+	})
+}
+
+func (c *TypedCodec[T]) Decode(evt *cloudevents.Event) (*Resource, error) {
+	resource, err := decodeResource(evt, c.dataType)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := new(T)
+	if err := eventDataAs(evt, spec); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data %s, %v", string(evt.Data()), err)
+	}
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s spec: %v", c.dataType, err)
+	}
+	resource.Spec.Object = object
+
+	return resource, nil
+}
+
+// SpecAs converts the spec of the resource to an object of the type T, e.g. the spec of a resource that is decoded by
+// a TypedCodec[T].
+func SpecAs[T any](resource *Resource) (*T, error) {
+	spec := new(T)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.Spec.Object, spec); err != nil {
+		return nil, fmt.Errorf("failed to convert the spec of resource %s: %v", resource.ResourceID, err)
+	}
+
+	return spec, nil
+}
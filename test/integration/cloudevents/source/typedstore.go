@@ -0,0 +1,105 @@
+package source
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+// TypedResource is a resource whose spec is an object of the type T, e.g. a resource of the data type of a
+// TypedCodec[T]. The metadata and the status are the ones of the Resource, the spec is the TypedSpec rather than the
+// unstructured Spec of the Resource.
+type TypedResource[T any] struct {
+	*Resource
+
+	// TypedSpec is the spec of the resource.
+	TypedSpec *T
+}
+
+// NewTypedResource returns the typed resource of a copy of the resource, its spec is converted to the type T.
+func NewTypedResource[T any](resource *Resource) (*TypedResource[T], error) {
+	spec, err := SpecAs[T](resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedResource[T]{Resource: resource.DeepCopy(), TypedSpec: spec}, nil
+}
+
+// ToResource returns a copy of the resource whose unstructured spec is converted from the TypedSpec.
+func (r *TypedResource[T]) ToResource() (*Resource, error) {
+	resource := r.Resource.DeepCopy()
+	if r.TypedSpec == nil {
+		resource.Spec.Object = nil
+		return resource, nil
+	}
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r.TypedSpec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the spec of resource %s: %v", r.ResourceID, err)
+	}
+	resource.Spec.Object = object
+
+	return resource, nil
+}
+
+// TypedStore reads and writes the resources of a data type whose specs are the objects of the type T, e.g. the data
+// type of a TypedCodec[T], as the TypedResource[T] in a store. It is a Store too, so it is the store of the server,
+// and the resources of the other data types are still read and written with the methods of the Store.
+type TypedStore[T any] struct {
+	Store
+
+	dataType types.CloudEventsDataType
+}
+
+// NewTypedStore returns the typed store of the resources of the data type in the store, e.g. a MemoryStore.
+func NewTypedStore[T any](store Store, dataType types.CloudEventsDataType) *TypedStore[T] {
+	return &TypedStore[T]{Store: store, dataType: dataType}
+}
+
+// GetTyped returns the typed resource by its ID, ErrResourceNotFound is returned if the resource does not exist and
+// ErrUnsupportedDataType if the resource is not of the data type of the store.
+func (s *TypedStore[T]) GetTyped(resourceID string) (*TypedResource[T], error) {
+	resource, err := s.Get(resourceID)
+	if err != nil {
+		return nil, err
+	}
+	if resource.DataType != s.dataType {
+		return nil, fmt.Errorf("%w %s of resource %s", ErrUnsupportedDataType, resource.DataType, resourceID)
+	}
+
+	return NewTypedResource[T](resource)
+}
+
+// ListTyped lists the typed resources of the data type of the store like ListBySourceAndCluster, the resources of the
+// other data types are skipped.
+func (s *TypedStore[T]) ListTyped(filter SourceFilter, clusterName string) ([]*TypedResource[T], error) {
+	typed := []*TypedResource[T]{}
+	for _, resource := range s.ListBySourceAndCluster(filter, clusterName) {
+		if resource.DataType != s.dataType {
+			continue
+		}
+
+		typedResource, err := NewTypedResource[T](resource)
+		if err != nil {
+			return nil, err
+		}
+		typed = append(typed, typedResource)
+	}
+
+	return typed, nil
+}
+
+// UpSertTyped creates or updates the typed resource like UpSert, the resource is of the data type of the store.
+func (s *TypedStore[T]) UpSertTyped(ctx context.Context, typed *TypedResource[T]) (UpSertResult, error) {
+	resource, err := typed.ToResource()
+	if err != nil {
+		return "", err
+	}
+	resource.DataType = s.dataType
+
+	return s.UpSert(ctx, resource)
+}
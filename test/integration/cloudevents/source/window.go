@@ -0,0 +1,96 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// sendWindow bounds the events that are sent to a subscriber ahead of its acknowledgements, a send waits until the
+// window has room. The count of the acknowledgements is the total of the received events, so it never goes back.
+type sendWindow struct {
+	size uint64
+
+	mu    sync.Mutex
+	sent  uint64
+	acked uint64
+	// changed is closed and replaced once the window may have room, e.g. the events are acknowledged.
+	changed chan struct{}
+	stopped bool
+}
+
+// newSendWindow returns a window of the size, it is nil if the size is 0, so the events are not flow controlled.
+func newSendWindow(size uint32) *sendWindow {
+	if size == 0 {
+		return nil
+	}
+
+	return &sendWindow{size: uint64(size), changed: make(chan struct{})}
+}
+
+// acquire waits until the window has room, then takes the count of the events from it. A batch of the events is sent
+// once the window has room, so it may take the window further than its size.
+func (w *sendWindow) acquire(ctx context.Context, count int) error {
+	for {
+		w.mu.Lock()
+		if w.stopped {
+			w.mu.Unlock()
+			return errStreamClosed
+		}
+		if w.sent-w.acked < w.size {
+			w.sent += uint64(count)
+			w.mu.Unlock()
+			return nil
+		}
+		changed := w.changed
+		w.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release returns the count of the events that are not sent to the window, so the subscriber is not waited for them.
+func (w *sendWindow) release(count int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.sent -= uint64(count)
+	w.notify()
+}
+
+// ack acknowledges the events that are received by the subscriber, received is the total of the received events, an
+// acknowledgement that is older than the last one is ignored.
+func (w *sendWindow) ack(received uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if received > w.sent {
+		return fmt.Errorf("%d events are acknowledged, but only %d events are sent", received, w.sent)
+	}
+
+	if received > w.acked {
+		w.acked = received
+		w.notify()
+	}
+	return nil
+}
+
+// stop stops the window, the sends that are waiting for it are failed, so the subscriber can be unregistered without
+// waiting for its acknowledgements.
+func (w *sendWindow) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stopped = true
+	w.notify()
+}
+
+// notify must be called with the lock held.
+func (w *sendWindow) notify() {
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
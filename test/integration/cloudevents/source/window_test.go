@@ -0,0 +1,180 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+)
+
+func TestSubscribeWithAckWindow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewMemoryStore()
+	for i := 1; i <= 3; i++ {
+		res := NewResource("cluster1", fmt.Sprintf("resource%d", i))
+		res.Source = "test-source"
+		if _, err := store.UpSert(ctx, res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(store, eventBroadcaster))
+
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", AckWindow: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := stream.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := header.Get(SubscriptionIDHeader)
+	if len(ids) != 1 {
+		t.Fatalf("expected the subscription id in the header, but got %v", header)
+	}
+
+	received := make(chan *pbv1.CloudEvent, 10)
+	go func() {
+		for {
+			pbEvt, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			received <- pbEvt
+		}
+	}()
+
+	// the subscriber acknowledges the events slowly, the server sends no more than the window ahead of it
+	total := uint64(0)
+	recv := func(count int) {
+		for i := 0; i < count; i++ {
+			select {
+			case <-received:
+				total++
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected %d events, but got %d", count, i)
+			}
+		}
+
+		select {
+		case pbEvt := <-received:
+			t.Fatalf("expected no event beyond the window, but got %s", pbEvt.Type)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	ack := func() {
+		if _, err := client.Acknowledge(ctx, &pbv1.AcknowledgeRequest{SubscriptionId: ids[0], Received: total}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	broadcast := func(i int) {
+		res := NewResource("cluster2", fmt.Sprintf("resource%d", i))
+		res.Source = "test-source"
+		eventBroadcaster.Broadcast(res)
+	}
+
+	// the 3 resources of the snapshot and the snapshot done event
+	recv(2)
+	ack()
+	recv(2)
+
+	// the live events wait for the window too
+	for i := 1; i <= 3; i++ {
+		broadcast(i)
+	}
+	recv(0)
+	ack()
+	recv(2)
+	ack()
+	recv(1)
+
+	// the subscriber is unsubscribed without the acknowledgements of the events that wait for the window
+	for i := 4; i <= 5; i++ {
+		broadcast(i)
+	}
+	recv(1)
+	unsubscribed := make(chan error, 1)
+	go func() {
+		_, err := client.Unsubscribe(ctx, &pbv1.UnsubscribeRequest{SubscriptionId: ids[0]})
+		unsubscribed <- err
+	}()
+	select {
+	case err := <-unsubscribed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the subscriber is unsubscribed, but it waits for the window")
+	}
+}
+
+func TestAcknowledge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBroadcaster := NewEventBroadcaster()
+	go eventBroadcaster.Start(ctx)
+	client, _ := startTestServer(t, NewGRPCServer(NewMemoryStore(), eventBroadcaster))
+
+	subscribe := func(window uint32) string {
+		stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{Source: "test-source", AckWindow: window})
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, err := stream.Header()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the snapshot done event
+		if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+		return header.Get(SubscriptionIDHeader)[0]
+	}
+
+	cases := []struct {
+		name         string
+		req          *pbv1.AcknowledgeRequest
+		expectedCode codes.Code
+	}{
+		{
+			name:         "acknowledge the received events",
+			req:          &pbv1.AcknowledgeRequest{SubscriptionId: subscribe(2), Received: 1},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "acknowledge the events that are not sent",
+			req:          &pbv1.AcknowledgeRequest{SubscriptionId: subscribe(2), Received: 2},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "subscription without window",
+			req:          &pbv1.AcknowledgeRequest{SubscriptionId: subscribe(0), Received: 1},
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:         "unknown subscription",
+			req:          &pbv1.AcknowledgeRequest{SubscriptionId: "unknown", Received: 1},
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := client.Acknowledge(ctx, c.req)
+			if status.Code(err) != c.expectedCode {
+				t.Errorf("expected code %s, but got %v", c.expectedCode, err)
+			}
+		})
+	}
+}
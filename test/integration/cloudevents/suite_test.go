@@ -98,7 +98,7 @@ var _ = ginkgo.BeforeSuite(func(done ginkgo.Done) {
 	ginkgo.By("start the resource grpc server")
 	grpcServer = source.NewGRPCServer(store, eventBroadcaster)
 	go func() {
-		err := grpcServer.Start(grpcServerHost)
+		err := grpcServer.Start(ctx, grpcServerHost)
 		gomega.Expect(err).ToNot(gomega.HaveOccurred())
 	}()
 